@@ -1,105 +1,580 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"time"
-
-	"github.com/Saveliy12/prod2/internal/api"
-	"github.com/Saveliy12/prod2/internal/config"
-	"github.com/Saveliy12/prod2/internal/database"
-	logger "github.com/Saveliy12/prod2/internal/logger"
-	"github.com/Saveliy12/prod2/internal/service"
-	"github.com/Saveliy12/prod2/internal/service/tokenmanager"
-
-	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
-
-	_ "github.com/lib/pq"
-)
-
-const (
-	CONFIG_DIR  = "configs"
-	CONFIG_FILE = "main"
-)
-
-func main() {
-
-	logger.InitLogger()
-	log := logger.GetLogger()
-
-	cfg, err := config.New()
-	if err != nil {
-		log.Logger.Fatal(err)
-	}
-
-	log.Debugf("CONFIG: %+v\n", cfg)
-
-	// Инициализация базы данных
-	db := initDB(cfg)
-
-	// database.CreateTables(db) // Создание необходимых таблиц в базе данных
-
-	// Инициализация репозиториев
-	userRepository := database.NewUserRepository(db)
-
-	// Инициализация менеджера работы с токенами
-	tokenManager, _ := tokenmanager.NewManager("your-signing-key") // подгружать из окружения хз
-
-	// Инициализация сервисов
-	// вынести в константы ttl
-	authService := service.NewAuthService(tokenManager, userRepository, time.Hour*1, time.Hour*24*30)
-
-	authHandler := api.NewAuthHandler(authService)
-
-	// Инициализация роутеров
-	r := gin.Default()
-
-	// Эндпоинты для аутентификации и регистрации
-	r.POST("/register", authHandler.RegisterUserHandler)
-	r.POST("/login", authHandler.LoginUserHandler)
-	r.POST("/refresh", authHandler.RefreshTokenHandler)
-	// r.POST("/logout", authHandler.LogoutHandler)
-
-	// Защищенные маршруты
-	protected := r.Group("/protected")
-	protected.Use(api.AuthMiddleware(tokenManager))
-	protected.GET("/profile", authHandler.ProtectedProfileHandler)
-
-	// Запускаем сервер на порту :8080
-	if err := r.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
-		log.Logger.Fatal("Error starting server: ", err)
-	}
-
-}
-
-// initRoutes инициализирует все маршруты
-func initRouter(authHandler api.AuthHandlerInterface) *gin.Engine {
-	// Создаем новый экземпляр Gin
-	r := gin.Default()
-
-	// Инициализируем маршруты
-	r.GET("/api/ping", api.PingHandler)
-	r.POST("/api/register", authHandler.RegisterUserHandler)
-	r.POST("/api/login", authHandler.LoginUserHandler)
-	r.GET("/verify", authHandler.VerifyTokenHandler)
-
-	return r
-}
-
-func initDB(cfg *config.Config) *sqlx.DB {
-
-	// Подключение к базе данных
-	info := fmt.Sprintf("user=%v dbname=%v sslmode=%v password=%v", cfg.DB.User, cfg.DB.Name, cfg.DB.SSLMode, cfg.DB.Password)
-	db, err := sqlx.Connect("postgres", info)
-	if err != nil {
-		log.Logger.Fatal("Error connecting to the database: ", err)
-	}
-
-	err = db.Ping()
-	if err != nil {
-		log.Logger.Fatal("Error pinging the database: ", err)
-	}
-
-	return db
-}
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/api"
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/locales"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/config"
+	"github.com/Saveliy12/prod2/pkg/hash"
+	logger "github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/Saveliy12/prod2/pkg/mailer"
+	"github.com/Saveliy12/prod2/pkg/oidc"
+	"github.com/Saveliy12/prod2/pkg/sms"
+	"github.com/Saveliy12/prod2/pkg/storage"
+	"github.com/Saveliy12/prod2/pkg/tokenmanager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	_ "github.com/lib/pq"
+)
+
+const (
+	CONFIG_DIR  = "configs"
+	CONFIG_FILE = "main"
+
+	accessTokenTTL  = time.Hour * 1
+	refreshTokenTTL = time.Hour * 24 * 30
+
+	// keySyncInterval - как часто экземпляр загружает ключи подписи из базы данных
+	keySyncInterval = time.Minute
+)
+
+func main() {
+
+	logger.InitLogger()
+	log := logger.GetLogger()
+
+	cfg, err := config.New()
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+
+	log.Debugf("CONFIG: %+v\n", cfg)
+
+	// Номера телефонов без кода страны разбираются по правилам этой страны
+	if err := utils.SetDefaultPhoneRegion(cfg.Phone.DefaultRegion); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if err := initPolicy(cfg); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Язык сообщений для клиентов, чей язык не поддерживается
+	if err := locales.Bundle.SetFallback(cfg.Locale.Default); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Инициализация базы данных
+	db := initDB(cfg)
+
+	// database.CreateTables(db) // Создание необходимых таблиц в базе данных
+
+	// Инициализация репозиториев
+	userRepository := database.NewUserRepository(db)
+	oneTimeTokenRepository := database.NewOneTimeTokenRepository(db)
+	mfaRepository := database.NewMFARepository(db)
+	roleRepository := database.NewRoleRepository(db)
+	patRepository := database.NewPersonalAccessTokenRepository(db)
+	oauthRepository := database.NewOAuthRepository(db)
+	identityRepository := database.NewExternalIdentityRepository(db)
+	phoneRepository := database.NewPhoneVerificationRepository(db)
+	auditRepository := database.NewAuditRepository(db)
+	profileRepository := database.NewProfileRepository(db)
+	mediaObjectRepository := database.NewMediaObjectRepository(db)
+	privacyRepository := database.NewPrivacyRepository(db)
+	friendRepository := database.NewFriendRepository(db)
+	blockRepository := database.NewBlockRepository(db)
+	postRepository := database.NewPostRepository(db)
+	reactionRepository := database.NewReactionRepository(db)
+
+	// Инициализация менеджера работы с токенами
+	tokenManager, err := initTokenManager(cfg, db)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Хранилище отозванных access-токенов общее для всех экземпляров сервиса.
+	// Для запуска в одном экземпляре можно использовать tokenmanager.NewMemoryRevocationStore
+	revocationStore := database.NewTokenRevocationRepository(db)
+
+	// Ограничение неудачных попыток входа
+	loginThrottler, err := initLoginThrottler(cfg, db)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Хеширование паролей
+	hasher, err := initHasher(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Инициализация сервисов
+	auditService := service.NewAuditService(auditRepository)
+	roleService := service.NewRoleService(roleRepository, userRepository, revocationStore)
+	authService := service.NewAuthService(tokenManager, revocationStore, userRepository, oauthRepository, roleService,
+		auditService, loginThrottler, hasher, accessTokenTTL, refreshTokenTTL)
+
+	mail, err := initMailer(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	verificationService := service.NewEmailVerificationService(userRepository, oneTimeTokenRepository, mail, cfg.Mail.VerifyURL)
+	passwordResetService := service.NewPasswordResetService(authService, userRepository, oneTimeTokenRepository,
+		hasher, mail, cfg.Mail.ResetURL)
+	magicLinkService := service.NewMagicLinkService(userRepository, oneTimeTokenRepository, mail, cfg.Mail.MagicLinkURL)
+
+	smsSender, err := initSMSSender(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	phoneService := service.NewPhoneVerificationService(userRepository, phoneRepository, smsSender)
+
+	mfaService := service.NewMFAService(mfaRepository, userRepository, oneTimeTokenRepository, loginThrottler)
+	patService := service.NewPersonalAccessTokenService(patRepository)
+	oauthService := service.NewOAuthService(oauthRepository, tokenManager, revocationStore, accessTokenTTL)
+	oidcService := service.NewOIDCService(initOIDCProviders(cfg), identityRepository, userRepository)
+	localeService := service.NewLocaleService(userRepository)
+
+	fileStorage, urlSigner, err := initStorage(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	mediaService := service.NewMediaService(profileRepository, mediaObjectRepository, fileStorage, urlSigner, cfg.Media.URLTTL)
+	privacyService := service.NewPrivacyService(privacyRepository, friendRepository, blockRepository)
+	profileService := service.NewProfileService(profileRepository, privacyService, mediaService)
+	friendService := service.NewFriendService(userRepository, friendRepository, privacyService, mediaService)
+	blockService := service.NewBlockService(userRepository, blockRepository, mediaService)
+	postService := service.NewPostService(postRepository, reactionRepository, userRepository, privacyService, mediaService)
+
+	authHandler := api.NewAuthHandler(authService, verificationService, mfaService, auditService)
+	mfaHandler := api.NewMFAHandler(mfaService, authService, auditService)
+	verificationHandler := api.NewEmailVerificationHandler(verificationService)
+	phoneHandler := api.NewPhoneVerificationHandler(phoneService)
+	passwordHandler := api.NewPasswordHandler(passwordResetService, auditService)
+	magicLinkHandler := api.NewMagicLinkHandler(magicLinkService, authService, mfaService)
+	adminHandler := api.NewAdminHandler(roleService, auditService)
+	auditHandler := api.NewAuditHandler(auditService)
+	patHandler := api.NewPersonalAccessTokenHandler(patService)
+	oauthHandler := api.NewOAuthHandler(oauthService)
+	oidcHandler := api.NewOIDCHandler(oidcService, authService, mfaService, verificationService)
+	localeHandler := api.NewLocaleHandler(localeService)
+	profileHandler := api.NewProfileHandler(profileService)
+	mediaHandler := api.NewMediaHandler(mediaService, cfg.Media.MaxUploadSize)
+	privacyHandler := api.NewPrivacyHandler(privacyService)
+	friendHandler := api.NewFriendHandler(friendService)
+	blockHandler := api.NewBlockHandler(blockService)
+	postHandler := api.NewPostHandler(postService)
+	authMiddleware := api.NewAuthMiddleware(tokenManager, revocationStore, patService)
+
+	// Инициализация роутеров
+	r := gin.Default()
+
+	// Сообщения об ошибках переводятся на язык пользователя или клиента
+	r.Use(api.LocaleMiddleware(localeService))
+
+	// Эндпоинты для аутентификации и регистрации
+	r.POST("/register", authHandler.RegisterUserHandler)
+	r.POST("/login", authHandler.LoginUserHandler)
+	r.POST("/login/mfa", mfaHandler.LoginMFAHandler)
+	r.POST("/login/magic-link", magicLinkHandler.RequestLinkHandler)
+	r.POST("/login/magic-link/verify", magicLinkHandler.VerifyLinkHandler)
+	r.POST("/refresh", authHandler.RefreshTokenHandler)
+	r.POST("/logout", authHandler.LogoutHandler)
+	r.POST("/verify-email", verificationHandler.ConfirmEmailHandler)
+	r.POST("/password/forgot", passwordHandler.ForgotPasswordHandler)
+	r.POST("/password/reset", passwordHandler.ResetPasswordHandler)
+
+	// Вход через внешних провайдеров OpenID Connect
+	r.GET("/oidc/providers", oidcHandler.GetProvidersHandler)
+	r.GET("/oidc/:provider/start", oidcHandler.StartLoginHandler)
+	r.POST("/oidc/:provider/callback", api.OptionalAuth(authMiddleware.JWTAuthMiddleware()), oidcHandler.CallbackHandler)
+	r.POST("/oidc/signup", oidcHandler.SignupHandler)
+
+	// Файлы локального хранилища по подписанным ссылкам
+	r.GET("/media/*key", mediaHandler.ServeFileHandler)
+
+	// Открытые ключи для проверки токенов другими сервисами
+	r.GET("/.well-known/jwks.json", api.JWKSHandler(tokenManager))
+
+	// OAuth 2.1: эндпоинты для сторонних приложений, клиент аутентифицируется в самом запросе
+	r.POST("/oauth/token", oauthHandler.TokenHandler)
+	r.POST("/oauth/introspect", oauthHandler.IntrospectHandler)
+	r.POST("/oauth/revoke", oauthHandler.RevokeHandler)
+
+	// Защищенные маршруты
+	protected := r.Group("/protected")
+	protected.Use(authMiddleware.JWTAuthMiddleware())
+	protected.GET("/profile", profileHandler.GetMyProfileHandler)
+
+	// Управление сессиями
+	protected.POST("/logout/all", authHandler.LogoutAllHandler)
+	protected.POST("/token/revoke", authHandler.RevokeAccessTokenHandler)
+	protected.GET("/sessions", authHandler.GetSessionsHandler)
+	protected.DELETE("/sessions/:id", authHandler.RevokeSessionHandler)
+	protected.GET("/security/events", auditHandler.GetMyEventsHandler)
+
+	// Язык сообщений
+	protected.GET("/locale", localeHandler.GetLocaleHandler)
+	protected.PUT("/locale", localeHandler.SetLocaleHandler)
+
+	// Подтверждение почты
+	protected.POST("/verify-email/resend", verificationHandler.ResendVerificationHandler)
+
+	// Подтверждение номера телефона
+	protected.GET("/phone", phoneHandler.GetPhoneStatusHandler)
+	protected.POST("/phone/verify/send", phoneHandler.SendCodeHandler)
+	protected.POST("/phone/verify/confirm", phoneHandler.ConfirmCodeHandler)
+
+	// Двухфакторная аутентификация
+	protected.GET("/mfa", mfaHandler.GetMFAStatusHandler)
+	protected.POST("/mfa/totp/setup", mfaHandler.SetupTOTPHandler)
+	protected.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTPHandler)
+	protected.POST("/mfa/disable", mfaHandler.DisableMFAHandler)
+	protected.POST("/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodesHandler)
+
+	// Привязка аккаунтов внешних провайдеров
+	protected.GET("/oidc/:provider/link", oidcHandler.StartLinkHandler)
+	protected.GET("/identities", oidcHandler.GetIdentitiesHandler)
+	protected.DELETE("/identities/:id", oidcHandler.UnlinkIdentityHandler)
+
+	// Персональные токены. Управлять ими можно только из сессии, не самими персональными токенами.
+	protected.POST("/tokens", patHandler.CreateTokenHandler)
+	protected.GET("/tokens", patHandler.GetTokensHandler)
+	protected.DELETE("/tokens/:id", patHandler.RevokeTokenHandler)
+
+	// OAuth 2.1: регистрация приложений и согласие пользователя
+	protected.POST("/oauth/clients", oauthHandler.RegisterClientHandler)
+	protected.GET("/oauth/clients", oauthHandler.GetClientsHandler)
+	protected.DELETE("/oauth/clients/:clientId", oauthHandler.DeleteClientHandler)
+	protected.GET("/oauth/authorize", oauthHandler.AuthorizePromptHandler)
+	protected.POST("/oauth/authorize", oauthHandler.AuthorizeHandler)
+	protected.GET("/oauth/consents", oauthHandler.GetConsentsHandler)
+	protected.DELETE("/oauth/consents/:clientId", oauthHandler.RevokeConsentHandler)
+
+	// Публичные профили доступны без входа, с токеном - с учетом дружбы и блокировок
+	users := r.Group("/users")
+	users.Use(api.OptionalAuth(authMiddleware.TokenAuthMiddleware(models.ScopeRead)))
+	users.GET("/:login", profileHandler.GetUserProfileHandler)
+	users.GET("/:login/friends", friendHandler.GetUserFriendsHandler)
+	users.GET("/:login/posts", postHandler.GetUserPostsHandler)
+
+	// Посты и реакции на них доступны без входа так же, как профили
+	posts := r.Group("/posts")
+	posts.Use(api.OptionalAuth(authMiddleware.TokenAuthMiddleware(models.ScopeRead)))
+	posts.GET("/:postId", postHandler.GetPostHandler)
+	posts.GET("/:postId/reactions", postHandler.GetReactionsHandler)
+
+	// Свой профиль. Читать его можно и персональным токеном или токеном приложения с областью read,
+	// изменять - только из сессии.
+	profiles := r.Group("/")
+	profiles.Use(authMiddleware.TokenAuthMiddleware(models.ScopeRead))
+	profiles.GET("/me", profileHandler.GetMyProfileHandler)
+	profiles.GET("/me/preview", profileHandler.PreviewMyProfileHandler)
+	profiles.GET("/me/privacy", privacyHandler.GetPrivacyHandler)
+	profiles.GET("/me/blocks", blockHandler.GetBlockedHandler)
+	profiles.GET("/me/mutes", blockHandler.GetMutedHandler)
+	profiles.GET("/me/feed", postHandler.GetFeedHandler)
+
+	account := r.Group("/me")
+	account.Use(authMiddleware.JWTAuthMiddleware())
+	account.PATCH("", profileHandler.UpdateMyProfileHandler)
+	account.PATCH("/privacy", privacyHandler.UpdatePrivacyHandler)
+
+	// Друзья, блокировки и заглушения
+	account.PUT("/friends/:login", friendHandler.AddFriendHandler)
+	account.DELETE("/friends/:login", friendHandler.RemoveFriendHandler)
+	account.PUT("/blocks/:login", blockHandler.BlockUserHandler)
+	account.DELETE("/blocks/:login", blockHandler.UnblockUserHandler)
+	account.PUT("/mutes/:login", blockHandler.MuteUserHandler)
+	account.DELETE("/mutes/:login", blockHandler.UnmuteUserHandler)
+	account.PUT("/avatar", mediaHandler.UploadAvatarHandler)
+	account.DELETE("/avatar", mediaHandler.DeleteAvatarHandler)
+	account.PUT("/cover", mediaHandler.UploadCoverHandler)
+	account.DELETE("/cover", mediaHandler.DeleteCoverHandler)
+
+	// Публиковать посты и ставить реакции могут только пользователи с подтвержденной почтой.
+	// Кроме сессии принимается персональный токен или токен приложения с областью posts:write.
+	posting := r.Group("/protected/posts")
+	posting.Use(authMiddleware.TokenAuthMiddleware(models.ScopePostsWrite), api.RequireVerifiedEmail(verificationService))
+	posting.POST("", postHandler.CreatePostHandler)
+	posting.PUT("/:postId/like", postHandler.LikePostHandler)
+	posting.PUT("/:postId/dislike", postHandler.DislikePostHandler)
+	posting.DELETE("/:postId/reaction", postHandler.RemoveReactionHandler)
+
+	// Административные маршруты доступны только с включенной двухфакторной аутентификацией.
+	// Первый администратор назначается в базе данных: UPDATE users SET role = 'admin' WHERE login = '...'
+	admin := protected.Group("/admin")
+	admin.Use(api.RequireMFA(mfaService))
+	admin.POST("/login/unlock", api.RequirePermission(models.PermissionUsersUnlock), authHandler.UnlockLoginHandler)
+	admin.GET("/users/:id/access", api.RequirePermission(models.PermissionUsersRead), adminHandler.GetUserAccessHandler)
+	admin.GET("/audit", api.RequirePermission(models.PermissionAuditRead), auditHandler.GetEventsHandler)
+	admin.GET("/audit/export", api.RequirePermission(models.PermissionAuditRead), auditHandler.ExportEventsHandler)
+
+	roles := admin.Group("/users/:id")
+	roles.Use(api.RequirePermission(models.PermissionRolesManage))
+	roles.PUT("/role", adminHandler.SetRoleHandler)
+	roles.POST("/permissions", adminHandler.GrantPermissionHandler)
+	roles.DELETE("/permissions/:permission", adminHandler.RevokePermissionHandler)
+
+	// Запускаем сервер на порту :8080
+	if err := r.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
+		log.Logger.Fatal("Error starting server: ", err)
+	}
+
+}
+
+// initRoutes инициализирует все маршруты
+func initRouter(authHandler api.AuthHandlerInterface) *gin.Engine {
+	// Создаем новый экземпляр Gin
+	r := gin.Default()
+
+	// Инициализируем маршруты
+	r.GET("/api/ping", api.PingHandler)
+	r.POST("/api/register", authHandler.RegisterUserHandler)
+	r.POST("/api/login", authHandler.LoginUserHandler)
+	r.GET("/verify", authHandler.VerifyTokenHandler)
+
+	return r
+}
+
+// initTokenManager создает менеджер токенов по настройкам JWT.
+// Ключ из JWT_PRIVATE_KEY_FILE без ротации используется как есть. В остальных случаях асимметричные ключи
+// хранятся в базе данных, чтобы все экземпляры подписывали токены одним ключом и ротировали его вместе,
+// а ключ из файла становится первым ключом, если в базе их еще нет.
+func initTokenManager(cfg *config.Config, db *sqlx.DB) (*tokenmanager.Manager, error) {
+	if cfg.JWT.Algorithm == tokenmanager.AlgorithmHS256 {
+		return tokenmanager.NewManager(cfg.JWT.SigningKey)
+	}
+
+	var seed *tokenmanager.SigningKey
+	if cfg.JWT.PrivateKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWT.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+		}
+		seed, err = tokenmanager.ParseSigningKeyPEM("primary", data)
+		if err != nil {
+			return nil, err
+		}
+		if seed.Algorithm != cfg.JWT.Algorithm {
+			return nil, fmt.Errorf("JWT private key is not a %s key", cfg.JWT.Algorithm)
+		}
+
+		if cfg.JWT.RotationPeriod == 0 {
+			return tokenmanager.NewManagerWithKeyRing(tokenmanager.NewKeyRing(seed, accessTokenTTL)), nil
+		}
+	}
+
+	// Следующий ключ появляется в JWKS раньше, чем его успеют запросить сервисы с закешированным JWKS
+	rotation := tokenmanager.KeyRotation{
+		Algorithm:    cfg.JWT.Algorithm,
+		Period:       cfg.JWT.RotationPeriod,
+		PublishAhead: api.JWKSMaxAge + keySyncInterval,
+	}
+
+	// Выведенный из оборота ключ проверяет токены, пока не истекут все подписанные им
+	keys, err := tokenmanager.NewStoredKeyRing(database.NewSigningKeyRepository(db), rotation, accessTokenTTL, seed)
+	if err != nil {
+		return nil, err
+	}
+	keys.StartRotation(keySyncInterval, func(err error) {
+		logger.GetLogger().Error("JWT key rotation failed: " + err.Error())
+	})
+
+	return tokenmanager.NewManagerWithKeyRing(keys), nil
+}
+
+// initHasher создает хешер паролей. Новые хеши создаются выбранным алгоритмом,
+// хеши другого алгоритма проверяются и пересчитываются при входе пользователя.
+func initHasher(cfg *config.Config) (*hash.Hasher, error) {
+	var pepper *hash.Pepper
+	if cfg.Password.Pepper != "" {
+		pepper = &hash.Pepper{ID: cfg.Password.PepperID, Secret: []byte(cfg.Password.Pepper)}
+	}
+
+	argon2Params := hash.DefaultArgon2Params
+	if cfg.Password.Argon2Memory > 0 {
+		argon2Params.Memory = cfg.Password.Argon2Memory
+	}
+	if cfg.Password.Argon2Iterations > 0 {
+		argon2Params.Iterations = cfg.Password.Argon2Iterations
+	}
+	if cfg.Password.Argon2Parallelism > 0 {
+		argon2Params.Parallelism = cfg.Password.Argon2Parallelism
+	}
+
+	argon2idHasher := hash.NewArgon2idHasher(argon2Params, pepper)
+	bcryptHasher := hash.NewBcryptHasher(cfg.Password.BcryptCost, pepper)
+
+	switch cfg.Password.Algorithm {
+	case "argon2id":
+		return hash.NewHasher(argon2idHasher, bcryptHasher), nil
+	case "bcrypt":
+		return hash.NewHasher(bcryptHasher, argon2idHasher), nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM: %s", cfg.Password.Algorithm)
+	}
+}
+
+// initPolicy задает требования к логину, почте и паролю. Не указанные в настройках значения берутся по умолчанию.
+func initPolicy(cfg *config.Config) error {
+	policy := utils.DefaultPolicy()
+	if cfg.Policy.LoginMaxLength > 0 {
+		policy.LoginMaxLength = cfg.Policy.LoginMaxLength
+	}
+	if cfg.Policy.EmailMaxLength > 0 {
+		policy.EmailMaxLength = cfg.Policy.EmailMaxLength
+	}
+	if cfg.Policy.PasswordMinLength > 0 {
+		policy.PasswordMinLength = cfg.Policy.PasswordMinLength
+	}
+	if cfg.Policy.PasswordMaxLength > 0 {
+		policy.PasswordMaxLength = cfg.Policy.PasswordMaxLength
+	}
+	if cfg.Policy.MinStrength >= 0 {
+		policy.MinStrength = cfg.Policy.MinStrength
+	}
+	policy.RequireLower = cfg.Policy.RequireLower
+	policy.RequireUpper = cfg.Policy.RequireUpper
+	policy.RequireDigit = cfg.Policy.RequireDigit
+	policy.RequireSpecial = cfg.Policy.RequireSpecial
+
+	if cfg.Policy.BlocklistFile != "" {
+		blocklist, err := utils.LoadBlocklist(cfg.Policy.BlocklistFile)
+		if err != nil {
+			return err
+		}
+		policy.Blocklist = blocklist
+	}
+
+	return utils.SetPolicy(policy)
+}
+
+// initLoginThrottler создает ограничитель попыток входа. Счетчики в памяти
+// подходят только для одного экземпляра, при нескольких нужен postgres.
+func initLoginThrottler(cfg *config.Config, db *sqlx.DB) (*service.LoginThrottler, error) {
+	throttleConfig := service.DefaultLoginThrottleConfig
+	if cfg.Login.LockThreshold > 0 {
+		throttleConfig.LockThreshold = cfg.Login.LockThreshold
+	}
+	if cfg.Login.LockDuration > 0 {
+		throttleConfig.LockDuration = cfg.Login.LockDuration
+	}
+
+	switch cfg.Login.AttemptsStore {
+	case "memory":
+		return service.NewLoginThrottler(database.NewMemoryLoginAttemptRepository(), throttleConfig), nil
+	case "postgres":
+		return service.NewLoginThrottler(database.NewLoginAttemptRepository(db), throttleConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown LOGIN_ATTEMPTS_STORE: %s", cfg.Login.AttemptsStore)
+	}
+}
+
+// initOIDCProviders создает клиентов внешних провайдеров OpenID Connect.
+// Discovery выполняется при первом входе, поэтому недоступный провайдер не мешает запуску.
+func initOIDCProviders(cfg *config.Config) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDC))
+	for _, p := range cfg.OIDC {
+		providers[p.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+	return providers
+}
+
+// initMailer выбирает способ доставки писем по настройкам
+func initMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUser, cfg.Mail.SMTPPassword, cfg.Mail.From), nil
+	case "file":
+		return mailer.NewFileOutbox(cfg.Mail.OutboxDir, cfg.Mail.From)
+	case "memory":
+		return mailer.NewMemoryOutbox(), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER: %s", cfg.Mail.Driver)
+	}
+}
+
+// initStorage выбирает хранилище загруженных файлов по настройкам. Файлы локального хранилища
+// раздает сам сервис через /media по подписанным ссылкам, для S3 signer не нужен.
+// Без MEDIA_SIGNING_KEY секрет генерируется при старте только в режиме отладки gin: у каждого
+// экземпляра он свой, а после перезапуска все выданные ссылки перестают работать.
+func initStorage(cfg *config.Config) (storage.Storage, *storage.URLSigner, error) {
+	switch cfg.Media.Driver {
+	case "local":
+		secret := []byte(cfg.Media.SigningKey)
+		if len(secret) == 0 {
+			if gin.Mode() != gin.DebugMode {
+				return nil, nil, fmt.Errorf("MEDIA_SIGNING_KEY is required for MEDIA_STORAGE_DRIVER=local")
+			}
+			logger.GetLogger().Warn("MEDIA_SIGNING_KEY is not set, media links are signed with a random key and expire on restart")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, nil, fmt.Errorf("failed to generate media signing key: %w", err)
+			}
+		}
+		signer := storage.NewURLSigner(secret, cfg.Media.BaseURL)
+		fileStorage, err := storage.NewLocalStorage(cfg.Media.StorageDir, signer)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileStorage, signer, nil
+	case "s3":
+		fileStorage, err := storage.NewS3Storage(storage.S3Config{
+			Endpoint:  cfg.Media.S3Endpoint,
+			Region:    cfg.Media.S3Region,
+			Bucket:    cfg.Media.S3Bucket,
+			AccessKey: cfg.Media.S3AccessKey,
+			SecretKey: cfg.Media.S3SecretKey,
+			PathStyle: cfg.Media.S3PathStyle,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileStorage, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown MEDIA_STORAGE_DRIVER: %s", cfg.Media.Driver)
+	}
+}
+
+// initSMSSender выбирает способ доставки SMS по настройкам
+func initSMSSender(cfg *config.Config) (sms.SMSSender, error) {
+	switch cfg.SMS.Driver {
+	case "http":
+		if cfg.SMS.GatewayURL == "" {
+			return nil, fmt.Errorf("SMS_GATEWAY_URL is required for SMS_DRIVER=http")
+		}
+		return sms.NewHTTPSender(cfg.SMS.GatewayURL, cfg.SMS.GatewayToken, cfg.SMS.From), nil
+	case "log":
+		return sms.NewLogSender(logger.GetLogger()), nil
+	case "memory":
+		return sms.NewMemorySender(), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS_DRIVER: %s", cfg.SMS.Driver)
+	}
+}
+
+func initDB(cfg *config.Config) *sqlx.DB {
+
+	// Подключение к базе данных
+	info := fmt.Sprintf("user=%v dbname=%v sslmode=%v password=%v", cfg.DB.User, cfg.DB.Name, cfg.DB.SSLMode, cfg.DB.Password)
+	db, err := sqlx.Connect("postgres", info)
+	if err != nil {
+		log.Logger.Fatal("Error connecting to the database: ", err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		log.Logger.Fatal("Error pinging the database: ", err)
+	}
+
+	return db
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler предоставляет обработчики для управления ролями и правами пользователей
+type AdminHandler struct {
+	roleService  service.RoleServiceInterface
+	auditService service.AuditServiceInterface
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler
+func NewAdminHandler(roleService service.RoleServiceInterface, auditService service.AuditServiceInterface) *AdminHandler {
+	return &AdminHandler{
+		roleService:  roleService,
+		auditService: auditService,
+	}
+}
+
+// GetUserAccessHandler возвращает роль и права пользователя
+func (h *AdminHandler) GetUserAccessHandler(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	subject, err := h.roleService.GetSubject(userID)
+	if errors.Is(err, service.ErrUserNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "admin.get_access_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": subject.Role, "permissions": subject.Permissions})
+}
+
+// SetRoleHandler назначает пользователю роль
+func (h *AdminHandler) SetRoleHandler(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.roleService.SetRole(userID, requestBody.Role)
+	if err == nil {
+		h.recordAccessChange(c, models.AuditRoleChanged, userID, models.AuditDetails{"role": requestBody.Role})
+	}
+	respondRoleChange(c, err)
+}
+
+// GrantPermissionHandler выдает пользователю право сверх прав его роли
+func (h *AdminHandler) GrantPermissionHandler(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Permission string `json:"permission"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.roleService.GrantPermission(userID, requestBody.Permission)
+	if err == nil {
+		h.recordAccessChange(c, models.AuditPermissionGranted, userID, models.AuditDetails{"permission": requestBody.Permission})
+	}
+	respondRoleChange(c, err)
+}
+
+// RevokePermissionHandler отзывает право, выданное пользователю отдельно
+func (h *AdminHandler) RevokePermissionHandler(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	err := h.roleService.RevokePermission(userID, c.Param("permission"))
+	if err == nil {
+		h.recordAccessChange(c, models.AuditPermissionRevoked, userID, models.AuditDetails{"permission": c.Param("permission")})
+	}
+	respondRoleChange(c, err)
+}
+
+// recordAccessChange записывает изменение роли или прав в журнал безопасности
+func (h *AdminHandler) recordAccessChange(c *gin.Context, eventType string, userID uint, details models.AuditDetails) {
+	recordAudit(c, h.auditService, models.AuditEvent{Type: eventType, TargetID: &userID, Details: details})
+}
+
+// respondRoleChange отвечает на изменение роли или прав
+func respondRoleChange(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownRole), errors.Is(err, service.ErrUnknownPermission):
+		respondServiceError(c, http.StatusBadRequest, err)
+	case errors.Is(err, service.ErrUserNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "admin.update_access_failed", nil)
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// userIDParam разбирает идентификатор пользователя из пути. При ошибке ответ уже отправлен.
+func userIDParam(c *gin.Context) (uint, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "admin.invalid_user_id", nil)
+		return 0, false
+	}
+	return uint(userID), true
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler предоставляет обработчики журнала безопасности
+type AuditHandler struct {
+	auditService service.AuditServiceInterface
+	log          logger.LoggerInterface
+}
+
+// NewAuditHandler создает новый экземпляр AuditHandler
+func NewAuditHandler(auditService service.AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		log:          logger.GetLogger(),
+	}
+}
+
+// GetEventsHandler возвращает события журнала по фильтрам из query:
+// type, outcome, ip, actorId, targetId, userId, from, to (RFC 3339), before (id), limit.
+// Предназначен для административных маршрутов.
+func (h *AuditHandler) GetEventsHandler(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.auditService.GetEvents(filter)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "audit.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ExportEventsHandler выгружает события журнала в формате JSON Lines с теми же фильтрами
+func (h *AuditHandler) ExportEventsHandler(c *gin.Context) {
+	filter, ok := auditFilter(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit-events.jsonl"`)
+	c.Status(http.StatusOK)
+
+	// Заголовки уже отправлены, поэтому об ошибке посреди выгрузки можно только записать в лог
+	if err := h.auditService.ExportEvents(filter, c.Writer); err != nil {
+		h.log.Error("Failed to export audit events: " + err.Error())
+	}
+}
+
+// GetMyEventsHandler возвращает последние события безопасности текущего пользователя
+func (h *AuditHandler) GetMyEventsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	events, err := h.auditService.GetUserEvents(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "audit.my_events_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// auditFilter разбирает фильтр журнала из query. При ошибке ответ уже отправлен.
+func auditFilter(c *gin.Context) (models.AuditFilter, bool) {
+	filter := models.AuditFilter{
+		Type:    c.Query("type"),
+		Outcome: c.Query("outcome"),
+		IP:      c.Query("ip"),
+	}
+
+	ids := map[string]**uint{"actorId": &filter.ActorID, "targetId": &filter.TargetID, "userId": &filter.UserID}
+	for name, field := range ids {
+		if value := c.Query(name); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "audit.invalid_filter", map[string]interface{}{"name": name})
+				return filter, false
+			}
+			*field = models.UintPtr(uint(id))
+		}
+	}
+
+	times := map[string]*time.Time{"from": &filter.From, "to": &filter.To}
+	for name, field := range times {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, "audit.invalid_time", map[string]interface{}{"name": name})
+				return filter, false
+			}
+			*field = t
+		}
+	}
+
+	if value := c.Query("before"); value != "" {
+		before, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "audit.invalid_filter", map[string]interface{}{"name": "before"})
+			return filter, false
+		}
+		filter.BeforeID = uint(before)
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			respondError(c, http.StatusBadRequest, "audit.invalid_filter", map[string]interface{}{"name": "limit"})
+			return filter, false
+		}
+		filter.Limit = limit
+	}
+
+	return filter, true
+}
+
+// recordAudit дополняет событие адресом и клиентом из запроса и записывает его.
+// Если исполнитель не указан, им считается текущий пользователь.
+func recordAudit(c *gin.Context, auditService service.AuditServiceInterface, event models.AuditEvent) {
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	if event.ActorID == nil {
+		if userID, ok := currentUserID(c); ok {
+			event.ActorID = &userID
+		}
+	}
+	auditService.Record(event)
+}
@@ -1,107 +1,309 @@
-package api
-
-import (
-	"net/http"
-	"time"
-
-	"github.com/Saveliy12/prod2/internal/models"
-	"github.com/Saveliy12/prod2/internal/service"
-	"github.com/Saveliy12/prod2/pkg/logger"
-	"github.com/gin-gonic/gin"
-)
-
-// AuthHandlerInterface определяет методы для работы с аутентификацией
-type AuthHandlerInterface interface {
-	RegisterUserHandler(c *gin.Context)
-	SignInUserHandler(c *gin.Context)
-}
-
-// AuthHandler предоставляет обработчики для аутентификации
-type AuthHandler struct {
-	authService service.AuthService
-	log         logger.LoggerInterface
-}
-
-// NewAuthHandler создает новый экземпляр AuthHandler
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
-	return &AuthHandler{
-		authService: authService,
-		log:         logger.GetLogger(),
-	}
-}
-
-// RegisterUserHandler обрабатывает запрос на регистрацию нового пользователя
-func (a *AuthHandler) RegisterUserHandler(c *gin.Context) {
-	var newUser models.RegistrationUser
-	if err := c.ShouldBindJSON(&newUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Проверка, что все необходимые данные получены
-	if newUser.Login == "" || newUser.Email == "" || newUser.Phone == "" || newUser.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required fields"})
-		return
-	}
-
-	// Устанавливаем текущее время в поле CreatedAt
-	newUser.CreatedAt = time.Now()
-
-	// Передаем нового пользователя в сервис для создания
-	createdUser, err := a.authService.RegisterUser(newUser)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to register user: " + err.Error()})
-		return
-	}
-
-	// Возвращаем успешный ответ с созданным пользователем
-	c.JSON(http.StatusCreated, createdUser)
-}
-
-func (a *AuthHandler) LoginUserHandler(c *gin.Context) {
-	var credentials models.LoginUser
-	if err := c.ShouldBindJSON(&credentials); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	userID, err := a.authService.AuthenticateUser(credentials)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid login or password"})
-		return
-	}
-
-	tokens, err := a.authService.AuthorizeUser(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to  user: " + err.Error()})
-		return
-	}
-
-	tokenResponse := struct {
-		AccessToken  string `json:"accessToken"`
-		RefreshToken string `json:"refreshToken"`
-	}{
-		AccessToken:  tokens.AccessToken,
-		RefreshToken: tokens.RefreshToken,
-	}
-
-	c.JSON(http.StatusOK, tokenResponse)
-}
-
-func (a *AuthHandler) RefreshTokenHandler(c *gin.Context) {
-	var requestBody struct {
-		RefreshToken string `json:"refreshToken"`
-	}
-	if err := c.ShouldBindJSON(&requestBody); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	newAccessToken, err := a.authService.NewRefreshToken(requestBody.RefreshToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, err.Error())
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"accessToken": newAccessToken})
-}
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandlerInterface определяет методы для работы с аутентификацией
+type AuthHandlerInterface interface {
+	RegisterUserHandler(c *gin.Context)
+	SignInUserHandler(c *gin.Context)
+}
+
+// AuthHandler предоставляет обработчики для аутентификации
+type AuthHandler struct {
+	authService         service.AuthServiceInterface
+	verificationService service.EmailVerificationServiceInterface
+	mfaService          service.MFAServiceInterface
+	auditService        service.AuditServiceInterface
+	log                 logger.LoggerInterface
+}
+
+// NewAuthHandler создает новый экземпляр AuthHandler
+func NewAuthHandler(authService service.AuthServiceInterface, verificationService service.EmailVerificationServiceInterface,
+	mfaService service.MFAServiceInterface, auditService service.AuditServiceInterface) *AuthHandler {
+	return &AuthHandler{
+		authService:         authService,
+		verificationService: verificationService,
+		mfaService:          mfaService,
+		auditService:        auditService,
+		log:                 logger.GetLogger(),
+	}
+}
+
+// RegisterUserHandler обрабатывает запрос на регистрацию нового пользователя
+func (a *AuthHandler) RegisterUserHandler(c *gin.Context) {
+	var newUser models.RegistrationUser
+	if err := c.ShouldBindJSON(&newUser); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// Устанавливаем текущее время в поле CreatedAt
+	newUser.CreatedAt = time.Now()
+
+	// Передаем нового пользователя в сервис для создания
+	// Обязательные поля и формат проверяются в сервисе, ошибки всех полей возвращаются сразу
+	createdUser, err := a.authService.RegisterUser(newUser)
+	if err != nil {
+		if !respondValidationError(c, err) {
+			respondError(c, http.StatusInternalServerError, "auth.register_failed", nil)
+		}
+		return
+	}
+
+	// Аккаунт создан, письмо можно запросить повторно, поэтому ошибка отправки не прерывает регистрацию
+	if err := a.verificationService.SendVerificationEmail(createdUser); err != nil {
+		a.log.Error("Failed to send verification email: " + err.Error())
+	}
+
+	// Возвращаем успешный ответ с созданным пользователем
+	c.JSON(http.StatusCreated, createdUser)
+}
+
+// tokenResponse - ответ с парой токенов после входа или обновления
+type tokenResponse struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	DeviceID     string `json:"deviceId"`
+}
+
+func (a *AuthHandler) LoginUserHandler(c *gin.Context) {
+	var credentials models.LoginUser
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	credentials.IP = c.ClientIP()
+	credentials.UserAgent = c.Request.UserAgent()
+
+	userID, err := a.authService.AuthenticateUser(credentials)
+	var (
+		retryErr  *service.RetryAfterError
+		lockedErr *service.AccountLockedError
+	)
+	switch {
+	case errors.As(err, &lockedErr):
+		respondRetryAfter(c, http.StatusLocked, lockedErr.RetryAfter, lockedErr)
+		return
+	case errors.As(err, &retryErr):
+		respondRetryAfter(c, http.StatusTooManyRequests, retryErr.RetryAfter, retryErr)
+		return
+	case errors.Is(err, service.ErrInvalidCredentials):
+		respondError(c, http.StatusUnauthorized, "auth.invalid_credentials", nil)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "auth.authenticate_failed", nil)
+		return
+	}
+
+	device := deviceInfo(c)
+	device.DeviceID = credentials.DeviceID
+	device.DeviceName = credentials.DeviceName
+
+	respondLogin(c, a.authService, a.mfaService, userID, device)
+}
+
+// respondLogin выдает токены пользователю, личность которого уже проверена.
+// При включенной двухфакторной аутентификации токены выдаются только после второго шага.
+func respondLogin(c *gin.Context, authService service.AuthServiceInterface, mfaService service.MFAServiceInterface,
+	userID uint, device models.DeviceInfo) {
+	mfaEnabled, err := mfaService.IsEnabled(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "mfa.check_failed", nil)
+		return
+	}
+	if mfaEnabled {
+		mfaToken, err := mfaService.BeginLogin(userID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "mfa.start_failed", nil)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"mfaRequired": true, "mfaToken": mfaToken})
+		return
+	}
+
+	tokens, err := authService.AuthorizeUser(userID, device, false)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "auth.authorize_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		DeviceID:     tokens.DeviceID,
+	})
+}
+
+// RefreshTokenHandler обменивает refresh-токен на новую пару токенов
+func (a *AuthHandler) RefreshTokenHandler(c *gin.Context) {
+	var requestBody struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	tokens, err := a.authService.RefreshTokens(requestBody.RefreshToken, deviceInfo(c))
+	if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+		respondServiceError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.refresh_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		DeviceID:     tokens.DeviceID,
+	})
+}
+
+// LogoutHandler завершает текущую сессию по refresh-токену
+func (a *AuthHandler) LogoutHandler(c *gin.Context) {
+	var requestBody struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := a.authService.Logout(requestBody.RefreshToken)
+	if errors.Is(err, service.ErrInvalidRefreshToken) {
+		respondServiceError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.logout_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAccessTokenHandler отзывает access-токен, с которым выполнен запрос
+func (a *AuthHandler) RevokeAccessTokenHandler(c *gin.Context) {
+	claims, ok := currentTokenClaims(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	if err := a.authService.RevokeAccessToken(claims); err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.revoke_token_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAllHandler завершает все сессии текущего пользователя
+func (a *AuthHandler) LogoutAllHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	if err := a.authService.LogoutAll(userID); err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.logout_failed", nil)
+		return
+	}
+	recordAudit(c, a.auditService, models.AuditEvent{Type: models.AuditLogoutAll, TargetID: &userID})
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetSessionsHandler возвращает активные сессии текущего пользователя
+func (a *AuthHandler) GetSessionsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	sessions, err := a.authService.GetActiveSessions(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.sessions_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessionHandler завершает одну сессию текущего пользователя
+func (a *AuthHandler) RevokeSessionHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "auth.invalid_session_id", nil)
+		return
+	}
+
+	err = a.authService.RevokeSession(userID, uint(sessionID))
+	if errors.Is(err, service.ErrSessionNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.revoke_session_failed", nil)
+		return
+	}
+	recordAudit(c, a.auditService, models.AuditEvent{
+		Type:     models.AuditSessionRevoked,
+		TargetID: &userID,
+		Details:  models.AuditDetails{"sessionId": c.Param("id")},
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UnlockLoginHandler снимает блокировку входа с логина. Предназначен для административных маршрутов.
+func (a *AuthHandler) UnlockLoginHandler(c *gin.Context) {
+	var requestBody struct {
+		Login string `json:"login"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := a.authService.UnlockLogin(requestBody.Login); err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.unlock_failed", nil)
+		return
+	}
+	recordAudit(c, a.auditService, models.AuditEvent{
+		Type:    models.AuditLoginUnlocked,
+		Details: models.AuditDetails{"login": requestBody.Login},
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// deviceInfo собирает сведения о клиенте из запроса
+func deviceInfo(c *gin.Context) models.DeviceInfo {
+	return models.DeviceInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Размер страницы списков заблокированных и заглушенных
+const (
+	defaultBlocksLimit = 50
+	maxBlocksLimit     = 100
+)
+
+// BlockHandler предоставляет обработчики для блокировки и заглушения пользователей
+type BlockHandler struct {
+	blockService service.BlockServiceInterface
+	log          logger.LoggerInterface
+}
+
+// NewBlockHandler создает новый экземпляр BlockHandler
+func NewBlockHandler(blockService service.BlockServiceInterface) *BlockHandler {
+	return &BlockHandler{
+		blockService: blockService,
+		log:          logger.GetLogger(),
+	}
+}
+
+// BlockUserHandler блокирует пользователя по логину
+func (h *BlockHandler) BlockUserHandler(c *gin.Context) {
+	h.changeRelation(c, h.blockService.Block, "block.block_failed")
+}
+
+// UnblockUserHandler снимает блокировку с пользователя по логину
+func (h *BlockHandler) UnblockUserHandler(c *gin.Context) {
+	h.changeRelation(c, h.blockService.Unblock, "block.unblock_failed")
+}
+
+// MuteUserHandler заглушает пользователя по логину
+func (h *BlockHandler) MuteUserHandler(c *gin.Context) {
+	h.changeRelation(c, h.blockService.Mute, "block.mute_failed")
+}
+
+// UnmuteUserHandler снимает заглушение с пользователя по логину
+func (h *BlockHandler) UnmuteUserHandler(c *gin.Context) {
+	h.changeRelation(c, h.blockService.Unmute, "block.unmute_failed")
+}
+
+// GetBlockedHandler возвращает пользователей, заблокированных текущим пользователем.
+// Параметры запроса: limit (по умолчанию 50, не больше 100) и offset.
+func (h *BlockHandler) GetBlockedHandler(c *gin.Context) {
+	h.listRelation(c, h.blockService.GetBlocked)
+}
+
+// GetMutedHandler возвращает пользователей, заглушенных текущим пользователем
+func (h *BlockHandler) GetMutedHandler(c *gin.Context) {
+	h.listRelation(c, h.blockService.GetMuted)
+}
+
+func (h *BlockHandler) changeRelation(c *gin.Context, change func(userID uint, login string) error, failCode string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := change(userID, c.Param("login"))
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrCannotRestrictSelf):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		h.log.Error("Failed to change user relation: " + err.Error())
+		respondError(c, http.StatusInternalServerError, failCode, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *BlockHandler) listRelation(c *gin.Context, list func(userID uint, limit, offset int) ([]models.RestrictedUser, error)) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	limit, offset, ok := pagination(c, defaultBlocksLimit, maxBlocksLimit)
+	if !ok {
+		return
+	}
+
+	users, err := list(userID, limit, offset)
+	if err != nil {
+		h.log.Error("Failed to list user relations: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "block.list_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
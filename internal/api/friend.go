@@ -1 +1,116 @@
-package api
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Размер страницы списка друзей
+const (
+	defaultFriendsLimit = 50
+	maxFriendsLimit     = 100
+)
+
+// FriendHandler предоставляет обработчики для работы со списками друзей
+type FriendHandler struct {
+	friendService service.FriendServiceInterface
+	log           logger.LoggerInterface
+}
+
+// NewFriendHandler создает новый экземпляр FriendHandler
+func NewFriendHandler(friendService service.FriendServiceInterface) *FriendHandler {
+	return &FriendHandler{
+		friendService: friendService,
+		log:           logger.GetLogger(),
+	}
+}
+
+// AddFriendHandler добавляет пользователя по логину в друзья текущего пользователя
+func (h *FriendHandler) AddFriendHandler(c *gin.Context) {
+	h.changeFriend(c, h.friendService.AddFriend, "friends.add_failed")
+}
+
+// RemoveFriendHandler удаляет пользователя по логину из друзей текущего пользователя
+func (h *FriendHandler) RemoveFriendHandler(c *gin.Context) {
+	h.changeFriend(c, h.friendService.RemoveFriend, "friends.remove_failed")
+}
+
+func (h *FriendHandler) changeFriend(c *gin.Context, change func(userID uint, login string) error, failCode string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := change(userID, c.Param("login"))
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrCannotFriendSelf):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		h.log.Error("Failed to change friends: " + err.Error())
+		respondError(c, http.StatusInternalServerError, failCode, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUserFriendsHandler возвращает список друзей пользователя по логину, если он открыт текущему пользователю.
+// Параметры запроса: limit (по умолчанию 50, не больше 100) и offset.
+func (h *FriendHandler) GetUserFriendsHandler(c *gin.Context) {
+	// Без токена список видно так, как его видит посторонний
+	viewerID, _ := currentUserID(c)
+
+	limit, offset, ok := pagination(c, defaultFriendsLimit, maxFriendsLimit)
+	if !ok {
+		return
+	}
+
+	friends, err := h.friendService.GetFriends(viewerID, c.Param("login"), limit, offset)
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrHiddenByPrivacy):
+		respondServiceError(c, http.StatusForbidden, err)
+		return
+	case err != nil:
+		h.log.Error("Failed to get friends: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "friends.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, friends)
+}
+
+// pagination читает limit и offset из запроса. При ошибке ответ уже отправлен.
+func pagination(c *gin.Context, defaultLimit, maxLimit int) (int, int, bool) {
+	limit, offset := defaultLimit, 0
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			respondError(c, http.StatusBadRequest, "pagination.invalid_limit", map[string]interface{}{"max": maxLimit})
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			respondError(c, http.StatusBadRequest, "pagination.invalid_offset", nil)
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
+}
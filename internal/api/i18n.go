@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/locales"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// errorCodes сопоставляет ошибки сервисов с кодами сообщений в каталогах
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{service.ErrInvalidCredentials, "auth.invalid_credentials"},
+	{service.ErrInvalidRefreshToken, "auth.invalid_refresh_token"},
+	{service.ErrRefreshTokenReused, "auth.refresh_token_reused"},
+	{service.ErrSessionNotFound, "auth.session_not_found"},
+	{service.ErrInvalidPersonalAccessToken, "auth.invalid_personal_access_token"},
+	{service.ErrInvalidMFACode, "mfa.invalid_code"},
+	{service.ErrInvalidMFAToken, "mfa.invalid_token"},
+	{service.ErrMFAAlreadyEnabled, "mfa.already_enabled"},
+	{service.ErrMFANotEnabled, "mfa.not_enabled"},
+	{service.ErrMFASetupNotStarted, "mfa.setup_not_started"},
+	{service.ErrInvalidResetToken, "password.invalid_reset_token"},
+	{service.ErrInvalidVerificationToken, "email.invalid_verification_token"},
+	{service.ErrEmailAlreadyVerified, "email.already_verified"},
+	{service.ErrEmailNotVerified, "email.not_verified"},
+	{service.ErrInvalidMagicLink, "magic_link.invalid"},
+	{service.ErrInvalidCodeChallenge, "magic_link.invalid_code_challenge"},
+	{service.ErrUnknownOIDCProvider, "oidc.unknown_provider"},
+	{service.ErrInvalidOIDCState, "oidc.invalid_state"},
+	{service.ErrOIDCLoginFailed, "oidc.login_failed"},
+	{service.ErrOIDCEmailRequired, "oidc.email_required"},
+	{service.ErrInvalidSignupToken, "oidc.invalid_signup_token"},
+	{service.ErrIdentityLinkedToAnotherUser, "oidc.identity_linked_to_another_user"},
+	{service.ErrOIDCLinkUserMismatch, "oidc.link_user_mismatch"},
+	{service.ErrIdentityNotFound, "oidc.identity_not_found"},
+	{service.ErrLastLoginMethod, "oidc.last_login_method"},
+	{service.ErrPhoneMissing, "phone.missing"},
+	{service.ErrPhoneAlreadyVerified, "phone.already_verified"},
+	{service.ErrInvalidPhoneCode, "phone.invalid_code"},
+	{service.ErrPhoneCodeAttemptsExceeded, "phone.attempts_exceeded"},
+	{service.ErrHiddenByPrivacy, "privacy.hidden"},
+	{service.ErrCannotFriendSelf, "friends.self"},
+	{service.ErrCannotRestrictSelf, "block.self"},
+	{service.ErrInvalidPersonalAccessTokenRequest, "pat.invalid_request"},
+	{service.ErrTooManyPersonalAccessTokens, "pat.too_many"},
+	{service.ErrPersonalAccessTokenNotFound, "pat.not_found"},
+	{service.ErrInvalidOAuthClient, "oauth.invalid_client"},
+	{service.ErrTooManyOAuthClients, "oauth.too_many_clients"},
+	{service.ErrOAuthClientNotFound, "oauth.client_not_found"},
+	{service.ErrOAuthConsentNotFound, "oauth.consent_not_found"},
+	{service.ErrUnknownRole, "admin.unknown_role"},
+	{service.ErrUnknownPermission, "admin.unknown_permission"},
+	{service.ErrUserNotFound, "user.not_found"},
+	{database.ErrUserNotFound, "user.not_found"},
+	{database.ErrPostNotFound, "posts.not_found"},
+	{database.ErrLoginTaken, "login.taken"},
+	{database.ErrEmailTaken, "email.taken"},
+	{database.ErrPhoneTaken, "phone.taken"},
+}
+
+// LocaleMiddleware сохраняет в контексте сервис языковых настроек. Язык ответа определяется
+// только при отправке сообщения об ошибке, поэтому успешные запросы не обращаются к базе.
+func LocaleMiddleware(localeService service.LocaleServiceInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("localeService", localeService)
+		c.Next()
+	}
+}
+
+// requestLocale возвращает язык ответа: выбранный пользователем в настройках,
+// затем по заголовку Accept-Language, затем язык по умолчанию
+func requestLocale(c *gin.Context) string {
+	if locale := c.GetString("locale"); locale != "" {
+		return locale
+	}
+
+	locale := ""
+	if userID, ok := currentUserID(c); ok {
+		value, _ := c.Get("localeService")
+		if localeService, ok := value.(service.LocaleServiceInterface); ok {
+			// Если настройку прочитать не удалось, язык выбирается по заголовку
+			preferred, err := localeService.GetLocale(userID)
+			if err == nil && locales.Bundle.Supports(preferred) {
+				locale = preferred
+			}
+		}
+	}
+	if locale == "" {
+		locale = locales.Bundle.Negotiate(c.GetHeader("Accept-Language"))
+	}
+
+	c.Set("locale", locale)
+	return locale
+}
+
+// translate возвращает сообщение с кодом code на языке запроса. Для кода ошибки поля без своего
+// сообщения используется общее *.<причина>, если нет и его - fallback.
+func translate(c *gin.Context, code string, params map[string]interface{}, fallback string) string {
+	locale := requestLocale(c)
+	if text, ok := locales.Bundle.Translate(locale, code, params); ok {
+		return text
+	}
+
+	if field, reason, ok := strings.Cut(code, "."); ok {
+		withField := map[string]interface{}{"field": field}
+		for name, value := range params {
+			withField[name] = value
+		}
+		if text, ok := locales.Bundle.Translate(locale, "*."+reason, withField); ok {
+			return text
+		}
+	}
+	return fallback
+}
+
+// respondError отвечает ошибкой с кодом и сообщением на языке клиента
+func respondError(c *gin.Context, status int, code string, params map[string]interface{}) {
+	c.JSON(status, gin.H{"error": translate(c, code, params, code), "code": code})
+}
+
+// abortError отвечает ошибкой из middleware и прерывает обработку запроса
+func abortError(c *gin.Context, status int, code string, params map[string]interface{}) {
+	respondError(c, status, code, params)
+	c.Abort()
+}
+
+// respondServiceError отвечает ошибкой сервиса. Текст ошибки, которой нет в каталогах, клиенту не отдается:
+// он может раскрывать подробности устройства сервиса, поэтому только записывается в лог.
+func respondServiceError(c *gin.Context, status int, err error) {
+	code, params := errorCode(err)
+	if code == "" {
+		logger.GetLogger().Error("Unmapped service error: " + err.Error())
+		respondError(c, status, "internal_error", nil)
+		return
+	}
+	c.JSON(status, gin.H{"error": translate(c, code, params, err.Error()), "code": code})
+}
+
+// errorCode возвращает код сообщения для ошибки сервиса и параметры для подстановки
+func errorCode(err error) (string, map[string]interface{}) {
+	var (
+		retryErr  *service.RetryAfterError
+		lockedErr *service.AccountLockedError
+	)
+	switch {
+	case errors.As(err, &retryErr):
+		return "rate_limited", map[string]interface{}{"seconds": ceilUnits(retryErr.RetryAfter.Seconds())}
+	case errors.As(err, &lockedErr):
+		return "auth.account_locked", map[string]interface{}{"minutes": ceilUnits(lockedErr.RetryAfter.Minutes())}
+	}
+
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code, nil
+		}
+	}
+	return "", nil
+}
+
+// ceilUnits округляет время ожидания вверх, но не меньше чем до единицы
+func ceilUnits(value float64) int {
+	if units := int(math.Ceil(value)); units > 1 {
+		return units
+	}
+	return 1
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/Saveliy12/prod2/internal/locales"
+)
+
+// Ошибки сервисов без перевода отдавались бы клиенту как internal_error,
+// а сообщения ответов 202 - как код сообщения
+func TestErrorCodesTranslated(t *testing.T) {
+	codes := []string{"internal_error", "magic_link.sent", "password.reset_sent"}
+	for _, known := range errorCodes {
+		codes = append(codes, known.code)
+	}
+	for _, code := range codes {
+		en, ok := locales.Bundle.Translate("en", code, nil)
+		if !ok {
+			t.Errorf("no message for %s", code)
+			continue
+		}
+		// Без русского сообщения Translate возвращает английское
+		if ru, _ := locales.Bundle.Translate("ru", code, nil); ru == en {
+			t.Errorf("no russian message for %s", code)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saveliy12/prod2/pkg/tokenmanager"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSMaxAge - сколько другие сервисы могут кешировать JWKS. Следующий ключ публикуется
+// не позже чем за это время до того, как начнет подписывать токены.
+const JWKSMaxAge = 5 * time.Minute
+
+// JWKSHandler обработчик GET /.well-known/jwks.json.
+// Отдает открытые ключи, которыми другие сервисы проверяют наши access-токены.
+func JWKSHandler(tokenManager tokenmanager.TokenManagerInterface) gin.HandlerFunc {
+	cacheControl := "public, max-age=" + strconv.Itoa(int(JWKSMaxAge.Seconds()))
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", cacheControl)
+		c.JSON(http.StatusOK, tokenManager.JWKS())
+	}
+}
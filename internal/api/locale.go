@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LocaleHandler предоставляет обработчики для выбора языка сообщений
+type LocaleHandler struct {
+	localeService service.LocaleServiceInterface
+}
+
+// NewLocaleHandler создает новый экземпляр LocaleHandler
+func NewLocaleHandler(localeService service.LocaleServiceInterface) *LocaleHandler {
+	return &LocaleHandler{localeService: localeService}
+}
+
+// GetLocaleHandler возвращает выбранный пользователем язык, язык текущего запроса и поддерживаемые языки
+func (h *LocaleHandler) GetLocaleHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	preferred, err := h.localeService.GetLocale(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.locale_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locale":    preferred,
+		"effective": requestLocale(c),
+		"supported": h.localeService.SupportedLocales(),
+	})
+}
+
+// SetLocaleHandler сохраняет предпочитаемый язык. Пустое значение возвращает выбор по Accept-Language.
+func (h *LocaleHandler) SetLocaleHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody struct {
+		Locale string `json:"locale"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.localeService.SetLocale(userID, requestBody.Locale); err != nil {
+		if !respondValidationError(c, err) {
+			respondError(c, http.StatusInternalServerError, "auth.locale_failed", nil)
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
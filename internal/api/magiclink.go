@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MagicLinkHandler предоставляет обработчики входа по ссылке из письма
+type MagicLinkHandler struct {
+	magicLinkService service.MagicLinkServiceInterface
+	authService      service.AuthServiceInterface
+	mfaService       service.MFAServiceInterface
+}
+
+// NewMagicLinkHandler создает новый экземпляр MagicLinkHandler
+func NewMagicLinkHandler(magicLinkService service.MagicLinkServiceInterface, authService service.AuthServiceInterface,
+	mfaService service.MFAServiceInterface) *MagicLinkHandler {
+	return &MagicLinkHandler{
+		magicLinkService: magicLinkService,
+		authService:      authService,
+		mfaService:       mfaService,
+	}
+}
+
+// RequestLinkHandler запрашивает письмо со ссылкой для входа.
+// Клиент генерирует codeVerifier, хранит его у себя и передает только codeChallenge.
+// Ответ всегда одинаковый, чтобы по нему нельзя было узнать, зарегистрирован ли адрес.
+func (h *MagicLinkHandler) RequestLinkHandler(c *gin.Context) {
+	var requestBody struct {
+		Email         string `json:"email"`
+		CodeChallenge string `json:"codeChallenge"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.magicLinkService.RequestLink(requestBody.Email, requestBody.CodeChallenge); err != nil {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": translate(c, "magic_link.sent", nil, "magic_link.sent")})
+}
+
+// VerifyLinkHandler обменивает токен из ссылки и codeVerifier устройства на пару токенов
+func (h *MagicLinkHandler) VerifyLinkHandler(c *gin.Context) {
+	var requestBody struct {
+		Token        string `json:"token"`
+		CodeVerifier string `json:"codeVerifier"`
+		DeviceID     string `json:"deviceId"`
+		DeviceName   string `json:"deviceName"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	userID, err := h.magicLinkService.VerifyLink(requestBody.Token, requestBody.CodeVerifier)
+	if errors.Is(err, service.ErrInvalidMagicLink) {
+		respondServiceError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "magic_link.failed", nil)
+		return
+	}
+
+	device := deviceInfo(c)
+	device.DeviceID = requestBody.DeviceID
+	device.DeviceName = requestBody.DeviceName
+
+	respondLogin(c, h.authService, h.mfaService, userID, device)
+}
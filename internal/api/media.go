@@ -0,0 +1,176 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/Saveliy12/prod2/pkg/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Запас на заголовки multipart сверх размера самого файла
+const multipartOverhead = 64 << 10
+
+// MediaHandler предоставляет обработчики для загрузки изображений профиля и раздачи файлов
+type MediaHandler struct {
+	mediaService  service.MediaServiceInterface
+	maxUploadSize int64
+	log           logger.LoggerInterface
+}
+
+// NewMediaHandler создает новый экземпляр MediaHandler. maxUploadSize - наибольший размер загружаемого файла в байтах.
+func NewMediaHandler(mediaService service.MediaServiceInterface, maxUploadSize int64) *MediaHandler {
+	return &MediaHandler{
+		mediaService:  mediaService,
+		maxUploadSize: maxUploadSize,
+		log:           logger.GetLogger(),
+	}
+}
+
+// UploadAvatarHandler загружает аватар текущего пользователя. Файл передается в поле file формы multipart/form-data.
+func (h *MediaHandler) UploadAvatarHandler(c *gin.Context) {
+	h.uploadImage(c, models.ImageAvatar)
+}
+
+// UploadCoverHandler загружает обложку профиля текущего пользователя
+func (h *MediaHandler) UploadCoverHandler(c *gin.Context) {
+	h.uploadImage(c, models.ImageCover)
+}
+
+// DeleteAvatarHandler удаляет аватар текущего пользователя
+func (h *MediaHandler) DeleteAvatarHandler(c *gin.Context) {
+	h.deleteImage(c, models.ImageAvatar)
+}
+
+// DeleteCoverHandler удаляет обложку профиля текущего пользователя
+func (h *MediaHandler) DeleteCoverHandler(c *gin.Context) {
+	h.deleteImage(c, models.ImageCover)
+}
+
+func (h *MediaHandler) uploadImage(c *gin.Context, kind string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	data, ok := h.readUpload(c)
+	if !ok {
+		return
+	}
+
+	image, err := h.mediaService.SetProfileImage(userID, kind, data)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to upload " + kind + ": " + err.Error())
+		respondError(c, http.StatusInternalServerError, "media.upload_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, image)
+}
+
+// readUpload читает файл из поля file. При ошибке ответ уже отправлен.
+func (h *MediaHandler) readUpload(c *gin.Context) ([]byte, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize+multipartOverhead)
+
+	file, header, err := c.Request.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		h.respondTooLarge(c)
+		return nil, false
+	case errors.Is(err, http.ErrMissingFile):
+		respondError(c, http.StatusBadRequest, "file.required", nil)
+		return nil, false
+	case err != nil:
+		respondBindError(c, err)
+		return nil, false
+	}
+	defer file.Close()
+
+	if header.Size > h.maxUploadSize {
+		h.respondTooLarge(c)
+		return nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(file, h.maxUploadSize+1))
+	if err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	if int64(len(data)) > h.maxUploadSize {
+		h.respondTooLarge(c)
+		return nil, false
+	}
+	return data, true
+}
+
+func (h *MediaHandler) respondTooLarge(c *gin.Context) {
+	respondError(c, http.StatusRequestEntityTooLarge, "file.too_large",
+		map[string]interface{}{"max": h.maxUploadSize >> 20})
+}
+
+func (h *MediaHandler) deleteImage(c *gin.Context, kind string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	if err := h.mediaService.DeleteProfileImage(userID, kind); err != nil {
+		h.log.Error("Failed to delete " + kind + ": " + err.Error())
+		respondError(c, http.StatusInternalServerError, "media.delete_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ServeFileHandler отдает файл локального хранилища по подписанной ссылке. Содержимое файла
+// по ключу не меняется, поэтому ответ кешируется до истечения срока действия ссылки.
+func (h *MediaHandler) ServeFileHandler(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if storage.ValidateKey(key) != nil {
+		respondError(c, http.StatusNotFound, "media.not_found", nil)
+		return
+	}
+
+	expires := c.Query("expires")
+	file, object, err := h.mediaService.OpenSignedFile(key, expires, c.Query("signature"))
+	switch {
+	case errors.Is(err, storage.ErrInvalidSignature):
+		respondError(c, http.StatusForbidden, "media.link_invalid", nil)
+		return
+	case errors.Is(err, storage.ErrURLExpired):
+		respondError(c, http.StatusForbidden, "media.link_expired", nil)
+		return
+	case errors.Is(err, storage.ErrNotFound):
+		respondError(c, http.StatusNotFound, "media.not_found", nil)
+		return
+	case err != nil:
+		h.log.Error("Failed to open media file: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "media.read_failed", nil)
+		return
+	}
+	defer file.Close()
+
+	// Подпись уже проверена, значит expires - число
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	maxAge := expiresAt - time.Now().Unix()
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.Header("Cache-Control", "public, max-age="+strconv.FormatInt(maxAge, 10)+", immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+	c.DataFromReader(http.StatusOK, object.Size, object.ContentType, file, nil)
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// MFAHandler предоставляет обработчики для двухфакторной аутентификации
+type MFAHandler struct {
+	mfaService   service.MFAServiceInterface
+	authService  service.AuthServiceInterface
+	auditService service.AuditServiceInterface
+}
+
+// NewMFAHandler создает новый экземпляр MFAHandler
+func NewMFAHandler(mfaService service.MFAServiceInterface, authService service.AuthServiceInterface,
+	auditService service.AuditServiceInterface) *MFAHandler {
+	return &MFAHandler{
+		mfaService:   mfaService,
+		authService:  authService,
+		auditService: auditService,
+	}
+}
+
+// mfaCodeRequest - тело запроса с кодом из приложения или кодом восстановления
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFAHandler - второй шаг входа: обменивает токен первого шага и код на пару токенов
+func (h *MFAHandler) LoginMFAHandler(c *gin.Context) {
+	var requestBody struct {
+		MFAToken   string `json:"mfaToken"`
+		Code       string `json:"code"`
+		DeviceID   string `json:"deviceId"`
+		DeviceName string `json:"deviceName"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	userID, err := h.mfaService.CompleteLogin(requestBody.MFAToken, requestBody.Code, c.ClientIP())
+	var (
+		retryErr  *service.RetryAfterError
+		lockedErr *service.AccountLockedError
+	)
+	throttled := errors.As(err, &lockedErr) || errors.As(err, &retryErr)
+
+	// Пароль уже подошел, поэтому неверный второй фактор - важный сигнал о подборе
+	if err != nil && userID != 0 {
+		reason := "invalid_mfa_code"
+		if throttled {
+			reason = "locked"
+		}
+		recordAudit(c, h.auditService, models.AuditEvent{
+			Type:     models.AuditLoginFailed,
+			Outcome:  models.AuditFailure,
+			TargetID: &userID,
+			Details:  models.AuditDetails{"reason": reason},
+		})
+	}
+
+	switch {
+	case lockedErr != nil:
+		respondRetryAfter(c, http.StatusLocked, lockedErr.RetryAfter, lockedErr)
+		return
+	case retryErr != nil:
+		respondRetryAfter(c, http.StatusTooManyRequests, retryErr.RetryAfter, retryErr)
+		return
+	case err != nil:
+		h.respondMFAError(c, err)
+		return
+	}
+
+	device := deviceInfo(c)
+	device.DeviceID = requestBody.DeviceID
+	device.DeviceName = requestBody.DeviceName
+
+	tokens, err := h.authService.AuthorizeUser(userID, device, true)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "auth.authorize_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		DeviceID:     tokens.DeviceID,
+	})
+}
+
+// GetMFAStatusHandler возвращает состояние двухфакторной аутентификации текущего пользователя
+func (h *MFAHandler) GetMFAStatusHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	status, err := h.mfaService.GetStatus(userID)
+	if err != nil {
+		h.respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTOTPHandler генерирует секрет и otpauth-ссылку для приложения-аутентификатора
+func (h *MFAHandler) SetupTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	setup, err := h.mfaService.BeginSetup(userID)
+	if err != nil {
+		h.respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// ConfirmTOTPHandler включает двухфакторную аутентификацию и возвращает коды восстановления
+func (h *MFAHandler) ConfirmTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody mfaCodeRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmSetup(userID, requestBody.Code)
+	if err != nil {
+		h.respondMFAError(c, err)
+		return
+	}
+	recordAudit(c, h.auditService, models.AuditEvent{Type: models.AuditMFAEnabled, TargetID: &userID})
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// DisableMFAHandler отключает двухфакторную аутентификацию
+func (h *MFAHandler) DisableMFAHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody mfaCodeRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.mfaService.Disable(userID, requestBody.Code); err != nil {
+		h.respondMFAError(c, err)
+		return
+	}
+	recordAudit(c, h.auditService, models.AuditEvent{Type: models.AuditMFADisabled, TargetID: &userID})
+
+	c.Status(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodesHandler выпускает новые коды восстановления
+func (h *MFAHandler) RegenerateRecoveryCodesHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody mfaCodeRequest
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID, requestBody.Code)
+	if err != nil {
+		h.respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func (h *MFAHandler) respondMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrInvalidMFAToken):
+		respondServiceError(c, http.StatusUnauthorized, err)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		respondServiceError(c, http.StatusConflict, err)
+	case errors.Is(err, service.ErrMFANotEnabled), errors.Is(err, service.ErrMFASetupNotStarted):
+		respondServiceError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, "mfa.error", nil)
+	}
+}
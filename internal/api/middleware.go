@@ -1,45 +1,237 @@
-package api
-
-import (
-	"net/http"
-	"strings"
-
-	"github.com/Saveliy12/prod2/pkg/tokenmanager"
-	"github.com/gin-gonic/gin"
-)
-
-type AuthMiddleware struct {
-	tokenManager tokenmanager.TokenManagerInterface
-}
-
-func NewAuthMiddleware(tokenManager tokenmanager.TokenManagerInterface) *AuthMiddleware {
-	return &AuthMiddleware{tokenManager: tokenManager}
-}
-
-func (m *AuthMiddleware) JWTAuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
-			return
-		}
-
-		userID, err := m.tokenManager.ParseJWT(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
-		}
-
-		c.Set("userID", userID)
-		c.Next()
-	}
-}
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/tokenmanager"
+	"github.com/gin-gonic/gin"
+)
+
+type AuthMiddleware struct {
+	tokenManager    tokenmanager.TokenManagerInterface
+	revocationStore tokenmanager.RevocationStore
+	patService      service.PersonalAccessTokenServiceInterface
+}
+
+func NewAuthMiddleware(tokenManager tokenmanager.TokenManagerInterface, revocationStore tokenmanager.RevocationStore,
+	patService service.PersonalAccessTokenServiceInterface) *AuthMiddleware {
+	return &AuthMiddleware{tokenManager: tokenManager, revocationStore: revocationStore, patService: patService}
+}
+
+// JWTAuthMiddleware пропускает только запросы с access-токеном сессии.
+// Персональные токены и токены сторонних приложений здесь не принимаются,
+// поэтому ими нельзя управлять аккаунтом.
+func (m *AuthMiddleware) JWTAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+
+		claims, ok := m.authenticateJWT(c, tokenString)
+		if !ok {
+			return
+		}
+		if claims.ClientID != "" {
+			abortError(c, http.StatusForbidden, "auth.third_party_token", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TokenAuthMiddleware пропускает запросы с access-токеном сессии, а также с персональным токеном
+// или токеном стороннего приложения, которым выдана область действия scope
+func (m *AuthMiddleware) TokenAuthMiddleware(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := bearerToken(c)
+		if !ok {
+			return
+		}
+
+		if !m.patService.IsPersonalAccessToken(tokenString) {
+			claims, ok := m.authenticateJWT(c, tokenString)
+			if !ok {
+				return
+			}
+			if claims.ClientID != "" && !claims.HasScope(scope) {
+				abortError(c, http.StatusForbidden, "auth.missing_scope", map[string]interface{}{"scope": scope})
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		pat, err := m.patService.Authenticate(tokenString)
+		if errors.Is(err, service.ErrInvalidPersonalAccessToken) {
+			respondServiceError(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			abortError(c, http.StatusInternalServerError, "auth.token_verify_failed", nil)
+			return
+		}
+		if !pat.HasScope(scope) {
+			abortError(c, http.StatusForbidden, "auth.missing_scope", map[string]interface{}{"scope": scope})
+			return
+		}
+
+		c.Set("userID", pat.UserID)
+		c.Set("personalAccessToken", pat)
+		c.Next()
+	}
+}
+
+// OptionalAuth проверяет токен middleware только если клиент его передал.
+// Запрос без заголовка Authorization проходит анонимно, без userID в контексте.
+func OptionalAuth(middleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		middleware(c)
+	}
+}
+
+// bearerToken извлекает токен из заголовка Authorization. При ошибке ответ уже отправлен.
+func bearerToken(c *gin.Context) (string, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		abortError(c, http.StatusUnauthorized, "auth.header_missing", nil)
+		return "", false
+	}
+
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		abortError(c, http.StatusUnauthorized, "auth.header_format", nil)
+		return "", false
+	}
+
+	return tokenString, true
+}
+
+// authenticateJWT проверяет access-токен. При ошибке ответ уже отправлен.
+func (m *AuthMiddleware) authenticateJWT(c *gin.Context, tokenString string) (tokenmanager.Claims, bool) {
+	claims, err := m.tokenManager.ParseClaims(tokenString)
+	if err != nil {
+		abortError(c, http.StatusUnauthorized, "auth.invalid_token", nil)
+		return tokenmanager.Claims{}, false
+	}
+
+	revoked, err := tokenmanager.IsRevoked(m.revocationStore, claims)
+	if err != nil {
+		abortError(c, http.StatusInternalServerError, "auth.token_verify_failed", nil)
+		return tokenmanager.Claims{}, false
+	}
+	if revoked {
+		abortError(c, http.StatusUnauthorized, "auth.token_revoked", nil)
+		return tokenmanager.Claims{}, false
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("tokenClaims", claims)
+	return claims, true
+}
+
+// RequireVerifiedEmail пропускает только пользователей с подтвержденной почтой.
+// Подключается после JWTAuthMiddleware.
+func RequireVerifiedEmail(verificationService service.EmailVerificationServiceInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			abortError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+			return
+		}
+
+		verified, err := verificationService.IsEmailVerified(userID)
+		if err != nil {
+			abortError(c, http.StatusInternalServerError, "email.check_failed", nil)
+			return
+		}
+		if !verified {
+			abortError(c, http.StatusForbidden, "email.verification_required", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireMFA пропускает только пользователей с включенной двухфакторной аутентификацией,
+// вход которых в текущую сессию подтвержден вторым фактором (claim amr access-токена).
+// Подключается к административным маршрутам после JWTAuthMiddleware.
+func RequireMFA(mfaService service.MFAServiceInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentTokenClaims(c)
+		if !ok {
+			abortError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+			return
+		}
+		userID := claims.UserID
+
+		enabled, err := mfaService.IsEnabled(userID)
+		if err != nil {
+			abortError(c, http.StatusInternalServerError, "mfa.check_failed", nil)
+			return
+		}
+		if !enabled {
+			abortError(c, http.StatusForbidden, "mfa.required", nil)
+			return
+		}
+		if !claims.MFA {
+			abortError(c, http.StatusForbidden, "mfa.session_required", nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission пропускает только запросы, access-токен которых дает все указанные права.
+// Подключается после JWTAuthMiddleware. Права берутся из токена: при их изменении
+// старые токены пользователя отзываются, поэтому проверка в базе на каждый запрос не нужна.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := currentTokenClaims(c)
+		if !ok {
+			abortError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+			return
+		}
+
+		for _, permission := range permissions {
+			if !claims.HasPermission(permission) {
+				abortError(c, http.StatusForbidden, "auth.permission_denied", map[string]interface{}{"permission": permission})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// currentUserID возвращает идентификатор пользователя, установленный JWTAuthMiddleware
+func currentUserID(c *gin.Context) (uint, bool) {
+	value, ok := c.Get("userID")
+	if !ok {
+		return 0, false
+	}
+	userID, ok := value.(uint)
+	return userID, ok
+}
+
+// currentTokenClaims возвращает claims access-токена текущего запроса
+func currentTokenClaims(c *gin.Context) (tokenmanager.Claims, bool) {
+	value, ok := c.Get("tokenClaims")
+	if !ok {
+		return tokenmanager.Claims{}, false
+	}
+	claims, ok := value.(tokenmanager.Claims)
+	return claims, ok
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOptionalAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenManager, err := tokenmanager.NewManager("optional-auth-signing-key-0123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocationStore := tokenmanager.NewMemoryRevocationStore(time.Hour, time.Hour)
+	defer revocationStore.Stop()
+	authMiddleware := NewAuthMiddleware(tokenManager, revocationStore, service.NewPersonalAccessTokenService(nil))
+
+	r := gin.New()
+	r.GET("/users/:login", OptionalAuth(authMiddleware.TokenAuthMiddleware(models.ScopeRead)), func(c *gin.Context) {
+		viewerID, _ := currentUserID(c)
+		c.JSON(http.StatusOK, gin.H{"viewerId": viewerID})
+	})
+
+	token, err := tokenManager.NewJWT(tokenmanager.Subject{UserID: 7, Role: models.RoleUser}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		body          string
+	}{
+		{"anonymous", "", http.StatusOK, `{"viewerId":0}`},
+		{"signed in", "Bearer " + token, http.StatusOK, `{"viewerId":7}`},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users/alice", nil)
+		if tt.authorization != "" {
+			req.Header.Set("Authorization", tt.authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.status || tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s: status %d, body %s; want %d %s", tt.name, w.Code, w.Body.String(), tt.status, tt.body)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler предоставляет обработчики сервера авторизации OAuth 2.1
+type OAuthHandler struct {
+	oauthService service.OAuthServiceInterface
+}
+
+// NewOAuthHandler создает новый экземпляр OAuthHandler
+func NewOAuthHandler(oauthService service.OAuthServiceInterface) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService}
+}
+
+// RegisterClientHandler регистрирует приложение. Секрет конфиденциального клиента возвращается только в этом ответе.
+func (h *OAuthHandler) RegisterClientHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirectUris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	client, secret, err := h.oauthService.RegisterClient(userID, requestBody.Name, requestBody.RedirectURIs,
+		requestBody.Scopes, requestBody.Confidential)
+	switch {
+	case errors.Is(err, service.ErrInvalidOAuthClient):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrTooManyOAuthClients):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "oauth.register_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		models.OAuthClient
+		ClientSecret string `json:"clientSecret,omitempty"`
+	}{client, secret})
+}
+
+// GetClientsHandler возвращает приложения пользователя
+func (h *OAuthHandler) GetClientsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	clients, err := h.oauthService.GetClients(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "oauth.clients_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, clients)
+}
+
+// DeleteClientHandler удаляет приложение пользователя
+func (h *OAuthHandler) DeleteClientHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := h.oauthService.DeleteClient(userID, c.Param("clientId"))
+	if errors.Is(err, service.ErrOAuthClientNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "oauth.delete_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AuthorizePromptHandler проверяет запрос авторизации из query и возвращает сведения для страницы согласия
+func (h *OAuthHandler) AuthorizePromptHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var req models.OAuthAuthorizationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	prompt, err := h.oauthService.ValidateAuthorizationRequest(userID, req)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// AuthorizeHandler принимает решение пользователя на странице согласия.
+// Access-токен сессии передается в заголовке, поэтому браузер не может перейти сюда напрямую:
+// страница согласия получает адрес возврата в приложение и сама перенаправляет пользователя.
+func (h *OAuthHandler) AuthorizeHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody struct {
+		models.OAuthAuthorizationRequest
+		Approve bool `json:"approve"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	redirectURI, err := h.oauthService.Authorize(userID, requestBody.OAuthAuthorizationRequest, requestBody.Approve)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redirectUri": redirectURI})
+}
+
+// GetConsentsHandler возвращает приложения, которым пользователь дал доступ
+func (h *OAuthHandler) GetConsentsHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	consents, err := h.oauthService.GetConsents(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "oauth.consents_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// RevokeConsentHandler отзывает доступ приложения
+func (h *OAuthHandler) RevokeConsentHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := h.oauthService.RevokeConsent(userID, c.Param("clientId"))
+	if errors.Is(err, service.ErrOAuthConsentNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "oauth.revoke_consent_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// TokenHandler - token endpoint (RFC 6749, раздел 3.2). Параметры передаются формой.
+func (h *OAuthHandler) TokenHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	var (
+		tokens models.OAuthTokenResponse
+		err    error
+	)
+	switch c.PostForm("grant_type") {
+	case "authorization_code":
+		tokens, err = h.oauthService.ExchangeCode(client, c.PostForm("code"), c.PostForm("redirect_uri"), c.PostForm("code_verifier"))
+	case "refresh_token":
+		tokens, err = h.oauthService.RefreshToken(client, c.PostForm("refresh_token"), c.PostForm("scope"))
+	default:
+		err = &service.OAuthError{Code: service.OAuthUnsupportedGrantType, Description: "only authorization_code and refresh_token grants are supported"}
+	}
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// IntrospectHandler - introspection endpoint (RFC 7662)
+func (h *OAuthHandler) IntrospectHandler(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	introspection, err := h.oauthService.Introspect(client, c.PostForm("token"))
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, introspection)
+}
+
+// RevokeHandler - revocation endpoint (RFC 7009). Для неизвестного токена тоже отвечает 200.
+func (h *OAuthHandler) RevokeHandler(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.Revoke(client, c.PostForm("token")); err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// authenticateClient проверяет клиента по HTTP Basic (client_secret_basic) или по параметрам формы
+// (client_secret_post, а для публичных клиентов - только client_id). При ошибке ответ уже отправлен.
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (models.OAuthClient, bool) {
+	clientID, clientSecret, basic := c.Request.BasicAuth()
+	if basic {
+		// В Basic идентификатор и секрет закодированы как application/x-www-form-urlencoded (RFC 6749, раздел 2.3.1)
+		var err1, err2 error
+		clientID, err1 = url.QueryUnescape(clientID)
+		clientSecret, err2 = url.QueryUnescape(clientSecret)
+		if err1 != nil || err2 != nil {
+			respondOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidClient, Description: "malformed client credentials"})
+			return models.OAuthClient{}, false
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	client, err := h.oauthService.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		respondOAuthError(c, err)
+		return models.OAuthClient{}, false
+	}
+	return client, true
+}
+
+// respondOAuthError отвечает ошибкой в формате RFC 6749 (раздел 5.2)
+func respondOAuthError(c *gin.Context, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == service.OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
@@ -0,0 +1,481 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/pkce"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryOAuthRepository хранит клиентов, согласия, коды и refresh-токены в памяти
+// с той же семантикой одноразовости, что и OAuthRepository
+type memoryOAuthRepository struct {
+	mu       sync.Mutex
+	clients  map[string]models.OAuthClient
+	consents map[string]models.OAuthConsent
+	codes    map[string]models.OAuthAuthorizationCode
+	tokens   []models.OAuthRefreshToken
+}
+
+func newMemoryOAuthRepository() *memoryOAuthRepository {
+	return &memoryOAuthRepository{
+		clients:  make(map[string]models.OAuthClient),
+		consents: make(map[string]models.OAuthConsent),
+		codes:    make(map[string]models.OAuthAuthorizationCode),
+	}
+}
+
+func (r *memoryOAuthRepository) CreateClient(client models.OAuthClient) (models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.ID = uint(len(r.clients) + 1)
+	r.clients[client.ClientID] = client
+	return client, nil
+}
+
+func (r *memoryOAuthRepository) GetClient(clientID string) (models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[clientID]
+	if !ok {
+		return models.OAuthClient{}, database.ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+func (r *memoryOAuthRepository) GetClientsByOwner(ownerID uint) ([]models.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := []models.OAuthClient{}
+	for _, client := range r.clients {
+		if client.OwnerID == ownerID {
+			clients = append(clients, client)
+		}
+	}
+	return clients, nil
+}
+
+func (r *memoryOAuthRepository) DeleteClient(ownerID uint, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[clientID]; !ok || client.OwnerID != ownerID {
+		return database.ErrOAuthClientNotFound
+	}
+	delete(r.clients, clientID)
+	return nil
+}
+
+func consentKey(userID uint, clientID string) string {
+	return fmt.Sprintf("%d/%s", userID, clientID)
+}
+
+func (r *memoryOAuthRepository) SaveConsent(consent models.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consents[consentKey(consent.UserID, consent.ClientID)] = consent
+	return nil
+}
+
+func (r *memoryOAuthRepository) GetConsent(userID uint, clientID string) (models.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consent, ok := r.consents[consentKey(userID, clientID)]
+	if !ok {
+		return models.OAuthConsent{}, database.ErrOAuthConsentNotFound
+	}
+	return consent, nil
+}
+
+func (r *memoryOAuthRepository) GetConsents(userID uint) ([]models.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	consents := []models.OAuthConsent{}
+	for _, consent := range r.consents {
+		if consent.UserID == userID {
+			consents = append(consents, consent)
+		}
+	}
+	return consents, nil
+}
+
+func (r *memoryOAuthRepository) DeleteConsent(userID uint, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := consentKey(userID, clientID)
+	if _, ok := r.consents[key]; !ok {
+		return database.ErrOAuthConsentNotFound
+	}
+	delete(r.consents, key)
+	return nil
+}
+
+func (r *memoryOAuthRepository) CreateAuthorizationCode(code models.OAuthAuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[code.CodeHash] = code
+	return nil
+}
+
+func (r *memoryOAuthRepository) ConsumeAuthorizationCode(codeHash string) (models.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[codeHash]
+	if !ok {
+		return models.OAuthAuthorizationCode{}, database.ErrAuthorizationCodeNotFound
+	}
+	if code.UsedAt != nil {
+		return code, database.ErrAuthorizationCodeUsed
+	}
+	now := time.Now()
+	code.UsedAt = &now
+	r.codes[codeHash] = code
+	return code, nil
+}
+
+func (r *memoryOAuthRepository) CreateRefreshToken(token models.OAuthRefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *memoryOAuthRepository) GetRefreshTokenByHash(tokenHash string) (models.OAuthRefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.OAuthRefreshToken{}, database.ErrOAuthRefreshTokenNotFound
+}
+
+func (r *memoryOAuthRepository) RotateRefreshToken(tokenID uint, next models.OAuthRefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	previous := &r.tokens[tokenID-1]
+	if previous.RotatedAt != nil || previous.RevokedAt != nil {
+		return database.ErrOAuthRefreshTokenRotated
+	}
+	rotatedAt := next.CreatedAt
+	previous.RotatedAt = &rotatedAt
+	next.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, next)
+	return nil
+}
+
+func (r *memoryOAuthRepository) RevokeRefreshTokenFamily(familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.tokens {
+		if r.tokens[i].FamilyID == familyID && r.tokens[i].RevokedAt == nil {
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *memoryOAuthRepository) RevokeUserRefreshTokens(userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for i := range r.tokens {
+		if r.tokens[i].UserID == userID && r.tokens[i].RevokedAt == nil {
+			r.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// oauthTestServer поднимает маршруты OAuth так же, как main, с хранилищами в памяти
+type oauthTestServer struct {
+	t            *testing.T
+	router       *gin.Engine
+	sessionToken string
+}
+
+const oauthTestRedirectURI = "http://localhost:8081/callback"
+
+func newOAuthTestServer(t *testing.T) *oauthTestServer {
+	gin.SetMode(gin.TestMode)
+
+	tokenManager, err := tokenmanager.NewManager("oauth-test-signing-key-0123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocationStore := tokenmanager.NewMemoryRevocationStore(time.Hour, time.Hour)
+	t.Cleanup(revocationStore.Stop)
+
+	oauthHandler := NewOAuthHandler(service.NewOAuthService(newMemoryOAuthRepository(), tokenManager, revocationStore, time.Minute))
+	authMiddleware := NewAuthMiddleware(tokenManager, revocationStore, service.NewPersonalAccessTokenService(nil))
+
+	r := gin.New()
+	r.POST("/oauth/token", oauthHandler.TokenHandler)
+	r.POST("/oauth/introspect", oauthHandler.IntrospectHandler)
+	r.POST("/oauth/revoke", oauthHandler.RevokeHandler)
+
+	protected := r.Group("/protected")
+	protected.Use(authMiddleware.JWTAuthMiddleware())
+	protected.POST("/oauth/clients", oauthHandler.RegisterClientHandler)
+	protected.GET("/oauth/authorize", oauthHandler.AuthorizePromptHandler)
+	protected.POST("/oauth/authorize", oauthHandler.AuthorizeHandler)
+
+	// Ресурс, доступный токену приложения с областью read
+	r.GET("/me", authMiddleware.TokenAuthMiddleware(models.ScopeRead), func(c *gin.Context) {
+		userID, _ := currentUserID(c)
+		c.JSON(http.StatusOK, gin.H{"userId": userID})
+	})
+
+	sessionToken, err := tokenManager.NewJWT(tokenmanager.Subject{UserID: 42, Role: models.RoleUser}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &oauthTestServer{t: t, router: r, sessionToken: sessionToken}
+}
+
+func (s *oauthTestServer) do(req *http.Request) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	body := map[string]interface{}{}
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			s.t.Fatalf("%s %s: invalid JSON response %q", req.Method, req.URL, w.Body.String())
+		}
+	}
+	return w.Code, body
+}
+
+func (s *oauthTestServer) session(method, target string, body interface{}) (int, map[string]interface{}) {
+	var reader *strings.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = strings.NewReader(string(data))
+	} else {
+		reader = strings.NewReader("")
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Authorization", "Bearer "+s.sessionToken)
+	req.Header.Set("Content-Type", "application/json")
+	return s.do(req)
+}
+
+func (s *oauthTestServer) form(target string, values url.Values) (int, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(req)
+}
+
+// registerClient регистрирует публичного клиента с областью read и возвращает его client_id
+func (s *oauthTestServer) registerClient() string {
+	status, body := s.session(http.MethodPost, "/protected/oauth/clients", gin.H{
+		"name":         "Test app",
+		"redirectUris": []string{oauthTestRedirectURI},
+		"scopes":       []string{models.ScopeRead},
+	})
+	if status != http.StatusCreated {
+		s.t.Fatalf("register client: status %d, body %v", status, body)
+	}
+	return body["clientId"].(string)
+}
+
+// authorize проходит согласие и возвращает код авторизации и code_verifier
+func (s *oauthTestServer) authorize(clientID string) (string, string) {
+	verifier, err := pkce.NewVerifier()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	request := models.OAuthAuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         oauthTestRedirectURI,
+		Scope:               models.ScopeRead,
+		State:               "xyz",
+		CodeChallenge:       pkce.Challenge(verifier),
+		CodeChallengeMethod: pkce.MethodS256,
+	}
+
+	query := url.Values{
+		"response_type":         {request.ResponseType},
+		"client_id":             {request.ClientID},
+		"redirect_uri":          {request.RedirectURI},
+		"scope":                 {request.Scope},
+		"state":                 {request.State},
+		"code_challenge":        {request.CodeChallenge},
+		"code_challenge_method": {request.CodeChallengeMethod},
+	}
+	status, prompt := s.session(http.MethodGet, "/protected/oauth/authorize?"+query.Encode(), nil)
+	if status != http.StatusOK || prompt["consentRequired"] != true {
+		s.t.Fatalf("authorize prompt: status %d, body %v", status, prompt)
+	}
+
+	status, body := s.session(http.MethodPost, "/protected/oauth/authorize", struct {
+		models.OAuthAuthorizationRequest
+		Approve bool `json:"approve"`
+	}{request, true})
+	if status != http.StatusOK {
+		s.t.Fatalf("authorize: status %d, body %v", status, body)
+	}
+
+	redirect, err := url.Parse(body["redirectUri"].(string))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if got := redirect.Scheme + "://" + redirect.Host + redirect.Path; got != oauthTestRedirectURI {
+		s.t.Fatalf("redirect to %s, want %s", got, oauthTestRedirectURI)
+	}
+	if state := redirect.Query().Get("state"); state != "xyz" {
+		s.t.Fatalf("state = %q, want xyz", state)
+	}
+	code := redirect.Query().Get("code")
+	if code == "" {
+		s.t.Fatalf("no code in redirect %s", redirect)
+	}
+	return code, verifier
+}
+
+func (s *oauthTestServer) exchange(clientID, code, redirectURI, verifier string) (int, map[string]interface{}) {
+	return s.form("/oauth/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {clientID},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+func (s *oauthTestServer) refresh(clientID, refreshToken string) (int, map[string]interface{}) {
+	return s.form("/oauth/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {clientID},
+		"refresh_token": {refreshToken},
+	})
+}
+
+func expectInvalidGrant(t *testing.T, step string, status int, body map[string]interface{}) {
+	t.Helper()
+	if status != http.StatusBadRequest || body["error"] != service.OAuthInvalidGrant {
+		t.Fatalf("%s: status %d, body %v; want 400 invalid_grant", step, status, body)
+	}
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	s := newOAuthTestServer(t)
+	clientID := s.registerClient()
+	code, verifier := s.authorize(clientID)
+
+	status, tokens := s.exchange(clientID, code, oauthTestRedirectURI, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("exchange: status %d, body %v", status, tokens)
+	}
+	if tokens["token_type"] != "Bearer" || tokens["scope"] != models.ScopeRead || tokens["refresh_token"] == "" {
+		t.Fatalf("unexpected token response %v", tokens)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	if status, body := s.do(req); status != http.StatusOK || body["userId"] != float64(42) {
+		t.Fatalf("resource with access token: status %d, body %v", status, body)
+	}
+
+	status, introspection := s.form("/oauth/introspect", url.Values{"client_id": {clientID}, "token": {tokens["access_token"].(string)}})
+	if status != http.StatusOK || introspection["active"] != true || introspection["sub"] != "42" {
+		t.Fatalf("introspect: status %d, body %v", status, introspection)
+	}
+
+	// Приложению с токеном нельзя управлять аккаунтом
+	req = httptest.NewRequest(http.MethodPost, "/protected/oauth/clients", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	if status, _ := s.do(req); status != http.StatusForbidden {
+		t.Fatalf("session route with app token: status %d, want 403", status)
+	}
+}
+
+func TestOAuthWrongCodeVerifier(t *testing.T) {
+	s := newOAuthTestServer(t)
+	clientID := s.registerClient()
+	code, verifier := s.authorize(clientID)
+
+	other, _ := pkce.NewVerifier()
+	status, body := s.exchange(clientID, code, oauthTestRedirectURI, other)
+	expectInvalidGrant(t, "wrong verifier", status, body)
+
+	// Код одноразовый даже после неудачного обмена
+	status, body = s.exchange(clientID, code, oauthTestRedirectURI, verifier)
+	expectInvalidGrant(t, "exchange after wrong verifier", status, body)
+}
+
+func TestOAuthCodeReuseRevokesIssuedTokens(t *testing.T) {
+	s := newOAuthTestServer(t)
+	clientID := s.registerClient()
+	code, verifier := s.authorize(clientID)
+
+	status, tokens := s.exchange(clientID, code, oauthTestRedirectURI, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("exchange: status %d, body %v", status, tokens)
+	}
+
+	status, body := s.exchange(clientID, code, oauthTestRedirectURI, verifier)
+	expectInvalidGrant(t, "code reuse", status, body)
+
+	status, body = s.refresh(clientID, tokens["refresh_token"].(string))
+	expectInvalidGrant(t, "refresh after code reuse", status, body)
+}
+
+func TestOAuthRedirectURIMismatch(t *testing.T) {
+	s := newOAuthTestServer(t)
+	clientID := s.registerClient()
+
+	// Незарегистрированный адрес: ошибка возвращается самому пользователю, без перенаправления
+	status, body := s.session(http.MethodPost, "/protected/oauth/authorize", gin.H{
+		"response_type":         "code",
+		"client_id":             clientID,
+		"redirect_uri":          "https://attacker.example/callback",
+		"code_challenge":        pkce.Challenge("a-verifier-that-is-long-enough-to-be-valid-0123456789"),
+		"code_challenge_method": pkce.MethodS256,
+		"approve":               true,
+	})
+	if status != http.StatusBadRequest || body["error"] != service.OAuthInvalidRequest {
+		t.Fatalf("authorize with unregistered redirect_uri: status %d, body %v", status, body)
+	}
+
+	code, verifier := s.authorize(clientID)
+	status, body = s.exchange(clientID, code, "http://localhost:8081/other", verifier)
+	expectInvalidGrant(t, "token with another redirect_uri", status, body)
+}
+
+func TestOAuthRefreshRotationAndReuseDetection(t *testing.T) {
+	s := newOAuthTestServer(t)
+	clientID := s.registerClient()
+	code, verifier := s.authorize(clientID)
+
+	_, first := s.exchange(clientID, code, oauthTestRedirectURI, verifier)
+	status, second := s.refresh(clientID, first["refresh_token"].(string))
+	if status != http.StatusOK {
+		t.Fatalf("refresh: status %d, body %v", status, second)
+	}
+	if second["refresh_token"] == first["refresh_token"] {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Повторное предъявление обменянного токена отзывает все семейство
+	status, body := s.refresh(clientID, first["refresh_token"].(string))
+	expectInvalidGrant(t, "reuse of rotated refresh token", status, body)
+
+	status, body = s.refresh(clientID, second["refresh_token"].(string))
+	expectInvalidGrant(t, "refresh with token of revoked family", status, body)
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCHandler предоставляет обработчики входа через внешних провайдеров OpenID Connect
+type OIDCHandler struct {
+	oidcService         service.OIDCServiceInterface
+	authService         service.AuthServiceInterface
+	mfaService          service.MFAServiceInterface
+	verificationService service.EmailVerificationServiceInterface
+	log                 logger.LoggerInterface
+}
+
+// NewOIDCHandler создает новый экземпляр OIDCHandler
+func NewOIDCHandler(oidcService service.OIDCServiceInterface, authService service.AuthServiceInterface,
+	mfaService service.MFAServiceInterface, verificationService service.EmailVerificationServiceInterface) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:         oidcService,
+		authService:         authService,
+		mfaService:          mfaService,
+		verificationService: verificationService,
+		log:                 logger.GetLogger(),
+	}
+}
+
+// GetProvidersHandler возвращает имена настроенных провайдеров
+func (h *OIDCHandler) GetProvidersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oidcService.Providers()})
+}
+
+// StartLoginHandler возвращает адрес страницы входа провайдера, на который клиент перенаправляет пользователя
+func (h *OIDCHandler) StartLoginHandler(c *gin.Context) {
+	authURL, binding, err := h.oidcService.BeginLogin(c.Request.Context(), c.Param("provider"))
+	h.respondAuthorizationURL(c, authURL, binding, err)
+}
+
+// StartLinkHandler возвращает адрес страницы входа провайдера для привязки аккаунта к текущему пользователю
+func (h *OIDCHandler) StartLinkHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	authURL, binding, err := h.oidcService.BeginLink(c.Request.Context(), c.Param("provider"), userID)
+	h.respondAuthorizationURL(c, authURL, binding, err)
+}
+
+// respondAuthorizationURL отвечает адресом страницы входа провайдера и запоминает в cookie браузера секрет,
+// без которого CallbackHandler не примет state
+func (h *OIDCHandler) respondAuthorizationURL(c *gin.Context, authURL, binding string, err error) {
+	if errors.Is(err, service.ErrUnknownOIDCProvider) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to start identity provider login: " + err.Error())
+		respondError(c, http.StatusBadGateway, "oidc.provider_unavailable", nil)
+		return
+	}
+
+	setBindingCookie(c, binding, oidcBindingMaxAge)
+	c.JSON(http.StatusOK, gin.H{"authorizationUrl": authURL})
+}
+
+// CallbackHandler принимает code и state, с которыми провайдер вернул пользователя на страницу клиента.
+// В ответе токены (или mfaToken), сведения о привязке либо токен для завершения регистрации.
+// Привязку аккаунта завершает только вошедший пользователь, начавший ее, поэтому access-токен необязателен
+// для входа, но нужен для привязки.
+func (h *OIDCHandler) CallbackHandler(c *gin.Context) {
+	var requestBody struct {
+		Code       string `json:"code"`
+		State      string `json:"state"`
+		DeviceID   string `json:"deviceId"`
+		DeviceName string `json:"deviceName"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	binding, _ := c.Cookie(oidcBindingCookie)
+	callerID, _ := currentUserID(c)
+	// Вход одноразовый, cookie больше не нужна при любом исходе
+	setBindingCookie(c, "", -1)
+
+	result, err := h.oidcService.CompleteLogin(c.Request.Context(), c.Param("provider"), requestBody.Code,
+		requestBody.State, binding, callerID)
+	switch {
+	case errors.Is(err, service.ErrUnknownOIDCProvider):
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrInvalidOIDCState), errors.Is(err, service.ErrOIDCEmailRequired):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrOIDCLoginFailed):
+		h.log.Error(err.Error())
+		respondServiceError(c, http.StatusUnauthorized, service.ErrOIDCLoginFailed)
+		return
+	case errors.Is(err, service.ErrIdentityLinkedToAnotherUser):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case errors.Is(err, service.ErrOIDCLinkUserMismatch):
+		respondServiceError(c, http.StatusForbidden, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "oidc.failed", nil)
+		return
+	}
+
+	if result.SignupRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"signupRequired": true,
+			"signupToken":    result.SignupToken,
+			"email":          result.Email,
+			"suggestedLogin": result.SuggestedLogin,
+		})
+		return
+	}
+
+	if result.LinkOnly {
+		c.JSON(http.StatusOK, gin.H{"linked": true, "provider": c.Param("provider")})
+		return
+	}
+
+	device := deviceInfo(c)
+	device.DeviceID = requestBody.DeviceID
+	device.DeviceName = requestBody.DeviceName
+
+	respondLogin(c, h.authService, h.mfaService, result.UserID, device)
+}
+
+// SignupHandler создает аккаунт с выбранным логином после входа у провайдера и выдает токены
+func (h *OIDCHandler) SignupHandler(c *gin.Context) {
+	var requestBody struct {
+		SignupToken string `json:"signupToken"`
+		Login       string `json:"login"`
+		DeviceID    string `json:"deviceId"`
+		DeviceName  string `json:"deviceName"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	required := &utils.ValidationError{}
+	required.Required("signupToken", requestBody.SignupToken)
+	required.Required("login", requestBody.Login)
+	if respondValidationError(c, required.Err()) {
+		return
+	}
+
+	user, err := h.oidcService.CompleteSignup(requestBody.SignupToken, requestBody.Login)
+	switch {
+	case errors.Is(err, service.ErrInvalidSignupToken):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, database.ErrEmailTaken):
+		respondConflict(c, "email", "oidc.email_taken", "An account with this email already exists, sign in with password and link the provider in settings")
+		return
+	case errors.Is(err, service.ErrIdentityLinkedToAnotherUser):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		if !respondValidationError(c, err) {
+			respondError(c, http.StatusInternalServerError, "auth.register_failed", nil)
+		}
+		return
+	}
+
+	// Провайдер не подтвердил почту, проверяем ее сами
+	if !user.EmailVerified {
+		if err := h.verificationService.SendVerificationEmail(user); err != nil {
+			h.log.Error("Failed to send verification email: " + err.Error())
+		}
+	}
+
+	device := deviceInfo(c)
+	device.DeviceID = requestBody.DeviceID
+	device.DeviceName = requestBody.DeviceName
+
+	tokens, err := h.authService.AuthorizeUser(user.ID, device, false)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "auth.authorize_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		DeviceID:     tokens.DeviceID,
+	})
+}
+
+// GetIdentitiesHandler возвращает привязанные аккаунты провайдеров
+func (h *OIDCHandler) GetIdentitiesHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	identities, err := h.oidcService.GetIdentities(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "oidc.identities_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// UnlinkIdentityHandler отвязывает аккаунт провайдера
+func (h *OIDCHandler) UnlinkIdentityHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	identityID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "oidc.invalid_identity_id", nil)
+		return
+	}
+
+	err = h.oidcService.UnlinkIdentity(userID, uint(identityID))
+	switch {
+	case errors.Is(err, service.ErrIdentityNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	case errors.Is(err, service.ErrLastLoginMethod):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "oidc.unlink_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+const (
+	// oidcBindingCookie связывает вход через провайдера с браузером, в котором он начинался
+	oidcBindingCookie = "oidc_binding"
+	// Совпадает со временем жизни state
+	oidcBindingMaxAge = 10 * 60
+)
+
+// setBindingCookie сохраняет секрет привязки входа к браузеру. maxAge < 0 удаляет cookie.
+// Чужой code и state, подброшенные пользователю, не подойдут к секрету из его браузера.
+func setBindingCookie(c *gin.Context, binding string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcBindingCookie,
+		Value:    binding,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordHandler предоставляет обработчики для восстановления пароля
+type PasswordHandler struct {
+	passwordResetService service.PasswordResetServiceInterface
+	auditService         service.AuditServiceInterface
+}
+
+// NewPasswordHandler создает новый экземпляр PasswordHandler
+func NewPasswordHandler(passwordResetService service.PasswordResetServiceInterface, auditService service.AuditServiceInterface) *PasswordHandler {
+	return &PasswordHandler{
+		passwordResetService: passwordResetService,
+		auditService:         auditService,
+	}
+}
+
+// ForgotPasswordHandler запрашивает письмо для сброса пароля.
+// Ответ всегда одинаковый, чтобы по нему нельзя было узнать, зарегистрирован ли адрес.
+func (h *PasswordHandler) ForgotPasswordHandler(c *gin.Context) {
+	var requestBody struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.passwordResetService.RequestPasswordReset(requestBody.Email)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": translate(c, "password.reset_sent", nil, "password.reset_sent")})
+}
+
+// ResetPasswordHandler устанавливает новый пароль по токену из письма
+func (h *PasswordHandler) ResetPasswordHandler(c *gin.Context) {
+	var requestBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	userID, err := h.passwordResetService.ResetPassword(requestBody.Token, requestBody.Password)
+	if errors.Is(err, service.ErrInvalidResetToken) {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	}
+	if respondValidationError(c, err) {
+		return
+	}
+	if userID != 0 {
+		recordAudit(c, h.auditService, models.AuditEvent{Type: models.AuditPasswordReset, ActorID: &userID, TargetID: &userID})
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "password.reset_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
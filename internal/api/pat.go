@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenHandler предоставляет обработчики для управления персональными токенами
+type PersonalAccessTokenHandler struct {
+	patService service.PersonalAccessTokenServiceInterface
+}
+
+// NewPersonalAccessTokenHandler создает новый экземпляр PersonalAccessTokenHandler
+func NewPersonalAccessTokenHandler(patService service.PersonalAccessTokenServiceInterface) *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{patService: patService}
+}
+
+// CreateTokenHandler выпускает персональный токен. Токен возвращается только в этом ответе.
+func (h *PersonalAccessTokenHandler) CreateTokenHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expiresInDays"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ttl := time.Duration(requestBody.ExpiresInDays) * time.Hour * 24
+	token, pat, err := h.patService.CreateToken(userID, requestBody.Name, requestBody.Scopes, ttl)
+	switch {
+	case errors.Is(err, service.ErrInvalidPersonalAccessTokenRequest):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrTooManyPersonalAccessTokens):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "pat.create_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, struct {
+		models.PersonalAccessToken
+		Token string `json:"token"`
+	}{pat, token})
+}
+
+// GetTokensHandler возвращает персональные токены пользователя без самих токенов
+func (h *PersonalAccessTokenHandler) GetTokensHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	tokens, err := h.patService.GetTokens(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "pat.list_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeTokenHandler отзывает персональный токен
+func (h *PersonalAccessTokenHandler) RevokeTokenHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "pat.invalid_id", nil)
+		return
+	}
+
+	err = h.patService.RevokeToken(userID, uint(tokenID))
+	if errors.Is(err, service.ErrPersonalAccessTokenNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "pat.revoke_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// PhoneVerificationHandler предоставляет обработчики для подтверждения номера телефона
+type PhoneVerificationHandler struct {
+	phoneService service.PhoneVerificationServiceInterface
+	log          logger.LoggerInterface
+}
+
+// NewPhoneVerificationHandler создает новый экземпляр PhoneVerificationHandler
+func NewPhoneVerificationHandler(phoneService service.PhoneVerificationServiceInterface) *PhoneVerificationHandler {
+	return &PhoneVerificationHandler{
+		phoneService: phoneService,
+		log:          logger.GetLogger(),
+	}
+}
+
+// GetPhoneStatusHandler возвращает номер текущего пользователя и состояние его подтверждения
+func (h *PhoneVerificationHandler) GetPhoneStatusHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	status, err := h.phoneService.GetStatus(userID)
+	if errors.Is(err, service.ErrPhoneMissing) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "phone.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SendCodeHandler отправляет код подтверждения в SMS на номер текущего пользователя
+func (h *PhoneVerificationHandler) SendCodeHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := h.phoneService.SendCode(userID, c.ClientIP())
+	var retryErr *service.RetryAfterError
+	switch {
+	case errors.As(err, &retryErr):
+		respondRetryAfter(c, http.StatusTooManyRequests, retryErr.RetryAfter, retryErr)
+		return
+	case errors.Is(err, service.ErrPhoneMissing):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrPhoneAlreadyVerified):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		h.log.Error("Failed to send phone verification code: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "phone.send_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// ConfirmCodeHandler подтверждает номер текущего пользователя кодом из SMS
+func (h *PhoneVerificationHandler) ConfirmCodeHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var requestBody struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.phoneService.ConfirmCode(userID, requestBody.Code)
+	switch {
+	case errors.Is(err, service.ErrInvalidPhoneCode):
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	case errors.Is(err, service.ErrPhoneCodeAttemptsExceeded):
+		respondServiceError(c, http.StatusTooManyRequests, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "phone.confirm_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
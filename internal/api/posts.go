@@ -1 +1,153 @@
-package api
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Размер страницы списков постов
+const (
+	defaultPostsLimit = 20
+	maxPostsLimit     = 100
+)
+
+// PostHandler предоставляет обработчики для работы с постами
+type PostHandler struct {
+	postService service.PostServiceInterface
+	log         logger.LoggerInterface
+}
+
+// NewPostHandler создает новый экземпляр PostHandler
+func NewPostHandler(postService service.PostServiceInterface) *PostHandler {
+	return &PostHandler{
+		postService: postService,
+		log:         logger.GetLogger(),
+	}
+}
+
+// CreatePostHandler публикует пост от имени текущего пользователя
+func (h *PostHandler) CreatePostHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var post models.NewPost
+	if err := c.ShouldBindJSON(&post); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	created, err := h.postService.CreatePost(userID, post)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to create post: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "posts.create_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetPostHandler возвращает пост по id, если он открыт текущему пользователю
+func (h *PostHandler) GetPostHandler(c *gin.Context) {
+	// Без токена пост видно так, как его видит посторонний
+	viewerID, _ := currentUserID(c)
+
+	postID, ok := postIDParam(c)
+	if !ok {
+		return
+	}
+
+	post, err := h.postService.GetPost(viewerID, postID)
+	if respondPostError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to get post: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "posts.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// GetUserPostsHandler возвращает посты пользователя по логину, если они открыты текущему пользователю.
+// Параметры запроса: limit (по умолчанию 20, не больше 100) и offset.
+func (h *PostHandler) GetUserPostsHandler(c *gin.Context) {
+	viewerID, _ := currentUserID(c)
+
+	limit, offset, ok := pagination(c, defaultPostsLimit, maxPostsLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postService.GetUserPosts(viewerID, c.Param("login"), limit, offset)
+	if respondPostError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to get posts: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "posts.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// GetFeedHandler возвращает ленту текущего пользователя.
+// Параметры запроса: limit (по умолчанию 20, не больше 100) и offset.
+func (h *PostHandler) GetFeedHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	limit, offset, ok := pagination(c, defaultPostsLimit, maxPostsLimit)
+	if !ok {
+		return
+	}
+
+	posts, err := h.postService.GetFeed(userID, limit, offset)
+	if err != nil {
+		h.log.Error("Failed to get feed: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "posts.feed_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// postIDParam читает id поста из пути. При ошибке ответ уже отправлен.
+func postIDParam(c *gin.Context) (int, bool) {
+	postID, err := strconv.Atoi(c.Param("postId"))
+	if err != nil || postID < 1 {
+		respondError(c, http.StatusBadRequest, "posts.invalid_id", nil)
+		return 0, false
+	}
+	return postID, true
+}
+
+// respondPostError отвечает на ошибки доступа к посту или автору и сообщает, был ли отправлен ответ
+func respondPostError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrPostNotFound), errors.Is(err, database.ErrUserNotFound):
+		respondServiceError(c, http.StatusNotFound, err)
+		return true
+	case errors.Is(err, service.ErrHiddenByPrivacy):
+		respondServiceError(c, http.StatusForbidden, err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// PrivacyHandler предоставляет обработчики для настроек приватности
+type PrivacyHandler struct {
+	privacyService service.PrivacyServiceInterface
+	log            logger.LoggerInterface
+}
+
+// NewPrivacyHandler создает новый экземпляр PrivacyHandler
+func NewPrivacyHandler(privacyService service.PrivacyServiceInterface) *PrivacyHandler {
+	return &PrivacyHandler{
+		privacyService: privacyService,
+		log:            logger.GetLogger(),
+	}
+}
+
+// GetPrivacyHandler возвращает настройки приватности текущего пользователя
+func (h *PrivacyHandler) GetPrivacyHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	settings, err := h.privacyService.GetSettings(userID)
+	if err != nil {
+		h.log.Error("Failed to get privacy settings: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "privacy.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdatePrivacyHandler изменяет переданные настройки приватности текущего пользователя.
+// Значения: everyone, friends или only_me.
+func (h *PrivacyHandler) UpdatePrivacyHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var update models.PrivacyUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	settings, err := h.privacyService.UpdateSettings(userID, update)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to update privacy settings: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "privacy.update_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -1 +1,113 @@
-package api
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler предоставляет обработчики для просмотра и изменения профилей
+type ProfileHandler struct {
+	profileService service.ProfileServiceInterface
+	log            logger.LoggerInterface
+}
+
+// NewProfileHandler создает новый экземпляр ProfileHandler
+func NewProfileHandler(profileService service.ProfileServiceInterface) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+		log:            logger.GetLogger(),
+	}
+}
+
+// GetUserProfileHandler возвращает профиль пользователя по логину с учетом его настроек приватности
+func (h *ProfileHandler) GetUserProfileHandler(c *gin.Context) {
+	// Без токена профиль видно так, как его видит посторонний
+	viewerID, _ := currentUserID(c)
+
+	profile, err := h.profileService.GetProfileByLogin(viewerID, c.Param("login"))
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondServiceError(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to get profile: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "profile.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetMyProfileHandler возвращает профиль текущего пользователя
+func (h *ProfileHandler) GetMyProfileHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	profile, err := h.profileService.GetProfile(userID)
+	if err != nil {
+		h.log.Error("Failed to get profile: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "profile.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// PreviewMyProfileHandler показывает профиль текущего пользователя таким, каким его видит
+// друг (?as=friend) или посторонний (?as=stranger)
+func (h *ProfileHandler) PreviewMyProfileHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	viewer := c.DefaultQuery("as", models.ViewerStranger)
+	profile, err := h.profileService.PreviewProfile(userID, viewer)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to preview profile: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "profile.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"as": viewer, "profile": profile})
+}
+
+// UpdateMyProfileHandler изменяет переданные поля профиля текущего пользователя
+func (h *ProfileHandler) UpdateMyProfileHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	var update models.ProfileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	profile, err := h.profileService.UpdateProfile(userID, update)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to update profile: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "profile.update_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -1 +1,89 @@
-package api
+package api
+
+import (
+	"net/http"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Размер страницы списка реакций
+const (
+	defaultReactionsLimit = 50
+	maxReactionsLimit     = 100
+)
+
+// LikePostHandler ставит лайк посту от имени текущего пользователя и возвращает пост с новыми счетчиками
+func (h *PostHandler) LikePostHandler(c *gin.Context) {
+	h.react(c, true)
+}
+
+// DislikePostHandler ставит дизлайк посту от имени текущего пользователя и возвращает пост с новыми счетчиками
+func (h *PostHandler) DislikePostHandler(c *gin.Context) {
+	h.react(c, false)
+}
+
+func (h *PostHandler) react(c *gin.Context, like bool) {
+	h.changeReaction(c, "reactions.set_failed", func(userID uint, postID int) (models.Post, error) {
+		return h.postService.React(userID, postID, like)
+	})
+}
+
+// RemoveReactionHandler убирает реакцию текущего пользователя на пост
+func (h *PostHandler) RemoveReactionHandler(c *gin.Context) {
+	h.changeReaction(c, "reactions.remove_failed", func(userID uint, postID int) (models.Post, error) {
+		return h.postService.RemoveReaction(userID, postID)
+	})
+}
+
+func (h *PostHandler) changeReaction(c *gin.Context, failCode string, change func(userID uint, postID int) (models.Post, error)) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	postID, ok := postIDParam(c)
+	if !ok {
+		return
+	}
+
+	post, err := change(userID, postID)
+	if respondPostError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to change reaction: " + err.Error())
+		respondError(c, http.StatusInternalServerError, failCode, nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// GetReactionsHandler возвращает реакции на пост без реакций заблокированных и заглушенных пользователей.
+// Параметры запроса: limit (по умолчанию 50, не больше 100) и offset.
+func (h *PostHandler) GetReactionsHandler(c *gin.Context) {
+	viewerID, _ := currentUserID(c)
+
+	postID, ok := postIDParam(c)
+	if !ok {
+		return
+	}
+	limit, offset, ok := pagination(c, defaultReactionsLimit, maxReactionsLimit)
+	if !ok {
+		return
+	}
+
+	reactions, err := h.postService.GetReactions(viewerID, postID, limit, offset)
+	if respondPostError(c, err) {
+		return
+	}
+	if err != nil {
+		h.log.Error("Failed to get reactions: " + err.Error())
+		respondError(c, http.StatusInternalServerError, "reactions.get_failed", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, reactions)
+}
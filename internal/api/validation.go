@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// uniqueFields сопоставляет нарушения уникальности с полями запроса
+var uniqueFields = []struct {
+	err   error
+	field string
+}{
+	{database.ErrLoginTaken, "login"},
+	{database.ErrEmailTaken, "email"},
+	{database.ErrPhoneTaken, "phone"},
+}
+
+// respondValidationError отвечает 400 со списком ошибок полей или 409, если логин, почта или телефон заняты.
+// Сообщения переводятся на язык клиента. Возвращает false, если err не относится к проверке данных
+// и ответ не отправлен.
+func respondValidationError(c *gin.Context, err error) bool {
+	var validationErr *utils.ValidationError
+	if errors.As(err, &validationErr) {
+		respondFields(c, http.StatusBadRequest, validationErr.Fields)
+		return true
+	}
+
+	for _, unique := range uniqueFields {
+		if errors.Is(err, unique.err) {
+			respondConflict(c, unique.field, unique.field+".taken", unique.err.Error())
+			return true
+		}
+	}
+	return false
+}
+
+// respondConflict отвечает 409 с ошибкой поля <field>.taken. code - код сообщения для поля error.
+func respondConflict(c *gin.Context, field, code, fallback string) {
+	fields := utils.NewFieldError(field, "taken", fallback, nil).Fields
+	message := translate(c, code, nil, fallback)
+	fields[0].Message = translate(c, fields[0].Code, nil, fallback)
+	c.JSON(http.StatusConflict, gin.H{"error": message, "code": code, "fields": fields})
+}
+
+// respondBindError отвечает 400, если тело или параметры запроса не удалось разобрать
+func respondBindError(c *gin.Context, err error) {
+	validationErr := &utils.ValidationError{}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		validationErr.Add(typeErr.Field, "invalid_type", fmt.Sprintf("%s must be of type %s", typeErr.Field, typeErr.Type),
+			map[string]interface{}{"type": typeErr.Type.String()})
+	} else {
+		validationErr.Add("body", "malformed", err.Error(), nil)
+	}
+
+	respondFields(c, http.StatusBadRequest, validationErr.Fields)
+}
+
+// respondFields переводит сообщения ошибок полей и отвечает ими
+func respondFields(c *gin.Context, status int, fields []utils.FieldError) {
+	translated := make([]utils.FieldError, len(fields))
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		field.Message = translate(c, field.Code, field.Params, field.Message)
+		translated[i] = field
+		messages[i] = field.Message
+	}
+	c.JSON(status, gin.H{"error": strings.Join(messages, "; "), "fields": translated})
+}
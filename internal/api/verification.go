@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/service"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationHandler предоставляет обработчики для подтверждения почты
+type EmailVerificationHandler struct {
+	verificationService service.EmailVerificationServiceInterface
+	log                 logger.LoggerInterface
+}
+
+// NewEmailVerificationHandler создает новый экземпляр EmailVerificationHandler
+func NewEmailVerificationHandler(verificationService service.EmailVerificationServiceInterface) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		verificationService: verificationService,
+		log:                 logger.GetLogger(),
+	}
+}
+
+// ConfirmEmailHandler подтверждает почту по токену из письма
+func (h *EmailVerificationHandler) ConfirmEmailHandler(c *gin.Context) {
+	var requestBody struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	err := h.verificationService.ConfirmEmail(requestBody.Token)
+	if errors.Is(err, service.ErrInvalidVerificationToken) {
+		respondServiceError(c, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "email.confirm_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ResendVerificationHandler повторно отправляет письмо с подтверждением текущему пользователю
+func (h *EmailVerificationHandler) ResendVerificationHandler(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, "auth.unauthorized", nil)
+		return
+	}
+
+	err := h.verificationService.ResendVerificationEmail(userID)
+	var retryErr *service.RetryAfterError
+	switch {
+	case errors.As(err, &retryErr):
+		respondRetryAfter(c, http.StatusTooManyRequests, retryErr.RetryAfter, retryErr)
+		return
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		respondServiceError(c, http.StatusConflict, err)
+		return
+	case err != nil:
+		respondError(c, http.StatusInternalServerError, "email.send_failed", nil)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+// respondRetryAfter отвечает ошибкой с заголовком Retry-After
+func respondRetryAfter(c *gin.Context, status int, retryAfter time.Duration, err error) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	respondServiceError(c, status, err)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// LoginAttemptRepositoryInterface определяет методы для учета неудачных попыток входа
+type LoginAttemptRepositoryInterface interface {
+	GetAttempts(key string) (models.LoginAttempts, error)
+	RecordFailure(key string, at time.Time, windowStart time.Time) (models.LoginAttempts, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// LoginAttemptRepository хранит попытки входа в Postgres, счетчики общие для всех экземпляров сервиса
+type LoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+// NewLoginAttemptRepository создает новый экземпляр LoginAttemptRepository
+func NewLoginAttemptRepository(db *sqlx.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+// GetAttempts возвращает счетчик по ключу или пустой счетчик, если неудачных попыток не было
+func (r *LoginAttemptRepository) GetAttempts(key string) (models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	err := r.db.Get(&attempts, "SELECT * FROM login_attempts WHERE key = $1", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LoginAttempts{Key: key}, nil
+	}
+	if err != nil {
+		return models.LoginAttempts{}, fmt.Errorf("failed to get login attempts: %v", err)
+	}
+	return attempts, nil
+}
+
+// RecordFailure увеличивает счетчик неудач. Если последняя неудача была раньше windowStart, счет начинается заново.
+func (r *LoginAttemptRepository) RecordFailure(key string, at time.Time, windowStart time.Time) (models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at, locked_until) VALUES ($1, 1, $2, 'epoch')
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING *
+	`
+	if err := r.db.Get(&attempts, query, key, at, windowStart); err != nil {
+		return models.LoginAttempts{}, fmt.Errorf("failed to record login failure: %v", err)
+	}
+	return attempts, nil
+}
+
+// Lock запрещает вход по ключу до указанного времени
+func (r *LoginAttemptRepository) Lock(key string, until time.Time) error {
+	if _, err := r.db.Exec("UPDATE login_attempts SET locked_until = $2 WHERE key = $1", key, until); err != nil {
+		return fmt.Errorf("failed to lock login: %v", err)
+	}
+	return nil
+}
+
+// Reset сбрасывает счетчик и блокировку
+func (r *LoginAttemptRepository) Reset(key string) error {
+	if _, err := r.db.Exec("DELETE FROM login_attempts WHERE key = $1", key); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %v", err)
+	}
+	return nil
+}
+
+const memoryEvictEvery = 100
+
+// MemoryLoginAttemptRepository хранит попытки входа в памяти процесса для запуска в одном экземпляре
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+	writes   int
+}
+
+// NewMemoryLoginAttemptRepository создает новый экземпляр MemoryLoginAttemptRepository
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: make(map[string]models.LoginAttempts)}
+}
+
+func (r *MemoryLoginAttemptRepository) GetAttempts(key string) (models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if !ok {
+		return models.LoginAttempts{Key: key}, nil
+	}
+	return attempts, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(key string, at time.Time, windowStart time.Time) (models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := r.attempts[key]
+	attempts.Key = key
+	if attempts.LastFailureAt.Before(windowStart) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.LastFailureAt = at
+	r.attempts[key] = attempts
+
+	// Очистка выполняется периодически, а не на каждой записи
+	r.writes++
+	if r.writes%memoryEvictEvery == 0 {
+		r.evictStale(windowStart)
+	}
+
+	return attempts, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := r.attempts[key]
+	attempts.Key = key
+	attempts.LockedUntil = until
+	r.attempts[key] = attempts
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Reset(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+// evictStale удаляет счетчики, которые уже не влияют на вход, чтобы карта не росла бесконечно
+func (r *MemoryLoginAttemptRepository) evictStale(windowStart time.Time) {
+	now := time.Now()
+	for key, attempts := range r.attempts {
+		if attempts.LastFailureAt.Before(windowStart) && attempts.LockedUntil.Before(now) {
+			delete(r.attempts, key)
+		}
+	}
+}
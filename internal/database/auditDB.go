@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepositoryInterface определяет методы для работы с журналом безопасности
+type AuditRepositoryInterface interface {
+	CreateEvent(event models.AuditEvent) error
+	GetEvents(filter models.AuditFilter) ([]models.AuditEvent, error)
+	EachEvent(filter models.AuditFilter, fn func(models.AuditEvent) error) error
+}
+
+// AuditRepository предоставляет реализацию AuditRepositoryInterface
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+// NewAuditRepository создает новый экземпляр AuditRepository
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// CreateEvent добавляет событие в журнал
+func (r *AuditRepository) CreateEvent(event models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (occurred_at, type, outcome, actor_id, target_id, ip, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query, event.OccurredAt, event.Type, event.Outcome, event.ActorID, event.TargetID,
+		event.IP, event.UserAgent, event.Details)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %v", err)
+	}
+	return nil
+}
+
+// GetEvents возвращает события по фильтру, новые первыми
+func (r *AuditRepository) GetEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	where, args := auditConditions(filter)
+	query := "SELECT * FROM audit_events" + where + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	events := []models.AuditEvent{}
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %v", err)
+	}
+	return events, nil
+}
+
+// EachEvent передает события по фильтру в fn по одному, не загружая всю выборку в память.
+// Используется для выгрузки журнала.
+func (r *AuditRepository) EachEvent(filter models.AuditFilter, fn func(models.AuditEvent) error) error {
+	where, args := auditConditions(filter)
+	query := "SELECT * FROM audit_events" + where + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.Queryx(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get audit events: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.StructScan(&event); err != nil {
+			return fmt.Errorf("failed to read audit event: %v", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// auditConditions собирает условие WHERE по заполненным полям фильтра
+func auditConditions(filter models.AuditFilter) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if filter.IP != "" {
+		add("ip = $%d", filter.IP)
+	}
+	if filter.ActorID != nil {
+		add("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.TargetID != nil {
+		add("target_id = $%d", *filter.TargetID)
+	}
+	if filter.UserID != nil {
+		add("(actor_id = $%[1]d OR target_id = $%[1]d)", *filter.UserID)
+	}
+	if !filter.From.IsZero() {
+		add("occurred_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("occurred_at < $%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		add("id < $%d", filter.BeforeID)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
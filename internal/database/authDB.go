@@ -1,92 +1,290 @@
-package database
-
-import (
-	"fmt"
-
-	"github.com/Saveliy12/prod2/internal/models"
-	"github.com/jmoiron/sqlx"
-)
-
-// UserRepositoryInterface определяет методы для работы с пользователями в базе данных
-type UserRepositoryInterface interface {
-	CreateUser(user models.RegistrationUser) (models.User, error)
-	IsUnique(login, email, phone string) error
-	GetUserByLogin(login string) (models.User, error)
-	SetSession(userID uint, session models.Session) error
-}
-
-// UserRepository предоставляет реализацию UserRepositoryInterface
-type UserRepository struct {
-	db *sqlx.DB
-}
-
-// NewUserRepository создает новый экземпляр UserRepository
-func NewUserRepository(db *sqlx.DB) *UserRepository {
-	return &UserRepository{db: db}
-}
-
-// CreateUser добавляет нового пользователя в базу данных
-func (s *UserRepository) CreateUser(user models.RegistrationUser) (models.User, error) {
-	query := `
-        INSERT INTO users (login, email, phone, password, createdat)
-        VALUES ($1, $2, $3, $4, $5)
-        RETURNING id, login, email, phone
-    `
-
-	var newUser models.User
-	err := s.db.QueryRow(query, user.Login, user.Email, user.Phone, user.Password, user.CreatedAt).Scan(
-		&newUser.ID, &newUser.Login, &newUser.Email, &newUser.Phone,
-	)
-	if err != nil {
-		return models.User{}, err
-	}
-
-	return newUser, nil
-}
-
-// IsUnique проверяет, что указанные значения login, email и phone уникальны в базе данных
-func (s *UserRepository) IsUnique(login, email, phone string) error {
-	// Combining the queries into a single query
-	query := `
-		SELECT 
-			(SELECT COUNT(*) FROM users WHERE login = $1) AS loginCount,
-			(SELECT COUNT(*) FROM users WHERE email = $2) AS emailCount,
-			(SELECT COUNT(*) FROM users WHERE phone = $3) AS phoneCount
-	`
-	var counts struct {
-		LoginCount int `db:"loginCount"`
-		EmailCount int `db:"emailCount"`
-		PhoneCount int `db:"phoneCount"`
-	}
-
-	if err := s.db.Get(&counts, query, login, email, phone); err != nil {
-		return fmt.Errorf("failed to check uniqueness in the database: %v", err)
-	}
-
-	if counts.LoginCount > 0 {
-		return fmt.Errorf("login already exists")
-	}
-	if counts.EmailCount > 0 {
-		return fmt.Errorf("email already exists")
-	}
-	if counts.PhoneCount > 0 {
-		return fmt.Errorf("phone number already exists")
-	}
-
-	return nil
-}
-
-func (s *UserRepository) GetUserByLogin(login string) (models.User, error) {
-	var user models.User
-	query := "SELECT id, login, email, phone, password FROM users WHERE login = $1"
-	err := s.db.Get(&user, query, login)
-	if err != nil {
-		return models.User{}, fmt.Errorf("failed to get user by login: %v", err)
-	}
-	return user, nil
-}
-
-func (s *UserRepository) SetSession(userID uint, session models.Session) error {
-	_, err := s.db.Exec("UPDATE sessions SET refresh_token = ?, expires_at = ? WHERE userID = ?", session.RefreshToken, session.ExpiresAt, userID)
-	return err
-}
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// UserRepositoryInterface определяет методы для работы с пользователями в базе данных
+type UserRepositoryInterface interface {
+	CreateUser(user models.RegistrationUser) (models.User, error)
+	GetUserByLogin(login string) (models.User, error)
+	GetUserByID(userID uint) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	GetUserByPhone(phone string) (models.User, error)
+	UpdatePassword(userID uint, passwordHash string) error
+	SetEmailVerified(userID uint) error
+	SetPhoneVerified(userID uint) error
+	SetLocale(userID uint, locale string) error
+	CreateSession(session models.Session) (models.Session, error)
+	GetSessionByTokenHash(tokenHash string) (models.Session, error)
+	RotateSession(sessionID uint, next models.Session) (models.Session, error)
+	RevokeSessionFamily(familyID string) error
+	RevokeDeviceSessions(userID uint, deviceID string) error
+	RevokeUserSession(userID, sessionID uint) error
+	RevokeUserSessions(userID uint) error
+	GetActiveSessions(userID uint) ([]models.Session, error)
+}
+
+// ErrUserNotFound возвращается, если пользователь не найден
+var ErrUserNotFound = errors.New("user not found")
+
+// Ошибки нарушения уникальности при создании пользователя
+var (
+	ErrLoginTaken = errors.New("login already exists")
+	ErrEmailTaken = errors.New("email already exists")
+	ErrPhoneTaken = errors.New("phone number already exists")
+)
+
+// userColumns - столбцы, из которых заполняется models.User.
+// Телефон и пароль могут отсутствовать у пользователей, зарегистрированных через внешнего провайдера.
+const userColumns = "id, login, email, COALESCE(phone, '') AS phone, COALESCE(password, '') AS password, email_verified, phone_verified, role, COALESCE(locale, '') AS locale"
+
+// uniqueViolations сопоставляет уникальные индексы таблицы users с ошибками
+var uniqueViolations = map[string]error{
+	"users_login_key": ErrLoginTaken,
+	"users_email_key": ErrEmailTaken,
+	"users_phone_key": ErrPhoneTaken,
+}
+
+// ErrSessionNotFound возвращается, если сессия с указанным токеном не найдена
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionAlreadyRotated возвращается, если сессия уже была обменяна на новую или отозвана
+var ErrSessionAlreadyRotated = errors.New("session already rotated")
+
+// UserRepository предоставляет реализацию UserRepositoryInterface
+type UserRepository struct {
+	db *sqlx.DB
+}
+
+// NewUserRepository создает новый экземпляр UserRepository
+func NewUserRepository(db *sqlx.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// CreateUser добавляет нового пользователя в базу данных.
+// Уникальность логина, email и телефона гарантируют индексы базы данных,
+// при нарушении возвращаются ErrLoginTaken, ErrEmailTaken или ErrPhoneTaken.
+func (s *UserRepository) CreateUser(user models.RegistrationUser) (models.User, error) {
+	query := `
+        INSERT INTO users (login, email, phone, password, createdat)
+        VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+        RETURNING id, login, email, COALESCE(phone, ''), email_verified, role
+    `
+
+	var newUser models.User
+	err := s.db.QueryRow(query, user.Login, user.Email, user.Phone, user.Password, user.CreatedAt).Scan(
+		&newUser.ID, &newUser.Login, &newUser.Email, &newUser.Phone, &newUser.EmailVerified, &newUser.Role,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			if uniqueErr, ok := uniqueViolations[pqErr.Constraint]; ok {
+				return models.User{}, uniqueErr
+			}
+		}
+		return models.User{}, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	return newUser, nil
+}
+
+// GetUserByLogin ищет пользователя по логину без учета регистра
+func (s *UserRepository) GetUserByLogin(login string) (models.User, error) {
+	return s.getUser("login", "SELECT "+userColumns+" FROM users WHERE lower(login) = lower($1)", login)
+}
+
+func (s *UserRepository) GetUserByID(userID uint) (models.User, error) {
+	return s.getUser("id", "SELECT "+userColumns+" FROM users WHERE id = $1", userID)
+}
+
+// GetUserByEmail ищет пользователя по нормализованному email
+func (s *UserRepository) GetUserByEmail(email string) (models.User, error) {
+	return s.getUser("email", "SELECT "+userColumns+" FROM users WHERE email = $1", email)
+}
+
+// GetUserByPhone ищет пользователя по номеру телефона в формате E.164
+func (s *UserRepository) GetUserByPhone(phone string) (models.User, error) {
+	return s.getUser("phone", "SELECT "+userColumns+" FROM users WHERE phone = $1", phone)
+}
+
+func (s *UserRepository) getUser(by, query string, arg interface{}) (models.User, error) {
+	var user models.User
+	err := s.db.Get(&user, query, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user by %s: %v", by, err)
+	}
+	return user, nil
+}
+
+// UpdatePassword сохраняет новый хеш пароля пользователя
+func (s *UserRepository) UpdatePassword(userID uint, passwordHash string) error {
+	if _, err := s.db.Exec("UPDATE users SET password = $2 WHERE id = $1", userID, passwordHash); err != nil {
+		return fmt.Errorf("failed to update password: %v", err)
+	}
+	return nil
+}
+
+// SetEmailVerified отмечает почту пользователя как подтвержденную
+func (s *UserRepository) SetEmailVerified(userID uint) error {
+	if _, err := s.db.Exec("UPDATE users SET email_verified = TRUE WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to set email verified: %v", err)
+	}
+	return nil
+}
+
+// SetPhoneVerified отмечает телефон пользователя как подтвержденный
+func (s *UserRepository) SetPhoneVerified(userID uint) error {
+	if _, err := s.db.Exec("UPDATE users SET phone_verified = TRUE WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to set phone verified: %v", err)
+	}
+	return nil
+}
+
+// SetLocale сохраняет предпочитаемый язык пользователя, пустая строка сбрасывает выбор
+func (s *UserRepository) SetLocale(userID uint, locale string) error {
+	if _, err := s.db.Exec("UPDATE users SET locale = NULLIF($2, '') WHERE id = $1", userID, locale); err != nil {
+		return fmt.Errorf("failed to set locale: %v", err)
+	}
+	return nil
+}
+
+// CreateSession сохраняет новую refresh-сессию
+func (s *UserRepository) CreateSession(session models.Session) (models.Session, error) {
+	query := `
+		INSERT INTO sessions (user_id, family_id, token_hash, device_id, device_name, user_agent, ip, created_at, last_used_at, expires_at, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9, $10)
+		RETURNING id
+	`
+
+	err := s.db.QueryRow(query, session.UserID, session.FamilyID, session.TokenHash, session.DeviceID,
+		session.DeviceName, session.UserAgent, session.IP, session.CreatedAt, session.ExpiresAt, session.MFA).Scan(&session.ID)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	session.LastUsedAt = session.CreatedAt
+	return session, nil
+}
+
+// GetSessionByTokenHash возвращает сессию по хешу refresh-токена, включая уже обменянные и отозванные
+func (s *UserRepository) GetSessionByTokenHash(tokenHash string) (models.Session, error) {
+	var session models.Session
+	query := "SELECT * FROM sessions WHERE token_hash = $1"
+	err := s.db.Get(&session, query, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to get session: %v", err)
+	}
+	return session, nil
+}
+
+// RotateSession помечает сессию как обменянную и в той же транзакции создает следующую сессию семейства.
+// Если сессия уже была обменяна или отозвана, возвращает ErrSessionAlreadyRotated.
+func (s *UserRepository) RotateSession(sessionID uint, next models.Session) (models.Session, error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Условие на rotated_at/revoked_at делает обмен атомарным: из двух параллельных
+	// запросов с одним и тем же токеном успешным будет только один
+	res, err := tx.Exec(`
+		UPDATE sessions SET rotated_at = $2, last_used_at = $2
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, sessionID, next.CreatedAt)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to rotate session: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Session{}, ErrSessionAlreadyRotated
+	}
+
+	query := `
+		INSERT INTO sessions (user_id, family_id, token_hash, device_id, device_name, user_agent, ip, created_at, last_used_at, expires_at, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9, $10)
+		RETURNING id
+	`
+	err = tx.QueryRow(query, next.UserID, next.FamilyID, next.TokenHash, next.DeviceID,
+		next.DeviceName, next.UserAgent, next.IP, next.CreatedAt, next.ExpiresAt, next.MFA).Scan(&next.ID)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to create session: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Session{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	next.LastUsedAt = next.CreatedAt
+	return next, nil
+}
+
+// RevokeSessionFamily отзывает все сессии семейства
+func (s *UserRepository) RevokeSessionFamily(familyID string) error {
+	query := "UPDATE sessions SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL"
+	if _, err := s.db.Exec(query, familyID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke session family: %v", err)
+	}
+	return nil
+}
+
+// RevokeDeviceSessions отзывает все активные сессии пользователя на указанном устройстве
+func (s *UserRepository) RevokeDeviceSessions(userID uint, deviceID string) error {
+	query := "UPDATE sessions SET revoked_at = $3 WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL"
+	if _, err := s.db.Exec(query, userID, deviceID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke device sessions: %v", err)
+	}
+	return nil
+}
+
+// RevokeUserSession отзывает сессию пользователя вместе со всем ее семейством
+func (s *UserRepository) RevokeUserSession(userID, sessionID uint) error {
+	query := `
+		UPDATE sessions SET revoked_at = $3
+		WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM sessions WHERE id = $2 AND user_id = $1)
+	`
+	res, err := s.db.Exec(query, userID, sessionID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions отзывает все сессии пользователя на всех устройствах
+func (s *UserRepository) RevokeUserSessions(userID uint) error {
+	query := "UPDATE sessions SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL"
+	if _, err := s.db.Exec(query, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %v", err)
+	}
+	return nil
+}
+
+// GetActiveSessions возвращает действующие сессии пользователя, по одной на каждый вход
+func (s *UserRepository) GetActiveSessions(userID uint) ([]models.Session, error) {
+	sessions := []models.Session{}
+	query := `
+		SELECT * FROM sessions
+		WHERE user_id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`
+	if err := s.db.Select(&sessions, query, userID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %v", err)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrUserBlocked возвращается, если действие невозможно, потому что один пользователь заблокировал другого
+var ErrUserBlocked = errors.New("user is blocked")
+
+// BlockRepositoryInterface определяет методы для хранения блокировок и заглушений
+type BlockRepositoryInterface interface {
+	Block(blockerID, blockedID uint) error
+	Unblock(blockerID, blockedID uint) error
+	GetBlocked(blockerID uint, limit, offset int) ([]models.RestrictedUser, error)
+	IsBlocked(userID, otherID uint) (bool, error)
+	Mute(muterID, mutedID uint) error
+	Unmute(muterID, mutedID uint) error
+	GetMuted(muterID uint, limit, offset int) ([]models.RestrictedUser, error)
+}
+
+// hiddenUserCondition возвращает условие SQL, которое истинно для пользователя из столбца userColumn,
+// скрытого от зрителя с id из параметра viewerParam: один из них заблокировал другого, а при withMutes
+// еще и зритель заглушил пользователя. Все запросы, выдающие пользователей или их содержимое другим
+// пользователям, фильтруют строки этим условием.
+func hiddenUserCondition(userColumn, viewerParam string, withMutes bool) string {
+	condition := `EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = ` + viewerParam + ` AND b.blocked_id = ` + userColumn + `)
+			OR (b.blocker_id = ` + userColumn + ` AND b.blocked_id = ` + viewerParam + `)
+	)`
+	if withMutes {
+		condition += ` OR EXISTS (
+			SELECT 1 FROM user_mutes m WHERE m.muter_id = ` + viewerParam + ` AND m.muted_id = ` + userColumn + `
+		)`
+	}
+	return "(" + condition + ")"
+}
+
+// lockUserPair сериализует изменения связей двух пользователей до конца транзакции,
+// чтобы добавление в друзья не разминулось с параллельной блокировкой
+func lockUserPair(tx *sqlx.Tx, userID, otherID uint) error {
+	low, high := userID, otherID
+	if low > high {
+		low, high = high, low
+	}
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1::int, $2::int)", low, high)
+	return err
+}
+
+// BlockRepository предоставляет реализацию BlockRepositoryInterface
+type BlockRepository struct {
+	db *sqlx.DB
+}
+
+// NewBlockRepository создает новый экземпляр BlockRepository
+func NewBlockRepository(db *sqlx.DB) *BlockRepository {
+	return &BlockRepository{db: db}
+}
+
+// Block блокирует пользователя и удаляет дружбу между ними в обе стороны
+func (r *BlockRepository) Block(blockerID, blockedID uint) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUserPair(tx, blockerID, blockedID); err != nil {
+		return fmt.Errorf("failed to block user: %v", err)
+	}
+
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`
+	if _, err := tx.Exec(query, blockerID, blockedID); err != nil {
+		return fmt.Errorf("failed to block user: %v", err)
+	}
+
+	query = "DELETE FROM friends WHERE (userId = $1 AND friendId = $2) OR (userId = $2 AND friendId = $1)"
+	if _, err := tx.Exec(query, blockerID, blockedID); err != nil {
+		return fmt.Errorf("failed to remove friendship: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// Unblock снимает блокировку. Удаленная дружба не восстанавливается.
+func (r *BlockRepository) Unblock(blockerID, blockedID uint) error {
+	if _, err := r.db.Exec("DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerID, blockedID); err != nil {
+		return fmt.Errorf("failed to unblock user: %v", err)
+	}
+	return nil
+}
+
+// GetBlocked возвращает пользователей, заблокированных blockerID, недавно заблокированные первыми
+func (r *BlockRepository) GetBlocked(blockerID uint, limit, offset int) ([]models.RestrictedUser, error) {
+	return r.getRestricted("user_blocks", "blocker_id", "blocked_id", blockerID, limit, offset)
+}
+
+// IsBlocked проверяет, заблокировал ли один из пользователей другого
+func (r *BlockRepository) IsBlocked(userID, otherID uint) (bool, error) {
+	var blocked bool
+	query := "SELECT " + hiddenUserCondition("$2", "$1", false)
+	if err := r.db.Get(&blocked, query, userID, otherID); err != nil {
+		return false, fmt.Errorf("failed to check block: %v", err)
+	}
+	return blocked, nil
+}
+
+// Mute заглушает пользователя: его содержимое больше не показывается muterID
+func (r *BlockRepository) Mute(muterID, mutedID uint) error {
+	query := `
+		INSERT INTO user_mutes (muter_id, muted_id, created_at) VALUES ($1, $2, NOW())
+		ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, muterID, mutedID); err != nil {
+		return fmt.Errorf("failed to mute user: %v", err)
+	}
+	return nil
+}
+
+// Unmute снимает заглушение
+func (r *BlockRepository) Unmute(muterID, mutedID uint) error {
+	if _, err := r.db.Exec("DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2", muterID, mutedID); err != nil {
+		return fmt.Errorf("failed to unmute user: %v", err)
+	}
+	return nil
+}
+
+// GetMuted возвращает пользователей, заглушенных muterID, недавно заглушенные первыми
+func (r *BlockRepository) GetMuted(muterID uint, limit, offset int) ([]models.RestrictedUser, error) {
+	return r.getRestricted("user_mutes", "muter_id", "muted_id", muterID, limit, offset)
+}
+
+// getRestricted выбирает список из таблицы user_blocks или user_mutes. Имена таблиц и столбцов
+// передаются только константами из этого файла.
+func (r *BlockRepository) getRestricted(table, ownerColumn, targetColumn string, userID uint, limit, offset int) ([]models.RestrictedUser, error) {
+	query := `
+		SELECT u.login, COALESCE(p.display_name, '') AS display_name, p.avatar, t.created_at
+		FROM ` + table + ` t
+		JOIN users u ON u.id = t.` + targetColumn + `
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE t.` + ownerColumn + ` = $1
+		ORDER BY t.created_at DESC, u.id
+		LIMIT $2 OFFSET $3
+	`
+	users := []models.RestrictedUser{}
+	if err := r.db.Select(&users, query, userID, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", table, err)
+	}
+	return users, nil
+}
@@ -1,120 +1,644 @@
-package database
-
-import (
-	"log"
-
-	"github.com/jmoiron/sqlx"
-)
-
-// DB содержит экземпляр базы данных и методы для работы с ней
-type Repository struct {
-	db *sqlx.DB
-}
-
-// NewDB создает новый экземпляр DB
-func NewDB(db *sqlx.DB) *Repository {
-	return &Repository{
-		db: db,
-	}
-}
-
-// DropTables удаляет необходимые таблицы в базе данных
-func DropTables(db *sqlx.DB) {
-	tables := []string{
-		"reactions",
-		"friends",
-		"posts",
-		"users",
-		"session",
-	}
-
-	for _, table := range tables {
-		query := "DROP TABLE IF EXISTS " + table + " CASCADE;"
-		if _, err := db.Exec(query); err != nil {
-			log.Fatalf("Error dropping table %s: %v", table, err)
-		}
-		log.Printf("Table %s dropped successfully", table)
-	}
-}
-
-// CreateTables создает необходимые таблицы в базе данных
-func CreateTables(db *sqlx.DB) {
-
-	// Создание таблицы users
-	q := `
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			login TEXT,
-			email TEXT,
-			phone TEXT,
-			password TEXT,
-			createdAt TIMESTAMP
-		);
-	`
-
-	if _, err := db.Exec(q); err != nil {
-		log.Fatalf("Error creating users table: %v", err)
-	}
-
-	// Создание таблицы friends
-	q = `
-		CREATE TABLE IF NOT EXISTS friends (
-			userId INT,
-			friendId INT,
-			friendLogin TEXT,
-			addedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			PRIMARY KEY (userId, friendId),
-			FOREIGN KEY (userId) REFERENCES users(id),
-			FOREIGN KEY (friendId) REFERENCES users(id)
-		);
-	`
-
-	if _, err := db.Exec(q); err != nil {
-		log.Fatalf("Error creating friends table: %v", err)
-	}
-
-	// Создание таблицы posts
-	q = `
-		CREATE TABLE IF NOT EXISTS posts (
-			id SERIAL PRIMARY KEY,
-			content TEXT,
-			author TEXT,
-			tags TEXT[],
-			createdAt TIMESTAMP,
-			likeCount INT,
-			dislikeCount INT
-		);
-	`
-
-	if _, err := db.Exec(q); err != nil {
-		log.Fatalf("Error creating posts table: %v", err)
-	}
-
-	// Создание таблицы reactions
-	// type: 0 - dislike, 1 - like
-	q = `
-		CREATE TABLE IF NOT EXISTS reactions (
-			id SERIAL PRIMARY KEY,
-			userId INT,
-			postId INT,
-			reactionType INT CHECK (reactionType IN (0, 1)),
-			createdAt TIMESTAMP,
-			CONSTRAINT fk_user_post FOREIGN KEY (userId) REFERENCES users(id),
-			CONSTRAINT fk_post_reaction FOREIGN KEY (postId) REFERENCES posts(id)
-		);
-	`
-
-	if _, err := db.Exec(q); err != nil {
-		log.Fatalf("Error creating reactions table: %v", err)
-	}
-
-	// Создание таблицы sessions
-	q = `
-		CREATE TABLE sessions (
-			id SERIAL PRIMARY KEY,
-			refresh_token VARCHAR(255) NOT NULL,
-			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
-		);
-	`
-
-}
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// DB содержит экземпляр базы данных и методы для работы с ней
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewDB создает новый экземпляр DB
+func NewDB(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// DropTables удаляет необходимые таблицы в базе данных
+func DropTables(db *sqlx.DB) {
+	tables := []string{
+		"user_mutes",
+		"user_blocks",
+		"privacy_settings",
+		"media_objects",
+		"profiles",
+		"audit_events",
+		"phone_verification_codes",
+		"oidc_pending_signups",
+		"oidc_login_states",
+		"external_identities",
+		"oauth_refresh_tokens",
+		"oauth_authorization_codes",
+		"oauth_consents",
+		"oauth_clients",
+		"personal_access_tokens",
+		"user_permissions",
+		"login_attempts",
+		"mfa_recovery_codes",
+		"user_mfa",
+		"one_time_tokens",
+		"signing_keys",
+		"revoked_tokens",
+		"token_watermarks",
+		"sessions",
+		"reactions",
+		"friends",
+		"posts",
+		"users",
+	}
+
+	for _, table := range tables {
+		query := "DROP TABLE IF EXISTS " + table + " CASCADE;"
+		if _, err := db.Exec(query); err != nil {
+			log.Fatalf("Error dropping table %s: %v", table, err)
+		}
+		log.Printf("Table %s dropped successfully", table)
+	}
+}
+
+// CreateTables создает необходимые таблицы в базе данных
+func CreateTables(db *sqlx.DB) {
+
+	// Создание таблицы users
+	q := `
+		CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			login TEXT,
+			email TEXT,
+			phone TEXT,
+			password TEXT,
+			createdAt TIMESTAMP,
+			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			phone_verified BOOLEAN NOT NULL DEFAULT FALSE,
+			role TEXT NOT NULL DEFAULT 'user',
+			locale TEXT
+		);
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT;
+		UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating users table: %v", err)
+	}
+
+	normalizeUserPhones(db)
+	createUserUniqueIndex(db, "users_login_key", "lower(login)")
+	createUserUniqueIndex(db, "users_email_key", "email")
+	createUserUniqueIndex(db, "users_phone_key", "phone")
+
+	// Создание таблицы friends
+	q = `
+		CREATE TABLE IF NOT EXISTS friends (
+			userId INT,
+			friendId INT,
+			friendLogin TEXT,
+			addedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (userId, friendId),
+			FOREIGN KEY (userId) REFERENCES users(id),
+			FOREIGN KEY (friendId) REFERENCES users(id)
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating friends table: %v", err)
+	}
+
+	// Создание таблицы posts
+	q = `
+		CREATE TABLE IF NOT EXISTS posts (
+			id SERIAL PRIMARY KEY,
+			content TEXT,
+			author TEXT,
+			tags TEXT[],
+			createdAt TIMESTAMP,
+			likeCount INT,
+			dislikeCount INT
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating posts table: %v", err)
+	}
+
+	// Автор поста хранится и по id: по нему посты связываются с пользователями, блокировками и заглушениями
+	q = `
+		ALTER TABLE posts ADD COLUMN IF NOT EXISTS author_id INT REFERENCES users(id) ON DELETE CASCADE;
+		UPDATE posts p SET author_id = u.id FROM users u WHERE p.author_id IS NULL AND lower(u.login) = lower(p.author);
+		CREATE INDEX IF NOT EXISTS posts_author_idx ON posts (author_id, createdAt DESC);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error altering posts table: %v", err)
+	}
+
+	// Создание таблицы reactions
+	// type: 0 - dislike, 1 - like
+	q = `
+		CREATE TABLE IF NOT EXISTS reactions (
+			id SERIAL PRIMARY KEY,
+			userId INT,
+			postId INT,
+			reactionType INT CHECK (reactionType IN (0, 1)),
+			createdAt TIMESTAMP,
+			CONSTRAINT fk_user_post FOREIGN KEY (userId) REFERENCES users(id),
+			CONSTRAINT fk_post_reaction FOREIGN KEY (postId) REFERENCES posts(id)
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating reactions table: %v", err)
+	}
+
+	// У пользователя на пост одна реакция. Из повторов остается последняя.
+	q = `
+		DELETE FROM reactions a USING reactions b
+		WHERE a.userId = b.userId AND a.postId = b.postId AND a.id < b.id;
+		CREATE UNIQUE INDEX IF NOT EXISTS reactions_user_post_idx ON reactions (userId, postId);
+		CREATE INDEX IF NOT EXISTS reactions_post_idx ON reactions (postId, createdAt DESC);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error altering reactions table: %v", err)
+	}
+
+	// Создание таблицы sessions
+	// token_hash - sha256 от refresh-токена, сам токен не хранится
+	// rotated_at - время, когда токен был обменян на новый (повторное использование = кража)
+	q = `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			device_id TEXT NOT NULL DEFAULT '',
+			device_name TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			rotated_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE INDEX IF NOT EXISTS sessions_user_device_idx ON sessions (user_id, device_id);
+		CREATE INDEX IF NOT EXISTS sessions_family_idx ON sessions (family_id);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating sessions table: %v", err)
+	}
+
+	// mfa - вход в сессию подтвержден вторым фактором
+	q = `
+		ALTER TABLE sessions ADD COLUMN IF NOT EXISTS mfa BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error altering sessions table: %v", err)
+	}
+
+	// Создание таблиц отзыва access-токенов
+	// revoked_tokens - отозванные по jti токены, хранятся до истечения их срока действия
+	// token_watermarks - все токены пользователя, выпущенные не позже revoked_before, недействительны
+	q = `
+		CREATE TABLE IF NOT EXISTS revoked_tokens (
+			token_id TEXT PRIMARY KEY,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS token_watermarks (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			revoked_before TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating token revocation tables: %v", err)
+	}
+
+	// Создание таблицы signing_keys
+	// Ключи подписи access-токенов, общие для всех экземпляров сервиса. Ключ подписывает токены с activates_at
+	// до вступления в действие следующего ключа и проверяет их, пока не истекут подписанные им токены.
+	q = `
+		CREATE TABLE IF NOT EXISTS signing_keys (
+			kid TEXT PRIMARY KEY,
+			private_key TEXT NOT NULL,
+			activates_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating signing_keys table: %v", err)
+	}
+
+	// Создание таблицы one_time_tokens
+	// Одноразовые токены из писем (подтверждение почты и т.п.), хранится только хеш
+	q = `
+		CREATE TABLE IF NOT EXISTS one_time_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			code_challenge TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+		ALTER TABLE one_time_tokens ADD COLUMN IF NOT EXISTS code_challenge TEXT NOT NULL DEFAULT '';
+		CREATE INDEX IF NOT EXISTS one_time_tokens_user_purpose_idx ON one_time_tokens (user_id, purpose, created_at);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating one_time_tokens table: %v", err)
+	}
+
+	// Создание таблиц двухфакторной аутентификации
+	// last_used_step - последний принятый шаг TOTP, защищает от повторного использования кода
+	q = `
+		CREATE TABLE IF NOT EXISTS user_mfa (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			secret TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT FALSE,
+			last_used_step BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			confirmed_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE INDEX IF NOT EXISTS mfa_recovery_codes_user_idx ON mfa_recovery_codes (user_id);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating mfa tables: %v", err)
+	}
+
+	// Создание таблицы login_attempts
+	// key - "user:<id>" для существующего пользователя, "login:<идентификатор>" для неизвестного или "ip:<адрес>"
+	q = `
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			key TEXT PRIMARY KEY,
+			failures INT NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			locked_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT 'epoch'
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating login_attempts table: %v", err)
+	}
+
+	// Создание таблицы user_permissions
+	// Права, выданные пользователю сверх прав его роли
+	q = `
+		CREATE TABLE IF NOT EXISTS user_permissions (
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			permission TEXT NOT NULL,
+			PRIMARY KEY (user_id, permission)
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating user_permissions table: %v", err)
+	}
+
+	// Создание таблицы personal_access_tokens
+	// Персональные токены для ботов и скриптов, хранится только хеш
+	q = `
+		CREATE TABLE IF NOT EXISTS personal_access_tokens (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			scopes TEXT[] NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE INDEX IF NOT EXISTS personal_access_tokens_user_idx ON personal_access_tokens (user_id);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating personal_access_tokens table: %v", err)
+	}
+
+	// Создание таблиц OAuth: клиенты, согласия пользователей, коды авторизации и refresh-токены.
+	// Секреты клиентов, коды и токены хранятся только в виде хешей.
+	q = `
+		CREATE TABLE IF NOT EXISTS oauth_clients (
+			id SERIAL PRIMARY KEY,
+			client_id TEXT NOT NULL UNIQUE,
+			secret_hash TEXT NOT NULL DEFAULT '',
+			owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			redirect_uris TEXT[] NOT NULL,
+			scopes TEXT[] NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS oauth_consents (
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+			scopes TEXT[] NOT NULL,
+			granted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (user_id, client_id)
+		);
+		CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+			id SERIAL PRIMARY KEY,
+			code_hash TEXT NOT NULL UNIQUE,
+			client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			redirect_uri TEXT NOT NULL,
+			scopes TEXT[] NOT NULL,
+			code_challenge TEXT NOT NULL,
+			family_id TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+			id SERIAL PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			family_id TEXT NOT NULL,
+			client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			scopes TEXT[] NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			rotated_at TIMESTAMP WITH TIME ZONE,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE INDEX IF NOT EXISTS oauth_refresh_tokens_family_idx ON oauth_refresh_tokens (family_id);
+		CREATE INDEX IF NOT EXISTS oauth_refresh_tokens_user_client_idx ON oauth_refresh_tokens (user_id, client_id);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating oauth tables: %v", err)
+	}
+
+	// Создание таблиц входа через внешних провайдеров OpenID Connect:
+	// привязанные аккаунты, параметры незавершенных входов и регистраций
+	q = `
+		CREATE TABLE IF NOT EXISTS external_identities (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (provider, subject)
+		);
+		CREATE INDEX IF NOT EXISTS external_identities_user_idx ON external_identities (user_id);
+		CREATE TABLE IF NOT EXISTS oidc_login_states (
+			id SERIAL PRIMARY KEY,
+			state_hash TEXT NOT NULL UNIQUE,
+			provider TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			code_verifier TEXT NOT NULL,
+			user_id INT REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE TABLE IF NOT EXISTS oidc_pending_signups (
+			id SERIAL PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL,
+			email_verified BOOLEAN NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating external identity tables: %v", err)
+	}
+
+	// binding_hash - хеш секрета из cookie браузера, в котором начинался вход через провайдера
+	q = `
+		ALTER TABLE oidc_login_states ADD COLUMN IF NOT EXISTS binding_hash TEXT NOT NULL DEFAULT '';
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error altering oidc_login_states table: %v", err)
+	}
+
+	// Создание таблицы phone_verification_codes
+	// Коды подтверждения телефона из SMS, хранится только хеш
+	q = `
+		CREATE TABLE IF NOT EXISTS phone_verification_codes (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			phone TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			code_hash TEXT NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE
+		);
+		CREATE INDEX IF NOT EXISTS phone_verification_codes_user_idx ON phone_verification_codes (user_id, created_at);
+		CREATE INDEX IF NOT EXISTS phone_verification_codes_phone_idx ON phone_verification_codes (phone, created_at);
+		CREATE INDEX IF NOT EXISTS phone_verification_codes_ip_idx ON phone_verification_codes (ip, created_at);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating phone_verification_codes table: %v", err)
+	}
+
+	// Создание таблицы audit_events
+	// Журнал безопасности. Триггер запрещает изменять и удалять записи.
+	// Внешних ключей на users нет, чтобы записи оставались после удаления пользователя.
+	q = `
+		CREATE TABLE IF NOT EXISTS audit_events (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			type TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			actor_id INT,
+			target_id INT,
+			ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			details JSONB NOT NULL DEFAULT '{}'
+		);
+		CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, id);
+		CREATE INDEX IF NOT EXISTS audit_events_target_idx ON audit_events (target_id, id);
+		CREATE INDEX IF NOT EXISTS audit_events_type_idx ON audit_events (type, id);
+		CREATE INDEX IF NOT EXISTS audit_events_occurred_at_idx ON audit_events (occurred_at);
+
+		CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+		CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+		DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+		CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+			FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating audit_events table: %v", err)
+	}
+
+	// Создание таблицы profiles
+	// Публичные сведения о пользователе, строка появляется при первом изменении профиля.
+	// avatar и cover - описания загруженных изображений со ссылками на файлы в хранилище.
+	q = `
+		CREATE TABLE IF NOT EXISTS profiles (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			display_name TEXT NOT NULL DEFAULT '',
+			bio TEXT NOT NULL DEFAULT '',
+			location TEXT NOT NULL DEFAULT '',
+			website TEXT NOT NULL DEFAULT '',
+			birthday DATE,
+			avatar JSONB,
+			cover JSONB,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating profiles table: %v", err)
+	}
+
+	// Создание таблицы media_objects
+	// Ключи файлов хранилища определяются содержимым, поэтому один файл может использоваться
+	// несколькими изображениями. refs - число ссылок на файл, при нуле файл удаляется.
+	q = `
+		CREATE TABLE IF NOT EXISTS media_objects (
+			key TEXT PRIMARY KEY,
+			refs INT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating media_objects table: %v", err)
+	}
+
+	// Создание таблицы privacy_settings
+	// Кто видит поля профиля: everyone, friends или only_me. Строка появляется при первом изменении,
+	// до этого действуют настройки по умолчанию.
+	q = `
+		CREATE TABLE IF NOT EXISTS privacy_settings (
+			user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			email TEXT NOT NULL CHECK (email IN ('everyone', 'friends', 'only_me')),
+			phone TEXT NOT NULL CHECK (phone IN ('everyone', 'friends', 'only_me')),
+			birthday TEXT NOT NULL CHECK (birthday IN ('everyone', 'friends', 'only_me')),
+			friends TEXT NOT NULL CHECK (friends IN ('everyone', 'friends', 'only_me')),
+			posts TEXT NOT NULL CHECK (posts IN ('everyone', 'friends', 'only_me')),
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating privacy_settings table: %v", err)
+	}
+
+	// Создание таблицы user_blocks
+	// Блокировка скрывает пользователей друг от друга, заглушение (user_mutes) скрывает
+	// от заглушившего только содержимое заглушенного.
+	q = `
+		CREATE TABLE IF NOT EXISTS user_blocks (
+			blocker_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			blocked_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (blocker_id, blocked_id)
+		);
+		CREATE INDEX IF NOT EXISTS user_blocks_blocked_idx ON user_blocks (blocked_id);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating user_blocks table: %v", err)
+	}
+
+	// Создание таблицы user_mutes
+	q = `
+		CREATE TABLE IF NOT EXISTS user_mutes (
+			muter_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			muted_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			PRIMARY KEY (muter_id, muted_id)
+		);
+	`
+
+	if _, err := db.Exec(q); err != nil {
+		log.Fatalf("Error creating user_mutes table: %v", err)
+	}
+}
+
+// normalizeUserPhones приводит номера телефонов к E.164 тем же разбором, что и при регистрации и входе,
+// иначе старые записи вида "8 916 ..." не находятся по нормализованному номеру и его можно зарегистрировать
+// повторно. Страна для номеров без кода должна быть задана до вызова (utils.SetDefaultPhoneRegion).
+func normalizeUserPhones(db *sqlx.DB) {
+	var users []struct {
+		ID    uint   `db:"id"`
+		Phone string `db:"phone"`
+	}
+	if err := db.Select(&users, "SELECT id, phone FROM users WHERE phone IS NOT NULL AND phone <> ''"); err != nil {
+		log.Fatalf("Error reading user phones: %v", err)
+	}
+
+	for _, user := range users {
+		phone := utils.NormalizePhone(user.Phone)
+		if phone == user.Phone {
+			continue
+		}
+
+		_, err := db.Exec("UPDATE users SET phone = $2 WHERE id = $1", user.ID, phone)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			log.Printf("Phone of user %d is left as is: the normalized number belongs to another user", user.ID)
+			continue
+		}
+		if err != nil {
+			log.Fatalf("Error normalizing phone of user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// createUserUniqueIndex создает уникальный индекс таблицы users. Если в старых данных есть дубликаты,
+// они перечисляются в логе, а индекс создается при следующем запуске, когда дубликаты будут устранены.
+func createUserUniqueIndex(db *sqlx.DB, name, expr string) {
+	var duplicates []string
+	query := fmt.Sprintf(`
+		SELECT string_agg(id::text, ', ' ORDER BY id) FROM users
+		WHERE %[1]s IS NOT NULL GROUP BY %[1]s HAVING count(*) > 1
+	`, expr)
+	if err := db.Select(&duplicates, query); err != nil {
+		log.Fatalf("Error checking duplicates for %s: %v", name, err)
+	}
+
+	if len(duplicates) > 0 {
+		for _, ids := range duplicates {
+			log.Printf("Users %s have the same %s", ids, expr)
+		}
+		log.Printf("Unique index %s is not created until the duplicates are resolved", name)
+		return
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON users (%s)", name, expr)); err != nil {
+		log.Fatalf("Error creating index %s: %v", name, err)
+	}
+}
@@ -1 +1,102 @@
-package database
+package database
+
+import (
+	"fmt"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// FriendRepositoryInterface определяет методы для чтения списков друзей.
+// Список друзей у каждого пользователя свой: userId добавил friendId в друзья.
+type FriendRepositoryInterface interface {
+	AddFriend(userID, friendID uint) error
+	RemoveFriend(userID, friendID uint) error
+	IsFriend(userID, friendID uint) (bool, error)
+	GetFriends(userID, viewerID uint, limit, offset int) ([]models.FriendProfile, error)
+}
+
+// FriendRepository предоставляет реализацию FriendRepositoryInterface
+type FriendRepository struct {
+	db *sqlx.DB
+}
+
+// NewFriendRepository создает новый экземпляр FriendRepository
+func NewFriendRepository(db *sqlx.DB) *FriendRepository {
+	return &FriendRepository{db: db}
+}
+
+// AddFriend добавляет friendID в список друзей userID. Повторное добавление не считается ошибкой.
+// Если один из пользователей заблокировал другого, возвращает ErrUserBlocked.
+func (r *FriendRepository) AddFriend(userID, friendID uint) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка в это время не может появиться: Block берет ту же блокировку пары
+	if err := lockUserPair(tx, userID, friendID); err != nil {
+		return fmt.Errorf("failed to add friend: %v", err)
+	}
+
+	var blocked bool
+	if err := tx.Get(&blocked, "SELECT "+hiddenUserCondition("$2", "$1", false), userID, friendID); err != nil {
+		return fmt.Errorf("failed to add friend: %v", err)
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+
+	query := `
+		INSERT INTO friends (userId, friendId, friendLogin, addedAt)
+		SELECT $1::int, id, login, NOW() FROM users WHERE id = $2
+		ON CONFLICT (userId, friendId) DO NOTHING
+	`
+	if _, err := tx.Exec(query, userID, friendID); err != nil {
+		return fmt.Errorf("failed to add friend: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// RemoveFriend удаляет friendID из списка друзей userID
+func (r *FriendRepository) RemoveFriend(userID, friendID uint) error {
+	if _, err := r.db.Exec("DELETE FROM friends WHERE userId = $1 AND friendId = $2", userID, friendID); err != nil {
+		return fmt.Errorf("failed to remove friend: %v", err)
+	}
+	return nil
+}
+
+// IsFriend проверяет, есть ли friendID в списке друзей userID
+func (r *FriendRepository) IsFriend(userID, friendID uint) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM friends WHERE userId = $1 AND friendId = $2)"
+	if err := r.db.Get(&exists, query, userID, friendID); err != nil {
+		return false, fmt.Errorf("failed to check friend: %v", err)
+	}
+	return exists, nil
+}
+
+// GetFriends возвращает друзей пользователя с краткими сведениями профиля, недавно добавленные первыми.
+// Друзья, с которыми у зрителя viewerID есть блокировка, и заглушенные им пользователи в список не попадают,
+// в том числе в его собственном списке.
+func (r *FriendRepository) GetFriends(userID, viewerID uint, limit, offset int) ([]models.FriendProfile, error) {
+	query := `
+		SELECT u.login, COALESCE(p.display_name, '') AS display_name, p.avatar, f.addedAt AS added_at
+		FROM friends f
+		JOIN users u ON u.id = f.friendId
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE f.userId = $1 AND NOT ` + hiddenUserCondition("u.id", "$2", true) + `
+		ORDER BY f.addedAt DESC, u.id
+		LIMIT $3 OFFSET $4
+	`
+	friends := []models.FriendProfile{}
+	if err := r.db.Select(&friends, query, userID, viewerID, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get friends: %v", err)
+	}
+	return friends, nil
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ExternalIdentityRepositoryInterface определяет методы для работы с аккаунтами внешних провайдеров
+type ExternalIdentityRepositoryInterface interface {
+	CreateIdentity(identity models.ExternalIdentity) error
+	GetIdentity(provider, subject string) (models.ExternalIdentity, error)
+	GetIdentities(userID uint) ([]models.ExternalIdentity, error)
+	DeleteIdentity(userID, identityID uint) error
+
+	CreateLoginState(state models.OIDCLoginState) error
+	ConsumeLoginState(provider, stateHash, bindingHash string) (models.OIDCLoginState, error)
+
+	CreatePendingSignup(signup models.OIDCPendingSignup) error
+	CompleteSignup(tokenHash, login string, createdAt time.Time) (models.User, error)
+}
+
+var (
+	// ErrIdentityNotFound возвращается, если аккаунт провайдера не привязан
+	ErrIdentityNotFound = errors.New("external identity not found")
+	// ErrIdentityAlreadyLinked возвращается, если аккаунт провайдера уже привязан к пользователю
+	ErrIdentityAlreadyLinked = errors.New("external identity is already linked")
+)
+
+// ExternalIdentityRepository предоставляет реализацию ExternalIdentityRepositoryInterface
+type ExternalIdentityRepository struct {
+	db *sqlx.DB
+}
+
+// NewExternalIdentityRepository создает новый экземпляр ExternalIdentityRepository
+func NewExternalIdentityRepository(db *sqlx.DB) *ExternalIdentityRepository {
+	return &ExternalIdentityRepository{db: db}
+}
+
+// CreateIdentity привязывает аккаунт провайдера к пользователю.
+// Один аккаунт провайдера может быть привязан только к одному пользователю.
+func (r *ExternalIdentityRepository) CreateIdentity(identity models.ExternalIdentity) error {
+	query := `
+		INSERT INTO external_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := r.db.Exec(query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrIdentityAlreadyLinked
+		}
+		return fmt.Errorf("failed to create external identity: %v", err)
+	}
+	return nil
+}
+
+func (r *ExternalIdentityRepository) GetIdentity(provider, subject string) (models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	query := "SELECT * FROM external_identities WHERE provider = $1 AND subject = $2"
+	err := r.db.Get(&identity, query, provider, subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ExternalIdentity{}, ErrIdentityNotFound
+	}
+	if err != nil {
+		return models.ExternalIdentity{}, fmt.Errorf("failed to get external identity: %v", err)
+	}
+	return identity, nil
+}
+
+func (r *ExternalIdentityRepository) GetIdentities(userID uint) ([]models.ExternalIdentity, error) {
+	identities := []models.ExternalIdentity{}
+	query := "SELECT * FROM external_identities WHERE user_id = $1 ORDER BY created_at"
+	if err := r.db.Select(&identities, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get external identities: %v", err)
+	}
+	return identities, nil
+}
+
+// DeleteIdentity отвязывает аккаунт провайдера от пользователя
+func (r *ExternalIdentityRepository) DeleteIdentity(userID, identityID uint) error {
+	res, err := r.db.Exec("DELETE FROM external_identities WHERE id = $1 AND user_id = $2", identityID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete external identity: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (r *ExternalIdentityRepository) CreateLoginState(state models.OIDCLoginState) error {
+	query := `
+		INSERT INTO oidc_login_states (state_hash, binding_hash, provider, nonce, code_verifier, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query, state.StateHash, state.BindingHash, state.Provider, state.Nonce, state.CodeVerifier,
+		state.UserID, state.CreatedAt, state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oidc login state: %v", err)
+	}
+	return nil
+}
+
+// ConsumeLoginState атомарно помечает действующий state использованным и возвращает его.
+// State из другого браузера (с другим bindingHash) не расходуется.
+// Повторное использование возвращает ErrTokenNotFound.
+func (r *ExternalIdentityRepository) ConsumeLoginState(provider, stateHash, bindingHash string) (models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+	query := `
+		UPDATE oidc_login_states SET used_at = $4
+		WHERE state_hash = $1 AND binding_hash = $2 AND provider = $3 AND used_at IS NULL AND expires_at > $4
+		RETURNING *
+	`
+	err := r.db.Get(&state, query, stateHash, bindingHash, provider, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OIDCLoginState{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return models.OIDCLoginState{}, fmt.Errorf("failed to consume oidc login state: %v", err)
+	}
+	return state, nil
+}
+
+func (r *ExternalIdentityRepository) CreatePendingSignup(signup models.OIDCPendingSignup) error {
+	query := `
+		INSERT INTO oidc_pending_signups (token_hash, provider, subject, email, email_verified, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.Exec(query, signup.TokenHash, signup.Provider, signup.Subject, signup.Email, signup.EmailVerified,
+		signup.CreatedAt, signup.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create pending signup: %v", err)
+	}
+	return nil
+}
+
+// CompleteSignup в одной транзакции создает пользователя по незавершенной регистрации,
+// привязывает к нему аккаунт провайдера и помечает регистрацию использованной.
+// Для неизвестной, просроченной или использованной регистрации возвращает ErrTokenNotFound,
+// при занятом логине или email - ErrLoginTaken или ErrEmailTaken, и регистрация остается действующей.
+func (r *ExternalIdentityRepository) CompleteSignup(tokenHash, login string, createdAt time.Time) (models.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки не дает двум параллельным запросам создать два аккаунта по одному токену
+	var signup models.OIDCPendingSignup
+	query := `
+		SELECT * FROM oidc_pending_signups
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`
+	err = tx.Get(&signup, query, tokenHash, createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get pending signup: %v", err)
+	}
+
+	query = `
+		INSERT INTO users (login, email, email_verified, createdat)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, login, email, email_verified, role
+	`
+	var user models.User
+	err = tx.QueryRow(query, login, signup.Email, signup.EmailVerified, createdAt).Scan(
+		&user.ID, &user.Login, &user.Email, &user.EmailVerified, &user.Role,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			if uniqueErr, ok := uniqueViolations[pqErr.Constraint]; ok {
+				return models.User{}, uniqueErr
+			}
+		}
+		return models.User{}, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	query = `
+		INSERT INTO external_identities (user_id, provider, subject, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = tx.Exec(query, user.ID, signup.Provider, signup.Subject, signup.Email, createdAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return models.User{}, ErrIdentityAlreadyLinked
+		}
+		return models.User{}, fmt.Errorf("failed to create external identity: %v", err)
+	}
+
+	if _, err := tx.Exec("UPDATE oidc_pending_signups SET used_at = $2 WHERE id = $1", signup.ID, createdAt); err != nil {
+		return models.User{}, fmt.Errorf("failed to consume pending signup: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MediaObjectRepositoryInterface считает ссылки на файлы хранилища
+type MediaObjectRepositoryInterface interface {
+	AcquireObjects(keys []string) error
+	ReleaseObjects(keys []string, deleteObject func(key string) error) error
+}
+
+// MediaObjectRepository предоставляет реализацию MediaObjectRepositoryInterface
+type MediaObjectRepository struct {
+	db *sqlx.DB
+}
+
+// NewMediaObjectRepository создает новый экземпляр MediaObjectRepository
+func NewMediaObjectRepository(db *sqlx.DB) *MediaObjectRepository {
+	return &MediaObjectRepository{db: db}
+}
+
+// AcquireObjects добавляет по ссылке на каждый файл. Вызывается до записи файлов в хранилище,
+// чтобы параллельное освобождение того же файла не удалило его после записи.
+func (r *MediaObjectRepository) AcquireObjects(keys []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Одинаковый порядок блокировок исключает взаимные блокировки параллельных загрузок
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	query := `
+		INSERT INTO media_objects (key, refs, created_at) VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE SET refs = media_objects.refs + 1
+	`
+	for _, key := range sorted {
+		if _, err := tx.Exec(query, key); err != nil {
+			return fmt.Errorf("failed to acquire media object: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// ReleaseObjects убирает по ссылке на каждый файл и вызывает deleteObject для файлов, на которые
+// больше никто не ссылается. Файл удаляется, пока строка заблокирована, поэтому параллельная загрузка
+// того же содержимого дождется удаления и запишет файл заново. Файлы без учета ссылок
+// (загруженные до его появления) принадлежат одному изображению и удаляются сразу.
+// Ошибка с одним файлом не мешает освободить остальные, возвращается первая ошибка.
+func (r *MediaObjectRepository) ReleaseObjects(keys []string, deleteObject func(key string) error) error {
+	var firstErr error
+	for _, key := range keys {
+		if err := r.releaseObject(key, deleteObject); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *MediaObjectRepository) releaseObject(key string, deleteObject func(key string) error) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var refs int
+	err = tx.Get(&refs, "UPDATE media_objects SET refs = refs - 1 WHERE key = $1 RETURNING refs", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return deleteObject(key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release media object: %v", err)
+	}
+
+	if refs <= 0 {
+		if err := deleteObject(key); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM media_objects WHERE key = $1", key); err != nil {
+			return fmt.Errorf("failed to delete media object: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
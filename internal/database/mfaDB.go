@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepositoryInterface определяет методы для работы с двухфакторной аутентификацией
+type MFARepositoryInterface interface {
+	GetMFA(userID uint) (models.MFA, error)
+	SaveMFASecret(userID uint, secret string) error
+	EnableMFA(userID uint) error
+	DeleteMFA(userID uint) error
+	UseTOTPStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountRecoveryCodes(userID uint) (int, error)
+}
+
+// ErrMFANotFound возвращается, если пользователь не начинал подключение двухфакторной аутентификации
+var ErrMFANotFound = errors.New("mfa not found")
+
+// MFARepository предоставляет реализацию MFARepositoryInterface
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+// NewMFARepository создает новый экземпляр MFARepository
+func NewMFARepository(db *sqlx.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) GetMFA(userID uint) (models.MFA, error) {
+	var mfa models.MFA
+	err := r.db.Get(&mfa, "SELECT * FROM user_mfa WHERE user_id = $1", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MFA{}, ErrMFANotFound
+	}
+	if err != nil {
+		return models.MFA{}, fmt.Errorf("failed to get mfa: %v", err)
+	}
+	return mfa, nil
+}
+
+// SaveMFASecret сохраняет новый секрет, еще не подтвержденный пользователем
+func (r *MFARepository) SaveMFASecret(userID uint, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled, created_at) VALUES ($1, $2, FALSE, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = FALSE,
+			last_used_step = 0, created_at = EXCLUDED.created_at, confirmed_at = NULL
+	`
+	if _, err := r.db.Exec(query, userID, secret, time.Now()); err != nil {
+		return fmt.Errorf("failed to save mfa secret: %v", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) EnableMFA(userID uint) error {
+	query := "UPDATE user_mfa SET enabled = TRUE, confirmed_at = $2 WHERE user_id = $1"
+	if _, err := r.db.Exec(query, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to enable mfa: %v", err)
+	}
+	return nil
+}
+
+// DeleteMFA отключает двухфакторную аутентификацию и удаляет коды восстановления
+func (r *MFARepository) DeleteMFA(userID uint) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM user_mfa WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete mfa: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep запоминает использованный шаг TOTP. Возвращает false,
+// если этот или более поздний шаг уже использовался (повтор кода).
+func (r *MFARepository) UseTOTPStep(userID uint, step int64) (bool, error) {
+	query := "UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2"
+	res, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp step: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ReplaceRecoveryCodes заменяет все коды восстановления пользователя новыми
+func (r *MFARepository) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %v", err)
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, codeHash); err != nil {
+			return fmt.Errorf("failed to save recovery code: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode помечает код восстановления использованным. Возвращает false, если такого неиспользованного кода нет.
+func (r *MFARepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	query := "UPDATE mfa_recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+	res, err := r.db.Exec(query, userID, codeHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления
+func (r *MFARepository) CountRecoveryCodes(userID uint) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL"
+	if err := r.db.Get(&count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %v", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// OAuthRepositoryInterface определяет методы для работы с клиентами, согласиями и токенами OAuth
+type OAuthRepositoryInterface interface {
+	CreateClient(client models.OAuthClient) (models.OAuthClient, error)
+	GetClient(clientID string) (models.OAuthClient, error)
+	GetClientsByOwner(ownerID uint) ([]models.OAuthClient, error)
+	DeleteClient(ownerID uint, clientID string) error
+
+	SaveConsent(consent models.OAuthConsent) error
+	GetConsent(userID uint, clientID string) (models.OAuthConsent, error)
+	GetConsents(userID uint) ([]models.OAuthConsent, error)
+	DeleteConsent(userID uint, clientID string) error
+
+	CreateAuthorizationCode(code models.OAuthAuthorizationCode) error
+	ConsumeAuthorizationCode(codeHash string) (models.OAuthAuthorizationCode, error)
+
+	CreateRefreshToken(token models.OAuthRefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (models.OAuthRefreshToken, error)
+	RotateRefreshToken(tokenID uint, next models.OAuthRefreshToken) error
+	RevokeRefreshTokenFamily(familyID string) error
+	RevokeUserRefreshTokens(userID uint) error
+}
+
+var (
+	// ErrOAuthClientNotFound возвращается, если клиент не зарегистрирован
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrOAuthConsentNotFound возвращается, если пользователь не давал согласия приложению
+	ErrOAuthConsentNotFound = errors.New("oauth consent not found")
+	// ErrAuthorizationCodeNotFound возвращается для неизвестного кода авторизации
+	ErrAuthorizationCodeNotFound = errors.New("authorization code not found")
+	// ErrAuthorizationCodeUsed возвращается вместе с кодом, если он уже был обменян
+	ErrAuthorizationCodeUsed = errors.New("authorization code already used")
+	// ErrOAuthRefreshTokenNotFound возвращается для неизвестного refresh-токена
+	ErrOAuthRefreshTokenNotFound = errors.New("oauth refresh token not found")
+	// ErrOAuthRefreshTokenRotated возвращается, если refresh-токен уже обменян или отозван
+	ErrOAuthRefreshTokenRotated = errors.New("oauth refresh token already rotated")
+)
+
+// OAuthRepository предоставляет реализацию OAuthRepositoryInterface
+type OAuthRepository struct {
+	db *sqlx.DB
+}
+
+// NewOAuthRepository создает новый экземпляр OAuthRepository
+func NewOAuthRepository(db *sqlx.DB) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+func (r *OAuthRepository) CreateClient(client models.OAuthClient) (models.OAuthClient, error) {
+	var created models.OAuthClient
+	query := `
+		INSERT INTO oauth_clients (client_id, secret_hash, owner_id, name, redirect_uris, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *
+	`
+	err := r.db.Get(&created, query, client.ClientID, client.SecretHash, client.OwnerID, client.Name,
+		client.RedirectURIs, client.Scopes, client.CreatedAt)
+	if err != nil {
+		return models.OAuthClient{}, fmt.Errorf("failed to create oauth client: %v", err)
+	}
+	return created, nil
+}
+
+func (r *OAuthRepository) GetClient(clientID string) (models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Get(&client, "SELECT * FROM oauth_clients WHERE client_id = $1", clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthClient{}, ErrOAuthClientNotFound
+	}
+	if err != nil {
+		return models.OAuthClient{}, fmt.Errorf("failed to get oauth client: %v", err)
+	}
+	return client, nil
+}
+
+func (r *OAuthRepository) GetClientsByOwner(ownerID uint) ([]models.OAuthClient, error) {
+	clients := []models.OAuthClient{}
+	query := "SELECT * FROM oauth_clients WHERE owner_id = $1 ORDER BY created_at"
+	if err := r.db.Select(&clients, query, ownerID); err != nil {
+		return nil, fmt.Errorf("failed to get oauth clients: %v", err)
+	}
+	return clients, nil
+}
+
+// DeleteClient удаляет клиента вместе с согласиями, кодами и токенами
+func (r *OAuthRepository) DeleteClient(ownerID uint, clientID string) error {
+	res, err := r.db.Exec("DELETE FROM oauth_clients WHERE owner_id = $1 AND client_id = $2", ownerID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete oauth client: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOAuthClientNotFound
+	}
+	return nil
+}
+
+// SaveConsent сохраняет согласие, объединяя новые области действия с ранее разрешенными
+func (r *OAuthRepository) SaveConsent(consent models.OAuthConsent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET
+			scopes = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes) ORDER BY 1),
+			granted_at = EXCLUDED.granted_at
+	`
+	if _, err := r.db.Exec(query, consent.UserID, consent.ClientID, consent.Scopes, consent.GrantedAt); err != nil {
+		return fmt.Errorf("failed to save oauth consent: %v", err)
+	}
+	return nil
+}
+
+func (r *OAuthRepository) GetConsent(userID uint, clientID string) (models.OAuthConsent, error) {
+	var consent models.OAuthConsent
+	query := "SELECT * FROM oauth_consents WHERE user_id = $1 AND client_id = $2"
+	err := r.db.Get(&consent, query, userID, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthConsent{}, ErrOAuthConsentNotFound
+	}
+	if err != nil {
+		return models.OAuthConsent{}, fmt.Errorf("failed to get oauth consent: %v", err)
+	}
+	return consent, nil
+}
+
+func (r *OAuthRepository) GetConsents(userID uint) ([]models.OAuthConsent, error) {
+	consents := []models.OAuthConsent{}
+	query := "SELECT * FROM oauth_consents WHERE user_id = $1 ORDER BY granted_at DESC"
+	if err := r.db.Select(&consents, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get oauth consents: %v", err)
+	}
+	return consents, nil
+}
+
+// DeleteConsent отзывает согласие и все refresh-токены, выданные приложению от имени пользователя
+func (r *OAuthRepository) DeleteConsent(userID uint, clientID string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2", userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete oauth consent: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOAuthConsentNotFound
+	}
+
+	query := "UPDATE oauth_refresh_tokens SET revoked_at = $3 WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL"
+	if _, err := tx.Exec(query, userID, clientID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke oauth refresh tokens: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (r *OAuthRepository) CreateAuthorizationCode(code models.OAuthAuthorizationCode) error {
+	query := `
+		INSERT INTO oauth_authorization_codes
+			(code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, family_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := r.db.Exec(query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scopes,
+		code.CodeChallenge, code.FamilyID, code.CreatedAt, code.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create authorization code: %v", err)
+	}
+	return nil
+}
+
+// ConsumeAuthorizationCode атомарно помечает код использованным и возвращает его.
+// Если код уже был обменян, возвращает его вместе с ErrAuthorizationCodeUsed,
+// чтобы можно было отозвать выданные по нему токены.
+func (r *OAuthRepository) ConsumeAuthorizationCode(codeHash string) (models.OAuthAuthorizationCode, error) {
+	var code models.OAuthAuthorizationCode
+	query := "UPDATE oauth_authorization_codes SET used_at = $2 WHERE code_hash = $1 AND used_at IS NULL RETURNING *"
+	err := r.db.Get(&code, query, codeHash, time.Now())
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthAuthorizationCode{}, fmt.Errorf("failed to consume authorization code: %v", err)
+	}
+
+	err = r.db.Get(&code, "SELECT * FROM oauth_authorization_codes WHERE code_hash = $1", codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthAuthorizationCode{}, ErrAuthorizationCodeNotFound
+	}
+	if err != nil {
+		return models.OAuthAuthorizationCode{}, fmt.Errorf("failed to get authorization code: %v", err)
+	}
+	return code, ErrAuthorizationCodeUsed
+}
+
+func (r *OAuthRepository) CreateRefreshToken(token models.OAuthRefreshToken) error {
+	query := `
+		INSERT INTO oauth_refresh_tokens (token_hash, family_id, client_id, user_id, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.db.Exec(query, token.TokenHash, token.FamilyID, token.ClientID, token.UserID, token.Scopes,
+		token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth refresh token: %v", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenByHash возвращает refresh-токен по хешу, включая уже обменянные и отозванные
+func (r *OAuthRepository) GetRefreshTokenByHash(tokenHash string) (models.OAuthRefreshToken, error) {
+	var token models.OAuthRefreshToken
+	err := r.db.Get(&token, "SELECT * FROM oauth_refresh_tokens WHERE token_hash = $1", tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OAuthRefreshToken{}, ErrOAuthRefreshTokenNotFound
+	}
+	if err != nil {
+		return models.OAuthRefreshToken{}, fmt.Errorf("failed to get oauth refresh token: %v", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken помечает токен обменянным и в той же транзакции сохраняет следующий.
+// Если токен уже обменян или отозван, возвращает ErrOAuthRefreshTokenRotated.
+func (r *OAuthRepository) RotateRefreshToken(tokenID uint, next models.OAuthRefreshToken) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE oauth_refresh_tokens SET rotated_at = $2
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, tokenID, next.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to rotate oauth refresh token: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOAuthRefreshTokenRotated
+	}
+
+	query := `
+		INSERT INTO oauth_refresh_tokens (token_hash, family_id, client_id, user_id, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = tx.Exec(query, next.TokenHash, next.FamilyID, next.ClientID, next.UserID, next.Scopes,
+		next.CreatedAt, next.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth refresh token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// RevokeRefreshTokenFamily отзывает все refresh-токены семейства
+func (r *OAuthRepository) RevokeRefreshTokenFamily(familyID string) error {
+	query := "UPDATE oauth_refresh_tokens SET revoked_at = $2 WHERE family_id = $1 AND revoked_at IS NULL"
+	if _, err := r.db.Exec(query, familyID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke oauth refresh tokens: %v", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens отзывает refresh-токены, выданные приложениям от имени пользователя
+func (r *OAuthRepository) RevokeUserRefreshTokens(userID uint) error {
+	query := "UPDATE oauth_refresh_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL"
+	if _, err := r.db.Exec(query, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke user oauth refresh tokens: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// PersonalAccessTokenRepositoryInterface определяет методы для работы с персональными токенами
+type PersonalAccessTokenRepositoryInterface interface {
+	CreatePersonalAccessToken(token models.PersonalAccessToken) (models.PersonalAccessToken, error)
+	GetPersonalAccessTokens(userID uint) ([]models.PersonalAccessToken, error)
+	GetPersonalAccessTokenByHash(tokenHash string) (models.PersonalAccessToken, error)
+	RevokePersonalAccessToken(userID, tokenID uint) error
+	TouchPersonalAccessToken(tokenID uint, usedAt time.Time) error
+}
+
+// ErrPersonalAccessTokenNotFound возвращается, если токен не найден или уже отозван
+var ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+
+// lastUsedPrecision - как часто обновляется время последнего использования токена.
+// Скрипты могут делать много запросов подряд, запись на каждый запрос не нужна.
+const lastUsedPrecision = time.Minute
+
+// PersonalAccessTokenRepository предоставляет реализацию PersonalAccessTokenRepositoryInterface
+type PersonalAccessTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewPersonalAccessTokenRepository создает новый экземпляр PersonalAccessTokenRepository
+func NewPersonalAccessTokenRepository(db *sqlx.DB) *PersonalAccessTokenRepository {
+	return &PersonalAccessTokenRepository{db: db}
+}
+
+// CreatePersonalAccessToken сохраняет новый токен
+func (r *PersonalAccessTokenRepository) CreatePersonalAccessToken(token models.PersonalAccessToken) (models.PersonalAccessToken, error) {
+	var created models.PersonalAccessToken
+	query := `
+		INSERT INTO personal_access_tokens (user_id, name, scopes, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`
+	err := r.db.Get(&created, query, token.UserID, token.Name, token.Scopes, token.TokenHash, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return models.PersonalAccessToken{}, fmt.Errorf("failed to create personal access token: %v", err)
+	}
+	return created, nil
+}
+
+// GetPersonalAccessTokens возвращает неотозванные токены пользователя, включая истекшие
+func (r *PersonalAccessTokenRepository) GetPersonalAccessTokens(userID uint) ([]models.PersonalAccessToken, error) {
+	tokens := []models.PersonalAccessToken{}
+	query := `
+		SELECT * FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+	if err := r.db.Select(&tokens, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get personal access tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+// GetPersonalAccessTokenByHash ищет действующий токен по хешу
+func (r *PersonalAccessTokenRepository) GetPersonalAccessTokenByHash(tokenHash string) (models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	query := `
+		SELECT * FROM personal_access_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > $2
+	`
+	err := r.db.Get(&token, query, tokenHash, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PersonalAccessToken{}, ErrPersonalAccessTokenNotFound
+	}
+	if err != nil {
+		return models.PersonalAccessToken{}, fmt.Errorf("failed to get personal access token: %v", err)
+	}
+	return token, nil
+}
+
+// RevokePersonalAccessToken отзывает токен пользователя
+func (r *PersonalAccessTokenRepository) RevokePersonalAccessToken(userID, tokenID uint) error {
+	query := "UPDATE personal_access_tokens SET revoked_at = $3 WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL"
+	res, err := r.db.Exec(query, tokenID, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke personal access token: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return nil
+}
+
+// TouchPersonalAccessToken обновляет время последнего использования токена не чаще раза в минуту
+func (r *PersonalAccessTokenRepository) TouchPersonalAccessToken(tokenID uint, usedAt time.Time) error {
+	query := `
+		UPDATE personal_access_tokens SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`
+	if _, err := r.db.Exec(query, tokenID, usedAt, usedAt.Add(-lastUsedPrecision)); err != nil {
+		return fmt.Errorf("failed to update personal access token: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// PhoneVerificationRepositoryInterface определяет методы для работы с кодами подтверждения телефона
+type PhoneVerificationRepositoryInterface interface {
+	CreateCode(code models.PhoneVerificationCode) error
+	GetActiveCode(userID uint) (models.PhoneVerificationCode, error)
+	RecordAttempt(codeID uint, maxAttempts int) error
+	ConsumeCode(codeID uint) error
+	InvalidateCodes(userID uint) error
+	CountCodesByPhone(phone string, since time.Time) (int, time.Time, time.Time, error)
+	CountCodesByIP(ip string, since time.Time) (int, time.Time, time.Time, error)
+}
+
+// PhoneVerificationRepository предоставляет реализацию PhoneVerificationRepositoryInterface
+type PhoneVerificationRepository struct {
+	db *sqlx.DB
+}
+
+// NewPhoneVerificationRepository создает новый экземпляр PhoneVerificationRepository
+func NewPhoneVerificationRepository(db *sqlx.DB) *PhoneVerificationRepository {
+	return &PhoneVerificationRepository{db: db}
+}
+
+// CreateCode сохраняет хеш нового кода
+func (r *PhoneVerificationRepository) CreateCode(code models.PhoneVerificationCode) error {
+	query := `
+		INSERT INTO phone_verification_codes (user_id, phone, ip, code_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(query, code.UserID, code.Phone, code.IP, code.CodeHash, code.CreatedAt, code.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create phone verification code: %v", err)
+	}
+	return nil
+}
+
+// GetActiveCode возвращает последний неиспользованный и не истекший код пользователя или ErrTokenNotFound
+func (r *PhoneVerificationRepository) GetActiveCode(userID uint) (models.PhoneVerificationCode, error) {
+	var code models.PhoneVerificationCode
+	query := `
+		SELECT * FROM phone_verification_codes
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > $2
+		ORDER BY created_at DESC LIMIT 1
+	`
+	err := r.db.Get(&code, query, userID, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PhoneVerificationCode{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return models.PhoneVerificationCode{}, fmt.Errorf("failed to get phone verification code: %v", err)
+	}
+	return code, nil
+}
+
+// RecordAttempt атомарно учитывает попытку ввода кода.
+// Если попытки исчерпаны или код уже использован, возвращает ErrTokenNotFound.
+func (r *PhoneVerificationRepository) RecordAttempt(codeID uint, maxAttempts int) error {
+	query := `
+		UPDATE phone_verification_codes SET attempts = attempts + 1
+		WHERE id = $1 AND used_at IS NULL AND attempts < $2
+	`
+	res, err := r.db.Exec(query, codeID, maxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to record phone verification attempt: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// ConsumeCode атомарно помечает код использованным. Повторное использование возвращает ErrTokenNotFound.
+func (r *PhoneVerificationRepository) ConsumeCode(codeID uint) error {
+	res, err := r.db.Exec("UPDATE phone_verification_codes SET used_at = $2 WHERE id = $1 AND used_at IS NULL", codeID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to consume phone verification code: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// InvalidateCodes делает недействительными все неиспользованные коды пользователя
+func (r *PhoneVerificationRepository) InvalidateCodes(userID uint) error {
+	query := "UPDATE phone_verification_codes SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL"
+	if _, err := r.db.Exec(query, userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to invalidate phone verification codes: %v", err)
+	}
+	return nil
+}
+
+// CountCodesByPhone возвращает число кодов, отправленных на номер начиная с since,
+// и время отправки первого и последнего из них
+func (r *PhoneVerificationRepository) CountCodesByPhone(phone string, since time.Time) (int, time.Time, time.Time, error) {
+	return r.countCodes("phone = $1", phone, since)
+}
+
+// CountCodesByIP возвращает число кодов, запрошенных с IP-адреса начиная с since,
+// и время отправки первого и последнего из них
+func (r *PhoneVerificationRepository) CountCodesByIP(ip string, since time.Time) (int, time.Time, time.Time, error) {
+	return r.countCodes("ip = $1", ip, since)
+}
+
+func (r *PhoneVerificationRepository) countCodes(condition string, arg interface{}, since time.Time) (int, time.Time, time.Time, error) {
+	var res struct {
+		Count int          `db:"count"`
+		First sql.NullTime `db:"first"`
+		Last  sql.NullTime `db:"last"`
+	}
+	query := `
+		SELECT COUNT(*) AS count, MIN(created_at) AS first, MAX(created_at) AS last FROM phone_verification_codes
+		WHERE ` + condition + ` AND created_at >= $2
+	`
+	if err := r.db.Get(&res, query, arg, since); err != nil {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("failed to count phone verification codes: %v", err)
+	}
+	return res.Count, res.First.Time, res.Last.Time, nil
+}
@@ -1 +1,121 @@
-package database
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrPostNotFound возвращается, если поста с таким id нет
+var ErrPostNotFound = errors.New("post not found")
+
+// PostRepositoryInterface определяет методы для работы с постами в базе данных
+type PostRepositoryInterface interface {
+	CreatePost(authorID uint, post models.NewPost) (models.Post, error)
+	GetPost(postID int) (models.Post, error)
+	GetPostsByAuthor(authorID uint, limit, offset int) ([]models.Post, error)
+	GetFeed(viewerID uint, limit, offset int) ([]models.Post, error)
+}
+
+// postColumns - столбцы поста под именами тегов models.Post. Столбцы таблицы posts созданы
+// без кавычек, поэтому Postgres возвращает их в нижнем регистре.
+const postColumns = `
+	p.id, COALESCE(p.author_id, 0) AS author_id, p.content, p.author, COALESCE(p.tags, '{}') AS tags, p.createdAt AS "createdAt",
+	COALESCE(p.likeCount, 0) AS "likesCount", COALESCE(p.dislikeCount, 0) AS "dislikesCount"
+`
+
+// PostRepository предоставляет реализацию PostRepositoryInterface
+type PostRepository struct {
+	db *sqlx.DB
+}
+
+// NewPostRepository создает новый экземпляр PostRepository
+func NewPostRepository(db *sqlx.DB) *PostRepository {
+	return &PostRepository{db: db}
+}
+
+// CreatePost публикует пост от имени пользователя и возвращает его.
+// Если пользователя нет, возвращает ErrUserNotFound.
+func (r *PostRepository) CreatePost(authorID uint, post models.NewPost) (models.Post, error) {
+	query := `
+		WITH p AS (
+			INSERT INTO posts (author_id, author, content, tags, createdAt, likeCount, dislikeCount)
+			SELECT id, login, $2, $3, NOW(), 0, 0 FROM users WHERE id = $1
+			RETURNING *
+		)
+		SELECT ` + postColumns + ` FROM p
+	`
+	var created models.Post
+	err := r.db.Get(&created, query, authorID, post.Content, pq.StringArray(post.Tags))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Post{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.Post{}, fmt.Errorf("failed to create post: %v", err)
+	}
+	return normalizePost(created), nil
+}
+
+// GetPost возвращает пост по id или ErrPostNotFound. Видимость поста для зрителя проверяет сервис.
+func (r *PostRepository) GetPost(postID int) (models.Post, error) {
+	var post models.Post
+	err := r.db.Get(&post, "SELECT "+postColumns+" FROM posts p WHERE p.id = $1", postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Post{}, ErrPostNotFound
+	}
+	if err != nil {
+		return models.Post{}, fmt.Errorf("failed to get post: %v", err)
+	}
+	return normalizePost(post), nil
+}
+
+// GetPostsByAuthor возвращает посты пользователя, новые первыми. Видимость постов для зрителя проверяет сервис.
+func (r *PostRepository) GetPostsByAuthor(authorID uint, limit, offset int) ([]models.Post, error) {
+	query := `
+		SELECT ` + postColumns + ` FROM posts p
+		WHERE p.author_id = $1
+		ORDER BY p.createdAt DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+	return r.selectPosts("posts by author", query, authorID, limit, offset)
+}
+
+// GetFeed возвращает ленту пользователя: его посты и посты тех, кого он добавил в друзья, новые первыми.
+// Посты пользователей, с которыми у него есть блокировка или которых он заглушил, и посты,
+// скрытые от него настройками приватности авторов, в ленту не попадают.
+func (r *PostRepository) GetFeed(viewerID uint, limit, offset int) ([]models.Post, error) {
+	query := `
+		SELECT ` + postColumns + ` FROM posts p
+		WHERE p.author_id = $1 OR (
+			EXISTS (SELECT 1 FROM friends f WHERE f.userId = $1 AND f.friendId = p.author_id)
+			AND NOT ` + hiddenUserCondition("p.author_id", "$1", true) + `
+			AND ` + postsVisibleCondition("p.author_id", "$1") + `
+		)
+		ORDER BY p.createdAt DESC, p.id DESC
+		LIMIT $2 OFFSET $3
+	`
+	return r.selectPosts("feed", query, viewerID, limit, offset)
+}
+
+func (r *PostRepository) selectPosts(what, query string, args ...interface{}) ([]models.Post, error) {
+	posts := []models.Post{}
+	if err := r.db.Select(&posts, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", what, err)
+	}
+	for i := range posts {
+		posts[i] = normalizePost(posts[i])
+	}
+	return posts, nil
+}
+
+// normalizePost заменяет пустой список тегов на пустой массив, чтобы в JSON не попадал null
+func normalizePost(post models.Post) models.Post {
+	if post.Tags == nil {
+		post.Tags = pq.StringArray{}
+	}
+	return post
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// PrivacyRepositoryInterface определяет методы для хранения настроек приватности
+type PrivacyRepositoryInterface interface {
+	GetPrivacySettings(userID uint) (models.PrivacySettings, error)
+	UpdatePrivacySettings(userID uint, update models.PrivacyUpdate) (models.PrivacySettings, error)
+}
+
+// PrivacyRepository предоставляет реализацию PrivacyRepositoryInterface
+type PrivacyRepository struct {
+	db *sqlx.DB
+}
+
+// NewPrivacyRepository создает новый экземпляр PrivacyRepository
+func NewPrivacyRepository(db *sqlx.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: db}
+}
+
+// GetPrivacySettings возвращает настройки пользователя или настройки по умолчанию, если он их не менял
+func (r *PrivacyRepository) GetPrivacySettings(userID uint) (models.PrivacySettings, error) {
+	var settings models.PrivacySettings
+	query := "SELECT email, phone, birthday, friends, posts FROM privacy_settings WHERE user_id = $1"
+	err := r.db.Get(&settings, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DefaultPrivacySettings(), nil
+	}
+	if err != nil {
+		return models.PrivacySettings{}, fmt.Errorf("failed to get privacy settings: %v", err)
+	}
+	return settings, nil
+}
+
+// UpdatePrivacySettings изменяет переданные настройки одним запросом и возвращает настройки после изменения.
+// Непереданная настройка (NULL) сохраняет значение из базы, а если пользователь еще не менял
+// настройки - значение по умолчанию, поэтому параллельные изменения разных настроек не затирают друг друга.
+func (r *PrivacyRepository) UpdatePrivacySettings(userID uint, update models.PrivacyUpdate) (models.PrivacySettings, error) {
+	defaults := models.DefaultPrivacySettings()
+	query := `
+		INSERT INTO privacy_settings (user_id, email, phone, birthday, friends, posts, updated_at)
+		VALUES ($1, COALESCE($2, $7), COALESCE($3, $8), COALESCE($4, $9), COALESCE($5, $10), COALESCE($6, $11), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = COALESCE($2, privacy_settings.email),
+			phone = COALESCE($3, privacy_settings.phone),
+			birthday = COALESCE($4, privacy_settings.birthday),
+			friends = COALESCE($5, privacy_settings.friends),
+			posts = COALESCE($6, privacy_settings.posts),
+			updated_at = EXCLUDED.updated_at
+		RETURNING email, phone, birthday, friends, posts
+	`
+	var settings models.PrivacySettings
+	err := r.db.Get(&settings, query, userID, update.Email, update.Phone, update.Birthday, update.Friends, update.Posts,
+		defaults.Email, defaults.Phone, defaults.Birthday, defaults.Friends, defaults.Posts)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return models.PrivacySettings{}, ErrUserNotFound
+		}
+		return models.PrivacySettings{}, fmt.Errorf("failed to update privacy settings: %v", err)
+	}
+	return settings, nil
+}
+
+// postsVisibleCondition возвращает условие SQL, которое истинно, если настройка posts автора из столбца
+// authorColumn позволяет зрителю с id из параметра viewerParam видеть его посты. Условие строится
+// по той же таблице models.VisibilityViewers, что и проверка models.Allows: друг - тот, кого автор
+// добавил в друзья, посторонний - все остальные. Свои посты и блокировки проверяются отдельно.
+func postsVisibleCondition(authorColumn, viewerParam string) string {
+	isFriend := `EXISTS (SELECT 1 FROM friends f WHERE f.userId = ` + authorColumn + ` AND f.friendId = ` + viewerParam + `)`
+	viewerConditions := map[string]string{
+		models.ViewerFriend:   isFriend,
+		models.ViewerStranger: "NOT " + isFriend,
+	}
+
+	var cases strings.Builder
+	for _, visibility := range models.Visibilities {
+		conditions := []string{}
+		for _, viewer := range models.VisibilityViewers(visibility) {
+			conditions = append(conditions, viewerConditions[viewer])
+		}
+		condition := "FALSE"
+		if len(conditions) > 0 {
+			condition = "(" + strings.Join(conditions, " OR ") + ")"
+		}
+		cases.WriteString(" WHEN '" + visibility + "' THEN " + condition)
+	}
+
+	return `(CASE COALESCE((SELECT s.posts FROM privacy_settings s WHERE s.user_id = ` + authorColumn + `), '` +
+		models.DefaultPrivacySettings().Posts + `')` + cases.String() + `
+		ELSE FALSE
+	END)`
+}
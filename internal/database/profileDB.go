@@ -1 +1,130 @@
-package database
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ProfileRepositoryInterface определяет методы для работы с профилями пользователей в базе данных
+type ProfileRepositoryInterface interface {
+	GetProfileByUserID(userID uint) (models.Profile, error)
+	GetProfileByLogin(login string) (models.Profile, error)
+	UpdateProfile(userID uint, update models.ProfileUpdate) (models.Profile, error)
+	SetImage(userID uint, kind string, image *models.Image) (*models.Image, error)
+}
+
+// profileQuery выбирает профиль вместе с логином. Строка в profiles создается при первом изменении,
+// до этого у пользователя пустой профиль.
+const profileQuery = `
+	SELECT u.id AS user_id, u.login, COALESCE(u.email, '') AS email, COALESCE(u.phone, '') AS phone,
+		COALESCE(p.display_name, '') AS display_name, COALESCE(p.bio, '') AS bio,
+		COALESCE(p.location, '') AS location, COALESCE(p.website, '') AS website,
+		COALESCE(to_char(p.birthday, 'YYYY-MM-DD'), '') AS birthday,
+		p.avatar, p.cover, p.updated_at
+	FROM users u LEFT JOIN profiles p ON p.user_id = u.id
+`
+
+// ProfileRepository предоставляет реализацию ProfileRepositoryInterface
+type ProfileRepository struct {
+	db *sqlx.DB
+}
+
+// NewProfileRepository создает новый экземпляр ProfileRepository
+func NewProfileRepository(db *sqlx.DB) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+// GetProfileByUserID возвращает профиль пользователя или ErrUserNotFound
+func (r *ProfileRepository) GetProfileByUserID(userID uint) (models.Profile, error) {
+	return r.getProfile("user id", profileQuery+"WHERE u.id = $1", userID)
+}
+
+// GetProfileByLogin ищет профиль по логину без учета регистра
+func (r *ProfileRepository) GetProfileByLogin(login string) (models.Profile, error) {
+	return r.getProfile("login", profileQuery+"WHERE lower(u.login) = lower($1)", login)
+}
+
+func (r *ProfileRepository) getProfile(by, query string, arg interface{}) (models.Profile, error) {
+	var profile models.Profile
+	err := r.db.Get(&profile, query, arg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Profile{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.Profile{}, fmt.Errorf("failed to get profile by %s: %v", by, err)
+	}
+	return profile, nil
+}
+
+// UpdateProfile изменяет переданные поля профиля одним запросом и возвращает профиль после изменения.
+// Непереданное поле (NULL) сохраняет значение из базы, поэтому параллельные изменения
+// разных полей не затирают друг друга.
+func (r *ProfileRepository) UpdateProfile(userID uint, update models.ProfileUpdate) (models.Profile, error) {
+	query := `
+		INSERT INTO profiles (user_id, display_name, bio, location, website, birthday, updated_at)
+		VALUES ($1, COALESCE($2, ''), COALESCE($3, ''), COALESCE($4, ''), COALESCE($5, ''), NULLIF($6, '')::date, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = COALESCE($2, profiles.display_name),
+			bio = COALESCE($3, profiles.bio),
+			location = COALESCE($4, profiles.location),
+			website = COALESCE($5, profiles.website),
+			birthday = CASE WHEN $6::text IS NULL THEN profiles.birthday ELSE NULLIF($6, '')::date END,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.Exec(query, userID, update.DisplayName, update.Bio, update.Location, update.Website, update.Birthday)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return models.Profile{}, ErrUserNotFound
+		}
+		return models.Profile{}, fmt.Errorf("failed to update profile: %v", err)
+	}
+	return r.GetProfileByUserID(userID)
+}
+
+// imageColumns - столбцы таблицы profiles для каждого вида изображения
+var imageColumns = map[string]string{
+	models.ImageAvatar: "avatar",
+	models.ImageCover:  "cover",
+}
+
+// SetImage заменяет аватар или обложку профиля (nil удаляет изображение) и возвращает прежнее изображение,
+// чтобы вызывающий удалил его файлы. Строка профиля блокируется, поэтому при параллельной замене
+// каждое прежнее изображение будет возвращено ровно один раз.
+func (r *ProfileRepository) SetImage(userID uint, kind string, image *models.Image) (*models.Image, error) {
+	column, ok := imageColumns[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile image kind: %s", kind)
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO profiles (user_id, updated_at) VALUES ($1, NOW()) ON CONFLICT (user_id) DO NOTHING", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set profile %s: %v", kind, err)
+	}
+
+	var previous *models.Image
+	if err := tx.Get(&previous, "SELECT "+column+" FROM profiles WHERE user_id = $1 FOR UPDATE", userID); err != nil {
+		return nil, fmt.Errorf("failed to set profile %s: %v", kind, err)
+	}
+
+	_, err = tx.Exec("UPDATE profiles SET "+column+" = $2, updated_at = NOW() WHERE user_id = $1", userID, image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set profile %s: %v", kind, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return previous, nil
+}
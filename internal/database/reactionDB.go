@@ -1 +1,114 @@
-package database
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// ReactionRepositoryInterface определяет методы для хранения реакций на посты.
+// У пользователя на пост может быть одна реакция: лайк или дизлайк.
+type ReactionRepositoryInterface interface {
+	SetReaction(userID uint, postID, reactionType int) (models.Post, error)
+	RemoveReaction(userID uint, postID int) (models.Post, error)
+	GetReactions(postID int, viewerID uint, limit, offset int) ([]models.PostReaction, error)
+}
+
+// ReactionRepository предоставляет реализацию ReactionRepositoryInterface
+type ReactionRepository struct {
+	db *sqlx.DB
+}
+
+// NewReactionRepository создает новый экземпляр ReactionRepository
+func NewReactionRepository(db *sqlx.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// SetReaction ставит или заменяет реакцию пользователя на пост и возвращает пост с новыми счетчиками.
+// Если поста нет, возвращает ErrPostNotFound.
+func (r *ReactionRepository) SetReaction(userID uint, postID, reactionType int) (models.Post, error) {
+	return r.changeReaction(postID, func(tx *sqlx.Tx) error {
+		query := `
+			INSERT INTO reactions (userId, postId, reactionType, createdAt) VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (userId, postId) DO UPDATE SET reactionType = EXCLUDED.reactionType, createdAt = EXCLUDED.createdAt
+			WHERE reactions.reactionType <> EXCLUDED.reactionType
+		`
+		_, err := tx.Exec(query, userID, postID, reactionType)
+		return err
+	})
+}
+
+// RemoveReaction убирает реакцию пользователя на пост и возвращает пост с новыми счетчиками.
+// Если поста нет, возвращает ErrPostNotFound.
+func (r *ReactionRepository) RemoveReaction(userID uint, postID int) (models.Post, error) {
+	return r.changeReaction(postID, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec("DELETE FROM reactions WHERE userId = $1 AND postId = $2", userID, postID)
+		return err
+	})
+}
+
+// changeReaction изменяет реакции на пост и пересчитывает его счетчики. Строка поста блокируется,
+// поэтому параллельные реакции на один пост не теряют друг друга при пересчете.
+func (r *ReactionRepository) changeReaction(postID int, change func(tx *sqlx.Tx) error) (models.Post, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return models.Post{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.Get(&locked, "SELECT id FROM posts WHERE id = $1 FOR UPDATE", postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Post{}, ErrPostNotFound
+	}
+	if err != nil {
+		return models.Post{}, fmt.Errorf("failed to change reaction: %v", err)
+	}
+
+	if err := change(tx); err != nil {
+		return models.Post{}, fmt.Errorf("failed to change reaction: %v", err)
+	}
+
+	query := `
+		WITH p AS (
+			UPDATE posts SET
+				likeCount = (SELECT COUNT(*) FROM reactions WHERE postId = $1 AND reactionType = $2),
+				dislikeCount = (SELECT COUNT(*) FROM reactions WHERE postId = $1 AND reactionType = $3)
+			WHERE id = $1
+			RETURNING *
+		)
+		SELECT ` + postColumns + ` FROM p
+	`
+	var post models.Post
+	if err := tx.Get(&post, query, postID, models.ReactionLike, models.ReactionDislike); err != nil {
+		return models.Post{}, fmt.Errorf("failed to update reaction counts: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Post{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return normalizePost(post), nil
+}
+
+// GetReactions возвращает реакции на пост, новые первыми. Реакции пользователей, с которыми у зрителя
+// viewerID есть блокировка или которых он заглушил, не показываются.
+func (r *ReactionRepository) GetReactions(postID int, viewerID uint, limit, offset int) ([]models.PostReaction, error) {
+	query := `
+		SELECT u.login, COALESCE(p.display_name, '') AS display_name, p.avatar,
+			r.reactionType = $3 AS is_like, r.createdAt AS created_at
+		FROM reactions r
+		JOIN users u ON u.id = r.userId
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE r.postId = $1 AND NOT ` + hiddenUserCondition("u.id", "$2", true) + `
+		ORDER BY r.createdAt DESC, r.id DESC
+		LIMIT $4 OFFSET $5
+	`
+	reactions := []models.PostReaction{}
+	if err := r.db.Select(&reactions, query, postID, viewerID, models.ReactionLike, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %v", err)
+	}
+	return reactions, nil
+}
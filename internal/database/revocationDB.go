@@ -0,0 +1,74 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenRevocationRepository хранит отозванные access-токены в Postgres,
+// чтобы отзыв действовал на всех экземплярах сервиса.
+// Реализует tokenmanager.RevocationStore.
+type TokenRevocationRepository struct {
+	db *sqlx.DB
+}
+
+// NewTokenRevocationRepository создает новый экземпляр TokenRevocationRepository
+func NewTokenRevocationRepository(db *sqlx.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+// RevokeToken вносит jti в список отзыва и попутно удаляет записи об уже истекших токенах
+func (r *TokenRevocationRepository) RevokeToken(tokenID string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_tokens (token_id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (token_id) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, tokenID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+
+	if _, err := r.db.Exec("DELETE FROM revoked_tokens WHERE expires_at < $1", time.Now()); err != nil {
+		return fmt.Errorf("failed to delete expired revoked tokens: %v", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked проверяет, внесен ли jti в список отзыва
+func (r *TokenRevocationRepository) IsTokenRevoked(tokenID string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = $1)"
+	if err := r.db.Get(&exists, query, tokenID); err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %v", err)
+	}
+	return exists, nil
+}
+
+// RevokeUserTokens отзывает все токены пользователя, выпущенные не позже before
+func (r *TokenRevocationRepository) RevokeUserTokens(userID uint, before time.Time) error {
+	query := `
+		INSERT INTO token_watermarks (user_id, revoked_before) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET revoked_before = GREATEST(token_watermarks.revoked_before, EXCLUDED.revoked_before)
+	`
+	if _, err := r.db.Exec(query, userID, before); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %v", err)
+	}
+	return nil
+}
+
+// UserTokensRevokedBefore возвращает отметку пользователя или нулевое время, если ее нет
+func (r *TokenRevocationRepository) UserTokensRevokedBefore(userID uint) (time.Time, error) {
+	var before time.Time
+	query := "SELECT revoked_before FROM token_watermarks WHERE user_id = $1"
+	err := r.db.Get(&before, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get token watermark: %v", err)
+	}
+	return before, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RoleRepositoryInterface определяет методы для работы с ролями и правами пользователей
+type RoleRepositoryInterface interface {
+	SetRole(userID uint, role string) error
+	GetPermissions(userID uint) ([]string, error)
+	GrantPermission(userID uint, permission string) error
+	RevokePermission(userID uint, permission string) error
+}
+
+// RoleRepository предоставляет реализацию RoleRepositoryInterface
+type RoleRepository struct {
+	db *sqlx.DB
+}
+
+// NewRoleRepository создает новый экземпляр RoleRepository
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// SetRole меняет роль пользователя
+func (r *RoleRepository) SetRole(userID uint, role string) error {
+	res, err := r.db.Exec("UPDATE users SET role = $2 WHERE id = $1", userID, role)
+	if err != nil {
+		return fmt.Errorf("failed to set role: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetPermissions возвращает права, выданные пользователю сверх прав его роли
+func (r *RoleRepository) GetPermissions(userID uint) ([]string, error) {
+	permissions := []string{}
+	query := "SELECT permission FROM user_permissions WHERE user_id = $1 ORDER BY permission"
+	if err := r.db.Select(&permissions, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %v", err)
+	}
+	return permissions, nil
+}
+
+// GrantPermission выдает пользователю право. Повторная выдача ничего не меняет.
+func (r *RoleRepository) GrantPermission(userID uint, permission string) error {
+	query := `
+		INSERT INTO user_permissions (user_id, permission) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := r.db.Exec(query, userID, permission); err != nil {
+		return fmt.Errorf("failed to grant permission: %v", err)
+	}
+	return nil
+}
+
+// RevokePermission отзывает выданное пользователю право
+func (r *RoleRepository) RevokePermission(userID uint, permission string) error {
+	query := "DELETE FROM user_permissions WHERE user_id = $1 AND permission = $2"
+	if _, err := r.db.Exec(query, userID, permission); err != nil {
+		return fmt.Errorf("failed to revoke permission: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/pkg/tokenmanager"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// SigningKeyRepository хранит ключи подписи access-токенов в Postgres,
+// чтобы все экземпляры сервиса подписывали и проверяли токены одними ключами.
+// Реализует tokenmanager.KeyStore.
+type SigningKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewSigningKeyRepository создает новый экземпляр SigningKeyRepository
+func NewSigningKeyRepository(db *sqlx.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+type signingKeyRow struct {
+	ID          string    `db:"kid"`
+	PrivateKey  string    `db:"private_key"`
+	ActivatesAt time.Time `db:"activates_at"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// LoadSigningKeys возвращает все ключи в порядке вступления в действие
+func (r *SigningKeyRepository) LoadSigningKeys() ([]tokenmanager.StoredKey, error) {
+	var rows []signingKeyRow
+	query := "SELECT kid, private_key, activates_at, created_at FROM signing_keys ORDER BY activates_at"
+	if err := r.db.Select(&rows, query); err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %v", err)
+	}
+
+	keys := make([]tokenmanager.StoredKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, tokenmanager.StoredKey{
+			ID:          row.ID,
+			PrivateKey:  []byte(row.PrivateKey),
+			ActivatesAt: row.ActivatesAt,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+	return keys, nil
+}
+
+// AddSigningKey сохраняет ключ, если нет ключа, вступающего в действие позже after
+func (r *SigningKeyRepository) AddSigningKey(key tokenmanager.StoredKey, after time.Time) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка не дает двум экземплярам одновременно добавить следующий ключ, чтение ключей она не останавливает
+	if _, err := tx.Exec("LOCK TABLE signing_keys IN EXCLUSIVE MODE"); err != nil {
+		return false, fmt.Errorf("failed to lock signing keys: %v", err)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO signing_keys (kid, private_key, activates_at, created_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM signing_keys WHERE activates_at > $5)
+	`, key.ID, string(key.PrivateKey), key.ActivatesAt, key.CreatedAt, after)
+	if err != nil {
+		return false, fmt.Errorf("failed to add signing key: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteSigningKeys удаляет ключи, которые больше не проверяют токены
+func (r *SigningKeyRepository) DeleteSigningKeys(ids []string) error {
+	if _, err := r.db.Exec("DELETE FROM signing_keys WHERE kid = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete signing keys: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/jmoiron/sqlx"
+)
+
+// OneTimeTokenRepositoryInterface определяет методы для работы с одноразовыми токенами
+type OneTimeTokenRepositoryInterface interface {
+	CreateToken(token models.OneTimeToken) error
+	GetToken(purpose, tokenHash string) (models.OneTimeToken, error)
+	ConsumeToken(purpose, tokenHash string) (models.OneTimeToken, error)
+	CountTokensSince(userID uint, purpose string, since time.Time) (int, time.Time, time.Time, error)
+	InvalidateTokens(userID uint, purpose string) error
+}
+
+// ErrTokenNotFound возвращается, если токен не найден, уже использован или истек
+var ErrTokenNotFound = errors.New("token not found")
+
+// OneTimeTokenRepository предоставляет реализацию OneTimeTokenRepositoryInterface
+type OneTimeTokenRepository struct {
+	db *sqlx.DB
+}
+
+// NewOneTimeTokenRepository создает новый экземпляр OneTimeTokenRepository
+func NewOneTimeTokenRepository(db *sqlx.DB) *OneTimeTokenRepository {
+	return &OneTimeTokenRepository{db: db}
+}
+
+// CreateToken сохраняет хеш нового одноразового токена
+func (r *OneTimeTokenRepository) CreateToken(token models.OneTimeToken) error {
+	query := `
+		INSERT INTO one_time_tokens (user_id, purpose, token_hash, code_challenge, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := r.db.Exec(query, token.UserID, token.Purpose, token.TokenHash, token.CodeChallenge, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create token: %v", err)
+	}
+	return nil
+}
+
+// GetToken возвращает действующий токен, не помечая его использованным
+func (r *OneTimeTokenRepository) GetToken(purpose, tokenHash string) (models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	query := `
+		SELECT * FROM one_time_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+	`
+	err := r.db.Get(&token, query, tokenHash, purpose, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OneTimeToken{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return models.OneTimeToken{}, fmt.Errorf("failed to get token: %v", err)
+	}
+	return token, nil
+}
+
+// ConsumeToken атомарно помечает действующий токен использованным и возвращает его.
+// Повторное использование токена возвращает ErrTokenNotFound.
+func (r *OneTimeTokenRepository) ConsumeToken(purpose, tokenHash string) (models.OneTimeToken, error) {
+	var token models.OneTimeToken
+	query := `
+		UPDATE one_time_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING *
+	`
+	err := r.db.Get(&token, query, tokenHash, purpose, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OneTimeToken{}, ErrTokenNotFound
+	}
+	if err != nil {
+		return models.OneTimeToken{}, fmt.Errorf("failed to consume token: %v", err)
+	}
+	return token, nil
+}
+
+// CountTokensSince возвращает число токенов, выпущенных пользователю начиная с since,
+// и время выпуска первого и последнего из них
+func (r *OneTimeTokenRepository) CountTokensSince(userID uint, purpose string, since time.Time) (int, time.Time, time.Time, error) {
+	var res struct {
+		Count int          `db:"count"`
+		First sql.NullTime `db:"first"`
+		Last  sql.NullTime `db:"last"`
+	}
+	query := `
+		SELECT COUNT(*) AS count, MIN(created_at) AS first, MAX(created_at) AS last FROM one_time_tokens
+		WHERE user_id = $1 AND purpose = $2 AND created_at >= $3
+	`
+	if err := r.db.Get(&res, query, userID, purpose, since); err != nil {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("failed to count tokens: %v", err)
+	}
+	return res.Count, res.First.Time, res.Last.Time, nil
+}
+
+// InvalidateTokens делает недействительными все неиспользованные токены пользователя с этим назначением
+func (r *OneTimeTokenRepository) InvalidateTokens(userID uint, purpose string) error {
+	query := "UPDATE one_time_tokens SET used_at = $3 WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL"
+	if _, err := r.db.Exec(query, userID, purpose, time.Now()); err != nil {
+		return fmt.Errorf("failed to invalidate tokens: %v", err)
+	}
+	return nil
+}
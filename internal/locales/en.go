@@ -0,0 +1,247 @@
+package locales
+
+import "github.com/Saveliy12/prod2/pkg/i18n"
+
+var en = map[string]i18n.Message{
+	// Общие ошибки полей
+	"*.required":                {Other: "{field} is required"},
+	"*.invalid_type":            {Other: "{field} must be of type {type}"},
+	"*.invalid_visibility":      {Other: "{field} visibility must be one of: {allowed}"},
+	"pagination.invalid_limit":  {Other: "limit must be a number from 1 to {max}"},
+	"pagination.invalid_offset": {Other: "offset must be a non-negative number"},
+	"body.malformed":            {Other: "Request body is malformed"},
+	"internal_error":            {Other: "Internal server error"},
+	"rate_limited": {Count: "seconds",
+		One:   "Too many requests, retry after {seconds} second",
+		Other: "Too many requests, retry after {seconds} seconds"},
+
+	// Регистрация
+	"login.required": {Other: "Login is required"},
+	"login.too_short": {Count: "min",
+		One:   "Min login length is {min} character",
+		Other: "Min login length is {min} characters"},
+	"login.too_long": {Count: "max",
+		One:   "Max login length is {max} character",
+		Other: "Max login length is {max} characters"},
+	"login.invalid_chars": {Other: "The login can contain only Latin letters, numbers and hyphens"},
+	"login.taken":         {Other: "This login is already taken"},
+	"email.required":      {Other: "Email is required"},
+	"email.too_long": {Count: "max",
+		One:   "Max email length is {max} character",
+		Other: "Max email length is {max} characters"},
+	"email.invalid_format": {Other: "Invalid email format"},
+	"email.taken":          {Other: "An account with this email already exists"},
+	"phone.required":       {Other: "Phone number is required"},
+	"phone.too_long": {Count: "max",
+		One:   "Max phone number length is {max} character",
+		Other: "Max phone number length is {max} characters"},
+	"phone.invalid_format": {Other: "Invalid phone number format"},
+	"phone.taken":          {Other: "An account with this phone number already exists"},
+	"locale.unsupported":   {Other: "Unsupported language, use one of: {supported}"},
+
+	// Пароль
+	"password.required": {Other: "Password is required"},
+	"password.too_short": {Count: "min",
+		One:   "Min password length is {min} character",
+		Other: "Min password length is {min} characters"},
+	"password.too_long": {Count: "max",
+		One:   "Max password length is {max} character",
+		Other: "Max password length is {max} characters"},
+	"password.no_lowercase":           {Other: "Password must contain at least one lowercase letter"},
+	"password.no_uppercase":           {Other: "Password must contain at least one uppercase letter"},
+	"password.no_digit":               {Other: "Password must contain at least one digit"},
+	"password.no_special":             {Other: "Password must contain at least one special character"},
+	"password.contains_personal_info": {Other: "Password must not contain your login or email"},
+	"password.too_common":             {Other: "Password is too common or has appeared in a data breach"},
+	"password.too_weak":               {Other: "Password is too easy to guess"},
+	"password.invalid_reset_token":    {Other: "Invalid or expired password reset token"},
+	"password.reset_failed":           {Other: "Failed to reset password"},
+	"password.reset_sent":             {Other: "If this email is registered, a password reset link has been sent"},
+
+	// Вход и сессии
+	"auth.unauthorized":                  {Other: "Unauthorized"},
+	"auth.header_missing":                {Other: "Authorization header is missing"},
+	"auth.header_format":                 {Other: "Authorization header format must be Bearer {token}"},
+	"auth.invalid_token":                 {Other: "Invalid or expired token"},
+	"auth.token_revoked":                 {Other: "Token has been revoked"},
+	"auth.token_verify_failed":           {Other: "Failed to verify token"},
+	"auth.third_party_token":             {Other: "Third-party application tokens are not accepted here"},
+	"auth.missing_scope":                 {Other: "Token has no scope: {scope}"},
+	"auth.permission_denied":             {Other: "Permission denied: {permission}"},
+	"auth.invalid_personal_access_token": {Other: "Invalid or expired personal access token"},
+	"auth.invalid_credentials":           {Other: "Invalid login or password"},
+	"auth.account_locked": {Count: "minutes",
+		One:   "Account is temporarily locked, retry after {minutes} minute",
+		Other: "Account is temporarily locked, retry after {minutes} minutes"},
+	"auth.invalid_refresh_token": {Other: "Invalid or expired refresh token"},
+	"auth.refresh_token_reused":  {Other: "Refresh token reuse detected, the session has been revoked"},
+	"auth.session_not_found":     {Other: "Session not found"},
+	"auth.invalid_session_id":    {Other: "Invalid session id"},
+	"auth.register_failed":       {Other: "Failed to register user"},
+	"auth.authenticate_failed":   {Other: "Failed to authenticate user"},
+	"auth.authorize_failed":      {Other: "Failed to authorize user"},
+	"auth.refresh_failed":        {Other: "Failed to refresh tokens"},
+	"auth.logout_failed":         {Other: "Failed to logout"},
+	"auth.revoke_token_failed":   {Other: "Failed to revoke token"},
+	"auth.sessions_failed":       {Other: "Failed to get sessions"},
+	"auth.revoke_session_failed": {Other: "Failed to revoke session"},
+	"auth.unlock_failed":         {Other: "Failed to unlock login"},
+	"auth.locale_failed":         {Other: "Failed to update language"},
+
+	// Двухфакторная аутентификация
+	"mfa.invalid_code":      {Other: "Invalid two-factor authentication code"},
+	"mfa.invalid_token":     {Other: "Invalid or expired mfa token"},
+	"mfa.already_enabled":   {Other: "Two-factor authentication is already enabled"},
+	"mfa.not_enabled":       {Other: "Two-factor authentication is not enabled"},
+	"mfa.setup_not_started": {Other: "Two-factor authentication setup is not started"},
+	"mfa.required":          {Other: "Two-factor authentication must be enabled"},
+	"mfa.session_required":  {Other: "Sign in again with a two-factor authentication code"},
+	"mfa.check_failed":      {Other: "Failed to check two-factor authentication"},
+	"mfa.start_failed":      {Other: "Failed to start two-factor authentication"},
+	"mfa.error":             {Other: "Two-factor authentication error"},
+
+	// Подтверждение почты и вход по ссылке
+	"email.invalid_verification_token":  {Other: "Invalid or expired verification token"},
+	"email.already_verified":            {Other: "Email is already verified"},
+	"email.not_verified":                {Other: "Email is not verified"},
+	"email.verification_required":       {Other: "Email must be verified before posting"},
+	"email.check_failed":                {Other: "Failed to check email verification"},
+	"email.confirm_failed":              {Other: "Failed to confirm email"},
+	"email.send_failed":                 {Other: "Failed to send verification email"},
+	"magic_link.invalid":                {Other: "Invalid or expired login link"},
+	"magic_link.invalid_code_challenge": {Other: "codeChallenge must be a base64url-encoded SHA-256 of codeVerifier"},
+	"magic_link.failed":                 {Other: "Failed to sign in"},
+	"magic_link.sent":                   {Other: "If this email is registered, a sign-in link has been sent"},
+
+	// Внешние провайдеры
+	"oidc.unknown_provider":                {Other: "Unknown identity provider"},
+	"oidc.invalid_state":                   {Other: "Invalid or expired login state"},
+	"oidc.login_failed":                    {Other: "Identity provider login failed"},
+	"oidc.email_required":                  {Other: "Identity provider did not share an email address"},
+	"oidc.invalid_signup_token":            {Other: "Invalid or expired signup token"},
+	"oidc.email_taken":                     {Other: "An account with this email already exists, sign in with password and link the provider in settings"},
+	"oidc.identity_linked_to_another_user": {Other: "This identity is linked to another account"},
+	"oidc.link_user_mismatch":              {Other: "Identity linking must be completed by the account that started it"},
+	"oidc.identity_not_found":              {Other: "Identity not found"},
+	"oidc.last_login_method":               {Other: "Cannot unlink the only sign-in method, set a password first"},
+	"oidc.invalid_identity_id":             {Other: "Invalid identity id"},
+	"oidc.provider_unavailable":            {Other: "Identity provider is unavailable"},
+	"oidc.failed":                          {Other: "Failed to sign in with identity provider"},
+	"oidc.identities_failed":               {Other: "Failed to get identities"},
+	"oidc.unlink_failed":                   {Other: "Failed to unlink identity"},
+
+	// Персональные токены и приложения OAuth
+	"pat.invalid_request":         {Other: "The token must have a name of up to 100 characters, known scopes and expire within a year"},
+	"pat.too_many":                {Other: "Too many personal access tokens"},
+	"pat.not_found":               {Other: "Personal access token not found"},
+	"pat.invalid_id":              {Other: "Invalid token id"},
+	"pat.create_failed":           {Other: "Failed to create token"},
+	"pat.list_failed":             {Other: "Failed to get tokens"},
+	"pat.revoke_failed":           {Other: "Failed to revoke token"},
+	"oauth.invalid_client":        {Other: "The application must have a name of up to 100 characters, 1-10 valid redirect URIs and known scopes"},
+	"oauth.too_many_clients":      {Other: "Too many applications"},
+	"oauth.client_not_found":      {Other: "Application not found"},
+	"oauth.consent_not_found":     {Other: "The application has no access to your account"},
+	"oauth.register_failed":       {Other: "Failed to register application"},
+	"oauth.clients_failed":        {Other: "Failed to get applications"},
+	"oauth.delete_failed":         {Other: "Failed to delete application"},
+	"oauth.consents_failed":       {Other: "Failed to get applications with access"},
+	"oauth.revoke_consent_failed": {Other: "Failed to revoke application access"},
+
+	// Администрирование и журнал безопасности
+	"admin.unknown_role":         {Other: "Unknown role"},
+	"admin.unknown_permission":   {Other: "Unknown permission"},
+	"admin.invalid_user_id":      {Other: "Invalid user id"},
+	"admin.get_access_failed":    {Other: "Failed to get user access"},
+	"admin.update_access_failed": {Other: "Failed to update user access"},
+	"audit.invalid_filter":       {Other: "Invalid {name}"},
+	"audit.invalid_time":         {Other: "Invalid {name}, expected RFC 3339 time"},
+	"audit.get_failed":           {Other: "Failed to get audit events"},
+	"audit.my_events_failed":     {Other: "Failed to get security events"},
+
+	// Телефон
+	"phone.missing":           {Other: "Account has no phone number"},
+	"phone.already_verified":  {Other: "Phone number is already verified"},
+	"phone.invalid_code":      {Other: "Invalid or expired verification code"},
+	"phone.attempts_exceeded": {Other: "Too many attempts, request a new code"},
+	"phone.get_failed":        {Other: "Failed to get phone number"},
+	"phone.send_failed":       {Other: "Failed to send verification code"},
+	"phone.confirm_failed":    {Other: "Failed to confirm phone number"},
+
+	// Профиль
+	"user.not_found": {Other: "User not found"},
+	"displayName.too_long": {Count: "max",
+		One:   "Max display name length is {max} character",
+		Other: "Max display name length is {max} characters"},
+	"displayName.invalid_chars": {Other: "The display name must not contain control characters"},
+	"bio.too_long": {Count: "max",
+		One:   "Max bio length is {max} character",
+		Other: "Max bio length is {max} characters"},
+	"bio.invalid_chars": {Other: "The bio must not contain control characters"},
+	"location.too_long": {Count: "max",
+		One:   "Max location length is {max} character",
+		Other: "Max location length is {max} characters"},
+	"location.invalid_chars": {Other: "The location must not contain control characters"},
+	"website.too_long": {Count: "max",
+		One:   "Max website length is {max} character",
+		Other: "Max website length is {max} characters"},
+	"website.invalid_format":  {Other: "The website must be an absolute http or https link"},
+	"birthday.invalid_format": {Other: "The birthday must be a date in YYYY-MM-DD format"},
+	"birthday.out_of_range":   {Other: "The birthday must be between 1900-01-01 and today"},
+	"profile.get_failed":      {Other: "Failed to get profile"},
+	"profile.update_failed":   {Other: "Failed to update profile"},
+
+	// Приватность и друзья
+	"as.invalid_viewer":     {Other: "as must be friend or stranger"},
+	"privacy.hidden":        {Other: "The user has hidden this from you"},
+	"privacy.get_failed":    {Other: "Failed to get privacy settings"},
+	"privacy.update_failed": {Other: "Failed to update privacy settings"},
+	"friends.get_failed":    {Other: "Failed to get friends"},
+	"friends.add_failed":    {Other: "Failed to add friend"},
+	"friends.remove_failed": {Other: "Failed to remove friend"},
+	"friends.self":          {Other: "You cannot add yourself to friends"},
+
+	// Блокировка и заглушение
+	"block.self":           {Other: "You cannot block or mute yourself"},
+	"block.block_failed":   {Other: "Failed to block user"},
+	"block.unblock_failed": {Other: "Failed to unblock user"},
+	"block.mute_failed":    {Other: "Failed to mute user"},
+	"block.unmute_failed":  {Other: "Failed to unmute user"},
+	"block.list_failed":    {Other: "Failed to get the list of users"},
+
+	// Изображения
+	"file.required":           {Other: "Attach an image in the file field"},
+	"file.too_large":          {Other: "The file must not exceed {max} MB"},
+	"file.unsupported_format": {Other: "The file must be a JPEG, PNG, GIF or WebP image"},
+	"file.too_many_pixels": {Count: "max",
+		One:   "The image must not exceed {max} megapixel",
+		Other: "The image must not exceed {max} megapixels"},
+	"file.too_small":      {Other: "The image must be at least {width}x{height} pixels"},
+	"media.upload_failed": {Other: "Failed to upload image"},
+	"media.delete_failed": {Other: "Failed to delete image"},
+	"media.not_found":     {Other: "File not found"},
+	"media.read_failed":   {Other: "Failed to read file"},
+	"media.link_invalid":  {Other: "The file link is invalid"},
+	"media.link_expired":  {Other: "The file link has expired, reload the page to get a new one"},
+
+	// Посты
+	"content.required": {Other: "The post text is required"},
+	"content.too_long": {Count: "max",
+		One:   "Max post length is {max} character",
+		Other: "Max post length is {max} characters"},
+	"content.invalid_chars": {Other: "The post text must not contain control characters"},
+	"tags.too_many": {Count: "max",
+		One:   "A post can have at most {max} tag",
+		Other: "A post can have at most {max} tags"},
+	"tags.invalid_format": {Other: "Tags must be 1 to {max} letters, digits or underscores"},
+	"posts.create_failed": {Other: "Failed to publish post"},
+	"posts.not_found":     {Other: "Post not found"},
+	"posts.invalid_id":    {Other: "Invalid post id"},
+	"posts.get_failed":    {Other: "Failed to get posts"},
+	"posts.feed_failed":   {Other: "Failed to get feed"},
+
+	// Реакции на посты
+	"reactions.set_failed":    {Other: "Failed to react to post"},
+	"reactions.remove_failed": {Other: "Failed to remove reaction"},
+	"reactions.get_failed":    {Other: "Failed to get reactions"},
+}
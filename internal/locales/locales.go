@@ -0,0 +1,14 @@
+package locales
+
+import "github.com/Saveliy12/prod2/pkg/i18n"
+
+// Bundle - каталоги сообщений API. Ключ сообщения - код ошибки: login.too_long, auth.unauthorized.
+// Для кодов ошибок полей, которых нет в каталоге, используется общее сообщение *.<причина> с параметром field.
+var Bundle = newBundle()
+
+func newBundle() *i18n.Bundle {
+	bundle := i18n.NewBundle("en")
+	bundle.Add("en", en)
+	bundle.Add("ru", ru)
+	return bundle
+}
@@ -0,0 +1,277 @@
+package locales
+
+import "github.com/Saveliy12/prod2/pkg/i18n"
+
+var ru = map[string]i18n.Message{
+	// Общие ошибки полей
+	"*.required":                {Other: "Поле {field} обязательно"},
+	"*.invalid_type":            {Other: "Поле {field} должно иметь тип {type}"},
+	"*.invalid_visibility":      {Other: "Видимость поля {field} должна быть одной из: {allowed}"},
+	"pagination.invalid_limit":  {Other: "limit должен быть числом от 1 до {max}"},
+	"pagination.invalid_offset": {Other: "offset должен быть неотрицательным числом"},
+	"body.malformed":            {Other: "Не удалось разобрать тело запроса"},
+	"internal_error":            {Other: "Внутренняя ошибка сервера"},
+	"rate_limited": {Count: "seconds",
+		One:   "Слишком много запросов, повторите через {seconds} секунду",
+		Few:   "Слишком много запросов, повторите через {seconds} секунды",
+		Many:  "Слишком много запросов, повторите через {seconds} секунд",
+		Other: "Слишком много запросов, повторите позже"},
+
+	// Регистрация
+	"login.required": {Other: "Укажите логин"},
+	"login.too_short": {Count: "min",
+		One:   "Логин должен содержать не меньше {min} символа",
+		Few:   "Логин должен содержать не меньше {min} символов",
+		Many:  "Логин должен содержать не меньше {min} символов",
+		Other: "Логин слишком короткий"},
+	"login.too_long": {Count: "max",
+		One:   "Логин может содержать не больше {max} символа",
+		Few:   "Логин может содержать не больше {max} символов",
+		Many:  "Логин может содержать не больше {max} символов",
+		Other: "Логин слишком длинный"},
+	"login.invalid_chars": {Other: "Логин может содержать только латинские буквы, цифры и дефис"},
+	"login.taken":         {Other: "Этот логин уже занят"},
+	"email.required":      {Other: "Укажите адрес почты"},
+	"email.too_long": {Count: "max",
+		One:   "Адрес почты может содержать не больше {max} символа",
+		Few:   "Адрес почты может содержать не больше {max} символов",
+		Many:  "Адрес почты может содержать не больше {max} символов",
+		Other: "Адрес почты слишком длинный"},
+	"email.invalid_format": {Other: "Неверный формат адреса почты"},
+	"email.taken":          {Other: "Аккаунт с такой почтой уже существует"},
+	"phone.required":       {Other: "Укажите номер телефона"},
+	"phone.too_long": {Count: "max",
+		One:   "Номер телефона может содержать не больше {max} символа",
+		Few:   "Номер телефона может содержать не больше {max} символов",
+		Many:  "Номер телефона может содержать не больше {max} символов",
+		Other: "Номер телефона слишком длинный"},
+	"phone.invalid_format": {Other: "Неверный формат номера телефона"},
+	"phone.taken":          {Other: "Аккаунт с таким номером телефона уже существует"},
+	"locale.unsupported":   {Other: "Язык не поддерживается, доступны: {supported}"},
+
+	// Пароль
+	"password.required": {Other: "Укажите пароль"},
+	"password.too_short": {Count: "min",
+		One:   "Пароль должен содержать не меньше {min} символа",
+		Few:   "Пароль должен содержать не меньше {min} символов",
+		Many:  "Пароль должен содержать не меньше {min} символов",
+		Other: "Пароль слишком короткий"},
+	"password.too_long": {Count: "max",
+		One:   "Пароль может содержать не больше {max} символа",
+		Few:   "Пароль может содержать не больше {max} символов",
+		Many:  "Пароль может содержать не больше {max} символов",
+		Other: "Пароль слишком длинный"},
+	"password.no_lowercase":           {Other: "Пароль должен содержать хотя бы одну строчную букву"},
+	"password.no_uppercase":           {Other: "Пароль должен содержать хотя бы одну заглавную букву"},
+	"password.no_digit":               {Other: "Пароль должен содержать хотя бы одну цифру"},
+	"password.no_special":             {Other: "Пароль должен содержать хотя бы один специальный символ"},
+	"password.contains_personal_info": {Other: "Пароль не должен содержать логин или адрес почты"},
+	"password.too_common":             {Other: "Пароль слишком распространен или встречался в утечках"},
+	"password.too_weak":               {Other: "Пароль слишком легко подобрать"},
+	"password.invalid_reset_token":    {Other: "Ссылка для сброса пароля недействительна или устарела"},
+	"password.reset_failed":           {Other: "Не удалось сбросить пароль"},
+	"password.reset_sent":             {Other: "Если этот адрес зарегистрирован, на него отправлена ссылка для сброса пароля"},
+
+	// Вход и сессии
+	"auth.unauthorized":                  {Other: "Требуется авторизация"},
+	"auth.header_missing":                {Other: "Отсутствует заголовок Authorization"},
+	"auth.header_format":                 {Other: "Заголовок Authorization должен иметь вид Bearer {token}"},
+	"auth.invalid_token":                 {Other: "Токен недействителен или истек"},
+	"auth.token_revoked":                 {Other: "Токен отозван"},
+	"auth.token_verify_failed":           {Other: "Не удалось проверить токен"},
+	"auth.third_party_token":             {Other: "Токены сторонних приложений здесь не принимаются"},
+	"auth.missing_scope":                 {Other: "У токена нет области действия {scope}"},
+	"auth.permission_denied":             {Other: "Недостаточно прав: {permission}"},
+	"auth.invalid_personal_access_token": {Other: "Персональный токен недействителен или истек"},
+	"auth.invalid_credentials":           {Other: "Неверный логин или пароль"},
+	"auth.account_locked": {Count: "minutes",
+		One:   "Вход временно заблокирован, повторите через {minutes} минуту",
+		Few:   "Вход временно заблокирован, повторите через {minutes} минуты",
+		Many:  "Вход временно заблокирован, повторите через {minutes} минут",
+		Other: "Вход временно заблокирован, повторите позже"},
+	"auth.invalid_refresh_token": {Other: "Refresh-токен недействителен или истек"},
+	"auth.refresh_token_reused":  {Other: "Refresh-токен использован повторно, сессия завершена"},
+	"auth.session_not_found":     {Other: "Сессия не найдена"},
+	"auth.invalid_session_id":    {Other: "Неверный идентификатор сессии"},
+	"auth.register_failed":       {Other: "Не удалось зарегистрировать пользователя"},
+	"auth.authenticate_failed":   {Other: "Не удалось проверить логин и пароль"},
+	"auth.authorize_failed":      {Other: "Не удалось выполнить вход"},
+	"auth.refresh_failed":        {Other: "Не удалось обновить токены"},
+	"auth.logout_failed":         {Other: "Не удалось выйти"},
+	"auth.revoke_token_failed":   {Other: "Не удалось отозвать токен"},
+	"auth.sessions_failed":       {Other: "Не удалось получить список сессий"},
+	"auth.revoke_session_failed": {Other: "Не удалось завершить сессию"},
+	"auth.unlock_failed":         {Other: "Не удалось разблокировать вход"},
+	"auth.locale_failed":         {Other: "Не удалось изменить язык"},
+
+	// Двухфакторная аутентификация
+	"mfa.invalid_code":      {Other: "Неверный код двухфакторной аутентификации"},
+	"mfa.invalid_token":     {Other: "Токен двухфакторной аутентификации недействителен или истек"},
+	"mfa.already_enabled":   {Other: "Двухфакторная аутентификация уже включена"},
+	"mfa.not_enabled":       {Other: "Двухфакторная аутентификация не включена"},
+	"mfa.setup_not_started": {Other: "Настройка двухфакторной аутентификации не начата"},
+	"mfa.required":          {Other: "Необходимо включить двухфакторную аутентификацию"},
+	"mfa.session_required":  {Other: "Войдите заново с кодом двухфакторной аутентификации"},
+	"mfa.check_failed":      {Other: "Не удалось проверить двухфакторную аутентификацию"},
+	"mfa.start_failed":      {Other: "Не удалось начать двухфакторную аутентификацию"},
+	"mfa.error":             {Other: "Ошибка двухфакторной аутентификации"},
+
+	// Подтверждение почты и вход по ссылке
+	"email.invalid_verification_token":  {Other: "Ссылка для подтверждения почты недействительна или устарела"},
+	"email.already_verified":            {Other: "Почта уже подтверждена"},
+	"email.not_verified":                {Other: "Почта не подтверждена"},
+	"email.verification_required":       {Other: "Подтвердите почту, чтобы публиковать записи"},
+	"email.check_failed":                {Other: "Не удалось проверить подтверждение почты"},
+	"email.confirm_failed":              {Other: "Не удалось подтвердить почту"},
+	"email.send_failed":                 {Other: "Не удалось отправить письмо для подтверждения"},
+	"magic_link.invalid":                {Other: "Ссылка для входа недействительна или устарела"},
+	"magic_link.invalid_code_challenge": {Other: "codeChallenge должен быть SHA-256 от codeVerifier в кодировке base64url"},
+	"magic_link.failed":                 {Other: "Не удалось выполнить вход"},
+	"magic_link.sent":                   {Other: "Если этот адрес зарегистрирован, на него отправлена ссылка для входа"},
+
+	// Внешние провайдеры
+	"oidc.unknown_provider":                {Other: "Неизвестный провайдер входа"},
+	"oidc.invalid_state":                   {Other: "Попытка входа недействительна или устарела"},
+	"oidc.login_failed":                    {Other: "Не удалось войти через провайдера"},
+	"oidc.email_required":                  {Other: "Провайдер не передал адрес почты"},
+	"oidc.invalid_signup_token":            {Other: "Токен регистрации недействителен или истек"},
+	"oidc.email_taken":                     {Other: "Аккаунт с такой почтой уже существует, войдите по паролю и привяжите провайдера в настройках"},
+	"oidc.identity_linked_to_another_user": {Other: "Этот аккаунт провайдера привязан к другому пользователю"},
+	"oidc.link_user_mismatch":              {Other: "Привязку должен завершить тот же пользователь, который ее начал"},
+	"oidc.identity_not_found":              {Other: "Привязка не найдена"},
+	"oidc.last_login_method":               {Other: "Нельзя отвязать единственный способ входа, сначала задайте пароль"},
+	"oidc.invalid_identity_id":             {Other: "Неверный идентификатор привязки"},
+	"oidc.provider_unavailable":            {Other: "Провайдер входа недоступен"},
+	"oidc.failed":                          {Other: "Не удалось войти через провайдера"},
+	"oidc.identities_failed":               {Other: "Не удалось получить список привязок"},
+	"oidc.unlink_failed":                   {Other: "Не удалось отвязать провайдера"},
+
+	// Персональные токены и приложения OAuth
+	"pat.invalid_request":         {Other: "У токена должно быть имя до 100 символов, известные области действия и срок не больше года"},
+	"pat.too_many":                {Other: "Слишком много персональных токенов"},
+	"pat.not_found":               {Other: "Персональный токен не найден"},
+	"pat.invalid_id":              {Other: "Неверный идентификатор токена"},
+	"pat.create_failed":           {Other: "Не удалось создать токен"},
+	"pat.list_failed":             {Other: "Не удалось получить токены"},
+	"pat.revoke_failed":           {Other: "Не удалось отозвать токен"},
+	"oauth.invalid_client":        {Other: "У приложения должно быть имя до 100 символов, от 1 до 10 корректных адресов возврата и известные области действия"},
+	"oauth.too_many_clients":      {Other: "Слишком много приложений"},
+	"oauth.client_not_found":      {Other: "Приложение не найдено"},
+	"oauth.consent_not_found":     {Other: "У приложения нет доступа к вашему аккаунту"},
+	"oauth.register_failed":       {Other: "Не удалось зарегистрировать приложение"},
+	"oauth.clients_failed":        {Other: "Не удалось получить приложения"},
+	"oauth.delete_failed":         {Other: "Не удалось удалить приложение"},
+	"oauth.consents_failed":       {Other: "Не удалось получить приложения с доступом"},
+	"oauth.revoke_consent_failed": {Other: "Не удалось отозвать доступ приложения"},
+
+	// Администрирование и журнал безопасности
+	"admin.unknown_role":         {Other: "Неизвестная роль"},
+	"admin.unknown_permission":   {Other: "Неизвестное право"},
+	"admin.invalid_user_id":      {Other: "Неверный идентификатор пользователя"},
+	"admin.get_access_failed":    {Other: "Не удалось получить права пользователя"},
+	"admin.update_access_failed": {Other: "Не удалось изменить права пользователя"},
+	"audit.invalid_filter":       {Other: "Неверное значение параметра {name}"},
+	"audit.invalid_time":         {Other: "Неверное значение параметра {name}, ожидается время в формате RFC 3339"},
+	"audit.get_failed":           {Other: "Не удалось получить события журнала"},
+	"audit.my_events_failed":     {Other: "Не удалось получить события безопасности"},
+
+	// Телефон
+	"phone.missing":           {Other: "В аккаунте не указан номер телефона"},
+	"phone.already_verified":  {Other: "Номер телефона уже подтвержден"},
+	"phone.invalid_code":      {Other: "Код подтверждения неверен или устарел"},
+	"phone.attempts_exceeded": {Other: "Слишком много попыток, запросите новый код"},
+	"phone.get_failed":        {Other: "Не удалось получить номер телефона"},
+	"phone.send_failed":       {Other: "Не удалось отправить код подтверждения"},
+	"phone.confirm_failed":    {Other: "Не удалось подтвердить номер телефона"},
+
+	// Профиль
+	"user.not_found": {Other: "Пользователь не найден"},
+	"displayName.too_long": {Count: "max",
+		One:   "Отображаемое имя может содержать не больше {max} символа",
+		Few:   "Отображаемое имя может содержать не больше {max} символов",
+		Many:  "Отображаемое имя может содержать не больше {max} символов",
+		Other: "Отображаемое имя слишком длинное"},
+	"displayName.invalid_chars": {Other: "Отображаемое имя не должно содержать управляющих символов"},
+	"bio.too_long": {Count: "max",
+		One:   "Описание может содержать не больше {max} символа",
+		Few:   "Описание может содержать не больше {max} символов",
+		Many:  "Описание может содержать не больше {max} символов",
+		Other: "Описание слишком длинное"},
+	"bio.invalid_chars": {Other: "Описание не должно содержать управляющих символов"},
+	"location.too_long": {Count: "max",
+		One:   "Местоположение может содержать не больше {max} символа",
+		Few:   "Местоположение может содержать не больше {max} символов",
+		Many:  "Местоположение может содержать не больше {max} символов",
+		Other: "Местоположение слишком длинное"},
+	"location.invalid_chars": {Other: "Местоположение не должно содержать управляющих символов"},
+	"website.too_long": {Count: "max",
+		One:   "Ссылка на сайт может содержать не больше {max} символа",
+		Few:   "Ссылка на сайт может содержать не больше {max} символов",
+		Many:  "Ссылка на сайт может содержать не больше {max} символов",
+		Other: "Ссылка на сайт слишком длинная"},
+	"website.invalid_format":  {Other: "Сайт должен быть полной ссылкой http или https"},
+	"birthday.invalid_format": {Other: "Дата рождения должна быть в формате ГГГГ-ММ-ДД"},
+	"birthday.out_of_range":   {Other: "Дата рождения должна быть не раньше 1900-01-01 и не позже сегодняшнего дня"},
+	"profile.get_failed":      {Other: "Не удалось получить профиль"},
+	"profile.update_failed":   {Other: "Не удалось изменить профиль"},
+
+	// Приватность и друзья
+	"as.invalid_viewer":     {Other: "as должен быть friend или stranger"},
+	"privacy.hidden":        {Other: "Пользователь скрыл это от вас"},
+	"privacy.get_failed":    {Other: "Не удалось получить настройки приватности"},
+	"privacy.update_failed": {Other: "Не удалось изменить настройки приватности"},
+	"friends.get_failed":    {Other: "Не удалось получить список друзей"},
+	"friends.add_failed":    {Other: "Не удалось добавить в друзья"},
+	"friends.remove_failed": {Other: "Не удалось удалить из друзей"},
+	"friends.self":          {Other: "Нельзя добавить в друзья самого себя"},
+
+	// Блокировка и заглушение
+	"block.self":           {Other: "Нельзя заблокировать или заглушить самого себя"},
+	"block.block_failed":   {Other: "Не удалось заблокировать пользователя"},
+	"block.unblock_failed": {Other: "Не удалось разблокировать пользователя"},
+	"block.mute_failed":    {Other: "Не удалось заглушить пользователя"},
+	"block.unmute_failed":  {Other: "Не удалось снять заглушение"},
+	"block.list_failed":    {Other: "Не удалось получить список пользователей"},
+
+	// Изображения
+	"file.required":           {Other: "Приложите изображение в поле file"},
+	"file.too_large":          {Other: "Размер файла не должен превышать {max} МБ"},
+	"file.unsupported_format": {Other: "Файл должен быть изображением JPEG, PNG, GIF или WebP"},
+	"file.too_many_pixels": {Count: "max",
+		One:   "Изображение должно быть не больше {max} мегапикселя",
+		Few:   "Изображение должно быть не больше {max} мегапикселей",
+		Many:  "Изображение должно быть не больше {max} мегапикселей",
+		Other: "Изображение слишком большое"},
+	"file.too_small":      {Other: "Изображение должно быть не меньше {width}x{height} пикселей"},
+	"media.upload_failed": {Other: "Не удалось загрузить изображение"},
+	"media.delete_failed": {Other: "Не удалось удалить изображение"},
+	"media.not_found":     {Other: "Файл не найден"},
+	"media.read_failed":   {Other: "Не удалось прочитать файл"},
+	"media.link_invalid":  {Other: "Ссылка на файл недействительна"},
+	"media.link_expired":  {Other: "Срок действия ссылки на файл истек, обновите страницу, чтобы получить новую"},
+
+	// Посты
+	"content.required": {Other: "Введите текст поста"},
+	"content.too_long": {Count: "max",
+		One:   "Пост может содержать не больше {max} символа",
+		Few:   "Пост может содержать не больше {max} символов",
+		Many:  "Пост может содержать не больше {max} символов",
+		Other: "Пост слишком длинный"},
+	"content.invalid_chars": {Other: "Текст поста не должен содержать управляющих символов"},
+	"tags.too_many": {Count: "max",
+		One:   "У поста может быть не больше {max} тега",
+		Few:   "У поста может быть не больше {max} тегов",
+		Many:  "У поста может быть не больше {max} тегов",
+		Other: "У поста слишком много тегов"},
+	"tags.invalid_format": {Other: "Тег может содержать от 1 до {max} букв, цифр или знаков подчеркивания"},
+	"posts.create_failed": {Other: "Не удалось опубликовать пост"},
+	"posts.not_found":     {Other: "Пост не найден"},
+	"posts.invalid_id":    {Other: "Неверный id поста"},
+	"posts.get_failed":    {Other: "Не удалось получить посты"},
+	"posts.feed_failed":   {Other: "Не удалось получить ленту"},
+
+	// Реакции на посты
+	"reactions.set_failed":    {Other: "Не удалось поставить реакцию"},
+	"reactions.remove_failed": {Other: "Не удалось убрать реакцию"},
+	"reactions.get_failed":    {Other: "Не удалось получить реакции"},
+}
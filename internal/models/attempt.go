@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempts - счетчик неудачных попыток входа по логину или IP-адресу
+type LoginAttempts struct {
+	Key           string    `db:"key"`
+	Failures      int       `db:"failures"`
+	LastFailureAt time.Time `db:"last_failure_at"`
+	LockedUntil   time.Time `db:"locked_until"`
+}
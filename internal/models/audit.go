@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Типы событий журнала безопасности
+const (
+	AuditLoginSucceeded     = "login.succeeded"
+	AuditLoginFailed        = "login.failed"
+	AuditLoginUnlocked      = "login.unlocked"
+	AuditTokenRefreshed     = "token.refreshed"
+	AuditTokenReuseDetected = "token.reuse_detected"
+	AuditLogoutAll          = "logout.all"
+	AuditSessionRevoked     = "session.revoked"
+	AuditPasswordReset      = "password.reset"
+	AuditMFAEnabled         = "mfa.enabled"
+	AuditMFADisabled        = "mfa.disabled"
+	AuditRoleChanged        = "role.changed"
+	AuditPermissionGranted  = "permission.granted"
+	AuditPermissionRevoked  = "permission.revoked"
+)
+
+// Результаты событий
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent - запись журнала безопасности. Записи только добавляются, изменить или удалить их нельзя.
+// ActorID - кто выполнил действие, TargetID - над чьим аккаунтом; для неудачного входа ActorID пустой.
+type AuditEvent struct {
+	ID         uint         `json:"id" db:"id"`
+	OccurredAt time.Time    `json:"occurredAt" db:"occurred_at"`
+	Type       string       `json:"type" db:"type"`
+	Outcome    string       `json:"outcome" db:"outcome"`
+	ActorID    *uint        `json:"actorId,omitempty" db:"actor_id"`
+	TargetID   *uint        `json:"targetId,omitempty" db:"target_id"`
+	IP         string       `json:"ip" db:"ip"`
+	UserAgent  string       `json:"userAgent" db:"user_agent"`
+	Details    AuditDetails `json:"details,omitempty" db:"details"`
+}
+
+// AuditDetails - дополнительные сведения о событии, хранятся в JSONB
+type AuditDetails map[string]string
+
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(d)
+}
+
+func (d *AuditDetails) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*d = nil
+		return nil
+	default:
+		return errors.New("unsupported audit details type")
+	}
+	return json.Unmarshal(data, d)
+}
+
+// AuditFilter - условия выборки из журнала. Пустые поля не ограничивают выборку.
+type AuditFilter struct {
+	Type     string
+	Outcome  string
+	IP       string
+	ActorID  *uint
+	TargetID *uint
+	UserID   *uint // пользователь - исполнитель или цель события
+	From     time.Time
+	To       time.Time
+	BeforeID uint // для постраничного просмотра: события старше указанного
+	Limit    int
+}
+
+// UintPtr возвращает указатель на id для необязательных полей
+func UintPtr(id uint) *uint {
+	return &id
+}
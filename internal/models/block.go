@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RestrictedUser - пользователь из списка заблокированных или заглушенных
+type RestrictedUser struct {
+	Login       string    `json:"login" db:"login"`
+	DisplayName string    `json:"displayName" db:"display_name"`
+	Avatar      *Image    `json:"avatar,omitempty" db:"avatar"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
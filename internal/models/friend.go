@@ -1,10 +1,18 @@
-package models
-
-import "time"
-
-type Friend struct {
-	UserId      int       `json:"userId" db:"userId"`
-	FriendId    int       `json:"friendId" db:"friendId"`
-	FriendLogin string    `json:"friendLogin" db:"friendLogin"`
-	AddedAt     time.Time `json:"addedAt" db:"addedAt"`
-}
+package models
+
+import "time"
+
+type Friend struct {
+	UserId      int       `json:"userId" db:"userId"`
+	FriendId    int       `json:"friendId" db:"friendId"`
+	FriendLogin string    `json:"friendLogin" db:"friendLogin"`
+	AddedAt     time.Time `json:"addedAt" db:"addedAt"`
+}
+
+// FriendProfile - друг из списка пользователя с краткими сведениями профиля
+type FriendProfile struct {
+	Login       string    `json:"login" db:"login"`
+	DisplayName string    `json:"displayName" db:"display_name"`
+	Avatar      *Image    `json:"avatar,omitempty" db:"avatar"`
+	AddedAt     time.Time `json:"addedAt" db:"added_at"`
+}
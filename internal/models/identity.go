@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// ExternalIdentity - аккаунт пользователя у внешнего провайдера OpenID Connect
+type ExternalIdentity struct {
+	ID        uint      `json:"id" db:"id"`
+	UserID    uint      `json:"-" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"-" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// OIDCLoginState хранит параметры входа через провайдера между перенаправлением и возвратом.
+// В базе лежит хеш state, nonce и code_verifier нужны в открытом виде для проверки ответа провайдера.
+// BindingHash - хеш секрета из cookie браузера, в котором начинался вход.
+// UserID заполнен, если пользователь привязывает провайдера к своему аккаунту.
+type OIDCLoginState struct {
+	ID           uint       `db:"id"`
+	StateHash    string     `db:"state_hash"`
+	BindingHash  string     `db:"binding_hash"`
+	Provider     string     `db:"provider"`
+	Nonce        string     `db:"nonce"`
+	CodeVerifier string     `db:"code_verifier"`
+	UserID       *uint      `db:"user_id"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+	UsedAt       *time.Time `db:"used_at"`
+}
+
+// OIDCPendingSignup - проверенный аккаунт провайдера, для которого пользователь еще не выбрал логин
+type OIDCPendingSignup struct {
+	ID            uint       `db:"id"`
+	TokenHash     string     `db:"token_hash"`
+	Provider      string     `db:"provider"`
+	Subject       string     `db:"subject"`
+	Email         string     `db:"email"`
+	EmailVerified bool       `db:"email_verified"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	UsedAt        *time.Time `db:"used_at"`
+}
+
+// ExternalLoginResult - результат возврата от провайдера
+type ExternalLoginResult struct {
+	// UserID - пользователь, которому нужно выдать токены или к которому привязан провайдер
+	UserID uint
+	// Linked - провайдер только что привязан к аккаунту
+	Linked bool
+	// LinkOnly - вход начинался из настроек аккаунта для привязки, токены выдавать не нужно
+	LinkOnly bool
+	// SignupRequired - аккаунта еще нет, пользователь должен выбрать логин
+	SignupRequired bool
+	SignupToken    string
+	Email          string
+	SuggestedLogin string
+}
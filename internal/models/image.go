@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Виды изображений профиля
+const (
+	ImageAvatar = "avatar"
+	ImageCover  = "cover"
+)
+
+// Image - загруженное изображение, обработанное в несколько размеров и форматов. Хранится в JSONB.
+type Image struct {
+	ID       string         `json:"id"`
+	Variants []ImageVariant `json:"variants"`
+}
+
+// ImageVariant - один размер изображения в одном формате. Key - ключ файла в хранилище,
+// URL заполняется при выдаче клиенту.
+type ImageVariant struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Format string `json:"format"`
+	Key    string `json:"key"`
+	URL    string `json:"url,omitempty"`
+}
+
+func (i Image) Value() (driver.Value, error) {
+	return json.Marshal(i)
+}
+
+func (i *Image) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, i)
+	case string:
+		return json.Unmarshal([]byte(v), i)
+	default:
+		return errors.New("unsupported image type")
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MFA - настройки двухфакторной аутентификации пользователя (TOTP)
+type MFA struct {
+	UserID       uint       `db:"user_id"`
+	Secret       string     `db:"secret"`
+	Enabled      bool       `db:"enabled"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+}
+
+// MFASetup - данные для подключения приложения-аутентификатора
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAStatus - состояние двухфакторной аутентификации для отображения пользователю
+type MFAStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
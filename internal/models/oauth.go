@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OAuthClient - стороннее приложение, зарегистрированное пользователем.
+// У публичных клиентов (мобильные и браузерные приложения) нет секрета, они защищены только PKCE.
+type OAuthClient struct {
+	ID           uint           `json:"-" db:"id"`
+	ClientID     string         `json:"clientId" db:"client_id"`
+	SecretHash   string         `json:"-" db:"secret_hash"`
+	OwnerID      uint           `json:"-" db:"owner_id"`
+	Name         string         `json:"name" db:"name"`
+	RedirectURIs pq.StringArray `json:"redirectUris" db:"redirect_uris"`
+	Scopes       pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedAt    time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// Confidential сообщает, что клиент должен аутентифицироваться секретом
+func (c OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// OAuthConsent - согласие пользователя на доступ приложения к областям действия
+type OAuthConsent struct {
+	UserID    uint           `json:"-" db:"user_id"`
+	ClientID  string         `json:"clientId" db:"client_id"`
+	Scopes    pq.StringArray `json:"scopes" db:"scopes"`
+	GrantedAt time.Time      `json:"grantedAt" db:"granted_at"`
+}
+
+// OAuthAuthorizationCode - одноразовый код авторизации, хранится только хеш.
+// FamilyID наследуют все refresh-токены, полученные по коду: при повторном
+// предъявлении кода они отзываются.
+type OAuthAuthorizationCode struct {
+	ID            uint           `db:"id"`
+	CodeHash      string         `db:"code_hash"`
+	ClientID      string         `db:"client_id"`
+	UserID        uint           `db:"user_id"`
+	RedirectURI   string         `db:"redirect_uri"`
+	Scopes        pq.StringArray `db:"scopes"`
+	CodeChallenge string         `db:"code_challenge"`
+	FamilyID      string         `db:"family_id"`
+	CreatedAt     time.Time      `db:"created_at"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	UsedAt        *time.Time     `db:"used_at"`
+}
+
+// OAuthRefreshToken - refresh-токен стороннего приложения, хранится только хеш.
+// Как и у сессий, каждый токен одноразовый и при обмене заменяется следующим в семействе.
+type OAuthRefreshToken struct {
+	ID        uint           `db:"id"`
+	TokenHash string         `db:"token_hash"`
+	FamilyID  string         `db:"family_id"`
+	ClientID  string         `db:"client_id"`
+	UserID    uint           `db:"user_id"`
+	Scopes    pq.StringArray `db:"scopes"`
+	CreatedAt time.Time      `db:"created_at"`
+	ExpiresAt time.Time      `db:"expires_at"`
+	RotatedAt *time.Time     `db:"rotated_at"`
+	RevokedAt *time.Time     `db:"revoked_at"`
+}
+
+// OAuthAuthorizationRequest - параметры запроса авторизации (RFC 6749, раздел 4.1.1, и RFC 7636)
+type OAuthAuthorizationRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type"`
+	ClientID            string `json:"client_id" form:"client_id"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+// OAuthTokenResponse - ответ token endpoint (RFC 6749, раздел 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthIntrospection - ответ introspection endpoint (RFC 7662)
+type OAuthIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// OAuthAuthorizationPrompt - сведения для страницы согласия: какое приложение и к чему запрашивает доступ
+type OAuthAuthorizationPrompt struct {
+	Client          OAuthClient `json:"client"`
+	Scopes          []string    `json:"scopes"`
+	ConsentRequired bool        `json:"consentRequired"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Области действия персональных токенов
+const (
+	// ScopeRead разрешает только чтение
+	ScopeRead = "read"
+	// ScopePostsWrite разрешает публиковать и изменять посты
+	ScopePostsWrite = "posts:write"
+)
+
+// Scopes - все известные области действия персональных токенов
+var Scopes = []string{ScopeRead, ScopePostsWrite}
+
+// PersonalAccessToken - именованный токен для ботов и скриптов.
+// Сам токен показывается один раз при создании, в базе хранится только его хеш.
+type PersonalAccessToken struct {
+	ID         uint           `json:"id" db:"id"`
+	UserID     uint           `json:"-" db:"user_id"`
+	Name       string         `json:"name" db:"name"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	TokenHash  string         `json:"-" db:"token_hash"`
+	CreatedAt  time.Time      `json:"createdAt" db:"created_at"`
+	ExpiresAt  time.Time      `json:"expiresAt" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"lastUsedAt" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"-" db:"revoked_at"`
+}
+
+// HasScope проверяет, что токен дает указанную область действия
+func (t PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
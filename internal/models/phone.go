@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PhoneVerificationCode - код подтверждения номера телефона, отправленный в SMS.
+// В базе хранится только хеш кода.
+type PhoneVerificationCode struct {
+	ID        uint       `db:"id"`
+	UserID    uint       `db:"user_id"`
+	Phone     string     `db:"phone"`
+	IP        string     `db:"ip"`
+	CodeHash  string     `db:"code_hash"`
+	Attempts  int        `db:"attempts"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+}
+
+// PhoneStatus - номер телефона пользователя и состояние его подтверждения
+type PhoneStatus struct {
+	Phone     string `json:"phone"`
+	Formatted string `json:"formatted"`
+	Region    string `json:"region"`
+	Verified  bool   `json:"verified"`
+}
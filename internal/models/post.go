@@ -1,13 +1,25 @@
-package models
-
-import "time"
-
-type Post struct {
-	Id            int       `json:"id" db:"id"`
-	Content       string    `json:"content" db:"content"`
-	Author        string    `json:"author" db:"author"`
-	Tags          []string  `json:"tags" db:"tags"`
-	CreatedAt     time.Time `json:"createdAt" db:"createdAt"`
-	LikesCount    int       `json:"likesCount" db:"likesCount"`
-	DislikesCount int       `json:"dislikesCount " db:"dislikesCount"`
-}
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Post - пост пользователя. Author - логин автора на момент публикации.
+type Post struct {
+	Id            int            `json:"id" db:"id"`
+	AuthorID      uint           `json:"-" db:"author_id"`
+	Content       string         `json:"content" db:"content"`
+	Author        string         `json:"author" db:"author"`
+	Tags          pq.StringArray `json:"tags" db:"tags"`
+	CreatedAt     time.Time      `json:"createdAt" db:"createdAt"`
+	LikesCount    int            `json:"likesCount" db:"likesCount"`
+	DislikesCount int            `json:"dislikesCount" db:"dislikesCount"`
+}
+
+// NewPost - текст и теги публикуемого поста
+type NewPost struct {
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
@@ -0,0 +1,103 @@
+package models
+
+// Кто может видеть поле профиля
+const (
+	VisibilityEveryone = "everyone"
+	VisibilityFriends  = "friends"
+	VisibilityOnlyMe   = "only_me"
+)
+
+// Кем зритель приходится владельцу профиля
+const (
+	ViewerSelf     = "self"
+	ViewerFriend   = "friend"
+	ViewerStranger = "stranger"
+	// Один из пользователей заблокировал другого, они не видят друг друга вовсе
+	ViewerBlocked = "blocked"
+)
+
+// Поля, видимость которых настраивается
+const (
+	PrivacyEmail    = "email"
+	PrivacyPhone    = "phone"
+	PrivacyBirthday = "birthday"
+	PrivacyFriends  = "friends"
+	PrivacyPosts    = "posts"
+)
+
+// PrivacySettings определяет, кто видит контакты, дату рождения, список друзей и посты пользователя
+type PrivacySettings struct {
+	Email    string `json:"email" db:"email"`
+	Phone    string `json:"phone" db:"phone"`
+	Birthday string `json:"birthday" db:"birthday"`
+	Friends  string `json:"friends" db:"friends"`
+	Posts    string `json:"posts" db:"posts"`
+}
+
+// DefaultPrivacySettings - настройки пользователя, который их не менял
+func DefaultPrivacySettings() PrivacySettings {
+	return PrivacySettings{
+		Email:    VisibilityOnlyMe,
+		Phone:    VisibilityOnlyMe,
+		Birthday: VisibilityFriends,
+		Friends:  VisibilityEveryone,
+		Posts:    VisibilityEveryone,
+	}
+}
+
+// PrivacyUpdate - изменение настроек приватности. nil означает, что настройка не меняется.
+type PrivacyUpdate struct {
+	Email    *string `json:"email"`
+	Phone    *string `json:"phone"`
+	Birthday *string `json:"birthday"`
+	Friends  *string `json:"friends"`
+	Posts    *string `json:"posts"`
+}
+
+// Visibilities - все значения видимости в порядке от открытого к закрытому
+var Visibilities = []string{VisibilityEveryone, VisibilityFriends, VisibilityOnlyMe}
+
+// visibilityViewers - кто, кроме самого владельца, видит поле с данной видимостью.
+// По этой таблице проверяет доступ Allows и строится условие видимости постов в SQL.
+var visibilityViewers = map[string][]string{
+	VisibilityEveryone: {ViewerFriend, ViewerStranger},
+	VisibilityFriends:  {ViewerFriend},
+	VisibilityOnlyMe:   {},
+}
+
+// VisibilityViewers возвращает, кем зритель должен приходиться владельцу, чтобы видеть поле
+// с видимостью visibility. Сам владелец видит все свои поля и в список не входит.
+func VisibilityViewers(visibility string) []string {
+	return visibilityViewers[visibility]
+}
+
+// Allows сообщает, видит ли зритель поле с видимостью visibility
+func Allows(visibility, viewer string) bool {
+	if viewer == ViewerSelf {
+		return true
+	}
+	for _, allowed := range visibilityViewers[visibility] {
+		if viewer == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Visibility возвращает видимость поля field
+func (s PrivacySettings) Visibility(field string) string {
+	switch field {
+	case PrivacyEmail:
+		return s.Email
+	case PrivacyPhone:
+		return s.Phone
+	case PrivacyBirthday:
+		return s.Birthday
+	case PrivacyFriends:
+		return s.Friends
+	case PrivacyPosts:
+		return s.Posts
+	default:
+		return VisibilityOnlyMe
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Profile - публичные сведения о пользователе, которые он заполняет сам.
+// Avatar и Cover - загруженные изображения, nil - изображения нет.
+// Birthday хранится как дата в формате YYYY-MM-DD. Email, Phone и Birthday выдаются только тем,
+// кому их разрешают настройки приватности, Hidden перечисляет скрытые от зрителя поля.
+type Profile struct {
+	UserID      uint       `json:"-" db:"user_id"`
+	Login       string     `json:"login" db:"login"`
+	Email       string     `json:"email,omitempty" db:"email"`
+	Phone       string     `json:"phone,omitempty" db:"phone"`
+	DisplayName string     `json:"displayName" db:"display_name"`
+	Bio         string     `json:"bio" db:"bio"`
+	Location    string     `json:"location" db:"location"`
+	Website     string     `json:"website" db:"website"`
+	Birthday    string     `json:"birthday,omitempty" db:"birthday"`
+	Avatar      *Image     `json:"avatar,omitempty" db:"avatar"`
+	Cover       *Image     `json:"cover,omitempty" db:"cover"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty" db:"updated_at"`
+	Hidden      []string   `json:"hidden,omitempty" db:"-"`
+}
+
+// ProfileUpdate - частичное изменение профиля. Поле, которое не передано, не меняется,
+// пустая строка очищает поле.
+type ProfileUpdate struct {
+	DisplayName *string `json:"displayName"`
+	Bio         *string `json:"bio"`
+	Location    *string `json:"location"`
+	Website     *string `json:"website"`
+	Birthday    *string `json:"birthday"`
+}
@@ -1,11 +1,26 @@
-package models
-
-import "time"
-
-type Reaction struct {
-	Id           int       `json:"id" db:"id"`
-	UserId       int       `json:"userId" db:"userId"`
-	PostId       int       `json:"postId" db:"postId"`
-	ReactionType int       `json:"reactionType" db:"reactionType"`
-	CreatedAt    time.Time `json:"createdAt" db:"createdAt"`
-}
+package models
+
+import "time"
+
+// Значения reactionType в таблице reactions
+const (
+	ReactionDislike = 0
+	ReactionLike    = 1
+)
+
+type Reaction struct {
+	Id           int       `json:"id" db:"id"`
+	UserId       int       `json:"userId" db:"userId"`
+	PostId       int       `json:"postId" db:"postId"`
+	ReactionType int       `json:"reactionType" db:"reactionType"`
+	CreatedAt    time.Time `json:"createdAt" db:"createdAt"`
+}
+
+// PostReaction - реакция на пост с краткими сведениями профиля поставившего ее пользователя
+type PostReaction struct {
+	Login       string    `json:"login" db:"login"`
+	DisplayName string    `json:"displayName" db:"display_name"`
+	Avatar      *Image    `json:"avatar,omitempty" db:"avatar"`
+	Like        bool      `json:"like" db:"is_like"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// Роли пользователей
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Права доступа
+const (
+	PermissionPostsModerate = "posts:moderate"
+	PermissionUsersRead     = "users:read"
+	PermissionUsersUnlock   = "users:unlock"
+	PermissionRolesManage   = "roles:manage"
+	PermissionAuditRead     = "audit:read"
+)
+
+// Permissions - все известные права
+var Permissions = []string{
+	PermissionPostsModerate,
+	PermissionUsersRead,
+	PermissionUsersUnlock,
+	PermissionRolesManage,
+	PermissionAuditRead,
+}
+
+// RolePermissions - права, которые дает каждая роль.
+// Отдельные права можно выдать пользователю сверх прав его роли.
+var RolePermissions = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermissionPostsModerate, PermissionUsersRead},
+	RoleAdmin:     Permissions,
+}
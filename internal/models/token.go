@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Назначения одноразовых токенов
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeMFAPending        = "mfa_pending"
+	TokenPurposeMagicLink         = "magic_link"
+)
+
+// OneTimeToken - одноразовый токен, отправляемый пользователю по почте
+// или выдаваемый между шагами входа.
+// В базе хранится только хеш токена.
+type OneTimeToken struct {
+	ID            uint       `db:"id"`
+	UserID        uint       `db:"user_id"`
+	Purpose       string     `db:"purpose"`
+	TokenHash     string     `db:"token_hash"`
+	CreatedAt     time.Time  `db:"created_at"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	UsedAt        *time.Time `db:"used_at"`
+	CodeChallenge string     `db:"code_challenge"` // code_challenge S256 устройства, запросившего токен, или пусто
+}
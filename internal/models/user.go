@@ -1,29 +1,64 @@
-package models
-
-import "time"
-
-type RegistrationUser struct {
-	Login     string    `json:"login" db:"login"`
-	Email     string    `json:"email" db:"email"`
-	Password  string    `json:"password" db:"password"`
-	Phone     string    `json:"phone" db:"phone"`
-	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
-}
-
-type LoginUser struct {
-	Login    string `json:"login" db:"login"`
-	Password string `json:"password" db:"password"`
-}
-
-type User struct {
-	ID       uint   `json:"id" db:"id"`
-	Login    string `json:"login" db:"login"`
-	Email    string `json:"email" db:"email"`
-	Phone    string `json:"phone" db:"phone"`
-	Password string `json:"-"`
-}
-
-type Session struct {
-	RefreshToken string    `json:"refreshToken" db:"refreshToken"`
-	ExpiresAt    time.Time `json:"expiresAt" db:"expiresAt"`
-}
+package models
+
+import "time"
+
+type RegistrationUser struct {
+	Login     string    `json:"login" db:"login"`
+	Email     string    `json:"email" db:"email"`
+	Password  string    `json:"password" db:"password"`
+	Phone     string    `json:"phone" db:"phone"`
+	CreatedAt time.Time `json:"createdAt" db:"createdAt"`
+}
+
+// LoginUser - данные для входа. В Login можно передать логин, email или номер телефона.
+type LoginUser struct {
+	Login      string `json:"login" db:"login"`
+	Password   string `json:"password" db:"password"`
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	IP         string `json:"-"`
+	UserAgent  string `json:"-"`
+}
+
+// User - учетная запись. Контакты в JSON не попадают: другим пользователям они выдаются
+// только в профиле с учетом настроек приватности.
+type User struct {
+	ID            uint   `json:"id" db:"id"`
+	Login         string `json:"login" db:"login"`
+	Email         string `json:"-" db:"email"`
+	Phone         string `json:"-" db:"phone"`
+	Password      string `json:"-"`
+	EmailVerified bool   `json:"emailVerified" db:"email_verified"`
+	PhoneVerified bool   `json:"phoneVerified" db:"phone_verified"`
+	Role          string `json:"role" db:"role"`
+	Locale        string `json:"locale,omitempty" db:"locale"` // предпочитаемый язык, пустой - по Accept-Language
+}
+
+// Session описывает refresh-сессию пользователя на конкретном устройстве.
+// Сам refresh-токен не хранится, в базе лежит только его хеш.
+// Все сессии, полученные ротацией из одного логина, образуют семейство (FamilyID).
+// MFA - вход в сессию подтвержден вторым фактором, он сохраняется при ротации.
+type Session struct {
+	ID         uint       `json:"id" db:"id"`
+	UserID     uint       `json:"userId" db:"user_id"`
+	FamilyID   string     `json:"-" db:"family_id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	DeviceID   string     `json:"deviceId" db:"device_id"`
+	DeviceName string     `json:"deviceName" db:"device_name"`
+	UserAgent  string     `json:"userAgent" db:"user_agent"`
+	IP         string     `json:"ip" db:"ip"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt time.Time  `json:"lastUsedAt" db:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expiresAt" db:"expires_at"`
+	RotatedAt  *time.Time `json:"-" db:"rotated_at"`
+	RevokedAt  *time.Time `json:"-" db:"revoked_at"`
+	MFA        bool       `json:"mfa" db:"mfa"`
+}
+
+// DeviceInfo содержит сведения об устройстве, с которого выполняется вход
+type DeviceInfo struct {
+	DeviceID   string
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
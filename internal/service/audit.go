@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/logger"
+)
+
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+
+	// Сколько последних событий безопасности видит сам пользователь
+	auditUserEventsLimit = 50
+)
+
+// AuditServiceInterface определяет методы журнала безопасности
+type AuditServiceInterface interface {
+	Record(event models.AuditEvent)
+	GetEvents(filter models.AuditFilter) ([]models.AuditEvent, error)
+	GetUserEvents(userID uint) ([]models.AuditEvent, error)
+	ExportEvents(filter models.AuditFilter, w io.Writer) error
+}
+
+// AuditService предоставляет реализацию AuditServiceInterface
+type AuditService struct {
+	auditRepository database.AuditRepositoryInterface
+	log             logger.LoggerInterface
+}
+
+// NewAuditService создает новый экземпляр AuditService
+func NewAuditService(auditRepository database.AuditRepositoryInterface) *AuditService {
+	return &AuditService{
+		auditRepository: auditRepository,
+		log:             logger.GetLogger(),
+	}
+}
+
+// Record записывает событие. Ошибка записи только логируется: недоступность журнала
+// не должна мешать пользователям входить в систему.
+func (s *AuditService) Record(event models.AuditEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	if event.Outcome == "" {
+		event.Outcome = models.AuditSuccess
+	}
+
+	if err := s.auditRepository.CreateEvent(event); err != nil {
+		s.log.Error("Failed to record audit event " + event.Type + ": " + err.Error())
+	}
+}
+
+// GetEvents возвращает события по фильтру, новые первыми
+func (s *AuditService) GetEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = auditDefaultLimit
+	}
+	if filter.Limit > auditMaxLimit {
+		filter.Limit = auditMaxLimit
+	}
+	return s.auditRepository.GetEvents(filter)
+}
+
+// GetUserEvents возвращает последние события, в которых пользователь был исполнителем или целью
+func (s *AuditService) GetUserEvents(userID uint) ([]models.AuditEvent, error) {
+	return s.auditRepository.GetEvents(models.AuditFilter{UserID: &userID, Limit: auditUserEventsLimit})
+}
+
+// ExportEvents выгружает события по фильтру в формате JSON Lines: по одному JSON-объекту в строке
+func (s *AuditService) ExportEvents(filter models.AuditFilter, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return s.auditRepository.EachEvent(filter, func(event models.AuditEvent) error {
+		return encoder.Encode(event)
+	})
+}
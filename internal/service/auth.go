@@ -1,115 +1,451 @@
-package service
-
-import (
-	"errors"
-	"strconv"
-	"time"
-
-	"github.com/Saveliy12/prod2/internal/database"
-	"github.com/Saveliy12/prod2/internal/models"
-	"github.com/Saveliy12/prod2/internal/utils"
-	"github.com/Saveliy12/prod2/pkg/hash"
-	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
-	"golang.org/x/crypto/bcrypt"
-)
-
-// AuthServiceInterface определяет методы для работы с аутентификацией
-type AuthServiceInterface interface {
-	RegisterUser(newUser models.RegistrationUser) (models.User, error)
-	AuthenticateUser(credentials models.LoginUser) (models.User, error)
-	AuthorizeUserUser(userID uint) (string, error)
-	NewRefreshToken(refreshToken string) (string, error)
-}
-
-// AuthService предоставляет реализацию AuthServiceInterface
-type AuthService struct {
-	tokenManager   tokenmanager.TokenManagerInterface
-	userRepository database.UserRepositoryInterface
-
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
-}
-
-// NewAuthService создает новый экземпляр AuthService
-func NewAuthService(tokenManager tokenmanager.TokenManagerInterface, userRepository database.UserRepositoryInterface,
-	accessTokenTTL time.Duration, refreshTokenTTL time.Duration) *AuthService {
-	return &AuthService{
-		tokenManager:    tokenManager,
-		userRepository:  userRepository,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-	}
-}
-
-func (s *AuthService) RegisterUser(newUser models.RegistrationUser) (models.User, error) {
-	if err := s.userRepository.IsUnique(newUser.Login, newUser.Email, newUser.Phone); err != nil {
-		return models.User{}, err
-	}
-
-	if err := utils.ValidateUser(newUser); err != nil {
-		return models.User{}, err
-	}
-
-	hashedPassword, err := hash.HashPassword(newUser.Password)
-	newUser.Password = hashedPassword
-
-	return s.userRepository.CreateUser(newUser)
-}
-
-func (s *AuthService) AuthenticateUser(credentials models.LoginUser) (uint, error) {
-	user, err := s.userRepository.GetUserByLogin(credentials.Login)
-	if err != nil {
-		return 0, err
-	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
-		return 0, errors.New("invalid login or password")
-	}
-
-	return user.ID, nil
-}
-
-func (s *AuthService) AuthorizeUser(userID uint) (tokenmanager.Tokens, error) {
-	var (
-		res tokenmanager.Tokens
-		err error
-	)
-
-	res.AccessToken, err = s.tokenManager.NewJWT(strconv.FormatUint(uint64(userID), 10), s.accessTokenTTL)
-	if err != nil {
-		return res, err
-	}
-
-	res.RefreshToken, err = s.tokenManager.NewRefreshToken()
-	if err != nil {
-		return res, err
-	}
-
-	session := models.Session{
-		RefreshToken: res.RefreshToken,
-		ExpiresAt:    time.Now().Add(s.refreshTokenTTL),
-	}
-
-	err = s.userRepository.SetSession(userID, session)
-	if err != nil {
-		return res, err
-	}
-
-	return res, nil
-}
-
-func (s *AuthService) NewRefreshToken(refreshToken string) (string, error) {
-	userID, err := s.tokenManager.ParseJWT(refreshToken)
-	if err != nil {
-		return "", err
-	}
-
-	userID_string := strconv.FormatUint(uint64(userID), 10)
-
-	newAccessToken, err := s.tokenManager.NewJWT(userID_string, s.accessTokenTTL)
-	if err != nil {
-		return "", err
-	}
-
-	return newAccessToken, nil
-}
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/hash"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+)
+
+// AuthServiceInterface определяет методы для работы с аутентификацией
+type AuthServiceInterface interface {
+	RegisterUser(newUser models.RegistrationUser) (models.User, error)
+	AuthenticateUser(credentials models.LoginUser) (uint, error)
+	AuthorizeUser(userID uint, device models.DeviceInfo, mfa bool) (tokenmanager.Tokens, error)
+	RefreshTokens(refreshToken string, device models.DeviceInfo) (tokenmanager.Tokens, error)
+	Logout(refreshToken string) error
+	LogoutAll(userID uint) error
+	RevokeAccessToken(claims tokenmanager.Claims) error
+	RevokeUserTokens(userID uint) error
+	UnlockLogin(login string) error
+	GetActiveSessions(userID uint) ([]models.Session, error)
+	RevokeSession(userID, sessionID uint) error
+}
+
+var (
+	// ErrInvalidCredentials возвращается при неверном логине или пароле
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	// ErrInvalidRefreshToken возвращается для неизвестного, просроченного или отозванного refresh-токена
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused возвращается при повторном предъявлении уже обменянного refresh-токена
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, the session has been revoked")
+	// ErrSessionNotFound возвращается, если у пользователя нет указанной сессии
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// AuthService предоставляет реализацию AuthServiceInterface
+type AuthService struct {
+	tokenManager    tokenmanager.TokenManagerInterface
+	revocationStore tokenmanager.RevocationStore
+	userRepository  database.UserRepositoryInterface
+	oauthRepository database.OAuthRepositoryInterface
+	roleService     RoleServiceInterface
+	auditService    AuditServiceInterface
+	loginThrottler  *LoginThrottler
+	hasher          hash.HasherInterface
+	log             logger.LoggerInterface
+
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+}
+
+// NewAuthService создает новый экземпляр AuthService
+func NewAuthService(tokenManager tokenmanager.TokenManagerInterface, revocationStore tokenmanager.RevocationStore,
+	userRepository database.UserRepositoryInterface, oauthRepository database.OAuthRepositoryInterface,
+	roleService RoleServiceInterface, auditService AuditServiceInterface, loginThrottler *LoginThrottler, hasher hash.HasherInterface,
+	accessTokenTTL time.Duration, refreshTokenTTL time.Duration) *AuthService {
+	return &AuthService{
+		tokenManager:    tokenManager,
+		revocationStore: revocationStore,
+		userRepository:  userRepository,
+		oauthRepository: oauthRepository,
+		roleService:     roleService,
+		auditService:    auditService,
+		loginThrottler:  loginThrottler,
+		hasher:          hasher,
+		log:             logger.GetLogger(),
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+func (s *AuthService) RegisterUser(newUser models.RegistrationUser) (models.User, error) {
+	utils.NormalizeUser(&newUser)
+
+	if err := utils.ValidateUser(newUser); err != nil {
+		return models.User{}, err
+	}
+
+	hashedPassword, err := s.hasher.HashPassword(newUser.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	newUser.Password = hashedPassword
+
+	return s.userRepository.CreateUser(newUser)
+}
+
+// AuthenticateUser проверяет логин (email, телефон) и пароль с учетом ограничений на число неудачных попыток
+func (s *AuthService) AuthenticateUser(credentials models.LoginUser) (uint, error) {
+	credentials.Login = normalizeIdentifier(credentials.Login)
+
+	// Пользователь ищется до проверки ограничений: попытки считаются по аккаунту, а не по тому,
+	// каким из идентификаторов и в какой записи его назвали
+	user, err := s.findUser(credentials.Login)
+	account := s.throttleAccount(user.ID, credentials.Login)
+
+	if err := s.loginThrottler.Check(account, credentials.IP); err != nil {
+		var target *uint
+		if user.ID != 0 {
+			target = &user.ID
+		}
+		s.recordLoginFailure(credentials, target, "locked")
+		return 0, err
+	}
+
+	if err == nil {
+		err = s.hasher.Compare(user.Password, credentials.Password)
+	}
+	if err != nil {
+		var target *uint
+		if user.ID != 0 {
+			target = &user.ID
+		}
+		s.recordLoginFailure(credentials, target, "invalid_credentials")
+
+		// Неизвестный логин учитывается так же, как неверный пароль
+		if err := s.loginThrottler.RecordFailure(account, credentials.IP); err != nil {
+			return 0, err
+		}
+		return 0, ErrInvalidCredentials
+	}
+
+	// Счетчик неудачных попыток сбрасывается в AuthorizeUser, когда пройдены все шаги входа:
+	// верный пароль не должен обнулять неудачные попытки ввода второго фактора
+
+	// Пароль известен только в момент входа, поэтому устаревший хеш обновляется здесь
+	if s.hasher.NeedsRehash(user.Password) {
+		s.rehashPassword(user.ID, credentials.Password)
+	}
+
+	return user.ID, nil
+}
+
+// recordLoginFailure записывает неудачную попытку входа в журнал безопасности
+func (s *AuthService) recordLoginFailure(credentials models.LoginUser, target *uint, reason string) {
+	s.auditService.Record(models.AuditEvent{
+		Type:      models.AuditLoginFailed,
+		Outcome:   models.AuditFailure,
+		TargetID:  target,
+		IP:        credentials.IP,
+		UserAgent: credentials.UserAgent,
+		Details:   models.AuditDetails{"login": credentials.Login, "reason": reason},
+	})
+}
+
+// normalizeIdentifier приводит email и номер телефона к виду, в котором они хранятся в базе данных.
+// Логин не может содержать @ и +, поэтому по ним email и телефон отличаются от логина.
+func normalizeIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	switch {
+	case strings.Contains(identifier, "@"):
+		return utils.NormalizeEmail(identifier)
+	case strings.HasPrefix(identifier, "+"):
+		return utils.NormalizePhone(identifier)
+	}
+	return identifier
+}
+
+// findUser ищет пользователя по нормализованному логину, email или номеру телефона.
+// Строка из цифр и дефисов может быть и логином, и телефоном без +, поэтому
+// сначала она ищется как логин, а затем как телефон.
+func (s *AuthService) findUser(identifier string) (models.User, error) {
+	switch {
+	case strings.Contains(identifier, "@"):
+		return s.userRepository.GetUserByEmail(identifier)
+	case strings.HasPrefix(identifier, "+"):
+		return s.userRepository.GetUserByPhone(identifier)
+	}
+
+	user, err := s.userRepository.GetUserByLogin(identifier)
+	if errors.Is(err, database.ErrUserNotFound) {
+		if phone := utils.NormalizePhone(identifier); strings.HasPrefix(phone, "+") {
+			return s.userRepository.GetUserByPhone(phone)
+		}
+	}
+	return user, err
+}
+
+// throttleAccount возвращает ключ счетчика неудачных попыток: для найденного пользователя - по его id,
+// для неизвестного - по идентификатору в том виде, в каком его ищет findUser
+func (s *AuthService) throttleAccount(userID uint, identifier string) string {
+	if userID != 0 {
+		return UserAccountKey(userID)
+	}
+	if !strings.Contains(identifier, "@") {
+		if phone := utils.NormalizePhone(identifier); strings.HasPrefix(phone, "+") {
+			identifier = phone
+		}
+	}
+	return IdentifierAccountKey(identifier)
+}
+
+// rehashPassword пересчитывает хеш пароля текущим алгоритмом.
+// Ошибка не мешает входу: старый хеш остается рабочим, обновление повторится при следующем входе.
+func (s *AuthService) rehashPassword(userID uint, password string) {
+	hashedPassword, err := s.hasher.HashPassword(password)
+	if err == nil {
+		err = s.userRepository.UpdatePassword(userID, hashedPassword)
+	}
+	if err != nil {
+		s.log.Warn("Failed to rehash password: " + err.Error())
+	}
+}
+
+// UnlockLogin снимает блокировку входа с аккаунта, названного логином, email или телефоном.
+// Сбрасывается и счетчик самого идентификатора: он мог накопиться, пока аккаунта с ним не было.
+func (s *AuthService) UnlockLogin(login string) error {
+	identifier := normalizeIdentifier(login)
+
+	user, err := s.findUser(identifier)
+	if err != nil && !errors.Is(err, database.ErrUserNotFound) {
+		return err
+	}
+	if err == nil {
+		if err := s.loginThrottler.Unlock(UserAccountKey(user.ID)); err != nil {
+			return err
+		}
+	}
+
+	return s.loginThrottler.Unlock(s.throttleAccount(0, identifier))
+}
+
+// AuthorizeUser выдает пару токенов и открывает новую сессию на устройстве.
+// Предыдущие сессии пользователя на этом же устройстве отзываются, а счетчик неудачных попыток входа сбрасывается.
+// mfa - вход подтвержден вторым фактором, это отмечается в сессии и ее access-токенах.
+func (s *AuthService) AuthorizeUser(userID uint, device models.DeviceInfo, mfa bool) (tokenmanager.Tokens, error) {
+	var res tokenmanager.Tokens
+
+	if device.DeviceID == "" {
+		deviceID, err := randomID()
+		if err != nil {
+			return res, err
+		}
+		device.DeviceID = deviceID
+	} else if err := s.userRepository.RevokeDeviceSessions(userID, device.DeviceID); err != nil {
+		return res, err
+	}
+
+	familyID, err := randomID()
+	if err != nil {
+		return res, err
+	}
+
+	refreshToken, err := s.tokenManager.NewRefreshToken()
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		TokenHash:  tokenmanager.HashRefreshToken(refreshToken),
+		DeviceID:   device.DeviceID,
+		DeviceName: device.DeviceName,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(s.refreshTokenTTL),
+		MFA:        mfa,
+	}
+
+	if _, err := s.userRepository.CreateSession(session); err != nil {
+		return res, err
+	}
+
+	if err := s.loginThrottler.RecordSuccess(UserAccountKey(userID)); err != nil {
+		return res, err
+	}
+
+	res.AccessToken, err = s.newAccessToken(userID, mfa)
+	if err != nil {
+		return res, err
+	}
+	res.RefreshToken = refreshToken
+	res.DeviceID = device.DeviceID
+
+	s.auditService.Record(models.AuditEvent{
+		Type:      models.AuditLoginSucceeded,
+		ActorID:   &userID,
+		TargetID:  &userID,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+		Details:   models.AuditDetails{"deviceId": device.DeviceID, "deviceName": device.DeviceName},
+	})
+
+	return res, nil
+}
+
+// RefreshTokens обменивает refresh-токен на новую пару токенов.
+// Каждый refresh-токен одноразовый: повторное предъявление уже обменянного токена
+// означает его утечку, поэтому все семейство сессий отзывается.
+func (s *AuthService) RefreshTokens(refreshToken string, device models.DeviceInfo) (tokenmanager.Tokens, error) {
+	var res tokenmanager.Tokens
+
+	session, err := s.userRepository.GetSessionByTokenHash(tokenmanager.HashRefreshToken(refreshToken))
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return res, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if session.RotatedAt != nil {
+		if err := s.userRepository.RevokeSessionFamily(session.FamilyID); err != nil {
+			return res, err
+		}
+		s.recordTokenReuse(session, device)
+		return res, ErrRefreshTokenReused
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return res, ErrInvalidRefreshToken
+	}
+
+	newRefreshToken, err := s.tokenManager.NewRefreshToken()
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now()
+	next := session
+	next.TokenHash = tokenmanager.HashRefreshToken(newRefreshToken)
+	next.UserAgent = device.UserAgent
+	next.IP = device.IP
+	next.CreatedAt = now
+	next.ExpiresAt = now.Add(s.refreshTokenTTL)
+
+	_, err = s.userRepository.RotateSession(session.ID, next)
+	if errors.Is(err, database.ErrSessionAlreadyRotated) {
+		// Токен обменяли параллельным запросом между чтением и обновлением
+		if err := s.userRepository.RevokeSessionFamily(session.FamilyID); err != nil {
+			return res, err
+		}
+		s.recordTokenReuse(session, device)
+		return res, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return res, err
+	}
+
+	res.AccessToken, err = s.newAccessToken(session.UserID, session.MFA)
+	if err != nil {
+		return res, err
+	}
+	res.RefreshToken = newRefreshToken
+	res.DeviceID = session.DeviceID
+
+	s.auditService.Record(models.AuditEvent{
+		Type:      models.AuditTokenRefreshed,
+		ActorID:   &session.UserID,
+		TargetID:  &session.UserID,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+		Details:   models.AuditDetails{"deviceId": session.DeviceID},
+	})
+
+	return res, nil
+}
+
+// recordTokenReuse записывает в журнал повторное предъявление refresh-токена.
+// Исполнитель неизвестен: токеном мог воспользоваться как пользователь, так и укравший его.
+func (s *AuthService) recordTokenReuse(session models.Session, device models.DeviceInfo) {
+	s.auditService.Record(models.AuditEvent{
+		Type:      models.AuditTokenReuseDetected,
+		Outcome:   models.AuditFailure,
+		TargetID:  &session.UserID,
+		IP:        device.IP,
+		UserAgent: device.UserAgent,
+		Details:   models.AuditDetails{"deviceId": session.DeviceID},
+	})
+}
+
+// newAccessToken выпускает access-токен с текущими ролью и правами пользователя
+func (s *AuthService) newAccessToken(userID uint, mfa bool) (string, error) {
+	subject, err := s.roleService.GetSubject(userID)
+	if err != nil {
+		return "", err
+	}
+	subject.MFA = mfa
+
+	return s.tokenManager.NewJWT(subject, s.accessTokenTTL)
+}
+
+// Logout завершает сессию, к которой относится refresh-токен
+func (s *AuthService) Logout(refreshToken string) error {
+	session, err := s.userRepository.GetSessionByTokenHash(tokenmanager.HashRefreshToken(refreshToken))
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.userRepository.RevokeSessionFamily(session.FamilyID)
+}
+
+// LogoutAll завершает все сессии пользователя на всех устройствах, отзывает refresh-токены
+// сторонних приложений и все выпущенные ему access-токены
+func (s *AuthService) LogoutAll(userID uint) error {
+	if err := s.userRepository.RevokeUserSessions(userID); err != nil {
+		return err
+	}
+	if err := s.oauthRepository.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+
+	return s.RevokeUserTokens(userID)
+}
+
+// RevokeAccessToken отзывает один access-токен до истечения его срока действия
+func (s *AuthService) RevokeAccessToken(claims tokenmanager.Claims) error {
+	return s.revocationStore.RevokeToken(claims.TokenID, claims.ExpiresAt)
+}
+
+// RevokeUserTokens отзывает все access-токены пользователя, выпущенные к текущему моменту.
+// Используется при блокировке пользователя и смене пароля.
+func (s *AuthService) RevokeUserTokens(userID uint) error {
+	return s.revocationStore.RevokeUserTokens(userID, time.Now())
+}
+
+// GetActiveSessions возвращает список устройств, на которых пользователь сейчас авторизован
+func (s *AuthService) GetActiveSessions(userID uint) ([]models.Session, error) {
+	return s.userRepository.GetActiveSessions(userID)
+}
+
+// RevokeSession завершает одну сессию пользователя, например на украденном устройстве
+func (s *AuthService) RevokeSession(userID, sessionID uint) error {
+	err := s.userRepository.RevokeUserSession(userID, sessionID)
+	if errors.Is(err, database.ErrSessionNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// randomID генерирует случайный идентификатор для устройств и семейств сессий
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+)
+
+// loginUsers - хранилище пользователей для проверки входа
+type loginUsers struct {
+	database.UserRepositoryInterface
+	user models.User
+}
+
+func (r loginUsers) GetUserByLogin(login string) (models.User, error) {
+	if login == r.user.Login {
+		return r.user, nil
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+func (r loginUsers) GetUserByEmail(email string) (models.User, error) {
+	if email == r.user.Email {
+		return r.user, nil
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+func (r loginUsers) GetUserByPhone(phone string) (models.User, error) {
+	if phone == r.user.Phone {
+		return r.user, nil
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+// plainHasher сравнивает пароли без хеширования
+type plainHasher struct{}
+
+func (plainHasher) HashPassword(password string) (string, error) { return password, nil }
+func (plainHasher) NeedsRehash(string) bool                      { return false }
+func (plainHasher) Compare(hash, password string) error {
+	if hash != password {
+		return errors.New("mismatch")
+	}
+	return nil
+}
+
+type discardAudit struct{ AuditServiceInterface }
+
+func (discardAudit) Record(models.AuditEvent) {}
+
+func TestLoginLockoutCountsAccountAcrossIdentifiers(t *testing.T) {
+	throttler := NewLoginThrottler(database.NewMemoryLoginAttemptRepository(), LoginThrottleConfig{
+		LockThreshold: 5,
+		LockDuration:  time.Hour,
+		Window:        time.Hour,
+	})
+	service := &AuthService{
+		userRepository: loginUsers{user: models.User{
+			ID: 1, Login: "alice", Email: "alice@example.com", Phone: "+79001234567", Password: "secret",
+		}},
+		auditService:   discardAudit{},
+		loginThrottler: throttler,
+		hasher:         plainHasher{},
+	}
+
+	// Один и тот же аккаунт под разными именами
+	identifiers := []string{"alice", "Alice@Example.com", "+7 900 123-45-67", "89001234567", "79001234567"}
+	for _, identifier := range identifiers {
+		_, err := service.AuthenticateUser(models.LoginUser{Login: identifier, Password: "wrong", IP: "10.0.0.1"})
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("AuthenticateUser(%q) error = %v, want ErrInvalidCredentials", identifier, err)
+		}
+	}
+
+	var locked *AccountLockedError
+	for _, identifier := range identifiers {
+		_, err := service.AuthenticateUser(models.LoginUser{Login: identifier, Password: "secret", IP: "10.0.0.2"})
+		if !errors.As(err, &locked) {
+			t.Errorf("AuthenticateUser(%q) error = %v, want AccountLockedError", identifier, err)
+		}
+	}
+
+	if err := service.UnlockLogin("+7 (900) 123-45-67"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.AuthenticateUser(models.LoginUser{Login: "alice", Password: "secret", IP: "10.0.0.2"}); err != nil {
+		t.Errorf("AuthenticateUser() after unlock error = %v", err)
+	}
+}
+
+// sessionStore хранит сессии в памяти. RotateSession обменивает сессию с тем же условием, что и база данных.
+type sessionStore struct {
+	database.UserRepositoryInterface
+
+	mu       sync.Mutex
+	sessions []models.Session
+	// beforeRotate вызывается между чтением сессии и ее обменом, как параллельный запрос
+	beforeRotate func()
+}
+
+func (s *sessionStore) CreateSession(session models.Session) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.ID = uint(len(s.sessions) + 1)
+	s.sessions = append(s.sessions, session)
+	return session, nil
+}
+
+func (s *sessionStore) GetSessionByTokenHash(tokenHash string) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			return session, nil
+		}
+	}
+	return models.Session{}, database.ErrSessionNotFound
+}
+
+func (s *sessionStore) RotateSession(sessionID uint, next models.Session) (models.Session, error) {
+	if s.beforeRotate != nil {
+		s.beforeRotate()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := &s.sessions[sessionID-1]
+	if current.RotatedAt != nil || current.RevokedAt != nil {
+		return models.Session{}, database.ErrSessionAlreadyRotated
+	}
+	current.RotatedAt = &next.CreatedAt
+	next.ID = uint(len(s.sessions) + 1)
+	s.sessions = append(s.sessions, next)
+	return next, nil
+}
+
+func (s *sessionStore) RevokeSessionFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range s.sessions {
+		if s.sessions[i].FamilyID == familyID && s.sessions[i].RevokedAt == nil {
+			s.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *sessionStore) RevokeUserSessions(userID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := range s.sessions {
+		if s.sessions[i].UserID == userID && s.sessions[i].RevokedAt == nil {
+			s.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *sessionStore) revoked(familyID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.FamilyID == familyID && session.RevokedAt == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// oauthTokens - refresh-токены, выданные сторонним приложениям
+type oauthTokens struct {
+	database.OAuthRepositoryInterface
+	tokens []models.OAuthRefreshToken
+}
+
+func (s *oauthTokens) GetRefreshTokenByHash(tokenHash string) (models.OAuthRefreshToken, error) {
+	for _, token := range s.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return models.OAuthRefreshToken{}, database.ErrOAuthRefreshTokenNotFound
+}
+
+func (s *oauthTokens) RevokeUserRefreshTokens(userID uint) error {
+	now := time.Now()
+	for i := range s.tokens {
+		if s.tokens[i].UserID == userID && s.tokens[i].RevokedAt == nil {
+			s.tokens[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+type userSubjects struct{ RoleServiceInterface }
+
+func (userSubjects) GetSubject(userID uint) (tokenmanager.Subject, error) {
+	return tokenmanager.Subject{UserID: userID, Role: models.RoleUser}, nil
+}
+
+func newTestSessionService(t *testing.T) (*AuthService, *sessionStore) {
+	t.Helper()
+	return newTestSessionServiceWithOAuth(t, &oauthTokens{})
+}
+
+func newTestSessionServiceWithOAuth(t *testing.T, oauth *oauthTokens) (*AuthService, *sessionStore) {
+	t.Helper()
+	manager, err := tokenmanager.NewManager("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocations := tokenmanager.NewMemoryRevocationStore(time.Hour, time.Hour)
+	t.Cleanup(revocations.Stop)
+
+	store := &sessionStore{}
+	throttler := NewLoginThrottler(database.NewMemoryLoginAttemptRepository(), DefaultLoginThrottleConfig)
+	return NewAuthService(manager, revocations, store, oauth, userSubjects{}, discardAudit{}, throttler, plainHasher{},
+		time.Minute, time.Hour), store
+}
+
+func TestRefreshTokensRotates(t *testing.T) {
+	service, store := newTestSessionService(t)
+	device := models.DeviceInfo{DeviceName: "phone", IP: "10.0.0.1"}
+
+	tokens, err := service.AuthorizeUser(1, device, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device.DeviceID = tokens.DeviceID
+
+	next, err := service.RefreshTokens(tokens.RefreshToken, device)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if next.RefreshToken == tokens.RefreshToken || next.DeviceID != tokens.DeviceID {
+		t.Fatalf("RefreshTokens = %+v, want a new refresh token for device %s", next, tokens.DeviceID)
+	}
+	claims, err := service.tokenManager.ParseClaims(next.AccessToken)
+	if err != nil || claims.UserID != 1 || !claims.MFA {
+		t.Fatalf("access token claims = %+v, err = %v; want user 1 with mfa", claims, err)
+	}
+	if len(store.sessions) != 2 || store.sessions[0].RotatedAt == nil || store.sessions[1].FamilyID != store.sessions[0].FamilyID {
+		t.Fatalf("sessions = %+v, want the first rotated into a second of the same family", store.sessions)
+	}
+
+	if _, err := service.RefreshTokens(next.RefreshToken, device); err != nil {
+		t.Fatalf("RefreshTokens with the rotated token: %v", err)
+	}
+	if _, err := service.RefreshTokens("unknown", device); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("unknown token: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	service, store := newTestSessionService(t)
+
+	first, err := service.AuthorizeUser(1, models.DeviceInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.AuthorizeUser(1, models.DeviceInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := service.RefreshTokens(first.RefreshToken, models.DeviceInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Обмененный токен предъявлен повторно: отзывается его семейство, но не другие сессии
+	if _, err := service.RefreshTokens(first.RefreshToken, models.DeviceInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if !store.revoked(store.sessions[0].FamilyID) {
+		t.Fatal("family of the reused token is not revoked")
+	}
+	if _, err := service.RefreshTokens(next.RefreshToken, models.DeviceInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("token issued before reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := service.RefreshTokens(other.RefreshToken, models.DeviceInfo{}); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+}
+
+func TestRefreshTokensConcurrentRotation(t *testing.T) {
+	service, store := newTestSessionService(t)
+
+	tokens, err := service.AuthorizeUser(1, models.DeviceInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Параллельный запрос обменял тот же токен после чтения сессии, но до условного обновления
+	var winner tokenmanager.Tokens
+	store.beforeRotate = func() {
+		store.beforeRotate = nil
+		if winner, err = service.RefreshTokens(tokens.RefreshToken, models.DeviceInfo{}); err != nil {
+			t.Fatalf("parallel RefreshTokens: %v", err)
+		}
+	}
+	if _, err := service.RefreshTokens(tokens.RefreshToken, models.DeviceInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := service.RefreshTokens(winner.RefreshToken, models.DeviceInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("token of the parallel request: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutAllRevokesOAuthRefreshTokens(t *testing.T) {
+	oauth := &oauthTokens{tokens: []models.OAuthRefreshToken{
+		{ID: 1, TokenHash: hashToken("user-token"), FamilyID: "f1", ClientID: "app", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: 2, TokenHash: hashToken("other-token"), FamilyID: "f2", ClientID: "app", UserID: 2, ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+	service, store := newTestSessionServiceWithOAuth(t, oauth)
+
+	session, err := service.AuthorizeUser(1, models.DeviceInfo{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.LogoutAll(1); err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+
+	if !store.revoked(store.sessions[0].FamilyID) {
+		t.Fatal("session is not revoked")
+	}
+	if _, err := service.RefreshTokens(session.RefreshToken, models.DeviceInfo{}); err == nil {
+		t.Fatal("session refresh token is accepted after LogoutAll")
+	}
+
+	// Приложение больше не может обменять refresh-токен пользователя, токены других пользователей действуют
+	oauthService := NewOAuthService(oauth, nil, nil, time.Minute)
+	_, err = oauthService.RefreshToken(models.OAuthClient{ClientID: "app"}, "user-token", "")
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != OAuthInvalidGrant {
+		t.Fatalf("oauth refresh after LogoutAll: err = %v, want invalid_grant", err)
+	}
+	if oauth.tokens[1].RevokedAt != nil {
+		t.Fatal("refresh token of another user is revoked")
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// ErrCannotRestrictSelf возвращается при попытке заблокировать или заглушить самого себя
+var ErrCannotRestrictSelf = errors.New("you cannot block or mute yourself")
+
+// BlockServiceInterface определяет методы для блокировки и заглушения пользователей.
+// Блокировка скрывает пользователей друг от друга и удаляет дружбу между ними,
+// заглушение скрывает от заглушившего только содержимое заглушенного.
+type BlockServiceInterface interface {
+	Block(userID uint, login string) error
+	Unblock(userID uint, login string) error
+	GetBlocked(userID uint, limit, offset int) ([]models.RestrictedUser, error)
+	Mute(userID uint, login string) error
+	Unmute(userID uint, login string) error
+	GetMuted(userID uint, limit, offset int) ([]models.RestrictedUser, error)
+}
+
+// BlockService предоставляет реализацию BlockServiceInterface
+type BlockService struct {
+	userRepository  database.UserRepositoryInterface
+	blockRepository database.BlockRepositoryInterface
+	mediaService    MediaServiceInterface
+}
+
+// NewBlockService создает новый экземпляр BlockService
+func NewBlockService(userRepository database.UserRepositoryInterface, blockRepository database.BlockRepositoryInterface,
+	mediaService MediaServiceInterface) *BlockService {
+	return &BlockService{
+		userRepository:  userRepository,
+		blockRepository: blockRepository,
+		mediaService:    mediaService,
+	}
+}
+
+// Block блокирует пользователя с указанным логином. Повторная блокировка не считается ошибкой.
+func (s *BlockService) Block(userID uint, login string) error {
+	targetID, err := s.target(userID, login)
+	if err != nil {
+		return err
+	}
+	return s.blockRepository.Block(userID, targetID)
+}
+
+// Unblock снимает блокировку с пользователя с указанным логином
+func (s *BlockService) Unblock(userID uint, login string) error {
+	targetID, err := s.target(userID, login)
+	if err != nil {
+		return err
+	}
+	return s.blockRepository.Unblock(userID, targetID)
+}
+
+// GetBlocked возвращает пользователей, которых заблокировал userID
+func (s *BlockService) GetBlocked(userID uint, limit, offset int) ([]models.RestrictedUser, error) {
+	return s.withAvatarURLs(s.blockRepository.GetBlocked(userID, limit, offset))
+}
+
+// Mute заглушает пользователя с указанным логином. Повторное заглушение не считается ошибкой.
+func (s *BlockService) Mute(userID uint, login string) error {
+	targetID, err := s.target(userID, login)
+	if err != nil {
+		return err
+	}
+	return s.blockRepository.Mute(userID, targetID)
+}
+
+// Unmute снимает заглушение с пользователя с указанным логином
+func (s *BlockService) Unmute(userID uint, login string) error {
+	targetID, err := s.target(userID, login)
+	if err != nil {
+		return err
+	}
+	return s.blockRepository.Unmute(userID, targetID)
+}
+
+// GetMuted возвращает пользователей, которых заглушил userID
+func (s *BlockService) GetMuted(userID uint, limit, offset int) ([]models.RestrictedUser, error) {
+	return s.withAvatarURLs(s.blockRepository.GetMuted(userID, limit, offset))
+}
+
+// target находит пользователя, к которому применяется действие
+func (s *BlockService) target(userID uint, login string) (uint, error) {
+	user, err := s.userRepository.GetUserByLogin(login)
+	if err != nil {
+		return 0, err
+	}
+	if user.ID == userID {
+		return 0, ErrCannotRestrictSelf
+	}
+	return user.ID, nil
+}
+
+func (s *BlockService) withAvatarURLs(users []models.RestrictedUser, err error) ([]models.RestrictedUser, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Avatar = s.mediaService.ResolveURLs(users[i].Avatar)
+	}
+	return users, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryAfterError возвращается, когда операция временно ограничена и ее можно повторить позже
+type RetryAfterError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %d seconds", int(e.RetryAfter.Seconds()))
+}
+
+// AccountLockedError возвращается, когда вход временно заблокирован после множества неудачных попыток
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account is temporarily locked, retry after %d seconds", int(e.RetryAfter.Seconds()))
+}
+
+// Коды ошибок OAuth (RFC 6749, разделы 4.1.2.1 и 5.2)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+)
+
+// OAuthError - ошибка OAuth, которая передается клиенту в поле error
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// ErrCannotFriendSelf возвращается при попытке добавить в друзья самого себя
+var ErrCannotFriendSelf = errors.New("you cannot add yourself to friends")
+
+// FriendServiceInterface определяет методы для работы со списками друзей
+type FriendServiceInterface interface {
+	AddFriend(userID uint, login string) error
+	RemoveFriend(userID uint, login string) error
+	GetFriends(viewerID uint, login string, limit, offset int) ([]models.FriendProfile, error)
+}
+
+// FriendService предоставляет реализацию FriendServiceInterface
+type FriendService struct {
+	userRepository   database.UserRepositoryInterface
+	friendRepository database.FriendRepositoryInterface
+	privacyService   PrivacyServiceInterface
+	mediaService     MediaServiceInterface
+}
+
+// NewFriendService создает новый экземпляр FriendService
+func NewFriendService(userRepository database.UserRepositoryInterface, friendRepository database.FriendRepositoryInterface,
+	privacyService PrivacyServiceInterface, mediaService MediaServiceInterface) *FriendService {
+	return &FriendService{
+		userRepository:   userRepository,
+		friendRepository: friendRepository,
+		privacyService:   privacyService,
+		mediaService:     mediaService,
+	}
+}
+
+// AddFriend добавляет пользователя с указанным логином в друзья. Заблокированного пользователя
+// и пользователя, который заблокировал userID, добавить нельзя - для userID он не существует.
+func (s *FriendService) AddFriend(userID uint, login string) error {
+	friend, err := s.userRepository.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	if friend.ID == userID {
+		return ErrCannotFriendSelf
+	}
+
+	err = s.friendRepository.AddFriend(userID, friend.ID)
+	if errors.Is(err, database.ErrUserBlocked) {
+		return database.ErrUserNotFound
+	}
+	return err
+}
+
+// RemoveFriend удаляет пользователя с указанным логином из друзей
+func (s *FriendService) RemoveFriend(userID uint, login string) error {
+	friend, err := s.userRepository.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	return s.friendRepository.RemoveFriend(userID, friend.ID)
+}
+
+// GetFriends возвращает список друзей пользователя с указанным логином, если его настройки приватности
+// позволяют viewerID видеть список, иначе ErrHiddenByPrivacy. Друзья, с которыми у viewerID
+// есть блокировка, и заглушенные им пользователи не показываются.
+func (s *FriendService) GetFriends(viewerID uint, login string, limit, offset int) ([]models.FriendProfile, error) {
+	owner, err := s.userRepository.GetUserByLogin(login)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.privacyService.CanView(viewerID, owner.ID, models.PrivacyFriends)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrHiddenByPrivacy
+	}
+
+	friends, err := s.friendRepository.GetFriends(owner.ID, viewerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range friends {
+		friends[i].Avatar = s.mediaService.ResolveURLs(friends[i].Avatar)
+	}
+	return friends, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/locales"
+	"github.com/Saveliy12/prod2/internal/utils"
+)
+
+// LocaleServiceInterface определяет методы для работы с предпочитаемым языком пользователя
+type LocaleServiceInterface interface {
+	GetLocale(userID uint) (string, error)
+	SetLocale(userID uint, locale string) error
+	SupportedLocales() []string
+}
+
+// LocaleService предоставляет реализацию LocaleServiceInterface
+type LocaleService struct {
+	userRepository database.UserRepositoryInterface
+}
+
+// NewLocaleService создает новый экземпляр LocaleService
+func NewLocaleService(userRepository database.UserRepositoryInterface) *LocaleService {
+	return &LocaleService{userRepository: userRepository}
+}
+
+// GetLocale возвращает предпочитаемый язык пользователя или пустую строку, если он не выбран
+func (s *LocaleService) GetLocale(userID uint) (string, error) {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Locale, nil
+}
+
+// SetLocale сохраняет предпочитаемый язык. Пустая строка возвращает выбор языка по Accept-Language.
+func (s *LocaleService) SetLocale(userID uint, locale string) error {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale != "" && !locales.Bundle.Supports(locale) {
+		supported := strings.Join(locales.Bundle.Locales(), ", ")
+		return utils.NewFieldError("locale", "unsupported", "unsupported language, use one of: "+supported,
+			map[string]interface{}{"supported": supported})
+	}
+	return s.userRepository.SetLocale(userID, locale)
+}
+
+// SupportedLocales возвращает языки, на которые переведены сообщения API
+func (s *LocaleService) SupportedLocales() []string {
+	return locales.Bundle.Locales()
+}
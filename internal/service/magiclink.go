@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/Saveliy12/prod2/pkg/mailer"
+	"github.com/Saveliy12/prod2/pkg/pkce"
+)
+
+const (
+	magicLinkTokenTTL = time.Minute * 15
+
+	// Не больше 5 писем со ссылкой для входа в час на один аккаунт
+	magicLinkHourlyLimit = 5
+)
+
+var (
+	// ErrInvalidMagicLink возвращается для неизвестной, использованной, просроченной ссылки
+	// или ссылки, открытой не на том устройстве, где ее запросили
+	ErrInvalidMagicLink = errors.New("invalid or expired login link")
+	// ErrInvalidCodeChallenge возвращается, если устройство не передало code_challenge S256
+	ErrInvalidCodeChallenge = errors.New("codeChallenge must be a base64url-encoded SHA-256 of codeVerifier")
+)
+
+// MagicLinkServiceInterface определяет методы входа по ссылке из письма
+type MagicLinkServiceInterface interface {
+	RequestLink(email, codeChallenge string) error
+	VerifyLink(token, codeVerifier string) (uint, error)
+}
+
+// MagicLinkService предоставляет реализацию MagicLinkServiceInterface.
+// Устройство, запросившее ссылку, передает code_challenge, а при входе - code_verifier,
+// который никогда не покидает устройство. Поэтому пересланная или перехваченная ссылка
+// не откроется на другом устройстве.
+type MagicLinkService struct {
+	userRepository  database.UserRepositoryInterface
+	tokenRepository database.OneTimeTokenRepositoryInterface
+	mailer          mailer.Mailer
+	log             logger.LoggerInterface
+
+	// loginURL - адрес страницы входа, токен передается в параметре token
+	loginURL string
+}
+
+// NewMagicLinkService создает новый экземпляр MagicLinkService
+func NewMagicLinkService(userRepository database.UserRepositoryInterface, tokenRepository database.OneTimeTokenRepositoryInterface,
+	mailer mailer.Mailer, loginURL string) *MagicLinkService {
+	return &MagicLinkService{
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		mailer:          mailer,
+		log:             logger.GetLogger(),
+		loginURL:        loginURL,
+	}
+}
+
+// RequestLink отправляет письмо со ссылкой для входа. Как и при сбросе пароля,
+// письмо отправляется в фоне, чтобы ответ не выдавал, зарегистрирован ли адрес.
+func (s *MagicLinkService) RequestLink(email, codeChallenge string) error {
+	if !pkce.ValidChallenge(codeChallenge) {
+		return ErrInvalidCodeChallenge
+	}
+
+	go func() {
+		if err := s.sendLinkEmail(email, codeChallenge); err != nil {
+			s.log.Error("Failed to send login link email: " + err.Error())
+		}
+	}()
+	return nil
+}
+
+func (s *MagicLinkService) sendLinkEmail(email, codeChallenge string) error {
+	user, err := s.userRepository.GetUserByEmail(utils.NormalizeEmail(email))
+	if err != nil {
+		// Неизвестный адрес не считается ошибкой
+		return nil
+	}
+
+	now := time.Now()
+	count, _, _, err := s.tokenRepository.CountTokensSince(user.ID, models.TokenPurposeMagicLink, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= magicLinkHourlyLimit {
+		return nil
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepository.CreateToken(models.OneTimeToken{
+		UserID:        user.ID,
+		Purpose:       models.TokenPurposeMagicLink,
+		TokenHash:     hashToken(token),
+		CodeChallenge: codeChallenge,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(magicLinkTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.loginURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Sign-in link",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo sign in, open the link below on the same device where you requested it:\n%s\n\n"+
+			"The link is valid for %d minutes and can be used once. If you did not try to sign in, ignore this email.\n",
+			user.Login, link, int(magicLinkTokenTTL.Minutes())),
+	})
+}
+
+// VerifyLink проверяет токен из письма и code_verifier устройства и возвращает пользователя.
+// Токен сгорает и при неверном code_verifier: открытая на чужом устройстве ссылка больше не действует.
+func (s *MagicLinkService) VerifyLink(token, codeVerifier string) (uint, error) {
+	link, err := s.tokenRepository.ConsumeToken(models.TokenPurposeMagicLink, hashToken(token))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return 0, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !pkce.Verify(codeVerifier, link.CodeChallenge) {
+		return 0, ErrInvalidMagicLink
+	}
+
+	// Остальные ссылки для входа больше не нужны
+	if err := s.tokenRepository.InvalidateTokens(link.UserID, models.TokenPurposeMagicLink); err != nil {
+		return 0, err
+	}
+
+	// Переход по ссылке из письма подтверждает владение почтой
+	if err := s.userRepository.SetEmailVerified(link.UserID); err != nil {
+		return 0, err
+	}
+
+	return link.UserID, nil
+}
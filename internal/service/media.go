@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/imaging"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/Saveliy12/prod2/pkg/storage"
+)
+
+const (
+	// Изображения больше 24 мегапикселей не декодируются: в памяти такое изображение занимает около 100 МБ,
+	// и еще столько же нужно для поворота по EXIF
+	maxImagePixels = 24 * 1000 * 1000
+	// Одновременно обрабатывается не больше maxConcurrentDecodes изображений, остальные загрузки ждут
+	maxConcurrentDecodes = 4
+	jpegQuality          = 85
+)
+
+// imageSpec описывает обработку изображения одного вида: соотношение сторон, к которому оно обрезается,
+// и ширины вариантов. Варианты шире исходного изображения не создаются.
+type imageSpec struct {
+	aspectW, aspectH int
+	widths           []int
+}
+
+var imageSpecs = map[string]imageSpec{
+	models.ImageAvatar: {aspectW: 1, aspectH: 1, widths: []int{64, 256, 1024}},
+	models.ImageCover:  {aspectW: 3, aspectH: 1, widths: []int{640, 1280, 1920}},
+}
+
+// imageFormats - форматы, в которых сохраняется каждый вариант
+var imageFormats = []struct {
+	format      string
+	extension   string
+	contentType string
+	encode      func(io.Writer, image.Image) error
+}{
+	{imaging.FormatWebP, "webp", "image/webp", imaging.EncodeWebP},
+	{imaging.FormatJPEG, "jpg", "image/jpeg", func(w io.Writer, img image.Image) error {
+		return imaging.EncodeJPEG(w, img, jpegQuality)
+	}},
+}
+
+// ErrUnknownImageKind возвращается для вида изображения, отличного от avatar и cover
+var ErrUnknownImageKind = errors.New("unknown image kind")
+
+// MediaServiceInterface определяет методы для работы с изображениями профиля
+type MediaServiceInterface interface {
+	SetProfileImage(userID uint, kind string, data []byte) (*models.Image, error)
+	DeleteProfileImage(userID uint, kind string) error
+	ResolveURLs(image *models.Image) *models.Image
+	OpenSignedFile(key, expires, signature string) (io.ReadCloser, storage.Object, error)
+}
+
+// MediaService предоставляет реализацию MediaServiceInterface
+type MediaService struct {
+	profileRepository     database.ProfileRepositoryInterface
+	mediaObjectRepository database.MediaObjectRepositoryInterface
+	storage               storage.Storage
+	signer                *storage.URLSigner
+	urlTTL                time.Duration
+	decodeSlots           chan struct{}
+	log                   logger.LoggerInterface
+}
+
+// NewMediaService создает новый экземпляр MediaService. signer проверяет ссылки на файлы, которые раздает
+// сам сервис, и равен nil, если клиенты скачивают файлы напрямую из хранилища. urlTTL - срок действия ссылок.
+func NewMediaService(profileRepository database.ProfileRepositoryInterface,
+	mediaObjectRepository database.MediaObjectRepositoryInterface, fileStorage storage.Storage,
+	signer *storage.URLSigner, urlTTL time.Duration) *MediaService {
+	return &MediaService{
+		profileRepository:     profileRepository,
+		mediaObjectRepository: mediaObjectRepository,
+		storage:               fileStorage,
+		signer:                signer,
+		urlTTL:                urlTTL,
+		decodeSlots:           make(chan struct{}, maxConcurrentDecodes),
+		log:                   logger.GetLogger(),
+	}
+}
+
+// SetProfileImage обрабатывает загруженное изображение и делает его аватаром или обложкой профиля.
+// Изображение поворачивается по EXIF, обрезается по центру и сохраняется в нескольких размерах
+// в форматах WebP и JPEG без метаданных. Ключи файлов определяются содержимым, поэтому одинаковые
+// варианты хранятся один раз. Файлы прежнего изображения удаляются, если на них больше никто не ссылается.
+func (s *MediaService) SetProfileImage(userID uint, kind string, data []byte) (*models.Image, error) {
+	spec, ok := imageSpecs[kind]
+	if !ok {
+		return nil, ErrUnknownImageKind
+	}
+
+	result, files, err := s.processImage(data, kind, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ссылки берутся до записи файлов, чтобы параллельная замена такого же изображения
+	// не удалила только что записанный файл
+	if err := s.mediaObjectRepository.AcquireObjects(imageKeys(result)); err != nil {
+		return nil, err
+	}
+	for i, variant := range result.Variants {
+		if err := s.storage.Put(variant.Key, bytes.NewReader(files[i]), imageContentType(variant.Format)); err != nil {
+			s.releaseVariants(result)
+			return nil, err
+		}
+	}
+
+	previous, err := s.profileRepository.SetImage(userID, kind, result)
+	if err != nil {
+		s.releaseVariants(result)
+		return nil, err
+	}
+	s.releaseVariants(previous)
+
+	return s.ResolveURLs(result), nil
+}
+
+// processImage декодирует изображение и создает его варианты. Одновременно обрабатывается
+// не больше maxConcurrentDecodes изображений, чтобы параллельные загрузки не исчерпали память.
+func (s *MediaService) processImage(data []byte, kind string, spec imageSpec) (*models.Image, [][]byte, error) {
+	s.decodeSlots <- struct{}{}
+	defer func() { <-s.decodeSlots }()
+
+	img, _, err := imaging.Decode(data, maxImagePixels)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return nil, nil, utils.NewFieldError("file", "unsupported_format", "the file must be a JPEG, PNG, GIF or WebP image", nil)
+	case errors.Is(err, imaging.ErrTooLarge):
+		return nil, nil, utils.NewFieldError("file", "too_many_pixels",
+			fmt.Sprintf("the image must not exceed %d megapixels", maxImagePixels/1000/1000),
+			map[string]interface{}{"max": maxImagePixels / 1000 / 1000})
+	case err != nil:
+		return nil, nil, err
+	}
+
+	bounds := img.Bounds()
+	cropW, _ := imaging.CropSize(bounds.Dx(), bounds.Dy(), spec.aspectW, spec.aspectH)
+	if minW := spec.widths[0]; cropW < minW {
+		minH := minW * spec.aspectH / spec.aspectW
+		return nil, nil, utils.NewFieldError("file", "too_small", fmt.Sprintf("the image must be at least %dx%d pixels", minW, minH),
+			map[string]interface{}{"width": minW, "height": minH})
+	}
+
+	sum := sha256.Sum256(data)
+	return encodeVariants(img, kind, hex.EncodeToString(sum[:16]), spec, cropW)
+}
+
+// encodeVariants создает все варианты изображения и возвращает их описание вместе с содержимым файлов
+func encodeVariants(img image.Image, kind, id string, spec imageSpec, cropW int) (*models.Image, [][]byte, error) {
+	result := &models.Image{ID: id}
+	var files [][]byte
+	for _, width := range spec.widths {
+		if width > cropW {
+			width = cropW
+		}
+		height := width * spec.aspectH / spec.aspectW
+		if n := len(result.Variants); n > 0 && result.Variants[n-1].Width == width {
+			continue
+		}
+
+		thumbnail := imaging.Thumbnail(img, width, height)
+		for _, f := range imageFormats {
+			var buf bytes.Buffer
+			if err := f.encode(&buf, thumbnail); err != nil {
+				return nil, nil, fmt.Errorf("failed to encode %s image: %v", f.format, err)
+			}
+
+			result.Variants = append(result.Variants, models.ImageVariant{
+				Width: width, Height: height, Format: f.format,
+				Key: storage.ContentKey(kind+"s", buf.Bytes(), f.extension),
+			})
+			files = append(files, buf.Bytes())
+		}
+	}
+	return result, files, nil
+}
+
+func imageKeys(img *models.Image) []string {
+	keys := make([]string, len(img.Variants))
+	for i, variant := range img.Variants {
+		keys[i] = variant.Key
+	}
+	return keys
+}
+
+func imageContentType(format string) string {
+	for _, f := range imageFormats {
+		if f.format == format {
+			return f.contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// DeleteProfileImage удаляет аватар или обложку профиля вместе с файлами
+func (s *MediaService) DeleteProfileImage(userID uint, kind string) error {
+	if _, ok := imageSpecs[kind]; !ok {
+		return ErrUnknownImageKind
+	}
+	previous, err := s.profileRepository.SetImage(userID, kind, nil)
+	if err != nil {
+		return err
+	}
+	s.releaseVariants(previous)
+	return nil
+}
+
+// releaseVariants освобождает файлы изображения. Ошибки только записываются в лог:
+// изображение уже не используется, и оставшийся файл не мешает работе.
+func (s *MediaService) releaseVariants(img *models.Image) {
+	if img == nil {
+		return
+	}
+	if err := s.mediaObjectRepository.ReleaseObjects(imageKeys(img), s.storage.Delete); err != nil {
+		s.log.Error("Failed to delete files of image " + img.ID + ": " + err.Error())
+	}
+}
+
+// ResolveURLs заполняет подписанные адреса вариантов изображения для выдачи клиенту.
+// Срок действия отсчитывается от начала окна длиной urlTTL, поэтому ссылки на файлы локального
+// хранилища не меняются от запроса к запросу и кешируются браузером.
+func (s *MediaService) ResolveURLs(img *models.Image) *models.Image {
+	if img == nil {
+		return nil
+	}
+	now := time.Now()
+	ttl := now.Truncate(s.urlTTL).Add(2 * s.urlTTL).Sub(now)
+
+	resolved := &models.Image{ID: img.ID, Variants: make([]models.ImageVariant, len(img.Variants))}
+	for i, variant := range img.Variants {
+		url, err := s.storage.PresignGet(variant.Key, ttl)
+		if err != nil {
+			s.log.Error("Failed to sign url of " + variant.Key + ": " + err.Error())
+		}
+		variant.URL = url
+		resolved.Variants[i] = variant
+	}
+	return resolved
+}
+
+// OpenSignedFile проверяет подписанную ссылку и открывает файл хранилища для раздачи клиенту
+func (s *MediaService) OpenSignedFile(key, expires, signature string) (io.ReadCloser, storage.Object, error) {
+	if s.signer == nil {
+		// Файлы раздает само хранилище
+		return nil, storage.Object{}, storage.ErrNotFound
+	}
+	if err := s.signer.Verify(key, expires, signature, time.Now()); err != nil {
+		return nil, storage.Object{}, err
+	}
+	return s.storage.Get(key)
+}
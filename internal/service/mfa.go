@@ -0,0 +1,315 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/totp"
+)
+
+const (
+	// mfaIssuer отображается в приложении-аутентификаторе
+	mfaIssuer = "Social-Network"
+
+	// Время на ввод кода между первым и вторым шагом входа
+	mfaPendingTokenTTL = time.Minute * 5
+
+	recoveryCodesCount = 10
+	// Алфавит кодов восстановления без похожих символов (0/O, 1/I/L)
+	recoveryCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+var (
+	// ErrMFAAlreadyEnabled возвращается при повторном подключении двухфакторной аутентификации
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnabled возвращается, если двухфакторная аутентификация не подключена
+	ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrMFASetupNotStarted возвращается при подтверждении без предварительной генерации секрета
+	ErrMFASetupNotStarted = errors.New("two-factor authentication setup is not started")
+	// ErrInvalidMFACode возвращается для неверного или уже использованного кода
+	ErrInvalidMFACode = errors.New("invalid two-factor authentication code")
+	// ErrInvalidMFAToken возвращается для неизвестного или просроченного токена второго шага входа
+	ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+)
+
+// MFAServiceInterface определяет методы для двухфакторной аутентификации
+type MFAServiceInterface interface {
+	IsEnabled(userID uint) (bool, error)
+	GetStatus(userID uint) (models.MFAStatus, error)
+	BeginSetup(userID uint) (models.MFASetup, error)
+	ConfirmSetup(userID uint, code string) ([]string, error)
+	Disable(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	BeginLogin(userID uint) (string, error)
+	CompleteLogin(mfaToken, code, ip string) (uint, error)
+}
+
+// MFAService предоставляет реализацию MFAServiceInterface
+type MFAService struct {
+	mfaRepository   database.MFARepositoryInterface
+	userRepository  database.UserRepositoryInterface
+	tokenRepository database.OneTimeTokenRepositoryInterface
+	loginThrottler  *LoginThrottler
+}
+
+// NewMFAService создает новый экземпляр MFAService
+func NewMFAService(mfaRepository database.MFARepositoryInterface, userRepository database.UserRepositoryInterface,
+	tokenRepository database.OneTimeTokenRepositoryInterface, loginThrottler *LoginThrottler) *MFAService {
+	return &MFAService{
+		mfaRepository:   mfaRepository,
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		loginThrottler:  loginThrottler,
+	}
+}
+
+// IsEnabled проверяет, включена ли у пользователя двухфакторная аутентификация
+func (s *MFAService) IsEnabled(userID uint) (bool, error) {
+	mfa, err := s.mfaRepository.GetMFA(userID)
+	if errors.Is(err, database.ErrMFANotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.Enabled, nil
+}
+
+// GetStatus возвращает состояние двухфакторной аутентификации пользователя
+func (s *MFAService) GetStatus(userID uint) (models.MFAStatus, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil || !enabled {
+		return models.MFAStatus{}, err
+	}
+
+	left, err := s.mfaRepository.CountRecoveryCodes(userID)
+	if err != nil {
+		return models.MFAStatus{}, err
+	}
+
+	return models.MFAStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// BeginSetup генерирует новый секрет. Двухфакторная аутентификация включится
+// только после подтверждения кодом из приложения.
+func (s *MFAService) BeginSetup(userID uint) (models.MFASetup, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return models.MFASetup{}, err
+	}
+	if enabled {
+		return models.MFASetup{}, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return models.MFASetup{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return models.MFASetup{}, err
+	}
+
+	if err := s.mfaRepository.SaveMFASecret(userID, secret); err != nil {
+		return models.MFASetup{}, err
+	}
+
+	return models.MFASetup{
+		Secret: secret,
+		URI:    totp.URI(mfaIssuer, user.Login, secret),
+	}, nil
+}
+
+// ConfirmSetup включает двухфакторную аутентификацию после проверки первого кода
+// и возвращает коды восстановления. Они показываются пользователю один раз.
+func (s *MFAService) ConfirmSetup(userID uint, code string) ([]string, error) {
+	mfa, err := s.mfaRepository.GetMFA(userID)
+	if errors.Is(err, database.ErrMFANotFound) {
+		return nil, ErrMFASetupNotStarted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.checkTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepository.EnableMFA(userID); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(userID)
+}
+
+// Disable отключает двухфакторную аутентификацию. Требуется код из приложения или код восстановления.
+func (s *MFAService) Disable(userID uint, code string) error {
+	if err := s.verify(userID, code); err != nil {
+		return err
+	}
+
+	return s.mfaRepository.DeleteMFA(userID)
+}
+
+// RegenerateRecoveryCodes выпускает новые коды восстановления, старые перестают действовать
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if err := s.verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(userID)
+}
+
+// BeginLogin выдает короткоживущий токен второго шага входа после проверки пароля
+func (s *MFAService) BeginLogin(userID uint) (string, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.tokenRepository.CreateToken(models.OneTimeToken{
+		UserID:    userID,
+		Purpose:   models.TokenPurposeMFAPending,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(mfaPendingTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// CompleteLogin проверяет код второго шага и возвращает пользователя, которому можно выдать токены.
+// Токен второго шага одноразовый: после неверного кода вход нужно начинать заново.
+// Неверные коды учитываются тем же ограничителем, что и неверные пароли, поэтому знающий пароль
+// не может перебирать коды, каждый раз получая новый токен.
+func (s *MFAService) CompleteLogin(mfaToken, code, ip string) (uint, error) {
+	pending, err := s.tokenRepository.ConsumeToken(models.TokenPurposeMFAPending, hashToken(mfaToken))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return 0, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// При ошибке пользователь тоже возвращается, чтобы неудачную попытку можно было записать в журнал
+	account := UserAccountKey(pending.UserID)
+	if err := s.loginThrottler.Check(account, ip); err != nil {
+		return pending.UserID, err
+	}
+
+	if err := s.verify(pending.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.loginThrottler.RecordFailure(account, ip); err != nil {
+				return pending.UserID, err
+			}
+		}
+		return pending.UserID, err
+	}
+
+	return pending.UserID, nil
+}
+
+// verify принимает код из приложения или код восстановления
+func (s *MFAService) verify(userID uint, code string) error {
+	mfa, err := s.mfaRepository.GetMFA(userID)
+	if errors.Is(err, database.ErrMFANotFound) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return ErrMFANotEnabled
+	}
+
+	if len(strings.ReplaceAll(code, " ", "")) == totp.Digits {
+		return s.checkTOTP(mfa, code)
+	}
+
+	used, err := s.mfaRepository.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// checkTOTP проверяет код из приложения и запрещает повторное использование того же кода
+func (s *MFAService) checkTOTP(mfa models.MFA, code string) error {
+	step, ok := totp.Validate(mfa.Secret, code, time.Now(), 1)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	fresh, err := s.mfaRepository.UseTOTPStep(mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.mfaRepository.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// newRecoveryCode генерирует код восстановления вида XXXXX-XXXXX
+func newRecoveryCode() (string, error) {
+	// Байты вне диапазона, кратного размеру алфавита, отбрасываются, чтобы символы были равновероятны
+	limit := byte(256 - 256%len(recoveryCodeAlphabet))
+
+	code := make([]byte, 0, 11)
+	b := make([]byte, 16)
+	for len(code) < 11 {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, v := range b {
+			if len(code) == 5 {
+				code = append(code, '-')
+			}
+			if v >= limit || len(code) == 11 {
+				continue
+			}
+			code = append(code, recoveryCodeAlphabet[int(v)%len(recoveryCodeAlphabet)])
+		}
+	}
+	return string(code), nil
+}
+
+// normalizeRecoveryCode приводит введенный код к виду, в котором хранится его хеш:
+// регистр и разделители не важны
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(code))
+}
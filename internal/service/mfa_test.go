@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/totp"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// mfaStore хранит настройки двухфакторной аутентификации и токены второго шага в памяти
+type mfaStore struct {
+	database.MFARepositoryInterface
+	database.OneTimeTokenRepositoryInterface
+
+	lastStep int64
+	pending  map[string]uint
+}
+
+func (s *mfaStore) GetMFA(userID uint) (models.MFA, error) {
+	return models.MFA{UserID: userID, Secret: testTOTPSecret, Enabled: true, LastUsedStep: s.lastStep}, nil
+}
+
+func (s *mfaStore) UseTOTPStep(userID uint, step int64) (bool, error) {
+	if step <= s.lastStep {
+		return false, nil
+	}
+	s.lastStep = step
+	return true, nil
+}
+
+func (s *mfaStore) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	return false, nil
+}
+
+func (s *mfaStore) CreateToken(token models.OneTimeToken) error {
+	s.pending[token.TokenHash] = token.UserID
+	return nil
+}
+
+func (s *mfaStore) ConsumeToken(purpose, tokenHash string) (models.OneTimeToken, error) {
+	userID, ok := s.pending[tokenHash]
+	if !ok {
+		return models.OneTimeToken{}, database.ErrTokenNotFound
+	}
+	delete(s.pending, tokenHash)
+	return models.OneTimeToken{UserID: userID, Purpose: purpose, TokenHash: tokenHash}, nil
+}
+
+func TestMFALoginFailuresLockAccount(t *testing.T) {
+	throttler := NewLoginThrottler(database.NewMemoryLoginAttemptRepository(), LoginThrottleConfig{
+		LockThreshold: 3,
+		LockDuration:  time.Hour,
+		Window:        time.Hour,
+	})
+	store := &mfaStore{pending: map[string]uint{}}
+	mfa := NewMFAService(store, nil, store, throttler)
+	auth := &AuthService{
+		userRepository: loginUsers{user: models.User{ID: 1, Login: "alice", Password: "secret"}},
+		auditService:   discardAudit{},
+		loginThrottler: throttler,
+		hasher:         plainHasher{},
+	}
+	credentials := models.LoginUser{Login: "alice", Password: "secret", IP: "10.0.0.1"}
+
+	// Каждый вход с верным паролем дает новый токен второго шага, но не сбрасывает неудачные попытки
+	for i := 0; i < 3; i++ {
+		userID, err := auth.AuthenticateUser(credentials)
+		if err != nil {
+			t.Fatalf("attempt %d: AuthenticateUser: %v", i, err)
+		}
+		token, err := mfa.BeginLogin(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mfa.CompleteLogin(token, "000000", "10.0.0.1"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: CompleteLogin err = %v, want ErrInvalidMFACode", i, err)
+		}
+	}
+
+	var locked *AccountLockedError
+	if _, err := auth.AuthenticateUser(credentials); !errors.As(err, &locked) {
+		t.Fatalf("AuthenticateUser after wrong codes: err = %v, want AccountLockedError", err)
+	}
+
+	// Токен, полученный до блокировки, тоже не позволяет продолжать перебор, даже с верным кодом
+	token, err := mfa.BeginLogin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfa.CompleteLogin(token, code, "10.0.0.2"); !errors.As(err, &locked) {
+		t.Fatalf("CompleteLogin while locked: err = %v, want AccountLockedError", err)
+	}
+}
+
+func TestMFALoginResetsFailuresAfterSecondFactor(t *testing.T) {
+	attempts := database.NewMemoryLoginAttemptRepository()
+	throttler := NewLoginThrottler(attempts, LoginThrottleConfig{LockThreshold: 10, LockDuration: time.Hour, Window: time.Hour})
+	store := &mfaStore{pending: map[string]uint{}}
+	mfa := NewMFAService(store, nil, store, throttler)
+	auth, _ := newTestSessionService(t)
+	auth.loginThrottler = throttler
+
+	token, err := mfa.BeginLogin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mfa.CompleteLogin(token, "000000", "10.0.0.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("CompleteLogin err = %v, want ErrInvalidMFACode", err)
+	}
+	if failures(t, attempts, UserAccountKey(1)) != 1 {
+		t.Fatal("wrong code is not counted")
+	}
+
+	token, err = mfa.BeginLogin(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := mfa.CompleteLogin(token, code, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if _, err := auth.AuthorizeUser(userID, models.DeviceInfo{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := failures(t, attempts, UserAccountKey(1)); n != 0 {
+		t.Fatalf("failures after the second factor = %d, want 0", n)
+	}
+}
+
+func failures(t *testing.T, attempts database.LoginAttemptRepositoryInterface, key string) int {
+	t.Helper()
+	a, err := attempts.GetAttempts(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.Failures
+}
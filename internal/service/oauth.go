@@ -0,0 +1,541 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/pkce"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+)
+
+const (
+	// RFC 6749 рекомендует время жизни кода авторизации не больше 10 минут
+	oauthAuthorizationCodeTTL = time.Minute * 10
+	oauthRefreshTokenTTL      = time.Hour * 24 * 30
+
+	maxOAuthClients       = 20
+	maxOAuthClientNameLen = 100
+	maxOAuthRedirectURIs  = 10
+)
+
+var (
+	// ErrInvalidOAuthClient возвращается при регистрации приложения с неверными параметрами
+	ErrInvalidOAuthClient = errors.New("client must have a name of up to 100 characters, 1-10 valid redirect URIs and known scopes")
+	// ErrTooManyOAuthClients возвращается при превышении числа приложений у пользователя
+	ErrTooManyOAuthClients = errors.New("too many oauth clients")
+	// ErrOAuthClientNotFound возвращается, если у пользователя нет указанного приложения
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrOAuthConsentNotFound возвращается, если пользователь не давал согласия приложению
+	ErrOAuthConsentNotFound = errors.New("oauth consent not found")
+)
+
+// OAuthServiceInterface определяет методы сервера авторизации OAuth 2.1
+type OAuthServiceInterface interface {
+	RegisterClient(ownerID uint, name string, redirectURIs, scopes []string, confidential bool) (models.OAuthClient, string, error)
+	GetClients(ownerID uint) ([]models.OAuthClient, error)
+	DeleteClient(ownerID uint, clientID string) error
+
+	ValidateAuthorizationRequest(userID uint, req models.OAuthAuthorizationRequest) (models.OAuthAuthorizationPrompt, error)
+	Authorize(userID uint, req models.OAuthAuthorizationRequest, approved bool) (string, error)
+	GetConsents(userID uint) ([]models.OAuthConsent, error)
+	RevokeConsent(userID uint, clientID string) error
+
+	AuthenticateClient(clientID, clientSecret string) (models.OAuthClient, error)
+	ExchangeCode(client models.OAuthClient, code, redirectURI, codeVerifier string) (models.OAuthTokenResponse, error)
+	RefreshToken(client models.OAuthClient, refreshToken, scope string) (models.OAuthTokenResponse, error)
+	Introspect(client models.OAuthClient, token string) (models.OAuthIntrospection, error)
+	Revoke(client models.OAuthClient, token string) error
+}
+
+// OAuthService предоставляет реализацию OAuthServiceInterface.
+// Поддерживается только authorization code с обязательным PKCE (S256):
+// implicit и password grant в OAuth 2.1 исключены.
+// Access-токены - те же JWT, что и у сессий, но с client_id и scope и без роли и прав пользователя.
+type OAuthService struct {
+	oauthRepository database.OAuthRepositoryInterface
+	tokenManager    tokenmanager.TokenManagerInterface
+	revocationStore tokenmanager.RevocationStore
+	accessTokenTTL  time.Duration
+}
+
+// NewOAuthService создает новый экземпляр OAuthService
+func NewOAuthService(oauthRepository database.OAuthRepositoryInterface, tokenManager tokenmanager.TokenManagerInterface,
+	revocationStore tokenmanager.RevocationStore, accessTokenTTL time.Duration) *OAuthService {
+	return &OAuthService{
+		oauthRepository: oauthRepository,
+		tokenManager:    tokenManager,
+		revocationStore: revocationStore,
+		accessTokenTTL:  accessTokenTTL,
+	}
+}
+
+// RegisterClient регистрирует приложение пользователя. Для конфиденциального клиента
+// возвращается секрет: он показывается один раз, в базе хранится только хеш.
+func (s *OAuthService) RegisterClient(ownerID uint, name string, redirectURIs, scopes []string, confidential bool) (models.OAuthClient, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxOAuthClientNameLen || len(redirectURIs) == 0 ||
+		len(redirectURIs) > maxOAuthRedirectURIs || !knownScopes(scopes) {
+		return models.OAuthClient{}, "", ErrInvalidOAuthClient
+	}
+	for _, uri := range redirectURIs {
+		if !validRedirectURI(uri) {
+			return models.OAuthClient{}, "", ErrInvalidOAuthClient
+		}
+	}
+
+	existing, err := s.oauthRepository.GetClientsByOwner(ownerID)
+	if err != nil {
+		return models.OAuthClient{}, "", err
+	}
+	if len(existing) >= maxOAuthClients {
+		return models.OAuthClient{}, "", ErrTooManyOAuthClients
+	}
+
+	clientID, err := randomID()
+	if err != nil {
+		return models.OAuthClient{}, "", err
+	}
+
+	var secret, secretHash string
+	if confidential {
+		secret, err = newSecretToken()
+		if err != nil {
+			return models.OAuthClient{}, "", err
+		}
+		secretHash = hashToken(secret)
+	}
+
+	client, err := s.oauthRepository.CreateClient(models.OAuthClient{
+		ClientID:     clientID,
+		SecretHash:   secretHash,
+		OwnerID:      ownerID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return models.OAuthClient{}, "", err
+	}
+
+	return client, secret, nil
+}
+
+// GetClients возвращает приложения, зарегистрированные пользователем
+func (s *OAuthService) GetClients(ownerID uint) ([]models.OAuthClient, error) {
+	return s.oauthRepository.GetClientsByOwner(ownerID)
+}
+
+// DeleteClient удаляет приложение пользователя вместе с выданными ему токенами
+func (s *OAuthService) DeleteClient(ownerID uint, clientID string) error {
+	err := s.oauthRepository.DeleteClient(ownerID, clientID)
+	if errors.Is(err, database.ErrOAuthClientNotFound) {
+		return ErrOAuthClientNotFound
+	}
+	return err
+}
+
+// ValidateAuthorizationRequest проверяет запрос авторизации и возвращает сведения для страницы согласия.
+// Согласие не требуется, если пользователь уже разрешил приложению все запрошенные области действия.
+func (s *OAuthService) ValidateAuthorizationRequest(userID uint, req models.OAuthAuthorizationRequest) (models.OAuthAuthorizationPrompt, error) {
+	client, err := s.authorizationClient(req)
+	if err != nil {
+		return models.OAuthAuthorizationPrompt{}, err
+	}
+
+	scopes, err := validateAuthorizationRequest(client, req)
+	if err != nil {
+		return models.OAuthAuthorizationPrompt{}, err
+	}
+
+	consentRequired := true
+	consent, err := s.oauthRepository.GetConsent(userID, client.ClientID)
+	if err == nil {
+		consentRequired = !subsetOf(scopes, consent.Scopes)
+	} else if !errors.Is(err, database.ErrOAuthConsentNotFound) {
+		return models.OAuthAuthorizationPrompt{}, err
+	}
+
+	return models.OAuthAuthorizationPrompt{Client: client, Scopes: scopes, ConsentRequired: consentRequired}, nil
+}
+
+// Authorize выполняет решение пользователя и возвращает адрес, на который нужно вернуть его в приложение:
+// с кодом авторизации, если доступ разрешен, или с ошибкой.
+// Если приложение или redirect_uri не прошли проверку, перенаправлять нельзя, и возвращается *OAuthError.
+func (s *OAuthService) Authorize(userID uint, req models.OAuthAuthorizationRequest, approved bool) (string, error) {
+	client, err := s.authorizationClient(req)
+	if err != nil {
+		return "", err
+	}
+
+	scopes, err := validateAuthorizationRequest(client, req)
+	var oauthErr *OAuthError
+	if errors.As(err, &oauthErr) {
+		return errorRedirectURL(req, oauthErr), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !approved {
+		return errorRedirectURL(req, newOAuthError(OAuthAccessDenied, "the user denied access")), nil
+	}
+
+	now := time.Now()
+	err = s.oauthRepository.SaveConsent(models.OAuthConsent{
+		UserID:    userID,
+		ClientID:  client.ClientID,
+		Scopes:    scopes,
+		GrantedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	code, err := newSecretToken()
+	if err != nil {
+		return "", err
+	}
+	familyID, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.oauthRepository.CreateAuthorizationCode(models.OAuthAuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		FamilyID:      familyID,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(oauthAuthorizationCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return redirectURL(req.RedirectURI, url.Values{"code": {code}}, req.State), nil
+}
+
+// GetConsents возвращает приложения, которым пользователь дал доступ
+func (s *OAuthService) GetConsents(userID uint) ([]models.OAuthConsent, error) {
+	return s.oauthRepository.GetConsents(userID)
+}
+
+// RevokeConsent отзывает доступ приложения и его refresh-токены.
+// Выданные приложению access-токены действуют до истечения срока.
+func (s *OAuthService) RevokeConsent(userID uint, clientID string) error {
+	err := s.oauthRepository.DeleteConsent(userID, clientID)
+	if errors.Is(err, database.ErrOAuthConsentNotFound) {
+		return ErrOAuthConsentNotFound
+	}
+	return err
+}
+
+// AuthenticateClient проверяет client_id и, для конфиденциальных клиентов, секрет
+func (s *OAuthService) AuthenticateClient(clientID, clientSecret string) (models.OAuthClient, error) {
+	client, err := s.oauthRepository.GetClient(clientID)
+	if errors.Is(err, database.ErrOAuthClientNotFound) {
+		return models.OAuthClient{}, newOAuthError(OAuthInvalidClient, "unknown client")
+	}
+	if err != nil {
+		return models.OAuthClient{}, err
+	}
+
+	if client.Confidential() &&
+		subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return models.OAuthClient{}, newOAuthError(OAuthInvalidClient, "invalid client credentials")
+	}
+
+	return client, nil
+}
+
+// ExchangeCode обменивает код авторизации на токены.
+// Повторное предъявление кода означает его перехват, поэтому выданные по нему refresh-токены отзываются.
+func (s *OAuthService) ExchangeCode(client models.OAuthClient, code, redirectURI, codeVerifier string) (models.OAuthTokenResponse, error) {
+	authCode, err := s.oauthRepository.ConsumeAuthorizationCode(hashToken(code))
+	if errors.Is(err, database.ErrAuthorizationCodeNotFound) {
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "invalid authorization code")
+	}
+	if errors.Is(err, database.ErrAuthorizationCodeUsed) {
+		if err := s.oauthRepository.RevokeRefreshTokenFamily(authCode.FamilyID); err != nil {
+			return models.OAuthTokenResponse{}, err
+		}
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "authorization code already used")
+	}
+	if err != nil {
+		return models.OAuthTokenResponse{}, err
+	}
+
+	switch {
+	case authCode.ClientID != client.ClientID:
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "authorization code was issued to another client")
+	case time.Now().After(authCode.ExpiresAt):
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "authorization code expired")
+	case authCode.RedirectURI != redirectURI:
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "redirect_uri does not match")
+	case !pkce.Verify(codeVerifier, authCode.CodeChallenge):
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "invalid code_verifier")
+	}
+
+	return s.issueTokens(nil, models.OAuthRefreshToken{
+		FamilyID: authCode.FamilyID,
+		ClientID: client.ClientID,
+		UserID:   authCode.UserID,
+		Scopes:   authCode.Scopes,
+	})
+}
+
+// RefreshToken обменивает refresh-токен на новую пару токенов. scope позволяет сузить области действия.
+// Как и у сессий, повторное предъявление уже обменянного токена отзывает все семейство.
+func (s *OAuthService) RefreshToken(client models.OAuthClient, refreshToken, scope string) (models.OAuthTokenResponse, error) {
+	token, err := s.oauthRepository.GetRefreshTokenByHash(hashToken(refreshToken))
+	if errors.Is(err, database.ErrOAuthRefreshTokenNotFound) {
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "invalid refresh token")
+	}
+	if err != nil {
+		return models.OAuthTokenResponse{}, err
+	}
+
+	if token.ClientID != client.ClientID {
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "refresh token was issued to another client")
+	}
+	if token.RotatedAt != nil {
+		if err := s.oauthRepository.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+			return models.OAuthTokenResponse{}, err
+		}
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "refresh token reuse detected")
+	}
+	if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "invalid refresh token")
+	}
+
+	next := token
+	if scope != "" {
+		scopes := strings.Fields(scope)
+		if !subsetOf(scopes, token.Scopes) {
+			return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidScope, "requested scope exceeds the granted scope")
+		}
+		next.Scopes = scopes
+	}
+
+	return s.issueTokens(&token, next)
+}
+
+// Introspect возвращает сведения о токене (RFC 7662). Клиент может узнать только о своих токенах,
+// чужие, неизвестные, истекшие и отозванные токены одинаково неактивны.
+func (s *OAuthService) Introspect(client models.OAuthClient, token string) (models.OAuthIntrospection, error) {
+	refresh, err := s.oauthRepository.GetRefreshTokenByHash(hashToken(token))
+	if err == nil {
+		if refresh.ClientID != client.ClientID || refresh.RotatedAt != nil || refresh.RevokedAt != nil ||
+			time.Now().After(refresh.ExpiresAt) {
+			return models.OAuthIntrospection{}, nil
+		}
+		return models.OAuthIntrospection{
+			Active:    true,
+			Scope:     strings.Join(refresh.Scopes, " "),
+			ClientID:  refresh.ClientID,
+			Subject:   strconv.FormatUint(uint64(refresh.UserID), 10),
+			ExpiresAt: refresh.ExpiresAt.Unix(),
+			IssuedAt:  refresh.CreatedAt.Unix(),
+		}, nil
+	}
+	if !errors.Is(err, database.ErrOAuthRefreshTokenNotFound) {
+		return models.OAuthIntrospection{}, err
+	}
+
+	claims, err := s.tokenManager.ParseClaims(token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return models.OAuthIntrospection{}, nil
+	}
+	revoked, err := tokenmanager.IsRevoked(s.revocationStore, claims)
+	if err != nil || revoked {
+		return models.OAuthIntrospection{}, err
+	}
+
+	return models.OAuthIntrospection{
+		Active:    true,
+		Scope:     strings.Join(claims.Scopes, " "),
+		ClientID:  claims.ClientID,
+		Subject:   strconv.FormatUint(uint64(claims.UserID), 10),
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+	}, nil
+}
+
+// Revoke отзывает refresh-токен (вместе с семейством) или access-токен клиента (RFC 7009).
+// Неизвестный или чужой токен не считается ошибкой.
+func (s *OAuthService) Revoke(client models.OAuthClient, token string) error {
+	refresh, err := s.oauthRepository.GetRefreshTokenByHash(hashToken(token))
+	if err == nil {
+		if refresh.ClientID != client.ClientID {
+			return nil
+		}
+		return s.oauthRepository.RevokeRefreshTokenFamily(refresh.FamilyID)
+	}
+	if !errors.Is(err, database.ErrOAuthRefreshTokenNotFound) {
+		return err
+	}
+
+	claims, err := s.tokenManager.ParseClaims(token)
+	if err != nil || claims.ClientID != client.ClientID {
+		return nil
+	}
+	return s.revocationStore.RevokeToken(claims.TokenID, claims.ExpiresAt)
+}
+
+// issueTokens выпускает access-токен и refresh-токен семейства next.
+// Если передан previous, он атомарно помечается обменянным.
+func (s *OAuthService) issueTokens(previous *models.OAuthRefreshToken, next models.OAuthRefreshToken) (models.OAuthTokenResponse, error) {
+	refreshToken, err := s.tokenManager.NewRefreshToken()
+	if err != nil {
+		return models.OAuthTokenResponse{}, err
+	}
+
+	now := time.Now()
+	next.TokenHash = hashToken(refreshToken)
+	next.CreatedAt = now
+	next.ExpiresAt = now.Add(oauthRefreshTokenTTL)
+	next.RotatedAt = nil
+	next.RevokedAt = nil
+
+	if previous == nil {
+		err = s.oauthRepository.CreateRefreshToken(next)
+	} else {
+		err = s.oauthRepository.RotateRefreshToken(previous.ID, next)
+		if errors.Is(err, database.ErrOAuthRefreshTokenRotated) {
+			// Токен обменяли параллельным запросом между чтением и обновлением
+			if err := s.oauthRepository.RevokeRefreshTokenFamily(previous.FamilyID); err != nil {
+				return models.OAuthTokenResponse{}, err
+			}
+			return models.OAuthTokenResponse{}, newOAuthError(OAuthInvalidGrant, "refresh token reuse detected")
+		}
+	}
+	if err != nil {
+		return models.OAuthTokenResponse{}, err
+	}
+
+	accessToken, err := s.tokenManager.NewJWT(tokenmanager.Subject{
+		UserID:   next.UserID,
+		ClientID: next.ClientID,
+		Scopes:   next.Scopes,
+	}, s.accessTokenTTL)
+	if err != nil {
+		return models.OAuthTokenResponse{}, err
+	}
+
+	return models.OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(next.Scopes, " "),
+	}, nil
+}
+
+// authorizationClient находит приложение и проверяет redirect_uri. Ошибки этой проверки
+// нельзя передавать через redirect_uri: иначе сервер стал бы открытым редиректом.
+func (s *OAuthService) authorizationClient(req models.OAuthAuthorizationRequest) (models.OAuthClient, error) {
+	client, err := s.oauthRepository.GetClient(req.ClientID)
+	if errors.Is(err, database.ErrOAuthClientNotFound) {
+		return models.OAuthClient{}, newOAuthError(OAuthInvalidClient, "unknown client")
+	}
+	if err != nil {
+		return models.OAuthClient{}, err
+	}
+
+	// OAuth 2.1 требует точного совпадения redirect_uri с зарегистрированным
+	for _, uri := range client.RedirectURIs {
+		if uri == req.RedirectURI {
+			return client, nil
+		}
+	}
+	return models.OAuthClient{}, newOAuthError(OAuthInvalidRequest, "redirect_uri is not registered for the client")
+}
+
+// validateAuthorizationRequest проверяет тип ответа, PKCE и области действия.
+// Если scope не указан, запрашиваются все области, разрешенные приложению.
+func validateAuthorizationRequest(client models.OAuthClient, req models.OAuthAuthorizationRequest) ([]string, error) {
+	if req.ResponseType != "code" {
+		return nil, newOAuthError(OAuthUnsupportedResponseType, "only response_type=code is supported")
+	}
+	if req.CodeChallengeMethod != pkce.MethodS256 || !pkce.ValidChallenge(req.CodeChallenge) {
+		return nil, newOAuthError(OAuthInvalidRequest, "code_challenge with code_challenge_method=S256 is required")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !subsetOf(scopes, client.Scopes) {
+		return nil, newOAuthError(OAuthInvalidScope, "requested scope is not allowed for the client")
+	}
+
+	return scopes, nil
+}
+
+// validRedirectURI принимает https, http только для локального адреса (RFC 8252, раздел 7.3)
+// и собственные схемы мобильных приложений вида com.example.app (раздел 7.1). Фрагмент запрещен.
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
+
+// redirectURL добавляет параметры ответа к redirect_uri
+func redirectURL(redirectURI string, params url.Values, state string) string {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func errorRedirectURL(req models.OAuthAuthorizationRequest, err *OAuthError) string {
+	return redirectURL(req.RedirectURI, url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+	}, req.State)
+}
+
+// subsetOf проверяет, что все элементы scopes содержатся в allowed
+func subsetOf(scopes, allowed []string) bool {
+	for _, scope := range scopes {
+		found := false
+		for _, a := range allowed {
+			if a == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/oidc"
+	"github.com/Saveliy12/prod2/pkg/pkce"
+)
+
+const (
+	// Время на вход у провайдера
+	oidcLoginStateTTL = 10 * time.Minute
+	// Время на выбор логина после входа у провайдера
+	oidcSignupTTL = 30 * time.Minute
+)
+
+// OIDCServiceInterface определяет методы входа через внешних провайдеров OpenID Connect
+type OIDCServiceInterface interface {
+	Providers() []string
+	BeginLogin(ctx context.Context, provider string) (authURL, binding string, err error)
+	BeginLink(ctx context.Context, provider string, userID uint) (authURL, binding string, err error)
+	CompleteLogin(ctx context.Context, provider, code, state, binding string, callerID uint) (models.ExternalLoginResult, error)
+	CompleteSignup(signupToken, login string) (models.User, error)
+	GetIdentities(userID uint) ([]models.ExternalIdentity, error)
+	UnlinkIdentity(userID, identityID uint) error
+}
+
+var (
+	// ErrUnknownOIDCProvider возвращается для провайдера, которого нет в настройках
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	// ErrInvalidOIDCState возвращается для неизвестного, просроченного или уже использованного state
+	ErrInvalidOIDCState = errors.New("invalid or expired login state")
+	// ErrOIDCLoginFailed возвращается, если провайдер не подтвердил вход
+	ErrOIDCLoginFailed = errors.New("identity provider login failed")
+	// ErrOIDCEmailRequired возвращается, если провайдер не сообщил email нового пользователя
+	ErrOIDCEmailRequired = errors.New("identity provider did not share an email address")
+	// ErrInvalidSignupToken возвращается для неизвестного, просроченного или уже использованного токена регистрации
+	ErrInvalidSignupToken = errors.New("invalid or expired signup token")
+	// ErrOIDCLinkUserMismatch возвращается, если привязку завершает не тот пользователь, который ее начал
+	ErrOIDCLinkUserMismatch = errors.New("identity linking must be completed by the account that started it")
+	// ErrIdentityLinkedToAnotherUser возвращается, если аккаунт провайдера уже привязан к другому пользователю
+	ErrIdentityLinkedToAnotherUser = errors.New("this identity is linked to another account")
+	// ErrIdentityNotFound возвращается, если у пользователя нет указанного аккаунта провайдера
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrLastLoginMethod возвращается при попытке отвязать единственный способ входа
+	ErrLastLoginMethod = errors.New("cannot unlink the only sign-in method, set a password first")
+)
+
+// OIDCService предоставляет реализацию OIDCServiceInterface
+type OIDCService struct {
+	providers          map[string]*oidc.Provider
+	identityRepository database.ExternalIdentityRepositoryInterface
+	userRepository     database.UserRepositoryInterface
+}
+
+// NewOIDCService создает новый экземпляр OIDCService. Ключи providers - имена провайдеров в адресах.
+func NewOIDCService(providers map[string]*oidc.Provider, identityRepository database.ExternalIdentityRepositoryInterface,
+	userRepository database.UserRepositoryInterface) *OIDCService {
+	return &OIDCService{
+		providers:          providers,
+		identityRepository: identityRepository,
+		userRepository:     userRepository,
+	}
+}
+
+// Providers возвращает имена настроенных провайдеров
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BeginLogin возвращает адрес страницы входа провайдера и секрет привязки к браузеру,
+// без которого возврат от провайдера не будет принят
+func (s *OIDCService) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	return s.begin(ctx, provider, nil)
+}
+
+// BeginLink возвращает адрес страницы входа провайдера для привязки аккаунта к пользователю
+// и секрет привязки к браузеру
+func (s *OIDCService) BeginLink(ctx context.Context, provider string, userID uint) (string, string, error) {
+	return s.begin(ctx, provider, &userID)
+}
+
+func (s *OIDCService) begin(ctx context.Context, providerName string, userID *uint) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	state, err := newSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := newSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+	binding, err := newSecretToken()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := pkce.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	err = s.identityRepository.CreateLoginState(models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		BindingHash:  hashToken(binding),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		UserID:       userID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, binding, nil
+}
+
+// CompleteLogin обрабатывает возврат от провайдера: обменивает код, проверяет ID-токен и находит пользователя.
+// Аккаунт провайдера привязывается к существующему пользователю автоматически, только если и провайдер,
+// и мы подтвердили один и тот же email. Иначе пользователь должен выбрать логин для нового аккаунта
+// или войти паролем и привязать провайдера в настройках.
+// state принимается только вместе с binding из того же браузера, в котором начинался вход, поэтому
+// чужой адрес возврата от провайдера бесполезен. Привязку завершает только начавший ее пользователь callerID.
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerName, code, state, binding string, callerID uint) (models.ExternalLoginResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return models.ExternalLoginResult{}, ErrUnknownOIDCProvider
+	}
+	if code == "" || state == "" || binding == "" {
+		return models.ExternalLoginResult{}, ErrInvalidOIDCState
+	}
+
+	loginState, err := s.identityRepository.ConsumeLoginState(providerName, hashToken(state), hashToken(binding))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return models.ExternalLoginResult{}, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return models.ExternalLoginResult{}, err
+	}
+	if loginState.UserID != nil && *loginState.UserID != callerID {
+		return models.ExternalLoginResult{}, ErrOIDCLinkUserMismatch
+	}
+
+	token, err := provider.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return models.ExternalLoginResult{}, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	idToken, err := provider.VerifyIDToken(ctx, token.IDToken, loginState.Nonce)
+	if err != nil {
+		return models.ExternalLoginResult{}, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+	email := utils.NormalizeEmail(idToken.Email)
+
+	identity, err := s.identityRepository.GetIdentity(providerName, idToken.Subject)
+	switch {
+	case err == nil:
+		if loginState.UserID != nil && *loginState.UserID != identity.UserID {
+			return models.ExternalLoginResult{}, ErrIdentityLinkedToAnotherUser
+		}
+		return models.ExternalLoginResult{UserID: identity.UserID, LinkOnly: loginState.UserID != nil}, nil
+	case !errors.Is(err, database.ErrIdentityNotFound):
+		return models.ExternalLoginResult{}, err
+	}
+
+	// Привязка из настроек аккаунта: пользователь уже вошел, email провайдера не важен
+	if loginState.UserID != nil {
+		if err := s.link(*loginState.UserID, providerName, idToken.Subject, email); err != nil {
+			return models.ExternalLoginResult{}, err
+		}
+		return models.ExternalLoginResult{UserID: *loginState.UserID, Linked: true, LinkOnly: true}, nil
+	}
+
+	if email == "" {
+		return models.ExternalLoginResult{}, ErrOIDCEmailRequired
+	}
+
+	if idToken.EmailVerified {
+		user, err := s.userRepository.GetUserByEmail(email)
+		switch {
+		case err == nil && user.EmailVerified:
+			if err := s.link(user.ID, providerName, idToken.Subject, email); err != nil {
+				return models.ExternalLoginResult{}, err
+			}
+			return models.ExternalLoginResult{UserID: user.ID, Linked: true}, nil
+		case err != nil && !errors.Is(err, database.ErrUserNotFound):
+			return models.ExternalLoginResult{}, err
+		}
+	}
+
+	signupToken, err := newSecretToken()
+	if err != nil {
+		return models.ExternalLoginResult{}, err
+	}
+	now := time.Now()
+	err = s.identityRepository.CreatePendingSignup(models.OIDCPendingSignup{
+		TokenHash:     hashToken(signupToken),
+		Provider:      providerName,
+		Subject:       idToken.Subject,
+		Email:         email,
+		EmailVerified: idToken.EmailVerified,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(oidcSignupTTL),
+	})
+	if err != nil {
+		return models.ExternalLoginResult{}, err
+	}
+
+	return models.ExternalLoginResult{
+		SignupRequired: true,
+		SignupToken:    signupToken,
+		Email:          email,
+		SuggestedLogin: suggestLogin(idToken),
+	}, nil
+}
+
+// CompleteSignup создает аккаунт с выбранным логином для пользователя, вошедшего через провайдера.
+// У такого аккаунта нет пароля и телефона, пароль можно задать через сброс пароля.
+// Токен регистрации расходуется только вместе с созданием аккаунта, поэтому после ошибки
+// (например, занятого логина) можно выбрать другой логин, не входя у провайдера заново.
+// Занятость логина без учета регистра проверяет уникальный индекс users_login_key при создании аккаунта.
+func (s *OIDCService) CompleteSignup(signupToken, login string) (models.User, error) {
+	login = strings.TrimSpace(login)
+	if err := utils.ValidateLogin(login); err != nil {
+		return models.User{}, err
+	}
+	user, err := s.identityRepository.CompleteSignup(hashToken(signupToken), login, time.Now())
+	switch {
+	case errors.Is(err, database.ErrTokenNotFound):
+		return models.User{}, ErrInvalidSignupToken
+	case errors.Is(err, database.ErrIdentityAlreadyLinked):
+		return models.User{}, ErrIdentityLinkedToAnotherUser
+	case err != nil:
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// GetIdentities возвращает привязанные аккаунты провайдеров
+func (s *OIDCService) GetIdentities(userID uint) ([]models.ExternalIdentity, error) {
+	return s.identityRepository.GetIdentities(userID)
+}
+
+// UnlinkIdentity отвязывает аккаунт провайдера. Пользователь без пароля не может отвязать последний аккаунт.
+func (s *OIDCService) UnlinkIdentity(userID, identityID uint) error {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Password == "" {
+		identities, err := s.identityRepository.GetIdentities(userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) == 1 && identities[0].ID == identityID {
+			return ErrLastLoginMethod
+		}
+	}
+
+	err = s.identityRepository.DeleteIdentity(userID, identityID)
+	if errors.Is(err, database.ErrIdentityNotFound) {
+		return ErrIdentityNotFound
+	}
+	return err
+}
+
+func (s *OIDCService) link(userID uint, provider, subject, email string) error {
+	err := s.identityRepository.CreateIdentity(models.ExternalIdentity{
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	if errors.Is(err, database.ErrIdentityAlreadyLinked) {
+		return ErrIdentityLinkedToAnotherUser
+	}
+	return err
+}
+
+var notLoginChars = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// suggestLogin предлагает логин по имени пользователя у провайдера или по email
+func suggestLogin(idToken oidc.IDToken) string {
+	candidate := idToken.PreferredUsername
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(idToken.Email, "@")
+	}
+	candidate = strings.Trim(notLoginChars.ReplaceAllString(candidate, "-"), "-")
+	if len(candidate) > 30 {
+		candidate = candidate[:30]
+	}
+	return candidate
+}
@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/oidc"
+	"github.com/Saveliy12/prod2/pkg/pkce"
+	"github.com/golang-jwt/jwt"
+)
+
+const testOIDCClientID = "prod2-test"
+
+// fakeIdentityProvider - провайдер OpenID Connect с discovery, JWKS и token endpoint
+type fakeIdentityProvider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]providerLogin
+}
+
+// providerLogin - вход пользователя у провайдера, который ждет обмена кода
+type providerLogin struct {
+	subject       string
+	email         string
+	emailVerified bool
+	nonce         string
+	codeChallenge string
+}
+
+func newFakeIdentityProvider(t *testing.T) *fakeIdentityProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdentityProvider{key: key, codes: make(map[string]providerLogin)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Metadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		coordinate := func(v []byte) string {
+			padded := make([]byte, 32)
+			copy(padded[32-len(v):], v)
+			return base64.RawURLEncoding.EncodeToString(padded)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "kid": "test", "use": "sig", "crv": "P-256",
+			"x": coordinate(key.X.Bytes()), "y": coordinate(key.Y.Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// login имитирует вход пользователя на странице провайдера и возвращает code и state,
+// с которыми провайдер перенаправит пользователя обратно
+func (p *fakeIdentityProvider) login(t *testing.T, authURL string, user providerLogin) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != pkce.MethodS256 || query.Get("client_id") != testOIDCClientID {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	if user.nonce == "" {
+		user.nonce = query.Get("nonce")
+	}
+	user.codeChallenge = query.Get("code_challenge")
+
+	code, err := newSecretToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.codes[code] = user
+	p.mu.Unlock()
+	return code, query.Get("state")
+}
+
+func (p *fakeIdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	user, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	if !ok || !pkce.Verify(r.PostFormValue("code_verifier"), user.codeChallenge) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            user.subject,
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          user.nonce,
+		"email":          user.email,
+		"email_verified": user.emailVerified,
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(oidc.Token{AccessToken: "provider-access-token", TokenType: "Bearer", IDToken: signed})
+}
+
+// oidcStore хранит пользователей, привязки и состояния входа в памяти
+type oidcStore struct {
+	database.UserRepositoryInterface
+
+	users      []models.User
+	identities []models.ExternalIdentity
+	states     []models.OIDCLoginState
+	signups    []models.OIDCPendingSignup
+}
+
+func (s *oidcStore) GetUserByID(userID uint) (models.User, error) {
+	for _, user := range s.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+func (s *oidcStore) GetUserByLogin(login string) (models.User, error) {
+	for _, user := range s.users {
+		if strings.EqualFold(user.Login, login) {
+			return user, nil
+		}
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+func (s *oidcStore) GetUserByEmail(email string) (models.User, error) {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, database.ErrUserNotFound
+}
+
+func (s *oidcStore) CreateIdentity(identity models.ExternalIdentity) error {
+	if _, err := s.GetIdentity(identity.Provider, identity.Subject); err == nil {
+		return database.ErrIdentityAlreadyLinked
+	}
+	identity.ID = uint(len(s.identities) + 1)
+	s.identities = append(s.identities, identity)
+	return nil
+}
+
+func (s *oidcStore) GetIdentity(provider, subject string) (models.ExternalIdentity, error) {
+	for _, identity := range s.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return models.ExternalIdentity{}, database.ErrIdentityNotFound
+}
+
+func (s *oidcStore) GetIdentities(userID uint) ([]models.ExternalIdentity, error) {
+	identities := []models.ExternalIdentity{}
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (s *oidcStore) DeleteIdentity(userID, identityID uint) error {
+	return errors.New("not implemented")
+}
+
+func (s *oidcStore) CreateLoginState(state models.OIDCLoginState) error {
+	s.states = append(s.states, state)
+	return nil
+}
+
+func (s *oidcStore) ConsumeLoginState(provider, stateHash, bindingHash string) (models.OIDCLoginState, error) {
+	now := time.Now()
+	for i := range s.states {
+		state := &s.states[i]
+		if state.Provider == provider && state.StateHash == stateHash && state.BindingHash == bindingHash &&
+			state.UsedAt == nil && state.ExpiresAt.After(now) {
+			state.UsedAt = &now
+			return *state, nil
+		}
+	}
+	return models.OIDCLoginState{}, database.ErrTokenNotFound
+}
+
+func (s *oidcStore) CreatePendingSignup(signup models.OIDCPendingSignup) error {
+	s.signups = append(s.signups, signup)
+	return nil
+}
+
+func (s *oidcStore) CompleteSignup(tokenHash, login string, createdAt time.Time) (models.User, error) {
+	for i := range s.signups {
+		signup := &s.signups[i]
+		if signup.TokenHash != tokenHash || signup.UsedAt != nil || !signup.ExpiresAt.After(createdAt) {
+			continue
+		}
+		// Как уникальный индекс по lower(login)
+		for _, user := range s.users {
+			if strings.EqualFold(user.Login, login) {
+				return models.User{}, database.ErrLoginTaken
+			}
+			if user.Email == signup.Email {
+				return models.User{}, database.ErrEmailTaken
+			}
+		}
+		if _, err := s.GetIdentity(signup.Provider, signup.Subject); err == nil {
+			return models.User{}, database.ErrIdentityAlreadyLinked
+		}
+
+		user := models.User{ID: uint(len(s.users) + 1), Login: login, Email: signup.Email, EmailVerified: signup.EmailVerified}
+		s.users = append(s.users, user)
+		s.CreateIdentity(models.ExternalIdentity{UserID: user.ID, Provider: signup.Provider, Subject: signup.Subject, Email: signup.Email})
+		signup.UsedAt = &createdAt
+		return user, nil
+	}
+	return models.User{}, database.ErrTokenNotFound
+}
+
+func newTestOIDCService(t *testing.T, users ...models.User) (*OIDCService, *fakeIdentityProvider, *oidcStore) {
+	idp := newFakeIdentityProvider(t)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       idp.server.URL,
+		ClientID:     testOIDCClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/oidc/callback",
+	}, idp.server.Client())
+	store := &oidcStore{users: users}
+	return NewOIDCService(map[string]*oidc.Provider{"test": provider}, store, store), idp, store
+}
+
+func TestOIDCLoginWithLinkedIdentity(t *testing.T) {
+	ctx := context.Background()
+	service, idp, store := newTestOIDCService(t, models.User{ID: 1, Login: "alice", Email: "alice@example.com"})
+	store.identities = []models.ExternalIdentity{{ID: 1, UserID: 1, Provider: "test", Subject: "alice-sub"}}
+
+	authURL, binding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "alice-sub", email: "alice@example.com"})
+
+	result, err := service.CompleteLogin(ctx, "test", code, state, binding, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UserID != 1 || result.LinkOnly || result.SignupRequired {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestOIDCSignup(t *testing.T) {
+	ctx := context.Background()
+	service, idp, store := newTestOIDCService(t, models.User{ID: 1, Login: "alice", Email: "alice@example.com"})
+
+	authURL, binding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "bob-sub", email: "Bob@Example.com", emailVerified: true})
+
+	result, err := service.CompleteLogin(ctx, "test", code, state, binding, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.SignupRequired || result.Email != "bob@example.com" || result.SuggestedLogin != "Bob" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// Занятый логин не расходует токен регистрации
+	if _, err := service.CompleteSignup(result.SignupToken, "Alice"); !errors.Is(err, database.ErrLoginTaken) {
+		t.Fatalf("CompleteSignup with taken login: error = %v, want ErrLoginTaken", err)
+	}
+
+	user, err := service.CompleteSignup(result.SignupToken, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Login != "bob" || user.Email != "bob@example.com" || !user.EmailVerified {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if identity, err := store.GetIdentity("test", "bob-sub"); err != nil || identity.UserID != user.ID {
+		t.Fatalf("identity = %+v, %v; want linked to user %d", identity, err, user.ID)
+	}
+
+	if _, err := service.CompleteSignup(result.SignupToken, "bob2"); !errors.Is(err, ErrInvalidSignupToken) {
+		t.Fatalf("reused signup token: error = %v, want ErrInvalidSignupToken", err)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	ctx := context.Background()
+	service, idp, store := newTestOIDCService(t, models.User{ID: 1, Login: "alice", Email: "alice@example.com"})
+
+	authURL, binding, err := service.BeginLink(ctx, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "alice-sub", email: "other@example.com"})
+
+	result, err := service.CompleteLogin(ctx, "test", code, state, binding, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UserID != 1 || !result.Linked || !result.LinkOnly {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if identity, err := store.GetIdentity("test", "alice-sub"); err != nil || identity.UserID != 1 {
+		t.Fatalf("identity = %+v, %v; want linked to user 1", identity, err)
+	}
+}
+
+func TestOIDCLinkCompletedByAnotherUser(t *testing.T) {
+	ctx := context.Background()
+	service, idp, store := newTestOIDCService(t,
+		models.User{ID: 1, Login: "alice", Email: "alice@example.com"},
+		models.User{ID: 2, Login: "mallory", Email: "mallory@example.com"},
+	)
+
+	// Злоумышленник начинает привязку к своему аккаунту и подсовывает жертве адрес возврата
+	for _, callerID := range []uint{1, 0} {
+		authURL, binding, err := service.BeginLink(ctx, "test", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := idp.login(t, authURL, providerLogin{subject: "alice-sub", email: "alice@example.com", emailVerified: true})
+
+		_, err = service.CompleteLogin(ctx, "test", code, state, binding, callerID)
+		if !errors.Is(err, ErrOIDCLinkUserMismatch) {
+			t.Fatalf("caller %d: error = %v, want ErrOIDCLinkUserMismatch", callerID, err)
+		}
+	}
+	if len(store.identities) != 0 {
+		t.Fatalf("identities = %+v, want none", store.identities)
+	}
+}
+
+func TestOIDCStateBoundToBrowser(t *testing.T) {
+	ctx := context.Background()
+	service, idp, _ := newTestOIDCService(t)
+
+	authURL, binding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "bob-sub", email: "bob@example.com"})
+
+	_, otherBinding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []string{"", otherBinding} {
+		if _, err := service.CompleteLogin(ctx, "test", code, state, b, 0); !errors.Is(err, ErrInvalidOIDCState) {
+			t.Fatalf("binding %q: error = %v, want ErrInvalidOIDCState", b, err)
+		}
+	}
+
+	// Попытка из чужого браузера не расходует state
+	if _, err := service.CompleteLogin(ctx, "test", code, state, binding, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOIDCReusedState(t *testing.T) {
+	ctx := context.Background()
+	service, idp, _ := newTestOIDCService(t)
+
+	authURL, binding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "bob-sub", email: "bob@example.com"})
+	if _, err := service.CompleteLogin(ctx, "test", code, state, binding, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.CompleteLogin(ctx, "test", code, state, binding, 0); !errors.Is(err, ErrInvalidOIDCState) {
+		t.Fatalf("reused state: error = %v, want ErrInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCWrongNonce(t *testing.T) {
+	ctx := context.Background()
+	service, idp, store := newTestOIDCService(t)
+
+	authURL, binding, err := service.BeginLogin(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := idp.login(t, authURL, providerLogin{subject: "bob-sub", email: "bob@example.com", nonce: "replayed-nonce"})
+
+	_, err = service.CompleteLogin(ctx, "test", code, state, binding, 0)
+	if !errors.Is(err, ErrOIDCLoginFailed) || !strings.Contains(err.Error(), "nonce does not match") {
+		t.Fatalf("error = %v, want ErrOIDCLoginFailed for nonce mismatch", err)
+	}
+	if len(store.signups) != 0 {
+		t.Fatalf("signups = %+v, want none", store.signups)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/hash"
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/Saveliy12/prod2/pkg/mailer"
+)
+
+const (
+	passwordResetTokenTTL = time.Minute * 30
+
+	// Не больше 3 писем для сброса пароля в час на один аккаунт
+	passwordResetHourlyLimit = 3
+)
+
+// ErrInvalidResetToken возвращается для неизвестного, использованного или просроченного токена сброса пароля
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// PasswordResetServiceInterface определяет методы для восстановления пароля
+type PasswordResetServiceInterface interface {
+	RequestPasswordReset(email string)
+	ResetPassword(token, newPassword string) (uint, error)
+}
+
+// PasswordResetService предоставляет реализацию PasswordResetServiceInterface
+type PasswordResetService struct {
+	authService     AuthServiceInterface
+	userRepository  database.UserRepositoryInterface
+	tokenRepository database.OneTimeTokenRepositoryInterface
+	hasher          hash.HasherInterface
+	mailer          mailer.Mailer
+	log             logger.LoggerInterface
+
+	// resetURL - адрес страницы ввода нового пароля, токен передается в параметре token
+	resetURL string
+}
+
+// NewPasswordResetService создает новый экземпляр PasswordResetService
+func NewPasswordResetService(authService AuthServiceInterface, userRepository database.UserRepositoryInterface,
+	tokenRepository database.OneTimeTokenRepositoryInterface, hasher hash.HasherInterface, mailer mailer.Mailer, resetURL string) *PasswordResetService {
+	return &PasswordResetService{
+		authService:     authService,
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		hasher:          hasher,
+		mailer:          mailer,
+		log:             logger.GetLogger(),
+		resetURL:        resetURL,
+	}
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой для сброса пароля.
+// Результат не возвращается, а письмо отправляется в фоне, чтобы ни ответ,
+// ни время его получения не выдавали, зарегистрирован ли адрес.
+func (s *PasswordResetService) RequestPasswordReset(email string) {
+	go func() {
+		if err := s.sendResetEmail(email); err != nil {
+			s.log.Error("Failed to send password reset email: " + err.Error())
+		}
+	}()
+}
+
+func (s *PasswordResetService) sendResetEmail(email string) error {
+	user, err := s.userRepository.GetUserByEmail(utils.NormalizeEmail(email))
+	if err != nil {
+		// Неизвестный адрес не считается ошибкой
+		return nil
+	}
+
+	now := time.Now()
+	count, _, _, err := s.tokenRepository.CountTokensSince(user.ID, models.TokenPurposePasswordReset, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= passwordResetHourlyLimit {
+		return nil
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.tokenRepository.CreateToken(models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposePasswordReset,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password, open the link below:\n%s\n\n"+
+			"The link is valid for %d minutes. If you did not request a password reset, ignore this email.\n",
+			user.Login, link, int(passwordResetTokenTTL.Minutes())),
+	})
+}
+
+// ResetPassword устанавливает новый пароль по токену из письма, завершает все сессии пользователя
+// и возвращает его идентификатор
+func (s *PasswordResetService) ResetPassword(token, newPassword string) (uint, error) {
+	reset, err := s.tokenRepository.GetToken(models.TokenPurposePasswordReset, hashToken(token))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := s.userRepository.GetUserByID(reset.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Пароль проверяется до использования токена, чтобы неподходящий пароль не сжигал ссылку
+	if err := utils.ValidatePassword(newPassword, user.Login, user.Email); err != nil {
+		return 0, err
+	}
+
+	reset, err = s.tokenRepository.ConsumeToken(models.TokenPurposePasswordReset, hashToken(token))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return 0, ErrInvalidResetToken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	passwordHash, err := s.hasher.HashPassword(newPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.userRepository.UpdatePassword(reset.UserID, passwordHash); err != nil {
+		return 0, err
+	}
+
+	// Остальные ссылки для сброса больше не нужны
+	if err := s.tokenRepository.InvalidateTokens(reset.UserID, models.TokenPurposePasswordReset); err != nil {
+		return 0, err
+	}
+
+	return reset.UserID, s.authService.LogoutAll(reset.UserID)
+}
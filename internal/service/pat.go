@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/logger"
+)
+
+const (
+	// personalAccessTokenPrefix отличает персональные токены от JWT и помогает находить их в утекших логах и коде
+	personalAccessTokenPrefix = "pat_"
+
+	defaultPersonalAccessTokenTTL = time.Hour * 24 * 90
+	maxPersonalAccessTokenTTL     = time.Hour * 24 * 365
+
+	maxPersonalAccessTokens       = 50
+	maxPersonalAccessTokenNameLen = 100
+)
+
+var (
+	// ErrInvalidPersonalAccessToken возвращается для неизвестного, истекшего или отозванного токена
+	ErrInvalidPersonalAccessToken = errors.New("invalid or expired personal access token")
+	// ErrPersonalAccessTokenNotFound возвращается, если у пользователя нет указанного токена
+	ErrPersonalAccessTokenNotFound = errors.New("personal access token not found")
+	// ErrInvalidPersonalAccessTokenRequest возвращается для токена без имени, областей действия или с неверным сроком
+	ErrInvalidPersonalAccessTokenRequest = errors.New("token must have a name of up to 100 characters, known scopes and expire within a year")
+	// ErrTooManyPersonalAccessTokens возвращается при превышении числа токенов у пользователя
+	ErrTooManyPersonalAccessTokens = errors.New("too many personal access tokens")
+)
+
+// PersonalAccessTokenServiceInterface определяет методы для работы с персональными токенами
+type PersonalAccessTokenServiceInterface interface {
+	CreateToken(userID uint, name string, scopes []string, ttl time.Duration) (string, models.PersonalAccessToken, error)
+	GetTokens(userID uint) ([]models.PersonalAccessToken, error)
+	RevokeToken(userID, tokenID uint) error
+	Authenticate(token string) (models.PersonalAccessToken, error)
+	IsPersonalAccessToken(token string) bool
+}
+
+// PersonalAccessTokenService предоставляет реализацию PersonalAccessTokenServiceInterface
+type PersonalAccessTokenService struct {
+	tokenRepository database.PersonalAccessTokenRepositoryInterface
+	log             logger.LoggerInterface
+}
+
+// NewPersonalAccessTokenService создает новый экземпляр PersonalAccessTokenService
+func NewPersonalAccessTokenService(tokenRepository database.PersonalAccessTokenRepositoryInterface) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{
+		tokenRepository: tokenRepository,
+		log:             logger.GetLogger(),
+	}
+}
+
+// CreateToken выпускает персональный токен и возвращает его вместе с сохраненной записью.
+// Токен показывается один раз: в базе остается только хеш. ttl = 0 означает срок по умолчанию.
+func (s *PersonalAccessTokenService) CreateToken(userID uint, name string, scopes []string, ttl time.Duration) (string, models.PersonalAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if ttl == 0 {
+		ttl = defaultPersonalAccessTokenTTL
+	}
+	if name == "" || len(name) > maxPersonalAccessTokenNameLen || ttl < 0 || ttl > maxPersonalAccessTokenTTL || !knownScopes(scopes) {
+		return "", models.PersonalAccessToken{}, ErrInvalidPersonalAccessTokenRequest
+	}
+
+	existing, err := s.tokenRepository.GetPersonalAccessTokens(userID)
+	if err != nil {
+		return "", models.PersonalAccessToken{}, err
+	}
+	if len(existing) >= maxPersonalAccessTokens {
+		return "", models.PersonalAccessToken{}, ErrTooManyPersonalAccessTokens
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return "", models.PersonalAccessToken{}, err
+	}
+	token := personalAccessTokenPrefix + secret
+
+	now := time.Now()
+	created, err := s.tokenRepository.CreatePersonalAccessToken(models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", models.PersonalAccessToken{}, err
+	}
+
+	return token, created, nil
+}
+
+// GetTokens возвращает неотозванные токены пользователя
+func (s *PersonalAccessTokenService) GetTokens(userID uint) ([]models.PersonalAccessToken, error) {
+	return s.tokenRepository.GetPersonalAccessTokens(userID)
+}
+
+// RevokeToken отзывает токен пользователя
+func (s *PersonalAccessTokenService) RevokeToken(userID, tokenID uint) error {
+	err := s.tokenRepository.RevokePersonalAccessToken(userID, tokenID)
+	if errors.Is(err, database.ErrPersonalAccessTokenNotFound) {
+		return ErrPersonalAccessTokenNotFound
+	}
+	return err
+}
+
+// Authenticate проверяет персональный токен и отмечает время его использования
+func (s *PersonalAccessTokenService) Authenticate(token string) (models.PersonalAccessToken, error) {
+	pat, err := s.tokenRepository.GetPersonalAccessTokenByHash(hashToken(token))
+	if errors.Is(err, database.ErrPersonalAccessTokenNotFound) {
+		return models.PersonalAccessToken{}, ErrInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+
+	// Время использования справочное, ошибка его записи не мешает запросу
+	if err := s.tokenRepository.TouchPersonalAccessToken(pat.ID, time.Now()); err != nil {
+		s.log.Warn("Failed to update personal access token last use: " + err.Error())
+	}
+
+	return pat, nil
+}
+
+// IsPersonalAccessToken отличает персональный токен от JWT по префиксу
+func (s *PersonalAccessTokenService) IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
+
+// knownScopes проверяет, что указана хотя бы одна область действия и все они известны
+func knownScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range models.Scopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+	"github.com/Saveliy12/prod2/pkg/sms"
+)
+
+const (
+	phoneCodeTTL         = time.Minute * 5
+	phoneCodeDigits      = 6
+	phoneCodeMaxAttempts = 5
+
+	// Ограничения на отправку SMS: на номер не чаще раза в минуту и не более 5 в час,
+	// с одного IP-адреса не более 20 в час
+	phoneCodeResendInterval   = time.Minute
+	phoneCodeHourlyLimitPhone = 5
+	phoneCodeHourlyLimitIP    = 20
+)
+
+var (
+	// ErrPhoneMissing возвращается, если у пользователя не указан номер телефона
+	ErrPhoneMissing = errors.New("account has no phone number")
+	// ErrPhoneAlreadyVerified возвращается при запросе кода для уже подтвержденного номера
+	ErrPhoneAlreadyVerified = errors.New("phone number is already verified")
+	// ErrInvalidPhoneCode возвращается для неверного, использованного или просроченного кода
+	ErrInvalidPhoneCode = errors.New("invalid or expired verification code")
+	// ErrPhoneCodeAttemptsExceeded возвращается, когда попытки ввода кода исчерпаны
+	ErrPhoneCodeAttemptsExceeded = errors.New("too many attempts, request a new code")
+)
+
+// PhoneVerificationServiceInterface определяет методы для подтверждения номера телефона
+type PhoneVerificationServiceInterface interface {
+	GetStatus(userID uint) (models.PhoneStatus, error)
+	SendCode(userID uint, ip string) error
+	ConfirmCode(userID uint, code string) error
+}
+
+// PhoneVerificationService предоставляет реализацию PhoneVerificationServiceInterface
+type PhoneVerificationService struct {
+	userRepository database.UserRepositoryInterface
+	codeRepository database.PhoneVerificationRepositoryInterface
+	smsSender      sms.SMSSender
+}
+
+// NewPhoneVerificationService создает новый экземпляр PhoneVerificationService
+func NewPhoneVerificationService(userRepository database.UserRepositoryInterface,
+	codeRepository database.PhoneVerificationRepositoryInterface, smsSender sms.SMSSender) *PhoneVerificationService {
+	return &PhoneVerificationService{
+		userRepository: userRepository,
+		codeRepository: codeRepository,
+		smsSender:      smsSender,
+	}
+}
+
+// GetStatus возвращает номер пользователя в международном формате и состояние его подтверждения
+func (s *PhoneVerificationService) GetStatus(userID uint) (models.PhoneStatus, error) {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return models.PhoneStatus{}, err
+	}
+	if user.Phone == "" {
+		return models.PhoneStatus{}, ErrPhoneMissing
+	}
+
+	return models.PhoneStatus{
+		Phone:     user.Phone,
+		Formatted: utils.FormatPhone(user.Phone),
+		Region:    utils.PhoneRegion(user.Phone),
+		Verified:  user.PhoneVerified,
+	}, nil
+}
+
+// SendCode отправляет код подтверждения на номер пользователя. Предыдущие коды перестают действовать.
+func (s *PhoneVerificationService) SendCode(userID uint, ip string) error {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Phone == "" {
+		return ErrPhoneMissing
+	}
+	if user.PhoneVerified {
+		return ErrPhoneAlreadyVerified
+	}
+
+	now := time.Now()
+	if err := s.checkSendLimits(user.Phone, ip, now); err != nil {
+		return err
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		return err
+	}
+
+	if err := s.codeRepository.InvalidateCodes(userID); err != nil {
+		return err
+	}
+	err = s.codeRepository.CreateCode(models.PhoneVerificationCode{
+		UserID:    userID,
+		Phone:     user.Phone,
+		IP:        ip,
+		CodeHash:  hashToken(code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(phoneCodeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.smsSender.Send(sms.Message{
+		To:   user.Phone,
+		Text: fmt.Sprintf("Your verification code: %s. It is valid for %d minutes.", code, int(phoneCodeTTL.Minutes())),
+	})
+}
+
+// checkSendLimits ограничивает отправку SMS на номер и с IP-адреса, чтобы сервис нельзя было
+// использовать для рассылки на чужие номера за наш счет
+// Часовой лимит освобождается, когда из окна выходит самый старый код, поэтому ожидание считается от него.
+func (s *PhoneVerificationService) checkSendLimits(phone, ip string, now time.Time) error {
+	count, first, last, err := s.codeRepository.CountCodesByPhone(phone, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= phoneCodeHourlyLimitPhone {
+		return &RetryAfterError{RetryAfter: first.Add(time.Hour).Sub(now)}
+	}
+	if wait := last.Add(phoneCodeResendInterval).Sub(now); count > 0 && wait > 0 {
+		return &RetryAfterError{RetryAfter: wait}
+	}
+
+	count, first, _, err = s.codeRepository.CountCodesByIP(ip, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= phoneCodeHourlyLimitIP {
+		return &RetryAfterError{RetryAfter: first.Add(time.Hour).Sub(now)}
+	}
+	return nil
+}
+
+// ConfirmCode подтверждает номер по коду из SMS. На каждый код дается phoneCodeMaxAttempts попыток.
+func (s *PhoneVerificationService) ConfirmCode(userID uint, code string) error {
+	active, err := s.codeRepository.GetActiveCode(userID)
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return ErrInvalidPhoneCode
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.codeRepository.RecordAttempt(active.ID, phoneCodeMaxAttempts)
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return ErrPhoneCodeAttemptsExceeded
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(code)), []byte(active.CodeHash)) != 1 {
+		return ErrInvalidPhoneCode
+	}
+
+	err = s.codeRepository.ConsumeCode(active.ID)
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return ErrInvalidPhoneCode
+	}
+	if err != nil {
+		return err
+	}
+
+	// Код подтверждает только тот номер, на который был отправлен
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Phone != active.Phone {
+		return ErrInvalidPhoneCode
+	}
+
+	return s.userRepository.SetPhoneVerified(userID)
+}
+
+// newPhoneCode генерирует числовой код для SMS
+func newPhoneCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(phoneCodeDigits), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n), nil
+}
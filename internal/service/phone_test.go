@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+)
+
+// sentCodes - история отправки кодов для проверки лимитов
+type sentCodes struct {
+	database.PhoneVerificationRepositoryInterface
+	sent []time.Time
+}
+
+func (r sentCodes) CountCodesByPhone(phone string, since time.Time) (int, time.Time, time.Time, error) {
+	return r.count(since)
+}
+
+func (r sentCodes) CountCodesByIP(ip string, since time.Time) (int, time.Time, time.Time, error) {
+	return r.count(since)
+}
+
+func (r sentCodes) count(since time.Time) (int, time.Time, time.Time, error) {
+	var count int
+	var first, last time.Time
+	for _, at := range r.sent {
+		if at.Before(since) {
+			continue
+		}
+		if count == 0 {
+			first = at
+		}
+		last = at
+		count++
+	}
+	return count, first, last, nil
+}
+
+func TestPhoneHourlyLimitWaitsForOldestCode(t *testing.T) {
+	now := time.Now()
+	codes := sentCodes{}
+	for i := phoneCodeHourlyLimitPhone; i > 0; i-- {
+		codes.sent = append(codes.sent, now.Add(-time.Duration(i)*10*time.Minute))
+	}
+	service := &PhoneVerificationService{codeRepository: codes}
+
+	var retry *RetryAfterError
+	if err := service.checkSendLimits("+79001234567", "10.0.0.1", now); !errors.As(err, &retry) {
+		t.Fatalf("checkSendLimits error = %v, want RetryAfterError", err)
+	}
+	// Самый старый код отправлен 50 минут назад, место в окне освободится через 10 минут
+	if retry.RetryAfter != 10*time.Minute {
+		t.Fatalf("RetryAfter = %v, want 10m", retry.RetryAfter)
+	}
+}
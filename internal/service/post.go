@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+)
+
+// PostServiceInterface определяет методы для работы с постами и реакциями на них.
+// Посты автора видны зрителю по настройке приватности posts, если между ними нет блокировки.
+// Заглушенные пользователи не попадают в ленту и в списки реакций, но их страницу и посты
+// по прямой ссылке заглушивший видит.
+type PostServiceInterface interface {
+	CreatePost(userID uint, post models.NewPost) (models.Post, error)
+	GetPost(viewerID uint, postID int) (models.Post, error)
+	GetUserPosts(viewerID uint, login string, limit, offset int) ([]models.Post, error)
+	GetFeed(userID uint, limit, offset int) ([]models.Post, error)
+	React(userID uint, postID int, like bool) (models.Post, error)
+	RemoveReaction(userID uint, postID int) (models.Post, error)
+	GetReactions(viewerID uint, postID int, limit, offset int) ([]models.PostReaction, error)
+}
+
+// PostService предоставляет реализацию PostServiceInterface
+type PostService struct {
+	postRepository     database.PostRepositoryInterface
+	reactionRepository database.ReactionRepositoryInterface
+	userRepository     database.UserRepositoryInterface
+	privacyService     PrivacyServiceInterface
+	mediaService       MediaServiceInterface
+}
+
+// NewPostService создает новый экземпляр PostService
+func NewPostService(postRepository database.PostRepositoryInterface, reactionRepository database.ReactionRepositoryInterface,
+	userRepository database.UserRepositoryInterface, privacyService PrivacyServiceInterface,
+	mediaService MediaServiceInterface) *PostService {
+	return &PostService{
+		postRepository:     postRepository,
+		reactionRepository: reactionRepository,
+		userRepository:     userRepository,
+		privacyService:     privacyService,
+		mediaService:       mediaService,
+	}
+}
+
+// CreatePost проверяет и публикует пост пользователя. Подтверждение почты проверяет маршрут.
+func (s *PostService) CreatePost(userID uint, post models.NewPost) (models.Post, error) {
+	post = utils.NormalizeNewPost(post)
+	if err := utils.ValidateNewPost(post); err != nil {
+		return models.Post{}, err
+	}
+	return s.postRepository.CreatePost(userID, post)
+}
+
+// GetPost возвращает пост, если зритель может его видеть. Пост автора, с которым у зрителя есть
+// блокировка, для зрителя не существует (ErrPostNotFound), пост, скрытый настройками приватности, -
+// ErrHiddenByPrivacy.
+func (s *PostService) GetPost(viewerID uint, postID int) (models.Post, error) {
+	post, err := s.postRepository.GetPost(postID)
+	if err != nil {
+		return models.Post{}, err
+	}
+	if err := s.checkPostsVisible(viewerID, post.AuthorID); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			return models.Post{}, database.ErrPostNotFound
+		}
+		return models.Post{}, err
+	}
+	return post, nil
+}
+
+// GetUserPosts возвращает посты пользователя с указанным логином, если его настройки приватности
+// позволяют viewerID их видеть, иначе ErrHiddenByPrivacy
+func (s *PostService) GetUserPosts(viewerID uint, login string, limit, offset int) ([]models.Post, error) {
+	author, err := s.userRepository.GetUserByLogin(login)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkPostsVisible(viewerID, author.ID); err != nil {
+		return nil, err
+	}
+	return s.postRepository.GetPostsByAuthor(author.ID, limit, offset)
+}
+
+// GetFeed возвращает ленту пользователя: его посты и посты тех, кого он добавил в друзья,
+// без заблокированных и заглушенных авторов
+func (s *PostService) GetFeed(userID uint, limit, offset int) ([]models.Post, error) {
+	return s.postRepository.GetFeed(userID, limit, offset)
+}
+
+// React ставит лайк или дизлайк посту, который пользователь может видеть, и возвращает пост
+// с новыми счетчиками. Повторная реакция заменяет прежнюю.
+func (s *PostService) React(userID uint, postID int, like bool) (models.Post, error) {
+	if _, err := s.GetPost(userID, postID); err != nil {
+		return models.Post{}, err
+	}
+	reactionType := models.ReactionDislike
+	if like {
+		reactionType = models.ReactionLike
+	}
+	return s.reactionRepository.SetReaction(userID, postID, reactionType)
+}
+
+// RemoveReaction убирает реакцию пользователя на пост и возвращает пост с новыми счетчиками
+func (s *PostService) RemoveReaction(userID uint, postID int) (models.Post, error) {
+	if _, err := s.GetPost(userID, postID); err != nil {
+		return models.Post{}, err
+	}
+	return s.reactionRepository.RemoveReaction(userID, postID)
+}
+
+// GetReactions возвращает реакции на пост, который зритель может видеть. Реакции пользователей,
+// с которыми у зрителя есть блокировка или которых он заглушил, не показываются.
+func (s *PostService) GetReactions(viewerID uint, postID int, limit, offset int) ([]models.PostReaction, error) {
+	if _, err := s.GetPost(viewerID, postID); err != nil {
+		return nil, err
+	}
+	reactions, err := s.reactionRepository.GetReactions(postID, viewerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reactions {
+		reactions[i].Avatar = s.mediaService.ResolveURLs(reactions[i].Avatar)
+	}
+	return reactions, nil
+}
+
+// checkPostsVisible проверяет, может ли зритель видеть посты автора. Если один из них заблокировал
+// другого, возвращает ErrUserNotFound.
+func (s *PostService) checkPostsVisible(viewerID, authorID uint) error {
+	allowed, err := s.privacyService.CanView(viewerID, authorID, models.PrivacyPosts)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrHiddenByPrivacy
+	}
+	return nil
+}
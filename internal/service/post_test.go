@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// postStore хранит в памяти пользователей, связи между ними, настройки приватности, посты и реакции
+type postStore struct {
+	database.PostRepositoryInterface
+	database.ReactionRepositoryInterface
+	database.UserRepositoryInterface
+	database.PrivacyRepositoryInterface
+	database.FriendRepositoryInterface
+	database.BlockRepositoryInterface
+
+	users     map[string]uint
+	friends   map[[2]uint]bool
+	blocks    map[[2]uint]bool
+	settings  map[uint]models.PrivacySettings
+	posts     map[int]models.Post
+	reactions map[[2]int]int
+	viewers   []uint
+}
+
+func (s *postStore) GetUserByLogin(login string) (models.User, error) {
+	id, ok := s.users[login]
+	if !ok {
+		return models.User{}, database.ErrUserNotFound
+	}
+	return models.User{ID: id, Login: login}, nil
+}
+
+func (s *postStore) IsFriend(userID, friendID uint) (bool, error) {
+	return s.friends[[2]uint{userID, friendID}], nil
+}
+
+func (s *postStore) IsBlocked(userID, otherID uint) (bool, error) {
+	return s.blocks[[2]uint{userID, otherID}] || s.blocks[[2]uint{otherID, userID}], nil
+}
+
+func (s *postStore) GetPrivacySettings(userID uint) (models.PrivacySettings, error) {
+	if settings, ok := s.settings[userID]; ok {
+		return settings, nil
+	}
+	return models.DefaultPrivacySettings(), nil
+}
+
+func (s *postStore) GetPost(postID int) (models.Post, error) {
+	post, ok := s.posts[postID]
+	if !ok {
+		return models.Post{}, database.ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (s *postStore) GetPostsByAuthor(authorID uint, limit, offset int) ([]models.Post, error) {
+	posts := []models.Post{}
+	for _, post := range s.posts {
+		if post.AuthorID == authorID {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (s *postStore) SetReaction(userID uint, postID, reactionType int) (models.Post, error) {
+	s.reactions[[2]int{int(userID), postID}] = reactionType
+	return s.posts[postID], nil
+}
+
+func (s *postStore) RemoveReaction(userID uint, postID int) (models.Post, error) {
+	delete(s.reactions, [2]int{int(userID), postID})
+	return s.posts[postID], nil
+}
+
+func (s *postStore) GetReactions(postID int, viewerID uint, limit, offset int) ([]models.PostReaction, error) {
+	s.viewers = append(s.viewers, viewerID)
+	return []models.PostReaction{{Login: "bob", Like: true, Avatar: &models.Image{ID: "avatar"}}}, nil
+}
+
+// signedMedia заполняет адреса вариантов изображений
+type signedMedia struct {
+	MediaServiceInterface
+}
+
+func (signedMedia) ResolveURLs(img *models.Image) *models.Image {
+	if img == nil {
+		return nil
+	}
+	return &models.Image{ID: img.ID, Variants: []models.ImageVariant{{URL: "/media/" + img.ID}}}
+}
+
+// Пользователи тестов: alice - автор, bob - ее друг, carol - посторонняя, dave заблокировал alice
+const (
+	alice uint = iota + 1
+	bob
+	carol
+	dave
+)
+
+func newTestPostService(postsVisibility string) (*PostService, *postStore) {
+	store := &postStore{
+		users:     map[string]uint{"alice": alice, "bob": bob, "carol": carol, "dave": dave},
+		friends:   map[[2]uint]bool{{alice, bob}: true},
+		blocks:    map[[2]uint]bool{{dave, alice}: true},
+		settings:  map[uint]models.PrivacySettings{},
+		posts:     map[int]models.Post{1: {Id: 1, AuthorID: alice, Author: "alice", Content: "hello"}},
+		reactions: map[[2]int]int{},
+	}
+	settings := models.DefaultPrivacySettings()
+	settings.Posts = postsVisibility
+	store.settings[alice] = settings
+
+	privacy := NewPrivacyService(store, store, store)
+	return NewPostService(store, store, store, privacy, signedMedia{}), store
+}
+
+func TestPostVisibility(t *testing.T) {
+	tests := []struct {
+		visibility string
+		viewer     uint
+		want       error
+	}{
+		{models.VisibilityEveryone, 0, nil},
+		{models.VisibilityEveryone, carol, nil},
+		{models.VisibilityEveryone, dave, database.ErrPostNotFound},
+		{models.VisibilityFriends, alice, nil},
+		{models.VisibilityFriends, bob, nil},
+		{models.VisibilityFriends, carol, ErrHiddenByPrivacy},
+		{models.VisibilityFriends, 0, ErrHiddenByPrivacy},
+		{models.VisibilityOnlyMe, alice, nil},
+		{models.VisibilityOnlyMe, bob, ErrHiddenByPrivacy},
+	}
+	for _, tt := range tests {
+		service, _ := newTestPostService(tt.visibility)
+		if _, err := service.GetPost(tt.viewer, 1); !errors.Is(err, tt.want) {
+			t.Errorf("posts=%s viewer=%d: GetPost err = %v, want %v", tt.visibility, tt.viewer, err, tt.want)
+		}
+	}
+
+	service, _ := newTestPostService(models.VisibilityEveryone)
+	if _, err := service.GetPost(carol, 2); !errors.Is(err, database.ErrPostNotFound) {
+		t.Errorf("GetPost of a missing post: err = %v, want ErrPostNotFound", err)
+	}
+}
+
+func TestGetUserPosts(t *testing.T) {
+	service, _ := newTestPostService(models.VisibilityFriends)
+
+	posts, err := service.GetUserPosts(bob, "alice", 20, 0)
+	if err != nil || len(posts) != 1 {
+		t.Fatalf("friend: posts = %v, err = %v", posts, err)
+	}
+	if _, err := service.GetUserPosts(carol, "alice", 20, 0); !errors.Is(err, ErrHiddenByPrivacy) {
+		t.Errorf("stranger: err = %v, want ErrHiddenByPrivacy", err)
+	}
+	// Для заблокированного автора не существует
+	if _, err := service.GetUserPosts(dave, "alice", 20, 0); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("blocked: err = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestReactRequiresVisiblePost(t *testing.T) {
+	service, store := newTestPostService(models.VisibilityFriends)
+
+	if _, err := service.React(carol, 1, true); !errors.Is(err, ErrHiddenByPrivacy) {
+		t.Fatalf("stranger: err = %v, want ErrHiddenByPrivacy", err)
+	}
+	if _, err := service.React(dave, 1, true); !errors.Is(err, database.ErrPostNotFound) {
+		t.Fatalf("blocked: err = %v, want ErrPostNotFound", err)
+	}
+	if _, err := service.RemoveReaction(dave, 1); !errors.Is(err, database.ErrPostNotFound) {
+		t.Fatalf("blocked RemoveReaction: err = %v, want ErrPostNotFound", err)
+	}
+	if len(store.reactions) != 0 {
+		t.Fatalf("reactions = %v, want none", store.reactions)
+	}
+
+	if _, err := service.React(bob, 1, false); err != nil {
+		t.Fatalf("React: %v", err)
+	}
+	if got := store.reactions[[2]int{int(bob), 1}]; got != models.ReactionDislike {
+		t.Fatalf("reaction = %d, want dislike", got)
+	}
+	if _, err := service.React(bob, 1, true); err != nil {
+		t.Fatalf("React: %v", err)
+	}
+	if got := store.reactions[[2]int{int(bob), 1}]; got != models.ReactionLike {
+		t.Fatalf("reaction = %d, want like", got)
+	}
+	if _, err := service.RemoveReaction(bob, 1); err != nil {
+		t.Fatalf("RemoveReaction: %v", err)
+	}
+	if len(store.reactions) != 0 {
+		t.Fatalf("reactions = %v, want none", store.reactions)
+	}
+}
+
+func TestGetReactions(t *testing.T) {
+	service, store := newTestPostService(models.VisibilityEveryone)
+
+	if _, err := service.GetReactions(dave, 1, 50, 0); !errors.Is(err, database.ErrPostNotFound) {
+		t.Fatalf("blocked: err = %v, want ErrPostNotFound", err)
+	}
+
+	reactions, err := service.GetReactions(carol, 1, 50, 0)
+	if err != nil {
+		t.Fatalf("GetReactions: %v", err)
+	}
+	// Заглушенных и заблокированных отфильтровывает репозиторий по зрителю
+	if len(store.viewers) != 1 || store.viewers[0] != carol {
+		t.Fatalf("repository viewers = %v, want [%d]", store.viewers, carol)
+	}
+	if len(reactions) != 1 || reactions[0].Avatar == nil || reactions[0].Avatar.Variants[0].URL != "/media/avatar" {
+		t.Fatalf("reactions = %+v, want a resolved avatar", reactions)
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+)
+
+// ErrHiddenByPrivacy возвращается, если настройки приватности владельца не позволяют зрителю видеть данные
+var ErrHiddenByPrivacy = errors.New("hidden by the user's privacy settings")
+
+// PrivacyServiceInterface определяет методы для работы с настройками приватности.
+// Все сервисы, которые выдают данные одного пользователя другому, проверяют доступ через него:
+// профиль - через Relation и FilterProfile, список друзей и посты - через CanView.
+// Пользователи, один из которых заблокировал другого, не видят друг друга вовсе.
+type PrivacyServiceInterface interface {
+	GetSettings(userID uint) (models.PrivacySettings, error)
+	UpdateSettings(userID uint, update models.PrivacyUpdate) (models.PrivacySettings, error)
+	Relation(viewerID, ownerID uint) (string, error)
+	CanView(viewerID, ownerID uint, field string) (bool, error)
+	FilterProfile(profile models.Profile, viewer string) (models.Profile, error)
+}
+
+// PrivacyService предоставляет реализацию PrivacyServiceInterface
+type PrivacyService struct {
+	privacyRepository database.PrivacyRepositoryInterface
+	friendRepository  database.FriendRepositoryInterface
+	blockRepository   database.BlockRepositoryInterface
+}
+
+// NewPrivacyService создает новый экземпляр PrivacyService
+func NewPrivacyService(privacyRepository database.PrivacyRepositoryInterface,
+	friendRepository database.FriendRepositoryInterface, blockRepository database.BlockRepositoryInterface) *PrivacyService {
+	return &PrivacyService{
+		privacyRepository: privacyRepository,
+		friendRepository:  friendRepository,
+		blockRepository:   blockRepository,
+	}
+}
+
+// GetSettings возвращает настройки приватности пользователя
+func (s *PrivacyService) GetSettings(userID uint) (models.PrivacySettings, error) {
+	return s.privacyRepository.GetPrivacySettings(userID)
+}
+
+// UpdateSettings изменяет переданные настройки, остальные остаются прежними
+func (s *PrivacyService) UpdateSettings(userID uint, update models.PrivacyUpdate) (models.PrivacySettings, error) {
+	if err := utils.ValidatePrivacyUpdate(update); err != nil {
+		return models.PrivacySettings{}, err
+	}
+	return s.privacyRepository.UpdatePrivacySettings(userID, update)
+}
+
+// Relation определяет, кем зритель приходится владельцу: им самим, заблокированным (в любую сторону),
+// другом (владелец добавил его в друзья) или посторонним. viewerID = 0 - неизвестный зритель.
+func (s *PrivacyService) Relation(viewerID, ownerID uint) (string, error) {
+	if viewerID == 0 {
+		return models.ViewerStranger, nil
+	}
+	if viewerID == ownerID {
+		return models.ViewerSelf, nil
+	}
+	blocked, err := s.blockRepository.IsBlocked(viewerID, ownerID)
+	if err != nil {
+		return "", err
+	}
+	if blocked {
+		return models.ViewerBlocked, nil
+	}
+	isFriend, err := s.friendRepository.IsFriend(ownerID, viewerID)
+	if err != nil {
+		return "", err
+	}
+	if isFriend {
+		return models.ViewerFriend, nil
+	}
+	return models.ViewerStranger, nil
+}
+
+// CanView сообщает, может ли зритель видеть поле field владельца. Если один из них заблокировал другого,
+// возвращает ErrUserNotFound: для зрителя владельца не существует.
+func (s *PrivacyService) CanView(viewerID, ownerID uint, field string) (bool, error) {
+	viewer, err := s.Relation(viewerID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	switch viewer {
+	case models.ViewerSelf:
+		return true, nil
+	case models.ViewerBlocked:
+		return false, database.ErrUserNotFound
+	}
+	settings, err := s.privacyRepository.GetPrivacySettings(ownerID)
+	if err != nil {
+		return false, err
+	}
+	return models.Allows(settings.Visibility(field), viewer), nil
+}
+
+// FilterProfile убирает из профиля поля, которые зритель не должен видеть, и перечисляет их в Hidden
+// вместе со скрытыми списком друзей и постами
+func (s *PrivacyService) FilterProfile(profile models.Profile, viewer string) (models.Profile, error) {
+	profile.Hidden = nil
+	if viewer == models.ViewerSelf {
+		return profile, nil
+	}
+
+	settings, err := s.privacyRepository.GetPrivacySettings(profile.UserID)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{models.PrivacyEmail, &profile.Email},
+		{models.PrivacyPhone, &profile.Phone},
+		{models.PrivacyBirthday, &profile.Birthday},
+		{models.PrivacyFriends, nil},
+		{models.PrivacyPosts, nil},
+	} {
+		if models.Allows(settings.Visibility(field.name), viewer) {
+			continue
+		}
+		if field.value != nil {
+			*field.value = ""
+		}
+		profile.Hidden = append(profile.Hidden, field.name)
+	}
+	return profile, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/internal/utils"
+)
+
+// ProfileServiceInterface определяет методы для просмотра и изменения профилей
+type ProfileServiceInterface interface {
+	GetProfile(userID uint) (models.Profile, error)
+	GetProfileByLogin(viewerID uint, login string) (models.Profile, error)
+	PreviewProfile(userID uint, viewer string) (models.Profile, error)
+	UpdateProfile(userID uint, update models.ProfileUpdate) (models.Profile, error)
+}
+
+// ProfileService предоставляет реализацию ProfileServiceInterface
+type ProfileService struct {
+	profileRepository database.ProfileRepositoryInterface
+	privacyService    PrivacyServiceInterface
+	mediaService      MediaServiceInterface
+}
+
+// NewProfileService создает новый экземпляр ProfileService
+func NewProfileService(profileRepository database.ProfileRepositoryInterface, privacyService PrivacyServiceInterface,
+	mediaService MediaServiceInterface) *ProfileService {
+	return &ProfileService{
+		profileRepository: profileRepository,
+		privacyService:    privacyService,
+		mediaService:      mediaService,
+	}
+}
+
+// GetProfile возвращает профиль пользователя для него самого, со всеми полями
+func (s *ProfileService) GetProfile(userID uint) (models.Profile, error) {
+	return s.withImageURLs(s.profileRepository.GetProfileByUserID(userID))
+}
+
+// GetProfileByLogin возвращает профиль пользователя с указанным логином таким, каким его видит viewerID.
+// Если один из них заблокировал другого, возвращает ErrUserNotFound.
+func (s *ProfileService) GetProfileByLogin(viewerID uint, login string) (models.Profile, error) {
+	profile, err := s.profileRepository.GetProfileByLogin(login)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	viewer, err := s.privacyService.Relation(viewerID, profile.UserID)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	if viewer == models.ViewerBlocked {
+		return models.Profile{}, database.ErrUserNotFound
+	}
+	return s.withImageURLs(s.privacyService.FilterProfile(profile, viewer))
+}
+
+// PreviewProfile показывает пользователю его профиль таким, каким его видит друг или посторонний
+func (s *ProfileService) PreviewProfile(userID uint, viewer string) (models.Profile, error) {
+	if viewer != models.ViewerFriend && viewer != models.ViewerStranger {
+		return models.Profile{}, utils.NewFieldError("as", "invalid_viewer", "as must be friend or stranger", nil)
+	}
+	profile, err := s.profileRepository.GetProfileByUserID(userID)
+	if err != nil {
+		return models.Profile{}, err
+	}
+	return s.withImageURLs(s.privacyService.FilterProfile(profile, viewer))
+}
+
+// UpdateProfile изменяет переданные поля профиля, остальные поля остаются прежними
+func (s *ProfileService) UpdateProfile(userID uint, update models.ProfileUpdate) (models.Profile, error) {
+	update = utils.NormalizeProfileUpdate(update)
+	if err := utils.ValidateProfileUpdate(update); err != nil {
+		return models.Profile{}, err
+	}
+	return s.withImageURLs(s.profileRepository.UpdateProfile(userID, update))
+}
+
+// withImageURLs заполняет адреса файлов аватара и обложки
+func (s *ProfileService) withImageURLs(profile models.Profile, err error) (models.Profile, error) {
+	if err != nil {
+		return models.Profile{}, err
+	}
+	profile.Avatar = s.mediaService.ResolveURLs(profile.Avatar)
+	profile.Cover = s.mediaService.ResolveURLs(profile.Cover)
+	return profile, nil
+}
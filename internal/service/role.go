@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	tokenmanager "github.com/Saveliy12/prod2/pkg/tokenmanager"
+)
+
+var (
+	// ErrUnknownRole возвращается при назначении несуществующей роли
+	ErrUnknownRole = errors.New("unknown role")
+	// ErrUnknownPermission возвращается при выдаче несуществующего права
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrUserNotFound возвращается, если пользователь не найден
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// RoleServiceInterface определяет методы для управления ролями и правами пользователей
+type RoleServiceInterface interface {
+	GetSubject(userID uint) (tokenmanager.Subject, error)
+	SetRole(userID uint, role string) error
+	GrantPermission(userID uint, permission string) error
+	RevokePermission(userID uint, permission string) error
+}
+
+// RoleService предоставляет реализацию RoleServiceInterface.
+// Роль и права записываются в access-токен при выпуске, поэтому после их изменения
+// ранее выпущенные токены пользователя отзываются: клиент обновит токен и получит новые права.
+type RoleService struct {
+	roleRepository  database.RoleRepositoryInterface
+	userRepository  database.UserRepositoryInterface
+	revocationStore tokenmanager.RevocationStore
+}
+
+// NewRoleService создает новый экземпляр RoleService
+func NewRoleService(roleRepository database.RoleRepositoryInterface, userRepository database.UserRepositoryInterface,
+	revocationStore tokenmanager.RevocationStore) *RoleService {
+	return &RoleService{
+		roleRepository:  roleRepository,
+		userRepository:  userRepository,
+		revocationStore: revocationStore,
+	}
+}
+
+// GetSubject возвращает роль пользователя и все его права: права роли и выданные отдельно
+func (s *RoleService) GetSubject(userID uint) (tokenmanager.Subject, error) {
+	user, err := s.userRepository.GetUserByID(userID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return tokenmanager.Subject{}, ErrUserNotFound
+	}
+	if err != nil {
+		return tokenmanager.Subject{}, err
+	}
+
+	granted, err := s.roleRepository.GetPermissions(userID)
+	if err != nil {
+		return tokenmanager.Subject{}, err
+	}
+
+	set := make(map[string]bool)
+	for _, p := range models.RolePermissions[user.Role] {
+		set[p] = true
+	}
+	for _, p := range granted {
+		set[p] = true
+	}
+
+	permissions := make([]string, 0, len(set))
+	for p := range set {
+		permissions = append(permissions, p)
+	}
+	sort.Strings(permissions)
+
+	return tokenmanager.Subject{UserID: userID, Role: user.Role, Permissions: permissions}, nil
+}
+
+// SetRole назначает пользователю роль
+func (s *RoleService) SetRole(userID uint, role string) error {
+	if _, ok := models.RolePermissions[role]; !ok {
+		return ErrUnknownRole
+	}
+
+	err := s.roleRepository.SetRole(userID, role)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.revocationStore.RevokeUserTokens(userID, time.Now())
+}
+
+// GrantPermission выдает пользователю право сверх прав его роли
+func (s *RoleService) GrantPermission(userID uint, permission string) error {
+	if err := s.checkPermission(userID, permission); err != nil {
+		return err
+	}
+
+	if err := s.roleRepository.GrantPermission(userID, permission); err != nil {
+		return err
+	}
+
+	return s.revocationStore.RevokeUserTokens(userID, time.Now())
+}
+
+// RevokePermission отзывает право, выданное пользователю отдельно. Права роли так отозвать нельзя.
+func (s *RoleService) RevokePermission(userID uint, permission string) error {
+	if err := s.checkPermission(userID, permission); err != nil {
+		return err
+	}
+
+	if err := s.roleRepository.RevokePermission(userID, permission); err != nil {
+		return err
+	}
+
+	return s.revocationStore.RevokeUserTokens(userID, time.Now())
+}
+
+// checkPermission проверяет, что право известно, а пользователь существует
+func (s *RoleService) checkPermission(userID uint, permission string) error {
+	known := false
+	for _, p := range models.Permissions {
+		if p == permission {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return ErrUnknownPermission
+	}
+
+	_, err := s.userRepository.GetUserByID(userID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
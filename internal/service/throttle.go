@@ -0,0 +1,147 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+)
+
+// LoginThrottleConfig задает ограничения на неудачные попытки входа
+type LoginThrottleConfig struct {
+	// FreeAttempts - число неудач подряд без задержки
+	FreeAttempts int
+	// BaseDelay - задержка после первой неудачи сверх FreeAttempts, дальше удваивается
+	BaseDelay time.Duration
+	// MaxDelay - верхняя граница задержки
+	MaxDelay time.Duration
+	// LockThreshold - число неудач по логину, после которого аккаунт блокируется
+	LockThreshold int
+	// IPLockThreshold - число неудач с одного адреса, после которого адрес блокируется
+	IPLockThreshold int
+	// LockDuration - время блокировки
+	LockDuration time.Duration
+	// Window - неудачи старше этого срока не учитываются
+	Window time.Duration
+}
+
+// DefaultLoginThrottleConfig - ограничения по умолчанию
+var DefaultLoginThrottleConfig = LoginThrottleConfig{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute * 5,
+	LockThreshold:   10,
+	IPLockThreshold: 100,
+	LockDuration:    time.Minute * 30,
+	Window:          time.Hour,
+}
+
+// LoginThrottler ограничивает подбор пароля: задержка растет экспоненциально с каждой неудачей,
+// а после порога аккаунт или адрес блокируется. Счетчики ведутся отдельно по аккаунту и по IP,
+// чтобы ограничивать и перебор паролей к одному аккаунту, и перебор аккаунтов с одного адреса.
+// Аккаунт задается ключом из UserAccountKey или IdentifierAccountKey.
+type LoginThrottler struct {
+	attempts database.LoginAttemptRepositoryInterface
+	config   LoginThrottleConfig
+}
+
+// NewLoginThrottler создает новый экземпляр LoginThrottler
+func NewLoginThrottler(attempts database.LoginAttemptRepositoryInterface, config LoginThrottleConfig) *LoginThrottler {
+	return &LoginThrottler{attempts: attempts, config: config}
+}
+
+// Check проверяет, можно ли сейчас попытаться войти.
+// Возвращает *AccountLockedError при блокировке и *RetryAfterError, если нужно подождать.
+func (t *LoginThrottler) Check(account, ip string) error {
+	now := time.Now()
+	for i, key := range t.keys(account, ip) {
+		attempts, err := t.attempts.GetAttempts(key)
+		if err != nil {
+			return err
+		}
+
+		if now.Before(attempts.LockedUntil) {
+			// Заблокированный адрес - это ограничение частоты, а не блокировка аккаунта
+			if i > 0 {
+				return &RetryAfterError{RetryAfter: attempts.LockedUntil.Sub(now)}
+			}
+			return &AccountLockedError{RetryAfter: attempts.LockedUntil.Sub(now)}
+		}
+
+		if attempts.LastFailureAt.Before(now.Add(-t.config.Window)) {
+			continue
+		}
+
+		if wait := attempts.LastFailureAt.Add(t.delay(attempts.Failures)).Sub(now); wait > 0 {
+			return &RetryAfterError{RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure учитывает неудачную попытку и блокирует вход при достижении порога
+func (t *LoginThrottler) RecordFailure(account, ip string) error {
+	now := time.Now()
+	windowStart := now.Add(-t.config.Window)
+
+	thresholds := []int{t.config.LockThreshold, t.config.IPLockThreshold}
+	for i, key := range t.keys(account, ip) {
+		attempts, err := t.attempts.RecordFailure(key, now, windowStart)
+		if err != nil {
+			return err
+		}
+
+		if thresholds[i] > 0 && attempts.Failures >= thresholds[i] {
+			if err := t.attempts.Lock(key, now.Add(t.config.LockDuration)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess сбрасывает счетчик аккаунта. Счетчик адреса не сбрасывается,
+// иначе вход в собственный аккаунт позволял бы продолжать перебор чужих.
+func (t *LoginThrottler) RecordSuccess(account string) error {
+	return t.attempts.Reset(account)
+}
+
+// Unlock снимает блокировку аккаунта. Вызывается администратором.
+func (t *LoginThrottler) Unlock(account string) error {
+	return t.attempts.Reset(account)
+}
+
+// delay возвращает задержку после failures неудач подряд
+func (t *LoginThrottler) delay(failures int) time.Duration {
+	over := failures - t.config.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := 1; i < over && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.config.MaxDelay {
+		delay = t.config.MaxDelay
+	}
+	return delay
+}
+
+func (t *LoginThrottler) keys(account, ip string) []string {
+	return []string{account, "ip:" + ip}
+}
+
+// UserAccountKey возвращает ключ счетчика существующего пользователя. Счетчик ведется по id,
+// чтобы вход по логину, email и номеру телефона в любой записи расходовал одни и те же попытки.
+func UserAccountKey(userID uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// IdentifierAccountKey возвращает ключ счетчика для идентификатора, которому не соответствует ни один пользователь
+func IdentifierAccountKey(identifier string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(identifier))
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Saveliy12/prod2/internal/database"
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/Saveliy12/prod2/pkg/mailer"
+)
+
+const (
+	verificationTokenTTL = time.Hour * 24
+
+	// Ограничения на повторную отправку письма
+	verificationResendInterval = time.Minute
+	verificationDailyLimit     = 5
+)
+
+var (
+	// ErrInvalidVerificationToken возвращается для неизвестного, использованного или просроченного токена
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	// ErrEmailAlreadyVerified возвращается при запросе письма для уже подтвержденной почты
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	// ErrEmailNotVerified возвращается при попытке действия, требующего подтвержденной почты
+	ErrEmailNotVerified = errors.New("email is not verified")
+)
+
+// EmailVerificationServiceInterface определяет методы для подтверждения почты
+type EmailVerificationServiceInterface interface {
+	SendVerificationEmail(user models.User) error
+	ResendVerificationEmail(userID uint) error
+	ConfirmEmail(token string) error
+	IsEmailVerified(userID uint) (bool, error)
+}
+
+// EmailVerificationService предоставляет реализацию EmailVerificationServiceInterface
+type EmailVerificationService struct {
+	userRepository  database.UserRepositoryInterface
+	tokenRepository database.OneTimeTokenRepositoryInterface
+	mailer          mailer.Mailer
+
+	// verifyURL - адрес страницы подтверждения, токен передается в параметре token
+	verifyURL string
+}
+
+// NewEmailVerificationService создает новый экземпляр EmailVerificationService
+func NewEmailVerificationService(userRepository database.UserRepositoryInterface, tokenRepository database.OneTimeTokenRepositoryInterface,
+	mailer mailer.Mailer, verifyURL string) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepository:  userRepository,
+		tokenRepository: tokenRepository,
+		mailer:          mailer,
+		verifyURL:       verifyURL,
+	}
+}
+
+// SendVerificationEmail выпускает новый токен подтверждения и отправляет его на почту пользователя
+func (s *EmailVerificationService) SendVerificationEmail(user models.User) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.tokenRepository.CreateToken(models.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   models.TokenPurposeEmailVerification,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(verificationTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.verifyURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo confirm your email address, open the link below:\n%s\n\n"+
+			"The link is valid for %d hours. If you did not register, ignore this email.\n",
+			user.Login, link, int(verificationTokenTTL.Hours())),
+	})
+}
+
+// ResendVerificationEmail повторно отправляет письмо не чаще раза в минуту и не более 5 раз в сутки
+func (s *EmailVerificationService) ResendVerificationEmail(userID uint) error {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	now := time.Now()
+	count, first, last, err := s.tokenRepository.CountTokensSince(userID, models.TokenPurposeEmailVerification, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if count >= verificationDailyLimit {
+		// Новое письмо можно будет отправить, когда самое раннее из окна выйдет за пределы суток
+		return &RetryAfterError{RetryAfter: first.Add(24 * time.Hour).Sub(now)}
+	}
+	if wait := last.Add(verificationResendInterval).Sub(now); count > 0 && wait > 0 {
+		return &RetryAfterError{RetryAfter: wait}
+	}
+
+	// Ссылки из предыдущих писем больше не действуют
+	if err := s.tokenRepository.InvalidateTokens(userID, models.TokenPurposeEmailVerification); err != nil {
+		return err
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+// ConfirmEmail подтверждает почту по токену из письма. Токен одноразовый.
+func (s *EmailVerificationService) ConfirmEmail(token string) error {
+	verification, err := s.tokenRepository.ConsumeToken(models.TokenPurposeEmailVerification, hashToken(token))
+	if errors.Is(err, database.ErrTokenNotFound) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.userRepository.SetEmailVerified(verification.UserID)
+}
+
+// IsEmailVerified проверяет, подтвердил ли пользователь почту
+func (s *EmailVerificationService) IsEmailVerified(userID uint) (bool, error) {
+	user, err := s.userRepository.GetUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+// newSecretToken генерирует токен для отправки пользователю в письме
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken возвращает хеш токена, под которым он хранится в базе
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
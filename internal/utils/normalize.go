@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// NormalizeEmail приводит email к виду, в котором он хранится в базе данных
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizeUser нормализует email и телефон нового пользователя перед проверкой и сохранением
+func NormalizeUser(user *models.RegistrationUser) {
+	user.Login = strings.TrimSpace(user.Login)
+	user.Email = NormalizeEmail(user.Email)
+	user.Phone = NormalizePhone(user.Phone)
+}
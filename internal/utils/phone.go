@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// defaultPhoneRegion - страна (ISO 3166-1 alpha-2), по правилам которой разбираются номера без кода страны
+var defaultPhoneRegion = "RU"
+
+// SetDefaultPhoneRegion задает страну для номеров без кода страны
+func SetDefaultPhoneRegion(region string) error {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if _, ok := phonenumbers.GetSupportedRegions()[region]; !ok {
+		return fmt.Errorf("unsupported phone region: %s", region)
+	}
+	defaultPhoneRegion = region
+	return nil
+}
+
+// NormalizePhone приводит номер телефона к формату E.164 (+79001234567) по правилам его страны:
+// национальные префиксы (8 в России, 0 в Великобритании и т.п.) отбрасываются, номер без кода страны
+// считается номером defaultPhoneRegion, префикс 00 заменяется на +.
+// Номер, который не удалось разобрать, возвращается без разделителей и не пройдет проверку формата.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+
+	number, err := phonenumbers.Parse(phone, defaultPhoneRegion)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(phone)
+	}
+	return phonenumbers.Format(number, phonenumbers.E164)
+}
+
+// FormatPhone возвращает номер в формате E.164 в международном виде для показа пользователю (+7 900 123-45-67)
+func FormatPhone(phone string) string {
+	number, err := phonenumbers.Parse(phone, defaultPhoneRegion)
+	if err != nil {
+		return phone
+	}
+	return phonenumbers.Format(number, phonenumbers.INTERNATIONAL)
+}
+
+// PhoneRegion возвращает страну номера в формате E.164 или пустую строку
+func PhoneRegion(phone string) string {
+	number, err := phonenumbers.Parse(phone, defaultPhoneRegion)
+	if err != nil {
+		return ""
+	}
+	return phonenumbers.GetRegionCodeForNumber(number)
+}
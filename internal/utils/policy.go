@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Policy - требования к логину, почте и паролю при регистрации и смене пароля
+type Policy struct {
+	LoginMinLength int
+	LoginMaxLength int
+	EmailMaxLength int
+
+	PasswordMinLength int
+	PasswordMaxLength int
+	RequireLower      bool
+	RequireUpper      bool
+	RequireDigit      bool
+	RequireSpecial    bool
+	MinStrength       int // минимальная оценка PasswordStrength от 0 до 4, 0 - без проверки
+
+	// Blocklist - дополнительные запрещенные пароли в нижнем регистре, например из утечек.
+	// Список распространенных паролей проверяется всегда.
+	Blocklist map[string]struct{}
+}
+
+// DefaultPolicy возвращает требования, которые действуют, если политика не задана в настройках
+func DefaultPolicy() Policy {
+	return Policy{
+		LoginMinLength:    1,
+		LoginMaxLength:    30,
+		EmailMaxLength:    50,
+		PasswordMinLength: 8,
+		PasswordMaxLength: 100,
+		RequireLower:      true,
+		RequireUpper:      true,
+		RequireDigit:      true,
+		RequireSpecial:    true,
+		MinStrength:       2,
+	}
+}
+
+// policy - действующие требования к учетным данным
+var policy = DefaultPolicy()
+
+// SetPolicy задает требования к учетным данным
+func SetPolicy(p Policy) error {
+	switch {
+	case p.LoginMinLength < 1 || p.LoginMaxLength < p.LoginMinLength:
+		return fmt.Errorf("invalid login length bounds: %d-%d", p.LoginMinLength, p.LoginMaxLength)
+	case p.EmailMaxLength < 3:
+		return fmt.Errorf("invalid max email length: %d", p.EmailMaxLength)
+	case p.PasswordMinLength < 1 || p.PasswordMaxLength < p.PasswordMinLength:
+		return fmt.Errorf("invalid password length bounds: %d-%d", p.PasswordMinLength, p.PasswordMaxLength)
+	case p.MinStrength < 0 || p.MinStrength > 4:
+		return fmt.Errorf("min password strength must be between 0 and 4, got %d", p.MinStrength)
+	}
+	policy = p
+	return nil
+}
+
+// LoadBlocklist читает запрещенные пароли из файла, по одному в строке
+func LoadBlocklist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password blocklist: %w", err)
+	}
+	defer file.Close()
+
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			blocklist[strings.ToLower(password)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read password blocklist: %w", err)
+	}
+	return blocklist, nil
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords - распространенные пароли и их место в списке (от самого частого)
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for _, line := range strings.Split(commonPasswordsList, "\n") {
+		if password := strings.TrimSpace(line); password != "" {
+			if _, ok := ranks[password]; !ok {
+				ranks[password] = len(ranks) + 1
+			}
+		}
+	}
+	return ranks
+}()
+
+// isBlocked проверяет пароль по списку распространенных паролей и списку из настроек
+func isBlocked(password string) bool {
+	password = strings.ToLower(password)
+	if _, ok := commonPasswords[password]; ok {
+		return true
+	}
+	_, ok := policy.Blocklist[password]
+	return ok
+}
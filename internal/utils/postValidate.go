@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// Ограничения поста, длина считается в символах
+const (
+	postContentMaxLength = 2000
+	postMaxTags          = 10
+	postTagMaxLength     = 30
+)
+
+// NormalizeNewPost убирает пробелы по краям текста, приводит теги к нижнему регистру
+// и убирает повторяющиеся теги и знак # перед тегом
+func NormalizeNewPost(post models.NewPost) models.NewPost {
+	post.Content = strings.TrimSpace(post.Content)
+
+	tags := make([]string, 0, len(post.Tags))
+	seen := make(map[string]bool, len(post.Tags))
+	for _, tag := range post.Tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	post.Tags = tags
+	return post
+}
+
+// ValidateNewPost проверяет текст и теги поста и возвращает ValidationError со всеми найденными ошибками
+func ValidateNewPost(post models.NewPost) error {
+	errs := &ValidationError{}
+
+	if errs.Required("content", post.Content) {
+		checkText(errs, "content", post.Content, postContentMaxLength, true)
+	}
+
+	if len(post.Tags) > postMaxTags {
+		errs.Add("tags", "too_many", fmt.Sprintf("a post can have at most %d tags", postMaxTags),
+			map[string]interface{}{"max": postMaxTags})
+	}
+	for _, tag := range post.Tags {
+		if !validTag(tag) {
+			errs.Add("tags", "invalid_format",
+				fmt.Sprintf("tags must be 1 to %d letters, digits or underscores", postTagMaxLength),
+				map[string]interface{}{"max": postTagMaxLength})
+			break
+		}
+	}
+
+	return errs.Err()
+}
+
+func validTag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > postTagMaxLength {
+		return false
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,36 @@
+package utils
+
+import "github.com/Saveliy12/prod2/internal/models"
+
+// Допустимые значения видимости для сообщения об ошибке
+const visibilityValues = models.VisibilityEveryone + ", " + models.VisibilityFriends + ", " + models.VisibilityOnlyMe
+
+// ValidatePrivacyUpdate проверяет переданные настройки приватности и возвращает ValidationError
+// со всеми найденными ошибками
+func ValidatePrivacyUpdate(update models.PrivacyUpdate) error {
+	errs := &ValidationError{}
+
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{models.PrivacyEmail, update.Email},
+		{models.PrivacyPhone, update.Phone},
+		{models.PrivacyBirthday, update.Birthday},
+		{models.PrivacyFriends, update.Friends},
+		{models.PrivacyPosts, update.Posts},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		switch *field.value {
+		case models.VisibilityEveryone, models.VisibilityFriends, models.VisibilityOnlyMe:
+		default:
+			errs.Add(field.name, "invalid_visibility", field.name+" visibility must be one of: "+visibilityValues,
+				map[string]interface{}{"allowed": visibilityValues})
+		}
+	}
+
+	return errs.Err()
+}
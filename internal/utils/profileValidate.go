@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Saveliy12/prod2/internal/models"
+)
+
+// Ограничения полей профиля, длина считается в символах
+const (
+	displayNameMaxLength = 50
+	bioMaxLength         = 500
+	locationMaxLength    = 100
+	websiteMaxLength     = 200
+)
+
+// Самая ранняя дата рождения, которую можно указать в профиле
+var minBirthday = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NormalizeProfileUpdate убирает пробелы по краям переданных полей профиля
+func NormalizeProfileUpdate(update models.ProfileUpdate) models.ProfileUpdate {
+	for _, field := range []*string{update.DisplayName, update.Bio, update.Location, update.Website, update.Birthday} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+	return update
+}
+
+// ValidateProfileUpdate проверяет переданные поля профиля и возвращает ValidationError со всеми найденными ошибками.
+// Пустые значения допустимы, они очищают поле.
+func ValidateProfileUpdate(update models.ProfileUpdate) error {
+	errs := &ValidationError{}
+
+	if update.DisplayName != nil {
+		checkText(errs, "displayName", *update.DisplayName, displayNameMaxLength, false)
+	}
+	if update.Bio != nil {
+		checkText(errs, "bio", *update.Bio, bioMaxLength, true)
+	}
+	if update.Location != nil {
+		checkText(errs, "location", *update.Location, locationMaxLength, false)
+	}
+	if update.Website != nil && *update.Website != "" {
+		checkWebsite(errs, *update.Website)
+	}
+	if update.Birthday != nil && *update.Birthday != "" {
+		checkBirthday(errs, *update.Birthday)
+	}
+
+	return errs.Err()
+}
+
+// checkText проверяет длину текстового поля и отсутствие управляющих символов.
+// Переводы строк разрешены только в многострочных полях.
+func checkText(errs *ValidationError, field, value string, maxLength int, multiline bool) {
+	if utf8.RuneCountInString(value) > maxLength {
+		errs.Add(field, "too_long", fmt.Sprintf("max %s length is %d characters", field, maxLength),
+			map[string]interface{}{"max": maxLength})
+	}
+
+	for _, r := range value {
+		if unicode.IsControl(r) && !(multiline && (r == '\n' || r == '\r' || r == '\t')) {
+			errs.Add(field, "invalid_chars", field+" must not contain control characters", nil)
+			return
+		}
+	}
+}
+
+func checkWebsite(errs *ValidationError, website string) {
+	if len(website) > websiteMaxLength {
+		errs.Add("website", "too_long", fmt.Sprintf("max website length is %d characters", websiteMaxLength),
+			map[string]interface{}{"max": websiteMaxLength})
+		return
+	}
+
+	// Принимаются только абсолютные ссылки http и https, чтобы в профиль нельзя было поместить javascript:
+	u, err := url.Parse(website)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(website, " \t\n") {
+		errs.Add("website", "invalid_format", "website must be an absolute http or https URL", nil)
+	}
+}
+
+func checkBirthday(errs *ValidationError, birthday string) {
+	date, err := time.Parse("2006-01-02", birthday)
+	if err != nil {
+		errs.Add("birthday", "invalid_format", "birthday must be a date in YYYY-MM-DD format", nil)
+		return
+	}
+
+	if date.Before(minBirthday) || date.After(time.Now()) {
+		errs.Add("birthday", "out_of_range", "birthday must be between 1900-01-01 and today", nil)
+	}
+}
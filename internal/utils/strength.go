@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Оценка стойкости пароля по образцу zxcvbn: пароль разбирается на известные шаблоны
+// (распространенные пароли, данные пользователя, повторы, последовательности, ряды клавиатуры),
+// для каждого оценивается число попыток подбора, и выбирается разбиение с наименьшим итогом.
+// Символы вне шаблонов подбираются перебором.
+
+const (
+	// Число попыток на символ, не попавший ни в один шаблон
+	bruteforceCardinality = 10
+	// Минимальная оценка шаблона, чтобы длинные пароли не складывались из дешевых кусков
+	minMatchGuesses = 50
+	// Длина самой длинной подстроки, которую имеет смысл искать в словаре
+	maxDictionaryWord = 32
+	// Оценивается только начало пароля: дальше стойкость уже не растет заметно, а время оценки растет
+	maxStrengthRunes = 64
+)
+
+// Пороги числа попыток для оценок 1-4, как в zxcvbn
+var strengthThresholds = []float64{1e3 + 5, 1e6 + 5, 1e8 + 5, 1e10 + 5}
+
+// Ряды клавиатуры для поиска наборов вроде qwerty и йцукен
+var keyboardRows = []string{
+	"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+	"ё1234567890-=", "йцукенгшщзхъ", "фывапролджэ", "ячсмитьбю.",
+}
+
+// Замены символов, которыми часто маскируют слова (p@ssw0rd)
+var leetReplacer = strings.NewReplacer(
+	"@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t",
+)
+
+type strengthMatch struct {
+	start, end int // границы шаблона в рунах, end не включается
+	guesses    float64
+}
+
+// PasswordStrength возвращает оценку стойкости пароля от 0 (угадывается сразу) до 4 (очень стойкий).
+// userInputs - логин, почта и другие данные пользователя, которые легко угадать.
+// Символы после первых maxStrengthRunes не учитываются.
+func PasswordStrength(password string, userInputs ...string) int {
+	runes := []rune(password)
+	if len(runes) > maxStrengthRunes {
+		runes = runes[:maxStrengthRunes]
+	}
+	guesses := estimateGuesses(runes, userDictionary(userInputs), make(map[string]float64))
+
+	score := 0
+	for _, threshold := range strengthThresholds {
+		if guesses >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// userDictionary составляет словарь из данных пользователя, каждое слово угадывается с первой попытки
+func userDictionary(userInputs []string) map[string]int {
+	dictionary := make(map[string]int)
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		if at := strings.Index(input, "@"); at > 0 {
+			dictionary[input[:at]] = 1
+		}
+		if input != "" {
+			dictionary[input] = 1
+		}
+	}
+	return dictionary
+}
+
+// estimateGuesses находит разбиение пароля на шаблоны и перебор с наименьшим числом попыток.
+// blocks запоминает оценки повторяющихся групп, чтобы каждая группа оценивалась один раз.
+func estimateGuesses(password []rune, userWords map[string]int, blocks map[string]float64) float64 {
+	n := len(password)
+	if n == 0 {
+		return 1
+	}
+
+	var matches []strengthMatch
+	matches = append(matches, dictionaryMatches(password, userWords)...)
+	matches = append(matches, repeatMatches(password, userWords, blocks)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, keyboardMatches(password)...)
+
+	byEnd := make([][]strengthMatch, n+1)
+	for _, m := range matches {
+		byEnd[m.end] = append(byEnd[m.end], m)
+	}
+
+	// best[k] - наименьшее число попыток для первых k символов
+	best := make([]float64, n+1)
+	best[0] = 1
+	for k := 1; k <= n; k++ {
+		best[k] = best[k-1] * bruteforceCardinality
+		for _, m := range byEnd[k] {
+			if guesses := best[m.start] * math.Max(m.guesses, minMatchGuesses); guesses < best[k] {
+				best[k] = guesses
+			}
+		}
+	}
+	return best[n]
+}
+
+// dictionaryMatches ищет распространенные пароли и данные пользователя, в том числе с заменами символов
+func dictionaryMatches(password []rune, userWords map[string]int) []strengthMatch {
+	var matches []strengthMatch
+	for i := range password {
+		for j := i + 3; j <= len(password) && j-i <= maxDictionaryWord; j++ {
+			original := string(password[i:j])
+			word := strings.ToLower(original)
+			unleeted := leetReplacer.Replace(word)
+
+			rank, ok := dictionaryRank(word, userWords)
+			variations := caseVariations(password[i:j])
+			if !ok && unleeted != word {
+				rank, ok = dictionaryRank(unleeted, userWords)
+				variations *= 2
+			}
+			if ok {
+				matches = append(matches, strengthMatch{start: i, end: j, guesses: float64(rank) * variations})
+			}
+		}
+	}
+	return matches
+}
+
+func dictionaryRank(word string, userWords map[string]int) (int, bool) {
+	if rank, ok := userWords[word]; ok {
+		return rank, true
+	}
+	rank, ok := commonPasswords[word]
+	return rank, ok
+}
+
+// caseVariations оценивает, во сколько раз заглавные буквы усложняют подбор слова:
+// заглавная первая или все буквы почти ничего не добавляют
+func caseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0, upper == 1 && unicode.IsUpper(word[0]):
+		return 2
+	}
+	return math.Pow(2, math.Min(float64(upper), float64(lower)))
+}
+
+// repeatMatches ищет повторы символа или группы символов (aaaa, abcabc).
+// Группа оценивается как отдельный пароль, оценка запоминается в blocks: без этого в пароле
+// из одних повторов одни и те же группы оценивались бы заново для каждого начала и размера.
+func repeatMatches(password []rune, userWords map[string]int, blocks map[string]float64) []strengthMatch {
+	var matches []strengthMatch
+	n := len(password)
+	for i := 0; i < n; i++ {
+		for size := 1; i+2*size <= n; size++ {
+			count := 1
+			for i+(count+1)*size <= n && string(password[i+count*size:i+(count+1)*size]) == string(password[i:i+size]) {
+				count++
+			}
+			if count < 2 || size == 1 && count < 3 {
+				continue
+			}
+			base := string(password[i : i+size])
+			block, ok := blocks[base]
+			if !ok {
+				block = estimateGuesses(password[i:i+size], userWords, blocks)
+				blocks[base] = block
+			}
+			matches = append(matches, strengthMatch{start: i, end: i + count*size, guesses: block * float64(count)})
+		}
+	}
+	return matches
+}
+
+// sequenceMatches ищет последовательности с постоянным шагом (abcd, 13579, 9876)
+func sequenceMatches(password []rune) []strengthMatch {
+	var matches []strengthMatch
+	n := len(password)
+	for i := 0; i+2 < n; {
+		delta := password[i+1] - password[i]
+		if delta == 0 || delta > 5 || delta < -5 {
+			i++
+			continue
+		}
+
+		j := i + 2
+		for j < n && password[j]-password[j-1] == delta {
+			j++
+		}
+		if j-i < 3 {
+			i++
+			continue
+		}
+
+		var base float64
+		switch first := unicode.ToLower(password[i]); {
+		case strings.ContainsRune("az019", first):
+			base = 4
+		case unicode.IsDigit(first):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, strengthMatch{start: i, end: j, guesses: base * float64(j-i)})
+		i = j - 1
+	}
+	return matches
+}
+
+// keyboardMatches ищет наборы соседних клавиш одного ряда (qwerty, 0987, фыва)
+func keyboardMatches(password []rune) []strengthMatch {
+	var matches []strengthMatch
+	lower := []rune(strings.ToLower(string(password)))
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		position := make(map[rune]int, len(keys))
+		for idx, key := range keys {
+			position[key] = idx
+		}
+
+		for i := 0; i+2 < len(lower); {
+			step, ok := keyStep(position, lower[i], lower[i+1])
+			if !ok {
+				i++
+				continue
+			}
+
+			j := i + 2
+			for j < len(lower) {
+				if next, ok := keyStep(position, lower[j-1], lower[j]); !ok || next != step {
+					break
+				}
+				j++
+			}
+			if j-i >= 3 {
+				// Начальная клавиша, направление и длина набора
+				matches = append(matches, strengthMatch{start: i, end: j, guesses: float64(len(keys)*2*(j-i)) * caseVariations(password[i:j])})
+			}
+			i = j - 1
+		}
+	}
+	return matches
+}
+
+// keyStep возвращает направление перехода между соседними клавишами ряда
+func keyStep(position map[rune]int, from, to rune) (int, bool) {
+	a, okA := position[from]
+	b, okB := position[to]
+	if !okA || !okB || (b-a != 1 && b-a != -1) {
+		return 0, false
+	}
+	return b - a, true
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"password", 0},
+		{"P@ssw0rd", 0},
+		{"qwerty123", 0},
+		{"hunter2", 0},
+		{"abcabcabc", 0},
+		{"alice2024", 1},
+		{"Tr0ub4dour&3", 4},
+		{"kT9#vQ2!mL8@", 4},
+		{"correct horse battery staple", 4},
+	}
+	for _, tt := range tests {
+		if got := PasswordStrength(tt.password, "alice", "alice@example.com"); got != tt.want {
+			t.Errorf("PasswordStrength(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordStrengthLongRepeats(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{strings.Repeat("a", 100), 0},
+		{strings.Repeat("ab", 50), 1},
+		{strings.Repeat("abc", 34), 1},
+		{strings.Repeat("abcdefgh", 13), 0},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		got := PasswordStrength(tt.password)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("PasswordStrength(%q) took %v", tt.password, elapsed)
+		}
+		if got != tt.want {
+			t.Errorf("PasswordStrength(%q) = %d, want %d", tt.password, got, tt.want)
+		}
+	}
+}
@@ -1,109 +1,187 @@
-package utils
-
-import (
-	"fmt"
-	"regexp"
-	"strings"
-
-	"github.com/Saveliy12/prod2/internal/models"
-)
-
-func ValidateUser(user models.RegistrationUser) error {
-
-	if err := validateLogin(user.Login); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	if err := validateEmail(user.Email); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	if err := validatePassword(user.Password); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	if err := validatePhoneNumber(user.Phone); err != nil {
-		return fmt.Errorf("%w", err)
-	}
-
-	return nil
-}
-
-func validateLogin(login string) error {
-	if len(login) > 30 {
-		return fmt.Errorf("max login length is 30 characters")
-	}
-
-	// Проверка на соответствие шаблону [a-zA-Z0-9-]+
-	pattern := regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
-	if !pattern.MatchString(login) {
-		return fmt.Errorf("the login can contain only Latin letters and numbers")
-	}
-	return nil
-}
-
-func validateEmail(email string) error {
-	// Проверка минимальной длины
-	if len(email) < 1 {
-		return fmt.Errorf("min email length is 1 characters")
-	}
-
-	// Проверка максимальной длины
-	if len(email) > 50 {
-		return fmt.Errorf("max email length is 50 characters")
-	}
-
-	// Проверка на наличие символа @
-	if !strings.Contains(email, "@") {
-		return fmt.Errorf("the email must contain @")
-	}
-
-	return nil
-}
-
-func validatePassword(password string) error {
-	// Проверка минимальной длины
-	if len(password) < 6 {
-		return fmt.Errorf("min password length is 6 characters")
-	}
-
-	// Проверка максимальной длины
-	if len(password) > 100 {
-		return fmt.Errorf("max password length is 100 characters")
-	}
-
-	// Проверка на наличие латинских символов в верхнем и нижнем регистрах
-	if matched, _ := regexp.MatchString(`[a-z]+`, password); !matched {
-		return fmt.Errorf("password must contain at least one lowercase Latin character")
-	}
-	if matched, _ := regexp.MatchString(`[A-Z]+`, password); !matched {
-		return fmt.Errorf("password must contain at least one uppercase Latin character")
-	}
-
-	// Проверка на наличие хотя бы одной цифры
-	if matched, _ := regexp.MatchString(`[0-9]+`, password); !matched {
-		return fmt.Errorf("password must contain at least one digit")
-	}
-
-	// Проверка на наличие других специальных символов
-	if matched, _ := regexp.MatchString(`[!@#$%^&*()-_+=~{}[\]|;:/?,.<>]+`, password); !matched {
-		return fmt.Errorf("password must contain at least one special character")
-	}
-
-	return nil
-}
-
-func validatePhoneNumber(phone string) error {
-	// Проверка максимальной длины
-	if len(phone) > 20 {
-		return fmt.Errorf("max phone number lenght is 20 characters")
-	}
-
-	// Проверка на соответствие шаблону \+[\d]+
-	pattern := regexp.MustCompile(`^\+[\d]+$`)
-	if !pattern.MatchString(phone) {
-		return fmt.Errorf("invalid phone number format")
-	}
-
-	return nil
-}
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Saveliy12/prod2/internal/models"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Максимальная длина номера телефона в формате E.164 вместе с +
+const maxPhoneLength = 20
+
+// ValidateUser проверяет все поля нового пользователя и возвращает ValidationError со всеми найденными ошибками
+func ValidateUser(user models.RegistrationUser) error {
+	errs := &ValidationError{}
+
+	if errs.Required("login", user.Login) {
+		checkLogin(errs, user.Login)
+	}
+
+	if errs.Required("email", user.Email) {
+		checkEmail(errs, user.Email)
+	}
+
+	if errs.Required("password", user.Password) {
+		checkPassword(errs, user.Password, user.Login, user.Email)
+	}
+
+	if errs.Required("phone", user.Phone) {
+		checkPhoneNumber(errs, user.Phone)
+	}
+
+	return errs.Err()
+}
+
+// ValidateLogin проверяет логин при регистрации, в том числе через внешнего провайдера
+func ValidateLogin(login string) error {
+	errs := &ValidationError{}
+	checkLogin(errs, login)
+	return errs.Err()
+}
+
+// ValidatePassword проверяет пароль по действующей политике при регистрации и смене пароля.
+// login и email нужны, чтобы не принять пароль, составленный из данных самого пользователя.
+func ValidatePassword(password, login, email string) error {
+	errs := &ValidationError{}
+	checkPassword(errs, password, login, email)
+	return errs.Err()
+}
+
+func checkLogin(errs *ValidationError, login string) {
+	if len(login) < policy.LoginMinLength {
+		errs.Add("login", "too_short", fmt.Sprintf("min login length is %d characters", policy.LoginMinLength),
+			map[string]interface{}{"min": policy.LoginMinLength})
+	}
+
+	if len(login) > policy.LoginMaxLength {
+		errs.Add("login", "too_long", fmt.Sprintf("max login length is %d characters", policy.LoginMaxLength),
+			map[string]interface{}{"max": policy.LoginMaxLength})
+	}
+
+	// Проверка на соответствие шаблону [a-zA-Z0-9-]+
+	pattern := regexp.MustCompile(`^[a-zA-Z0-9-]*$`)
+	if !pattern.MatchString(login) {
+		errs.Add("login", "invalid_chars", "the login can contain only Latin letters and numbers", nil)
+	}
+}
+
+func checkEmail(errs *ValidationError, email string) {
+	// Проверка максимальной длины
+	if len(email) > policy.EmailMaxLength {
+		errs.Add("email", "too_long", fmt.Sprintf("max email length is %d characters", policy.EmailMaxLength),
+			map[string]interface{}{"max": policy.EmailMaxLength})
+		return
+	}
+
+	// Проверка формата адреса: только сам адрес, без отображаемого имени
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		errs.Add("email", "invalid_format", "invalid email format", nil)
+	}
+}
+
+func checkPassword(errs *ValidationError, password, login, email string) {
+	found := len(errs.Fields)
+	length := utf8.RuneCountInString(password)
+
+	// Проверка минимальной длины
+	if length < policy.PasswordMinLength {
+		errs.Add("password", "too_short", fmt.Sprintf("min password length is %d characters", policy.PasswordMinLength),
+			map[string]interface{}{"min": policy.PasswordMinLength})
+	}
+
+	// Проверка максимальной длины
+	if length > policy.PasswordMaxLength {
+		errs.Add("password", "too_long", fmt.Sprintf("max password length is %d characters", policy.PasswordMaxLength),
+			map[string]interface{}{"max": policy.PasswordMaxLength})
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+
+	// Проверка на наличие букв в верхнем и нижнем регистрах
+	if policy.RequireLower && !hasLower {
+		errs.Add("password", "no_lowercase", "password must contain at least one lowercase letter", nil)
+	}
+	if policy.RequireUpper && !hasUpper {
+		errs.Add("password", "no_uppercase", "password must contain at least one uppercase letter", nil)
+	}
+
+	// Проверка на наличие хотя бы одной цифры
+	if policy.RequireDigit && !hasDigit {
+		errs.Add("password", "no_digit", "password must contain at least one digit", nil)
+	}
+
+	// Проверка на наличие специальных символов: все, кроме букв, цифр и пробелов
+	if policy.RequireSpecial && !hasSpecial {
+		errs.Add("password", "no_special", "password must contain at least one special character", nil)
+	}
+
+	// Пароль, не прошедший базовые требования, дальше не оценивается: эти ошибки клиенту уже не помогут
+	if len(errs.Fields) > found {
+		return
+	}
+
+	// Пароль не должен содержать логин или почту, даже в другом регистре
+	lowered := strings.ToLower(password)
+	for _, personal := range personalWords(login, email) {
+		if strings.Contains(lowered, personal) {
+			errs.Add("password", "contains_personal_info", "password must not contain your login or email", nil)
+			return
+		}
+	}
+
+	if isBlocked(password) {
+		errs.Add("password", "too_common", "password is too common or has appeared in a data breach", nil)
+		return
+	}
+
+	if score := PasswordStrength(password, login, email); score < policy.MinStrength {
+		errs.Add("password", "too_weak", "password is too easy to guess",
+			map[string]interface{}{"score": score, "minScore": policy.MinStrength})
+	}
+}
+
+// personalWords возвращает логин, почту и имя почтового ящика в нижнем регистре.
+// Слишком короткие части не учитываются, иначе под запрет попадет почти любой пароль.
+func personalWords(login, email string) []string {
+	var words []string
+	for _, word := range []string{login, email, strings.SplitN(email, "@", 2)[0]} {
+		if word = strings.ToLower(strings.TrimSpace(word)); len([]rune(word)) >= 3 {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+func checkPhoneNumber(errs *ValidationError, phone string) {
+	// Проверка максимальной длины
+	if len(phone) > maxPhoneLength {
+		errs.Add("phone", "too_long", fmt.Sprintf("max phone number length is %d characters", maxPhoneLength),
+			map[string]interface{}{"max": maxPhoneLength})
+		return
+	}
+
+	// Номер должен быть в формате E.164 и существовать в плане нумерации своей страны
+	number, err := phonenumbers.Parse(phone, "")
+	if err != nil || !phonenumbers.IsValidNumber(number) || phonenumbers.Format(number, phonenumbers.E164) != phone {
+		errs.Add("phone", "invalid_format", "invalid phone number format", nil)
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// FieldError - ошибка проверки одного поля запроса.
+// Code имеет вид <поле>.<причина> (login.too_long), Params - значения для подстановки в сообщение.
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationError собирает ошибки всех полей запроса, чтобы клиент мог показать их сразу
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewFieldError создает ValidationError с ошибкой одного поля
+func NewFieldError(field, reason, message string, params map[string]interface{}) *ValidationError {
+	e := &ValidationError{}
+	e.Add(field, reason, message, params)
+	return e
+}
+
+// Add добавляет ошибку поля, код составляется из имени поля и причины
+func (e *ValidationError) Add(field, reason, message string, params map[string]interface{}) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Code:    field + "." + reason,
+		Message: message,
+		Params:  params,
+	})
+}
+
+// Required добавляет ошибку required для пустого значения и сообщает, заполнено ли поле
+func (e *ValidationError) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		e.Add(field, "required", field+" is required", nil)
+		return false
+	}
+	return true
+}
+
+// Merge добавляет ошибки полей из err, если это ValidationError
+func (e *ValidationError) Merge(err error) {
+	var other *ValidationError
+	if errors.As(err, &other) {
+		e.Fields = append(e.Fields, other.Fields...)
+	}
+}
+
+// Err возвращает nil, если ошибок нет
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
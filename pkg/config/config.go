@@ -1,59 +1,410 @@
-package config
-
-import (
-	"errors"
-	"fmt"
-	"os"
-	"strconv"
-
-	"github.com/Saveliy12/prod2/pkg/logger"
-	"github.com/joho/godotenv"
-)
-
-type Config struct {
-	DB     Postgres
-	Server Server
-	log    logger.LoggerInterface
-}
-
-type Postgres struct {
-	User     string
-	Name     string
-	SSLMode  string
-	Password string
-}
-
-type Server struct {
-	Port int
-}
-
-func New() (*Config, error) {
-	cfg := new(Config)
-
-	cfg.log = logger.GetLogger()
-
-	err := godotenv.Load()
-
-	if err != nil {
-		cfg.log.Fatal("Error loading .env file")
-	}
-
-	// Загрузка значений из переменных окружения
-	cfg.DB.User = os.Getenv("DB_USER")
-	cfg.DB.Name = os.Getenv("DB_NAME")
-	cfg.DB.SSLMode = os.Getenv("DB_SSLMODE")
-	cfg.DB.Password = os.Getenv("DB_PASSWORD")
-
-	// Преобразование порта в int
-	portStr := os.Getenv("SERVER_PORT")
-	if portStr == "" {
-		return nil, errors.New("SERVER_PORT is empty")
-	}
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse SERVER_PORT: %w", err)
-	}
-	cfg.Server.Port = port
-
-	return cfg, nil
-}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	DB       Postgres
+	Server   Server
+	JWT      JWT
+	Mail     Mail
+	SMS      SMS
+	Phone    Phone
+	Login    Login
+	Password Password
+	Policy   Policy
+	Locale   Locale
+	Media    Media
+	OIDC     []OIDCProvider
+	log      logger.LoggerInterface
+}
+
+type Postgres struct {
+	User     string
+	Name     string
+	SSLMode  string
+	Password string
+}
+
+type Server struct {
+	Port int
+}
+
+// JWT содержит настройки подписи access-токенов
+type JWT struct {
+	Algorithm      string        // HS256, RS256 или EdDSA
+	SigningKey     string        // секрет для HS256
+	PrivateKeyFile string        // PEM-файл закрытого ключа для RS256/EdDSA, без него ключи хранятся в базе данных
+	RotationPeriod time.Duration // период плановой ротации асимметричных ключей, 0 - без ротации
+}
+
+// Login содержит настройки ограничения неудачных попыток входа
+type Login struct {
+	AttemptsStore string        // memory для одного экземпляра или postgres для нескольких
+	LockThreshold int           // число неудач, после которого аккаунт блокируется, 0 - по умолчанию
+	LockDuration  time.Duration // время блокировки, 0 - по умолчанию
+}
+
+// Password содержит настройки хеширования паролей
+type Password struct {
+	Algorithm string // argon2id или bcrypt, хеши другого алгоритма пересчитываются при входе
+	Pepper    string // секрет сервера, подмешиваемый в пароль, пустой - без pepper
+	PepperID  string // идентификатор pepper, записывается в хеш
+
+	BcryptCost        int
+	Argon2Memory      uint32 // КиБ
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// Policy содержит требования к логину, почте и паролю. Нулевые значения заменяются значениями по умолчанию.
+type Policy struct {
+	LoginMaxLength    int
+	EmailMaxLength    int
+	PasswordMinLength int
+	PasswordMaxLength int
+	RequireLower      bool
+	RequireUpper      bool
+	RequireDigit      bool
+	RequireSpecial    bool
+	MinStrength       int    // минимальная оценка стойкости от 0 до 4, -1 - по умолчанию
+	BlocklistFile     string // файл запрещенных паролей, по одному в строке
+}
+
+// Locale содержит настройки языка сообщений API
+type Locale struct {
+	Default string // ru или en, для клиентов без подходящего Accept-Language
+}
+
+// MaxMediaURLTTL - наибольший срок действия ссылок на файлы
+const MaxMediaURLTTL = 84 * time.Hour
+
+// Media содержит настройки хранения загруженных изображений
+type Media struct {
+	Driver        string        // local или s3
+	StorageDir    string        // каталог для файлов при Driver = local
+	BaseURL       string        // адрес, по которому сервис раздает файлы при Driver = local
+	SigningKey    string        // секрет подписи ссылок на файлы, без него сервис запускается только в режиме отладки
+	URLTTL        time.Duration // срок действия ссылок на файлы
+	MaxUploadSize int64         // наибольший размер загружаемого файла в байтах
+
+	S3Endpoint  string // например https://s3.eu-central-1.amazonaws.com или http://localhost:9000
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool // адреса вида endpoint/bucket/key, нужны для MinIO
+}
+
+// OIDCProvider содержит настройки входа через внешнего провайдера OpenID Connect.
+// Провайдеры перечисляются в OIDC_PROVIDERS, настройки каждого - в OIDC_<ИМЯ>_*.
+type OIDCProvider struct {
+	Name         string // имя провайдера в адресах /oidc/:provider
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string   // страница клиента, которая принимает code и state
+	Scopes       []string // по умолчанию openid email profile
+}
+
+// Mail содержит настройки отправки писем
+type Mail struct {
+	Driver       string // smtp, file или memory
+	From         string
+	OutboxDir    string // каталог для писем при Driver = file
+	VerifyURL    string // адрес страницы подтверждения почты
+	ResetURL     string // адрес страницы сброса пароля
+	MagicLinkURL string // адрес страницы входа по ссылке из письма
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+}
+
+// SMS содержит настройки отправки SMS
+type SMS struct {
+	Driver       string // log, memory или http
+	From         string // имя отправителя
+	GatewayURL   string // адрес HTTP API шлюза при Driver = http
+	GatewayToken string
+}
+
+// Phone содержит настройки разбора номеров телефонов
+type Phone struct {
+	DefaultRegion string // страна (ISO 3166-1 alpha-2) для номеров без кода страны
+}
+
+func New() (*Config, error) {
+	cfg := new(Config)
+
+	cfg.log = logger.GetLogger()
+
+	err := godotenv.Load()
+
+	if err != nil {
+		cfg.log.Fatal("Error loading .env file")
+	}
+
+	// Загрузка значений из переменных окружения
+	cfg.DB.User = os.Getenv("DB_USER")
+	cfg.DB.Name = os.Getenv("DB_NAME")
+	cfg.DB.SSLMode = os.Getenv("DB_SSLMODE")
+	cfg.DB.Password = os.Getenv("DB_PASSWORD")
+
+	// Преобразование порта в int
+	portStr := os.Getenv("SERVER_PORT")
+	if portStr == "" {
+		return nil, errors.New("SERVER_PORT is empty")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SERVER_PORT: %w", err)
+	}
+	cfg.Server.Port = port
+
+	cfg.JWT.Algorithm = os.Getenv("JWT_ALGORITHM")
+	if cfg.JWT.Algorithm == "" {
+		cfg.JWT.Algorithm = "HS256"
+	}
+	cfg.JWT.SigningKey = os.Getenv("JWT_SIGNING_KEY")
+	cfg.JWT.PrivateKeyFile = os.Getenv("JWT_PRIVATE_KEY_FILE")
+
+	if periodStr := os.Getenv("JWT_ROTATION_PERIOD"); periodStr != "" {
+		period, err := time.ParseDuration(periodStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWT_ROTATION_PERIOD: %w", err)
+		}
+		cfg.JWT.RotationPeriod = period
+	}
+
+	cfg.Mail.Driver = os.Getenv("MAIL_DRIVER")
+	if cfg.Mail.Driver == "" {
+		cfg.Mail.Driver = "file"
+	}
+	cfg.Mail.From = os.Getenv("MAIL_FROM")
+	cfg.Mail.OutboxDir = os.Getenv("MAIL_OUTBOX_DIR")
+	if cfg.Mail.OutboxDir == "" {
+		cfg.Mail.OutboxDir = "outbox"
+	}
+	cfg.Mail.VerifyURL = os.Getenv("MAIL_VERIFY_URL")
+	cfg.Mail.ResetURL = os.Getenv("MAIL_RESET_URL")
+	cfg.Mail.MagicLinkURL = os.Getenv("MAIL_MAGIC_LINK_URL")
+	cfg.Mail.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.Mail.SMTPUser = os.Getenv("SMTP_USER")
+	cfg.Mail.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+
+	if smtpPortStr := os.Getenv("SMTP_PORT"); smtpPortStr != "" {
+		smtpPort, err := strconv.Atoi(smtpPortStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SMTP_PORT: %w", err)
+		}
+		cfg.Mail.SMTPPort = smtpPort
+	}
+
+	cfg.SMS.Driver = os.Getenv("SMS_DRIVER")
+	if cfg.SMS.Driver == "" {
+		cfg.SMS.Driver = "log"
+	}
+	cfg.SMS.From = os.Getenv("SMS_FROM")
+	cfg.SMS.GatewayURL = os.Getenv("SMS_GATEWAY_URL")
+	cfg.SMS.GatewayToken = os.Getenv("SMS_GATEWAY_TOKEN")
+
+	cfg.Phone.DefaultRegion = os.Getenv("PHONE_DEFAULT_REGION")
+	if cfg.Phone.DefaultRegion == "" {
+		cfg.Phone.DefaultRegion = "RU"
+	}
+
+	cfg.Locale.Default = os.Getenv("LOCALE_DEFAULT")
+	if cfg.Locale.Default == "" {
+		cfg.Locale.Default = "en"
+	}
+
+	cfg.Media.Driver = os.Getenv("MEDIA_STORAGE_DRIVER")
+	if cfg.Media.Driver == "" {
+		cfg.Media.Driver = "local"
+	}
+	cfg.Media.StorageDir = os.Getenv("MEDIA_STORAGE_DIR")
+	if cfg.Media.StorageDir == "" {
+		cfg.Media.StorageDir = "media"
+	}
+	cfg.Media.BaseURL = os.Getenv("MEDIA_BASE_URL")
+	if cfg.Media.BaseURL == "" {
+		cfg.Media.BaseURL = "/media"
+	}
+	cfg.Media.SigningKey = os.Getenv("MEDIA_SIGNING_KEY")
+	cfg.Media.URLTTL = time.Hour
+	if ttlStr := os.Getenv("MEDIA_URL_TTL"); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MEDIA_URL_TTL: %w", err)
+		}
+		// Ссылка действует до 2*MEDIA_URL_TTL, а S3 не принимает подписи дольше 7 дней
+		if ttl < time.Minute || ttl > MaxMediaURLTTL {
+			return nil, fmt.Errorf("MEDIA_URL_TTL must be between 1m and %v", MaxMediaURLTTL)
+		}
+		cfg.Media.URLTTL = ttl
+	}
+	cfg.Media.MaxUploadSize = 10 << 20
+	if sizeStr := os.Getenv("MEDIA_MAX_UPLOAD_MB"); sizeStr != "" {
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MEDIA_MAX_UPLOAD_MB: %w", err)
+		}
+		cfg.Media.MaxUploadSize = size << 20
+	}
+	cfg.Media.S3Endpoint = os.Getenv("MEDIA_S3_ENDPOINT")
+	cfg.Media.S3Region = os.Getenv("MEDIA_S3_REGION")
+	if cfg.Media.S3Region == "" {
+		cfg.Media.S3Region = "us-east-1"
+	}
+	cfg.Media.S3Bucket = os.Getenv("MEDIA_S3_BUCKET")
+	cfg.Media.S3AccessKey = os.Getenv("MEDIA_S3_ACCESS_KEY")
+	cfg.Media.S3SecretKey = os.Getenv("MEDIA_S3_SECRET_KEY")
+	if pathStyleStr := os.Getenv("MEDIA_S3_PATH_STYLE"); pathStyleStr != "" {
+		pathStyle, err := strconv.ParseBool(pathStyleStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MEDIA_S3_PATH_STYLE: %w", err)
+		}
+		cfg.Media.S3PathStyle = pathStyle
+	}
+
+	cfg.Login.AttemptsStore = os.Getenv("LOGIN_ATTEMPTS_STORE")
+	if cfg.Login.AttemptsStore == "" {
+		cfg.Login.AttemptsStore = "postgres"
+	}
+
+	if thresholdStr := os.Getenv("LOGIN_LOCK_THRESHOLD"); thresholdStr != "" {
+		threshold, err := strconv.Atoi(thresholdStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOGIN_LOCK_THRESHOLD: %w", err)
+		}
+		cfg.Login.LockThreshold = threshold
+	}
+
+	if durationStr := os.Getenv("LOGIN_LOCK_DURATION"); durationStr != "" {
+		duration, err := time.ParseDuration(durationStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse LOGIN_LOCK_DURATION: %w", err)
+		}
+		cfg.Login.LockDuration = duration
+	}
+
+	cfg.Password.Algorithm = os.Getenv("PASSWORD_HASH_ALGORITHM")
+	if cfg.Password.Algorithm == "" {
+		cfg.Password.Algorithm = "argon2id"
+	}
+	cfg.Password.Pepper = os.Getenv("PASSWORD_PEPPER")
+	cfg.Password.PepperID = os.Getenv("PASSWORD_PEPPER_ID")
+	if cfg.Password.PepperID == "" {
+		cfg.Password.PepperID = "1"
+	}
+
+	if costStr := os.Getenv("BCRYPT_COST"); costStr != "" {
+		cost, err := strconv.Atoi(costStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse BCRYPT_COST: %w", err)
+		}
+		cfg.Password.BcryptCost = cost
+	}
+
+	if memoryStr := os.Getenv("ARGON2_MEMORY"); memoryStr != "" {
+		memory, err := strconv.ParseUint(memoryStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ARGON2_MEMORY: %w", err)
+		}
+		cfg.Password.Argon2Memory = uint32(memory)
+	}
+
+	if iterationsStr := os.Getenv("ARGON2_ITERATIONS"); iterationsStr != "" {
+		iterations, err := strconv.ParseUint(iterationsStr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ARGON2_ITERATIONS: %w", err)
+		}
+		cfg.Password.Argon2Iterations = uint32(iterations)
+	}
+
+	if parallelismStr := os.Getenv("ARGON2_PARALLELISM"); parallelismStr != "" {
+		parallelism, err := strconv.ParseUint(parallelismStr, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ARGON2_PARALLELISM: %w", err)
+		}
+		cfg.Password.Argon2Parallelism = uint8(parallelism)
+	}
+
+	policyLengths := map[string]*int{
+		"LOGIN_MAX_LENGTH":    &cfg.Policy.LoginMaxLength,
+		"EMAIL_MAX_LENGTH":    &cfg.Policy.EmailMaxLength,
+		"PASSWORD_MIN_LENGTH": &cfg.Policy.PasswordMinLength,
+		"PASSWORD_MAX_LENGTH": &cfg.Policy.PasswordMaxLength,
+	}
+	for name, target := range policyLengths {
+		if lengthStr := os.Getenv(name); lengthStr != "" {
+			length, err := strconv.Atoi(lengthStr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			*target = length
+		}
+	}
+
+	// Классы символов обязательны, пока не отключены явно
+	policyClasses := map[string]*bool{
+		"PASSWORD_REQUIRE_LOWER":   &cfg.Policy.RequireLower,
+		"PASSWORD_REQUIRE_UPPER":   &cfg.Policy.RequireUpper,
+		"PASSWORD_REQUIRE_DIGIT":   &cfg.Policy.RequireDigit,
+		"PASSWORD_REQUIRE_SPECIAL": &cfg.Policy.RequireSpecial,
+	}
+	for name, target := range policyClasses {
+		*target = true
+		if requiredStr := os.Getenv(name); requiredStr != "" {
+			required, err := strconv.ParseBool(requiredStr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+			}
+			*target = required
+		}
+	}
+
+	cfg.Policy.MinStrength = -1
+	if strengthStr := os.Getenv("PASSWORD_MIN_STRENGTH"); strengthStr != "" {
+		strength, err := strconv.Atoi(strengthStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PASSWORD_MIN_STRENGTH: %w", err)
+		}
+		cfg.Policy.MinStrength = strength
+	}
+	cfg.Policy.BlocklistFile = os.Getenv("PASSWORD_BLOCKLIST_FILE")
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+		}
+		cfg.OIDC = append(cfg.OIDC, provider)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,148 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params - параметры argon2id
+type Argon2Params struct {
+	Memory      uint32 // КиБ
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params - параметры по умолчанию (рекомендация OWASP с запасом)
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher хеширует пароли argon2id.
+// Хеши записываются в формате PHC: $argon2id$v=19$m=<память>,t=<итерации>,p=<потоки>[,keyid=<pepper>]$<соль>$<хеш>.
+type Argon2idHasher struct {
+	params Argon2Params
+	pepper *Pepper
+}
+
+// NewArgon2idHasher создает новый экземпляр Argon2idHasher. pepper может быть nil.
+func NewArgon2idHasher(params Argon2Params, pepper *Pepper) *Argon2idHasher {
+	return &Argon2idHasher{params: params, pepper: pepper}
+}
+
+func (h *Argon2idHasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey(peppered(password, h.pepper), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.params.Memory, h.params.Iterations, h.params.Parallelism)
+	if keyID := currentKeyID(h.pepper); keyID != "" {
+		params += ",keyid=" + keyID
+	}
+
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Compare(hash, password string) error {
+	parsed, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	pepper, err := pepperFor(parsed.keyID, h.pepper)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey(peppered(password, pepper), parsed.salt, parsed.params.Iterations, parsed.params.Memory,
+		parsed.params.Parallelism, uint32(len(parsed.key)))
+	if subtle.ConstantTimeCompare(key, parsed.key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return parsed.params.Memory != h.params.Memory ||
+		parsed.params.Iterations != h.params.Iterations ||
+		parsed.params.Parallelism != h.params.Parallelism ||
+		uint32(len(parsed.key)) != h.params.KeyLength ||
+		parsed.keyID != currentKeyID(h.pepper)
+}
+
+func (h *Argon2idHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+type argon2idHash struct {
+	params Argon2Params
+	keyID  string
+	salt   []byte
+	key    []byte
+}
+
+func parseArgon2id(hash string) (argon2idHash, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", соль, хеш
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idHash{}, ErrUnknownFormat
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return argon2idHash{}, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+
+	params := parseParams(parts[3])
+	memory, err := strconv.ParseUint(params["m"], 10, 32)
+	if err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2 memory: %v", err)
+	}
+	iterations, err := strconv.ParseUint(params["t"], 10, 32)
+	if err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2 iterations: %v", err)
+	}
+	parallelism, err := strconv.ParseUint(params["p"], 10, 8)
+	if err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2 parallelism: %v", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2 salt: %v", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2idHash{}, fmt.Errorf("invalid argon2 hash: %v", err)
+	}
+
+	return argon2idHash{
+		params: Argon2Params{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+		},
+		keyID: params["keyid"],
+		salt:  salt,
+		key:   key,
+	}, nil
+}
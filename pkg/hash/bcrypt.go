@@ -0,0 +1,129 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHasher хеширует пароли bcrypt.
+// Новые хеши записываются в формате PHC: $bcrypt$c=<cost>,keyid=<pepper>$<соль и хеш bcrypt> или,
+// без pepper, $bcrypt$c=<cost>,pre=sha256$<соль и хеш bcrypt>.
+// Хеши в исходном формате bcrypt ($2a$, $2b$, $2y$) и хеши без keyid и pre созданы из самого пароля и тоже проверяются.
+type BcryptHasher struct {
+	cost   int
+	pepper *Pepper
+}
+
+// NewBcryptHasher создает новый экземпляр BcryptHasher. pepper может быть nil.
+func NewBcryptHasher(cost int, pepper *Pepper) *BcryptHasher {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{cost: cost, pepper: pepper}
+}
+
+// bcryptPrehash - значение параметра pre для хешей, в которых пароль без pepper заранее сведен к SHA-256
+const bcryptPrehash = "sha256"
+
+func (h *BcryptHasher) HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword(bcryptPassword(password, h.pepper, true), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	// $2a$<cost>$<53 символа соли и хеша>
+	parts := strings.Split(string(hashed), "$")
+	params := "c=" + strconv.Itoa(h.cost)
+	if keyID := currentKeyID(h.pepper); keyID != "" {
+		params += ",keyid=" + keyID
+	} else {
+		params += ",pre=" + bcryptPrehash
+	}
+
+	return "$bcrypt$" + params + "$" + parts[3], nil
+}
+
+func (h *BcryptHasher) Compare(hash, password string) error {
+	parsed, err := parseBcrypt(hash)
+	if err != nil {
+		return err
+	}
+
+	pepper, err := pepperFor(parsed.keyID, h.pepper)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(parsed.native), bcryptPassword(password, pepper, parsed.prehash)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+	return nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	parsed, err := parseBcrypt(hash)
+	if err != nil {
+		return true
+	}
+	return parsed.cost != h.cost || parsed.keyID != currentKeyID(h.pepper) || (parsed.keyID == "" && !parsed.prehash)
+}
+
+func (h *BcryptHasher) Supports(hash string) bool {
+	return strings.HasPrefix(hash, "$bcrypt$") || strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+type bcryptHash struct {
+	native  string
+	cost    int
+	keyID   string
+	prehash bool
+}
+
+// bcryptPassword готовит пароль для bcrypt. bcrypt не принимает пароли длиннее 72 байт, а 40 символов
+// кириллицы - это уже 80 байт, поэтому пароль сводится к HMAC с pepper или, без pepper, к SHA-256.
+// prehash = false - пароль без pepper передается как есть, так созданы старые хеши.
+func bcryptPassword(password string, pepper *Pepper, prehash bool) []byte {
+	if pepper != nil || !prehash {
+		return peppered(password, pepper)
+	}
+	sum := sha256.Sum256([]byte(password))
+	return []byte(base64.RawStdEncoding.EncodeToString(sum[:]))
+}
+
+func parseBcrypt(hash string) (bcryptHash, error) {
+	if !strings.HasPrefix(hash, "$bcrypt$") {
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return bcryptHash{}, err
+		}
+		return bcryptHash{native: hash, cost: cost}, nil
+	}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return bcryptHash{}, ErrUnknownFormat
+	}
+
+	params := parseParams(parts[2])
+	cost, err := strconv.Atoi(params["c"])
+	if err != nil {
+		return bcryptHash{}, fmt.Errorf("invalid bcrypt cost: %v", err)
+	}
+
+	return bcryptHash{
+		native:  fmt.Sprintf("$2a$%02d$%s", cost, parts[3]),
+		cost:    cost,
+		keyID:   params["keyid"],
+		prehash: params["pre"] == bcryptPrehash,
+	}, nil
+}
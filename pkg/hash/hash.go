@@ -1,39 +1,114 @@
-package hash
-
-import (
-	"errors"
-
-	"golang.org/x/crypto/bcrypt"
-)
-
-type HasherInterface interface {
-	HashPassword(string) (string, error)
-	Compare(string, string) error
-}
-
-type Hasher struct {
-	salt string
-}
-
-func NewHasher(salt string) *Hasher {
-	return &Hasher{
-		salt: salt,
-	}
-}
-
-func (h *Hasher) HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-
-	return string(hashedPassword), nil
-}
-
-func (h *Hasher) Compare(password string, credentials string) (int, error) {
-	if err := bcrypt.CompareHashAndPassword([]byte(password), []byte(credentials)); err != nil {
-		return 0, errors.New("invalid login or password")
-	}
-
-	return 1, nil
-}
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrMismatch возвращается, если пароль не соответствует хешу
+var ErrMismatch = errors.New("invalid login or password")
+
+// ErrUnknownFormat возвращается для хеша, который не умеет проверять ни один алгоритм
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// HasherInterface определяет методы для хеширования и проверки паролей
+type HasherInterface interface {
+	HashPassword(password string) (string, error)
+	Compare(hash, password string) error
+	// NeedsRehash сообщает, что хеш получен устаревшим алгоритмом или параметрами
+	NeedsRehash(hash string) bool
+}
+
+// Algorithm - отдельный алгоритм хеширования, который умеет распознавать свои хеши
+type Algorithm interface {
+	HasherInterface
+	Supports(hash string) bool
+}
+
+// Pepper - секрет сервера, который подмешивается в пароль перед хешированием.
+// В отличие от соли хранится не в базе, а в конфигурации, поэтому утечка одной базы
+// не позволяет перебирать пароли. ID записывается в хеш, чтобы секрет можно было сменить.
+type Pepper struct {
+	ID     string
+	Secret []byte
+}
+
+// Hasher хеширует новые пароли текущим алгоритмом и проверяет хеши всех известных алгоритмов.
+// Хеши старых алгоритмов проверяются, но NeedsRehash для них возвращает true.
+type Hasher struct {
+	current    Algorithm
+	algorithms []Algorithm
+}
+
+// NewHasher создает новый экземпляр Hasher. legacy - алгоритмы, хеши которых еще встречаются в базе.
+func NewHasher(current Algorithm, legacy ...Algorithm) *Hasher {
+	return &Hasher{
+		current:    current,
+		algorithms: append([]Algorithm{current}, legacy...),
+	}
+}
+
+func (h *Hasher) HashPassword(password string) (string, error) {
+	return h.current.HashPassword(password)
+}
+
+func (h *Hasher) Compare(hash, password string) error {
+	for _, algorithm := range h.algorithms {
+		if algorithm.Supports(hash) {
+			return algorithm.Compare(hash, password)
+		}
+	}
+	return ErrUnknownFormat
+}
+
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if !h.current.Supports(hash) {
+		return true
+	}
+	return h.current.NeedsRehash(hash)
+}
+
+// peppered подмешивает секрет сервера в пароль. Результат HMAC кодируется в base64,
+// чтобы не содержать нулевых байт и укладываться в ограничение bcrypt в 72 байта.
+func peppered(password string, pepper *Pepper) []byte {
+	if pepper == nil {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, pepper.Secret)
+	mac.Write([]byte(password))
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// pepperFor возвращает секрет для keyid из хеша
+func pepperFor(keyID string, pepper *Pepper) (*Pepper, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	if pepper == nil || pepper.ID != keyID {
+		return nil, errors.New("password hash uses an unknown pepper")
+	}
+	return pepper, nil
+}
+
+// currentKeyID возвращает keyid, который записывается в новые хеши
+func currentKeyID(pepper *Pepper) string {
+	if pepper == nil {
+		return ""
+	}
+	return pepper.ID
+}
+
+// parseParams разбирает параметры PHC вида "a=1,b=2"
+func parseParams(s string) map[string]string {
+	params := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			params[k] = v
+		}
+	}
+	return params
+}
@@ -0,0 +1,237 @@
+package hash
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Параметры argon2id, при которых тесты выполняются быстро
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+var (
+	pepperV1 = &Pepper{ID: "v1", Secret: []byte("first pepper")}
+	pepperV2 = &Pepper{ID: "v2", Secret: []byte("second pepper")}
+)
+
+// longPassword - 100 символов кириллицы, 200 байт
+var longPassword = strings.Repeat("пароль-пар", 10)
+
+func TestBcryptLongPassword(t *testing.T) {
+	for _, pepper := range []*Pepper{nil, pepperV1} {
+		h := NewBcryptHasher(bcrypt.MinCost, pepper)
+
+		hash, err := h.HashPassword(longPassword)
+		if err != nil {
+			t.Fatalf("pepper %v: HashPassword: %v", pepper, err)
+		}
+		if err := h.Compare(hash, longPassword); err != nil {
+			t.Fatalf("pepper %v: Compare: %v", pepper, err)
+		}
+		// Отличие после 72-го байта тоже имеет значение
+		if err := h.Compare(hash, longPassword[:len(longPassword)-2]+"ь"); !errors.Is(err, ErrMismatch) {
+			t.Fatalf("pepper %v: Compare of another long password: err = %v, want ErrMismatch", pepper, err)
+		}
+		if h.NeedsRehash(hash) {
+			t.Fatalf("pepper %v: NeedsRehash of a fresh hash", pepper)
+		}
+	}
+}
+
+func TestBcryptFormat(t *testing.T) {
+	format := regexp.MustCompile(`^\$bcrypt\$c=4,pre=sha256\$[./A-Za-z0-9]{53}$`)
+	hash, err := NewBcryptHasher(bcrypt.MinCost, nil).HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !format.MatchString(hash) {
+		t.Fatalf("hash = %q, want $bcrypt$c=4,pre=sha256$...", hash)
+	}
+
+	hash, err = NewBcryptHasher(bcrypt.MinCost, pepperV1).HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$bcrypt$c=4,keyid=v1$") {
+		t.Fatalf("hash = %q, want $bcrypt$c=4,keyid=v1$...", hash)
+	}
+
+	parsed, err := parseBcrypt(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.cost != 4 || parsed.keyID != "v1" || !strings.HasPrefix(parsed.native, "$2a$04$") {
+		t.Fatalf("parseBcrypt = %+v", parsed)
+	}
+
+	for _, malformed := range []string{"$bcrypt$c=4", "$bcrypt$c=x$abc", "$2a$xx$abc"} {
+		if _, err := parseBcrypt(malformed); err == nil {
+			t.Errorf("parseBcrypt(%q) succeeded", malformed)
+		}
+	}
+}
+
+func TestBcryptLegacyHashes(t *testing.T) {
+	native, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// $bcrypt$ без keyid и pre - хеш самого пароля, как их создавали до перехода на SHA-256
+	phc := "$bcrypt$c=4$" + strings.Split(string(native), "$")[3]
+
+	h := NewBcryptHasher(bcrypt.MinCost, nil)
+	for _, hash := range []string{string(native), phc} {
+		if !h.Supports(hash) {
+			t.Fatalf("Supports(%q) = false", hash)
+		}
+		if err := h.Compare(hash, "secret"); err != nil {
+			t.Fatalf("Compare(%q): %v", hash, err)
+		}
+		if err := h.Compare(hash, "other"); !errors.Is(err, ErrMismatch) {
+			t.Fatalf("Compare(%q) with a wrong password: err = %v, want ErrMismatch", hash, err)
+		}
+		if !h.NeedsRehash(hash) {
+			t.Fatalf("NeedsRehash(%q) = false", hash)
+		}
+	}
+
+	if !NewBcryptHasher(bcrypt.MinCost+1, nil).NeedsRehash(phc) {
+		t.Fatal("NeedsRehash ignores the cost")
+	}
+}
+
+func TestArgon2idFormat(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2Params, pepperV1)
+	hash, err := h.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := regexp.MustCompile(`^\$argon2id\$v=19\$m=1024,t=1,p=1,keyid=v1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !format.MatchString(hash) {
+		t.Fatalf("hash = %q", hash)
+	}
+
+	parsed, err := parseArgon2id(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.params.Memory != 1024 || parsed.params.Iterations != 1 || parsed.params.Parallelism != 1 ||
+		parsed.keyID != "v1" || len(parsed.salt) != 16 || len(parsed.key) != 32 {
+		t.Fatalf("parseArgon2id = %+v", parsed)
+	}
+
+	if err := h.Compare(hash, "secret"); err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if err := h.Compare(hash, "other"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Compare with a wrong password: err = %v, want ErrMismatch", err)
+	}
+
+	for _, malformed := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!$aGFzaA",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+	} {
+		if _, err := parseArgon2id(malformed); err == nil {
+			t.Errorf("parseArgon2id(%q) succeeded", malformed)
+		}
+	}
+}
+
+func TestArgon2idNeedsRehash(t *testing.T) {
+	hash, err := NewArgon2idHasher(testArgon2Params, nil).HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if NewArgon2idHasher(testArgon2Params, nil).NeedsRehash(hash) {
+		t.Fatal("NeedsRehash with the same parameters")
+	}
+
+	changed := []Argon2Params{testArgon2Params, testArgon2Params, testArgon2Params, testArgon2Params}
+	changed[0].Memory *= 2
+	changed[1].Iterations++
+	changed[2].Parallelism++
+	changed[3].KeyLength = 16
+	for _, params := range changed {
+		if !NewArgon2idHasher(params, nil).NeedsRehash(hash) {
+			t.Errorf("NeedsRehash = false for %+v", params)
+		}
+	}
+	if !NewArgon2idHasher(testArgon2Params, nil).NeedsRehash("$argon2id$broken") {
+		t.Error("NeedsRehash = false for a malformed hash")
+	}
+}
+
+func TestPepperKeyIDs(t *testing.T) {
+	algorithms := map[string]func(*Pepper) Algorithm{
+		"bcrypt":   func(p *Pepper) Algorithm { return NewBcryptHasher(bcrypt.MinCost, p) },
+		"argon2id": func(p *Pepper) Algorithm { return NewArgon2idHasher(testArgon2Params, p) },
+	}
+	for name, newAlgorithm := range algorithms {
+		hash, err := newAlgorithm(pepperV1).HashPassword("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(hash, "keyid=v1") {
+			t.Fatalf("%s: hash %q has no keyid", name, hash)
+		}
+
+		// Хеш проверяется только секретом с тем же keyid
+		if err := newAlgorithm(pepperV1).Compare(hash, "secret"); err != nil {
+			t.Errorf("%s: Compare with the same pepper: %v", name, err)
+		}
+		for _, other := range []*Pepper{nil, pepperV2} {
+			if err := newAlgorithm(other).Compare(hash, "secret"); err == nil || errors.Is(err, ErrMismatch) {
+				t.Errorf("%s: Compare with pepper %v: err = %v, want unknown pepper", name, other, err)
+			}
+		}
+		if err := newAlgorithm(&Pepper{ID: "v1", Secret: []byte("other")}).Compare(hash, "secret"); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: Compare with another secret under the same keyid: err = %v, want ErrMismatch", name, err)
+		}
+
+		// Смена pepper требует пересчета хеша при следующем входе
+		if newAlgorithm(pepperV1).NeedsRehash(hash) {
+			t.Errorf("%s: NeedsRehash with the same pepper", name)
+		}
+		if !newAlgorithm(pepperV2).NeedsRehash(hash) || !newAlgorithm(nil).NeedsRehash(hash) {
+			t.Errorf("%s: NeedsRehash = false after the pepper changed", name)
+		}
+	}
+}
+
+func TestHasherMigratesLegacyAlgorithm(t *testing.T) {
+	legacy := NewBcryptHasher(bcrypt.MinCost, nil)
+	h := NewHasher(NewArgon2idHasher(testArgon2Params, nil), legacy)
+
+	old, err := legacy.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Compare(old, "secret"); err != nil {
+		t.Fatalf("Compare of a legacy hash: %v", err)
+	}
+	if !h.NeedsRehash(old) {
+		t.Fatal("NeedsRehash = false for a legacy algorithm")
+	}
+
+	fresh, err := h.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fresh, "$argon2id$") || h.NeedsRehash(fresh) {
+		t.Fatalf("new hash %q is not a current argon2id hash", fresh)
+	}
+
+	if err := h.Compare("$md5$abc", "secret"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("Compare of an unknown hash: err = %v, want ErrUnknownFormat", err)
+	}
+	if !h.NeedsRehash("$md5$abc") {
+		t.Fatal("NeedsRehash = false for an unknown hash")
+	}
+}
@@ -0,0 +1,176 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message - текст сообщения на одном языке. Параметры подставляются вместо {name}.
+// Если задан Count, форма выбирается по значению этого параметра по правилам языка,
+// иначе используется Other.
+type Message struct {
+	Count string
+	One   string
+	Few   string
+	Many  string
+	Other string
+}
+
+// Bundle хранит каталоги сообщений нескольких языков, ключом сообщения служит его код
+type Bundle struct {
+	fallback string
+	catalogs map[string]map[string]Message
+}
+
+// NewBundle создает набор каталогов. fallback - язык для клиентов, чей язык не поддерживается.
+func NewBundle(fallback string) *Bundle {
+	return &Bundle{fallback: fallback, catalogs: make(map[string]map[string]Message)}
+}
+
+// Add добавляет каталог сообщений языка
+func (b *Bundle) Add(locale string, messages map[string]Message) {
+	b.catalogs[strings.ToLower(locale)] = messages
+}
+
+// SetFallback задает язык для клиентов, чей язык не поддерживается
+func (b *Bundle) SetFallback(locale string) error {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if !b.Supports(locale) {
+		return fmt.Errorf("unsupported locale: %s", locale)
+	}
+	b.fallback = locale
+	return nil
+}
+
+// Fallback возвращает язык по умолчанию
+func (b *Bundle) Fallback() string {
+	return b.fallback
+}
+
+// Supports сообщает, есть ли каталог для языка
+func (b *Bundle) Supports(locale string) bool {
+	_, ok := b.catalogs[strings.ToLower(locale)]
+	return ok
+}
+
+// Locales возвращает поддерживаемые языки
+func (b *Bundle) Locales() []string {
+	locales := make([]string, 0, len(b.catalogs))
+	for locale := range b.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Negotiate выбирает язык по заголовку Accept-Language (RFC 9110, раздел 12.5.4).
+// Региональные варианты сводятся к основному языку: ru-RU - ru.
+func (b *Bundle) Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		if primary, _, ok := strings.Cut(tag, "-"); ok {
+			tag = primary
+		}
+		candidates = append(candidates, candidate{locale: tag, quality: quality})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	for _, c := range candidates {
+		if b.Supports(c.locale) {
+			return c.locale
+		}
+	}
+	return b.fallback
+}
+
+// Translate возвращает текст сообщения на языке locale. Если в каталоге языка сообщения нет,
+// берется каталог языка по умолчанию. Второе значение false, если сообщение не найдено нигде.
+func (b *Bundle) Translate(locale, code string, params map[string]interface{}) (string, bool) {
+	locale = strings.ToLower(locale)
+	message, ok := b.catalogs[locale][code]
+	if !ok {
+		locale = b.fallback
+		if message, ok = b.catalogs[locale][code]; !ok {
+			return "", false
+		}
+	}
+
+	text := message.Other
+	if message.Count != "" {
+		if n, ok := toInt(params[message.Count]); ok {
+			text = message.form(PluralCategory(locale, n))
+		}
+	}
+	return format(text, params), true
+}
+
+// form возвращает текст для категории числа, если формы для нее нет - Other
+func (m Message) form(category string) string {
+	var text string
+	switch category {
+	case One:
+		text = m.One
+	case Few:
+		text = m.Few
+	case Many:
+		text = m.Many
+	}
+	if text == "" {
+		return m.Other
+	}
+	return text
+}
+
+// format подставляет параметры вместо {name}
+func format(text string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,36 @@
+package i18n
+
+// Категории чисел по правилам CLDR, используются только целые числа
+const (
+	One   = "one"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// PluralCategory возвращает категорию числа n для языка locale.
+// Для языков без отдельных правил используются правила английского.
+func PluralCategory(locale string, n int64) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch locale {
+	case "ru", "uk", "be":
+		// 1, 21, 101 - one; 2-4, 22-24 - few; 0, 5-20, 25-30 - many
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return One
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return Few
+		default:
+			return Many
+		}
+	default:
+		if n == 1 {
+			return One
+		}
+		return Other
+	}
+}
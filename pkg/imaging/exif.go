@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const exifOrientationTag = 0x0112
+
+// orientation возвращает значение тега Orientation (1-8) из EXIF файла JPEG или WebP.
+// Если тега нет или EXIF поврежден, возвращает 1 - изображение не нужно поворачивать.
+func orientation(data []byte, format string) int {
+	var tiff []byte
+	switch format {
+	case FormatJPEG:
+		tiff = jpegExif(data)
+	case FormatWebP:
+		tiff = webpExif(data)
+	}
+	if o := tiffOrientation(tiff); o >= 1 && o <= 8 {
+		return o
+	}
+	return 1
+}
+
+// jpegExif ищет сегмент APP1 с EXIF среди сегментов до начала сжатых данных
+func jpegExif(data []byte) []byte {
+	exifHeader := []byte("Exif\x00\x00")
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return nil
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xff:
+			// Байт-заполнитель перед маркером
+			i++
+			continue
+		case marker == 0xd8 || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// Маркеры без длины
+			i += 2
+			continue
+		case marker == 0xda || marker == 0xd9:
+			// Дальше идут сжатые данные
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// webpExif возвращает содержимое чанка EXIF расширенного формата WebP
+func webpExif(data []byte) []byte {
+	if len(data) < 12 {
+		return nil
+	}
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+size > len(data) {
+			return nil
+		}
+		if string(data[i:i+4]) == "EXIF" {
+			// Некоторые программы оставляют заголовок из JPEG
+			return bytes.TrimPrefix(data[i+8:i+8+size], []byte("Exif\x00\x00"))
+		}
+		i += 8 + size + size&1
+	}
+	return nil
+}
+
+// tiffOrientation читает тег Orientation из IFD0 блока TIFF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 0
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		// Orientation имеет тип SHORT, значение лежит в начале поля значения
+		if order.Uint16(tiff[entry:]) == exifOrientationTag && order.Uint16(tiff[entry+2:]) == 3 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// orient поворачивает и отражает изображение так, чтобы оно выглядело как задумано при съемке
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // отражение по горизонтали
+				sx, sy = w-1-x, y
+			case 3: // поворот на 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // отражение по вертикали
+				sx, sy = x, h-1-y
+			case 5: // отражение относительно главной диагонали
+				sx, sy = y, x
+			case 6: // поворот на 90° по часовой стрелке
+				sx, sy = y, h-1-x
+			case 7: // отражение относительно побочной диагонали
+				sx, sy = w-1-y, h-1-x
+			case 8: // поворот на 90° против часовой стрелки
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
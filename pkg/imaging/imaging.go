@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// Форматы изображений
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+)
+
+var (
+	// ErrUnsupportedFormat возвращается, если содержимое файла не является изображением поддерживаемого формата
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrTooLarge возвращается, если в изображении больше пикселей, чем разрешено
+	ErrTooLarge = errors.New("image dimensions are too large")
+)
+
+type decoder struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+// decoders сопоставляет MIME-тип, определенный по содержимому файла, с декодером
+var decoders = map[string]struct {
+	format string
+	decoder
+}{
+	"image/jpeg": {FormatJPEG, decoder{jpeg.Decode, jpeg.DecodeConfig}},
+	"image/png":  {FormatPNG, decoder{png.Decode, png.DecodeConfig}},
+	"image/gif":  {FormatGIF, decoder{gif.Decode, gif.DecodeConfig}},
+	"image/webp": {FormatWebP, decoder{webp.Decode, webp.DecodeConfig}},
+}
+
+// Decode читает изображение. Формат определяется по содержимому, а не по имени файла или Content-Type,
+// размеры проверяются до декодирования, чтобы маленький файл не занял гигабайты памяти.
+// Изображение поворачивается по тегу Orientation из EXIF, метаданные в результат не попадают.
+// У GIF используется первый кадр.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	known, ok := decoders[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	cfg, err := known.decodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+
+	img, err := known.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	return orient(img, orientation(data, known.format)), known.format, nil
+}
+
+// CropSize возвращает размеры наибольшей области с соотношением сторон aspectW:aspectH,
+// которая помещается в изображение width x height
+func CropSize(width, height, aspectW, aspectH int) (int, int) {
+	if width*aspectH > height*aspectW {
+		return height * aspectW / aspectH, height
+	}
+	return width, width * aspectH / aspectW
+}
+
+// Thumbnail вырезает из центра изображения область с соотношением сторон width:height
+// и масштабирует ее до width x height
+func Thumbnail(img image.Image, width, height int) *image.NRGBA {
+	b := img.Bounds()
+	cropW, cropH := CropSize(b.Dx(), b.Dy(), width, height)
+	x0 := b.Min.X + (b.Dx()-cropW)/2
+	y0 := b.Min.Y + (b.Dy()-cropH)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, image.Rect(x0, y0, x0+cropW, y0+cropH), xdraw.Src, nil)
+	return dst
+}
+
+// EncodeJPEG записывает изображение в JPEG. Прозрачные области заливаются белым.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+}
+
+// toNRGBA возвращает изображение в виде *image.NRGBA с началом координат в (0, 0)
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) {
+		return nrgba
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,338 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeJPEG(&buf, img, 100); err != nil {
+		t.Fatalf("EncodeJPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// sameColor сравнивает цвета с допуском на потери JPEG
+func sameColor(a, b color.Color, tolerance int) bool {
+	ca := color.NRGBAModel.Convert(a).(color.NRGBA)
+	cb := color.NRGBAModel.Convert(b).(color.NRGBA)
+	for _, d := range []int{int(ca.R) - int(cb.R), int(ca.G) - int(cb.G), int(ca.B) - int(cb.B), int(ca.A) - int(cb.A)} {
+		if d > tolerance || d < -tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecodePNGRoundTrip(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 7; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 30), G: uint8(y * 50), B: uint8(x * y), A: uint8(255 - x*y)})
+		}
+	}
+
+	img, format, err := Decode(encodePNG(t, src), 1000)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != FormatPNG {
+		t.Fatalf("format = %q, want %q", format, FormatPNG)
+	}
+	if img.Bounds() != src.Bounds() {
+		t.Fatalf("bounds = %v, want %v", img.Bounds(), src.Bounds())
+	}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 7; x++ {
+			if !sameColor(img.At(x, y), src.At(x, y), 0) {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, img.At(x, y), src.At(x, y))
+			}
+		}
+	}
+}
+
+func TestDecodeJPEGRoundTrip(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			if x < 16 {
+				src.SetNRGBA(x, y, color.NRGBA{R: 200, G: 40, B: 40, A: 255})
+			}
+			// Правая половина прозрачная и должна стать белой
+		}
+	}
+
+	img, format, err := Decode(encodeJPEG(t, src), 1000)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != FormatJPEG {
+		t.Fatalf("format = %q, want %q", format, FormatJPEG)
+	}
+	if img.Bounds().Dx() != 32 || img.Bounds().Dy() != 16 {
+		t.Fatalf("bounds = %v, want 32x16", img.Bounds())
+	}
+	if c := img.At(8, 8); !sameColor(c, color.NRGBA{R: 200, G: 40, B: 40, A: 255}, 8) {
+		t.Errorf("opaque pixel = %v", c)
+	}
+	if c := img.At(24, 8); !sameColor(c, color.White, 8) {
+		t.Errorf("transparent pixel = %v, want white", c)
+	}
+}
+
+func TestDecodeGIFUsesFirstFrame(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	first := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	second := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	for i := range second.Pix {
+		second.Pix[i] = 1
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{first, second}, Delay: []int{0, 0}}); err != nil {
+		t.Fatalf("gif.EncodeAll: %v", err)
+	}
+
+	img, format, err := Decode(buf.Bytes(), 1000)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if format != FormatGIF {
+		t.Fatalf("format = %q, want %q", format, FormatGIF)
+	}
+	if !sameColor(img.At(0, 0), color.Black, 0) {
+		t.Fatalf("pixel = %v, want the first frame", img.At(0, 0))
+	}
+}
+
+func TestDecodeUnsupportedFormat(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"text":      []byte("definitely not an image"),
+		"empty":     nil,
+		"truncated": encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 4, 4)))[:20],
+	} {
+		if _, _, err := Decode(data, 1000); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedFormat", name, err)
+		}
+	}
+}
+
+func TestDecodePixelLimit(t *testing.T) {
+	data := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 100, 100)))
+
+	if _, _, err := Decode(data, 100*100); err != nil {
+		t.Fatalf("Decode at the limit: %v", err)
+	}
+	if _, _, err := Decode(data, 100*100-1); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Decode over the limit: err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestDecodeRejectsHugeDimensionsBeforeDecoding(t *testing.T) {
+	// Маленький файл с заголовком 60000x60000: при декодировании он занял бы больше 14 ГБ
+	data := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:], 60000)
+	binary.BigEndian.PutUint32(ihdr[8:], 60000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(ihdr))
+
+	if _, _, err := Decode(data, 24*1000*1000); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+// exifTIFF возвращает блок TIFF с единственным тегом Orientation
+func exifTIFF(order binary.ByteOrder, orientation int) []byte {
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+	return tiff
+}
+
+// withJPEGExif вставляет сегмент APP1 с EXIF сразу после SOI
+func withJPEGExif(data, tiff []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, data[2:]...)
+}
+
+// Изображение 3x2 в том виде, в каком оно должно отображаться
+var (
+	cellA = color.NRGBA{R: 255, A: 255}
+	cellB = color.NRGBA{G: 255, A: 255}
+	cellC = color.NRGBA{B: 255, A: 255}
+	cellD = color.NRGBA{R: 255, G: 255, A: 255}
+	cellE = color.NRGBA{G: 255, B: 255, A: 255}
+	cellF = color.NRGBA{R: 255, B: 255, A: 255}
+
+	displayed = [][]color.NRGBA{
+		{cellA, cellB, cellC},
+		{cellD, cellE, cellF},
+	}
+)
+
+// stored - то же изображение так, как его записывает камера с каждым значением Orientation
+var stored = map[int][][]color.NRGBA{
+	1: {{cellA, cellB, cellC}, {cellD, cellE, cellF}},
+	2: {{cellC, cellB, cellA}, {cellF, cellE, cellD}},
+	3: {{cellF, cellE, cellD}, {cellC, cellB, cellA}},
+	4: {{cellD, cellE, cellF}, {cellA, cellB, cellC}},
+	5: {{cellA, cellD}, {cellB, cellE}, {cellC, cellF}},
+	6: {{cellC, cellF}, {cellB, cellE}, {cellA, cellD}},
+	7: {{cellF, cellC}, {cellE, cellB}, {cellD, cellA}},
+	8: {{cellD, cellA}, {cellE, cellB}, {cellF, cellC}},
+}
+
+// gridImage рисует сетку из квадратов со стороной cell пикселей
+func gridImage(grid [][]color.NRGBA, cell int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(grid[0])*cell, len(grid)*cell))
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			img.SetNRGBA(x, y, grid[y/cell][x/cell])
+		}
+	}
+	return img
+}
+
+func checkGrid(t *testing.T, img image.Image, grid [][]color.NRGBA, cell, tolerance int) {
+	t.Helper()
+	b := img.Bounds()
+	if b.Dx() != len(grid[0])*cell || b.Dy() != len(grid)*cell {
+		t.Fatalf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), len(grid[0])*cell, len(grid)*cell)
+	}
+	for row := range grid {
+		for col, want := range grid[row] {
+			got := img.At(b.Min.X+col*cell+cell/2, b.Min.Y+row*cell+cell/2)
+			if !sameColor(got, want, tolerance) {
+				t.Errorf("cell (%d, %d) = %v, want %v", col, row, got, want)
+			}
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	for o := 1; o <= 8; o++ {
+		checkGrid(t, orient(gridImage(stored[o], 1), o), displayed, 1, 0)
+	}
+}
+
+func TestOrientIgnoresInvalidValues(t *testing.T) {
+	src := gridImage(displayed, 1)
+	for _, o := range []int{0, 9, -1} {
+		if got := orient(src, o); got != image.Image(src) {
+			t.Errorf("orient(%d) changed the image", o)
+		}
+	}
+}
+
+func TestDecodeAppliesJPEGOrientation(t *testing.T) {
+	const cell = 16
+	for o := 1; o <= 8; o++ {
+		order := binary.ByteOrder(binary.BigEndian)
+		if o%2 == 0 {
+			order = binary.LittleEndian
+		}
+		data := withJPEGExif(encodeJPEG(t, gridImage(stored[o], cell)), exifTIFF(order, o))
+
+		img, _, err := Decode(data, 1000*1000)
+		if err != nil {
+			t.Fatalf("orientation %d: Decode: %v", o, err)
+		}
+		checkGrid(t, img, displayed, cell, 24)
+	}
+}
+
+func TestOrientationWebP(t *testing.T) {
+	riff := func(chunks ...[]byte) []byte {
+		data := []byte("RIFF\x00\x00\x00\x00WEBP")
+		for _, c := range chunks {
+			data = append(data, c...)
+		}
+		binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+		return data
+	}
+	chunk := func(fourcc string, payload []byte) []byte {
+		c := append([]byte(fourcc), 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(c[4:], uint32(len(payload)))
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+
+	vp8x := chunk("VP8X", make([]byte, 10))
+	odd := chunk("ICCP", []byte{1, 2, 3})
+	for o := 1; o <= 8; o++ {
+		if got := orientation(riff(vp8x, odd, chunk("EXIF", exifTIFF(binary.LittleEndian, o))), FormatWebP); got != o {
+			t.Errorf("orientation = %d, want %d", got, o)
+		}
+	}
+
+	withHeader := append([]byte("Exif\x00\x00"), exifTIFF(binary.BigEndian, 6)...)
+	if got := orientation(riff(vp8x, chunk("EXIF", withHeader)), FormatWebP); got != 6 {
+		t.Errorf("orientation with Exif header = %d, want 6", got)
+	}
+}
+
+func TestOrientationMalformedExif(t *testing.T) {
+	jpeg := encodeJPEG(t, image.NewNRGBA(image.Rect(0, 0, 8, 8)))
+	cases := map[string][]byte{
+		"no exif":        jpeg,
+		"out of range":   withJPEGExif(jpeg, exifTIFF(binary.LittleEndian, 9)),
+		"truncated ifd":  withJPEGExif(jpeg, exifTIFF(binary.LittleEndian, 6)[:16]),
+		"bad byte order": withJPEGExif(jpeg, append([]byte("XX"), exifTIFF(binary.LittleEndian, 6)[2:]...)),
+	}
+	for name, data := range cases {
+		if got := orientation(data, FormatJPEG); got != 1 {
+			t.Errorf("%s: orientation = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestCropSize(t *testing.T) {
+	tests := []struct {
+		w, h, aw, ah, wantW, wantH int
+	}{
+		{1000, 500, 1, 1, 500, 500},
+		{500, 1000, 1, 1, 500, 500},
+		{3000, 2000, 3, 1, 3000, 1000},
+		{1200, 300, 3, 1, 900, 300},
+	}
+	for _, tt := range tests {
+		if w, h := CropSize(tt.w, tt.h, tt.aw, tt.ah); w != tt.wantW || h != tt.wantH {
+			t.Errorf("CropSize(%d, %d, %d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.aw, tt.ah, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
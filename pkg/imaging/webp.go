@@ -0,0 +1,560 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"sort"
+)
+
+// Кодировщик WebP без потерь (VP8L, RFC 9649). Используются преобразования subtract green
+// и predictor, пиксели кодируются кодами Хаффмана, из обратных ссылок - только повторы
+// предыдущего пикселя, кеш цветов не используется.
+// Файлы получаются больше, чем у libwebp, но декодируются всеми браузерами.
+
+const (
+	webpMaxDimension = 1 << 14
+
+	// Размер блока преобразования predictor - 16x16 пикселей
+	predictorBlockBits = 4
+
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+
+	greenAlphabetSize    = 256 + 24 // литералы и префиксы длин обратных ссылок
+	literalAlphabetSize  = 256
+	distanceAlphabetSize = 40
+
+	// Обратные ссылки короче трех пикселей выходят дороже литералов
+	minBackwardLength = 3
+	maxBackwardLength = 4096
+
+	// Расстояние до предыдущего пикселя - код 2 в таблице соседей (смещение на 1 влево),
+	// что после префиксного кодирования дает символ 1 без дополнительных бит
+	previousPixelDistanceSymbol = 1
+)
+
+// ErrTooLargeForWebP возвращается для изображений больше 16384 пикселей по одной из сторон
+var ErrTooLargeForWebP = errors.New("image is too large for WebP")
+
+// Порядок, в котором передаются длины кодов алфавита длин
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Режимы predictor, из которых выбирается лучший для каждого блока
+var predictorModes = []int{1, 2, 7, 12}
+
+// EncodeWebP записывает изображение в формате WebP без потерь
+func EncodeWebP(w io.Writer, img image.Image) error {
+	src := toNRGBA(img)
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width > webpMaxDimension || height > webpMaxDimension {
+		return ErrTooLargeForWebP
+	}
+
+	pixels := make([]uint32, width*height)
+	alphaUsed := false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := src.Pix[src.PixOffset(x, y):]
+			r, g, b, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+			if a != 0xff {
+				alphaUsed = true
+			}
+			// subtract green: красный и синий кодируются как разность с зеленым
+			pixels[y*width+x] = a<<24 | ((r-g)&0xff)<<16 | g<<8 | (b-g)&0xff
+		}
+	}
+	modes, residuals := predict(pixels, width, height)
+
+	bw := &bitWriter{}
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	if alphaUsed {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+	bw.writeBits(0, 3) // версия
+
+	// Преобразования перечисляются в порядке применения, декодер отменяет их в обратном
+	bw.writeBits(1, 1)
+	bw.writeBits(2, 2) // subtract green
+	bw.writeBits(1, 1)
+	bw.writeBits(0, 2) // predictor
+	bw.writeBits(predictorBlockBits-2, 3)
+	writeEntropyImage(bw, modes, false)
+	bw.writeBits(0, 1)
+
+	writeEntropyImage(bw, residuals, true)
+	data := bw.bytes()
+
+	padded := len(data) + len(data)&1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// predict выбирает для каждого блока режим предсказания с наименьшими остатками
+// и возвращает изображение режимов и остатки предсказания
+func predict(pixels []uint32, width, height int) ([]uint32, []uint32) {
+	blockSize := 1 << predictorBlockBits
+	tilesX := (width + blockSize - 1) >> predictorBlockBits
+	tilesY := (height + blockSize - 1) >> predictorBlockBits
+	modes := make([]uint32, tilesX*tilesY)
+	residuals := make([]uint32, len(pixels))
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, y0 := tx*blockSize, ty*blockSize
+			x1, y1 := minInt(x0+blockSize, width), minInt(y0+blockSize, height)
+
+			best, bestCost := predictorModes[0], -1
+			for _, mode := range predictorModes {
+				cost := 0
+				for y := y0; y < y1; y++ {
+					for x := x0; x < x1; x++ {
+						cost += residualCost(sub(pixels[y*width+x], predictPixel(pixels, width, x, y, mode)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					best, bestCost = mode, cost
+				}
+			}
+
+			modes[ty*tilesX+tx] = 0xff000000 | uint32(best)<<8
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					residuals[y*width+x] = sub(pixels[y*width+x], predictPixel(pixels, width, x, y, best))
+				}
+			}
+		}
+	}
+	return modes, residuals
+}
+
+// predictPixel предсказывает пиксель по уже декодированным соседям.
+// Для первой строки и первого столбца режим блока не используется.
+func predictPixel(pixels []uint32, width, x, y, mode int) uint32 {
+	switch {
+	case x == 0 && y == 0:
+		return 0xff000000
+	case y == 0:
+		return pixels[x-1]
+	case x == 0:
+		return pixels[(y-1)*width]
+	}
+
+	l := pixels[y*width+x-1]
+	t := pixels[(y-1)*width+x]
+	tl := pixels[(y-1)*width+x-1]
+	switch mode {
+	case 1:
+		return l
+	case 2:
+		return t
+	case 7:
+		return average2(l, t)
+	default: // 12
+		return clampAddSubtractFull(l, t, tl)
+	}
+}
+
+func average2(a, b uint32) uint32 {
+	var res uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		res |= ((a>>shift&0xff + b>>shift&0xff) / 2) << shift
+	}
+	return res
+}
+
+func clampAddSubtractFull(a, b, c uint32) uint32 {
+	var res uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		v := int(a>>shift&0xff) + int(b>>shift&0xff) - int(c>>shift&0xff)
+		if v < 0 {
+			v = 0
+		} else if v > 0xff {
+			v = 0xff
+		}
+		res |= uint32(v) << shift
+	}
+	return res
+}
+
+// sub вычитает предсказание по каналам по модулю 256
+func sub(a, b uint32) uint32 {
+	var res uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		res |= ((a>>shift - b>>shift) & 0xff) << shift
+	}
+	return res
+}
+
+// residualCost оценивает, сколько места займет остаток: чем ближе к нулю каналы, тем лучше
+func residualCost(p uint32) int {
+	cost := 0
+	for shift := uint(0); shift < 32; shift += 8 {
+		v := int(int8(p >> shift))
+		if v < 0 {
+			v = -v
+		}
+		cost += v
+	}
+	return cost
+}
+
+// writeEntropyImage записывает изображение. Повторы предыдущего пикселя кодируются обратной ссылкой
+// на расстояние 1, остальные пиксели - литералами. Для основного изображения дополнительно
+// передается признак отсутствия мета-кодов.
+func writeEntropyImage(bw *bitWriter, pixels []uint32, main bool) {
+	bw.writeBits(0, 1) // без кеша цветов
+	if main {
+		bw.writeBits(0, 1) // один набор кодов на все изображение
+	}
+
+	// Серия из length пикселей, повторяющих предыдущий; для литерала length = 0
+	type token struct {
+		pixel  uint32
+		length int
+	}
+	var tokens []token
+	for i := 0; i < len(pixels); {
+		run := 0
+		for i > 0 && i+run < len(pixels) && run < maxBackwardLength && pixels[i+run] == pixels[i-1] {
+			run++
+		}
+		if run >= minBackwardLength {
+			tokens = append(tokens, token{length: run})
+			i += run
+			continue
+		}
+		tokens = append(tokens, token{pixel: pixels[i]})
+		i++
+	}
+
+	green := make([]uint32, greenAlphabetSize)
+	red := make([]uint32, literalAlphabetSize)
+	blue := make([]uint32, literalAlphabetSize)
+	alpha := make([]uint32, literalAlphabetSize)
+	distance := make([]uint32, distanceAlphabetSize)
+	for _, t := range tokens {
+		if t.length > 0 {
+			symbol, _, _ := prefixEncode(t.length)
+			green[literalAlphabetSize+symbol]++
+			distance[previousPixelDistanceSymbol]++
+			continue
+		}
+		p := t.pixel
+		green[p>>8&0xff]++
+		red[p>>16&0xff]++
+		blue[p&0xff]++
+		alpha[p>>24]++
+	}
+
+	codes := []prefixCode{
+		buildPrefixCode(green, maxCodeLength),
+		buildPrefixCode(red, maxCodeLength),
+		buildPrefixCode(blue, maxCodeLength),
+		buildPrefixCode(alpha, maxCodeLength),
+		buildPrefixCode(distance, maxCodeLength),
+	}
+	for _, code := range codes {
+		writePrefixCode(bw, code)
+	}
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			symbol, extraBits, extra := prefixEncode(t.length)
+			codes[0].write(bw, literalAlphabetSize+symbol)
+			bw.writeBits(uint32(extra), extraBits)
+			codes[4].write(bw, previousPixelDistanceSymbol)
+			continue
+		}
+		p := t.pixel
+		codes[0].write(bw, int(p>>8&0xff))
+		codes[1].write(bw, int(p>>16&0xff))
+		codes[2].write(bw, int(p&0xff))
+		codes[3].write(bw, int(p>>24))
+	}
+}
+
+// prefixEncode кодирует длину или расстояние обратной ссылки (value >= 1): префикс
+// и младшие биты, которые передаются после него как есть
+func prefixEncode(value int) (int, uint, int) {
+	d := value - 1
+	if d < 4 {
+		return d, 0, 0
+	}
+	highBit := 0
+	for d>>(highBit+1) != 0 {
+		highBit++
+	}
+	second := d >> (highBit - 1) & 1
+	extraBits := uint(highBit - 1)
+	return 2*highBit + second, extraBits, d & (1<<extraBits - 1)
+}
+
+// prefixCode - канонический код Хаффмана. Если используется не больше одного символа,
+// код передается в простой форме и символы занимают 0 бит.
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16 // коды с обратным порядком бит, поток пишется начиная с младших бит
+	single  int      // единственный символ, -1 для обычного кода
+}
+
+func (c prefixCode) write(bw *bitWriter, symbol int) {
+	if c.single < 0 {
+		bw.writeBits(uint32(c.codes[symbol]), uint(c.lengths[symbol]))
+	}
+}
+
+// buildPrefixCode строит код Хаффмана с длиной кодов не больше maxLength
+func buildPrefixCode(histogram []uint32, maxLength int) prefixCode {
+	used := 0
+	single := 0
+	for symbol, count := range histogram {
+		if count > 0 {
+			used++
+			single = symbol
+		}
+	}
+	if used <= 1 {
+		return prefixCode{single: single}
+	}
+
+	counts := append([]uint32(nil), histogram...)
+	lengths := huffmanLengths(counts)
+	for maxOf(lengths) > maxLength {
+		// Уменьшаем разброс частот, пока дерево не станет достаточно низким
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+		lengths = huffmanLengths(counts)
+	}
+
+	// Канонические коды в порядке длины, при равной длине - в порядке символов (как в DEFLATE)
+	var lengthCount [maxCodeLength + 1]int
+	for _, l := range lengths {
+		lengthCount[l]++
+	}
+	lengthCount[0] = 0
+	var next [maxCodeLength + 2]int
+	code := 0
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + lengthCount[l-1]) << 1
+		next[l] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for symbol, l := range lengths {
+		if l > 0 {
+			codes[symbol] = reverseBits(uint16(next[l]), int(l))
+			next[l]++
+		}
+	}
+	return prefixCode{lengths: lengths, codes: codes, single: -1}
+}
+
+// huffmanLengths вычисляет длины кодов Хаффмана для символов с ненулевой частотой
+func huffmanLengths(counts []uint32) []uint8 {
+	type node struct {
+		count  uint64
+		parent int
+	}
+
+	var leaves []int
+	for symbol, count := range counts {
+		if count > 0 {
+			leaves = append(leaves, symbol)
+		}
+	}
+	sort.SliceStable(leaves, func(i, j int) bool { return counts[leaves[i]] < counts[leaves[j]] })
+
+	// Два упорядоченных списка: листья по возрастанию частоты и внутренние узлы в порядке создания
+	nodes := make([]node, 0, 2*len(leaves))
+	for _, symbol := range leaves {
+		nodes = append(nodes, node{count: uint64(counts[symbol]), parent: -1})
+	}
+	nextLeaf, nextInner := 0, len(leaves)
+	pick := func() int {
+		if nextLeaf < len(leaves) && (nextInner >= len(nodes) || nodes[nextLeaf].count <= nodes[nextInner].count) {
+			nextLeaf++
+			return nextLeaf - 1
+		}
+		nextInner++
+		return nextInner - 1
+	}
+	for i := 0; i < len(leaves)-1; i++ {
+		a, b := pick(), pick()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+
+	depth := make([]int, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depth[i] = depth[nodes[i].parent] + 1
+	}
+
+	lengths := make([]uint8, len(counts))
+	for i, symbol := range leaves {
+		lengths[symbol] = uint8(depth[i])
+	}
+	return lengths
+}
+
+// writePrefixCode передает код: простой для одного символа или длины кодов, сжатые кодом длин
+func writePrefixCode(bw *bitWriter, code prefixCode) {
+	if code.single >= 0 {
+		bw.writeBits(1, 1) // простой код
+		bw.writeBits(0, 1) // один символ
+		if code.single < 2 {
+			bw.writeBits(0, 1)
+			bw.writeBits(uint32(code.single), 1)
+		} else {
+			bw.writeBits(1, 1)
+			bw.writeBits(uint32(code.single), 8)
+		}
+		return
+	}
+	bw.writeBits(0, 1) // обычный код
+
+	// Серии нулевых длин сворачиваются символами 17 (3-10 нулей) и 18 (11-138 нулей)
+	type token struct {
+		symbol int
+		extra  uint32
+	}
+	var tokens []token
+	for i := 0; i < len(code.lengths); {
+		if code.lengths[i] != 0 {
+			tokens = append(tokens, token{symbol: int(code.lengths[i])})
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(code.lengths) && code.lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run > 0 {
+			switch {
+			case run < 3:
+				tokens = append(tokens, token{symbol: 0})
+				run--
+			case run <= 10:
+				tokens = append(tokens, token{symbol: 17, extra: uint32(run - 3)})
+				run = 0
+			default:
+				n := minInt(run, 138)
+				tokens = append(tokens, token{symbol: 18, extra: uint32(n - 11)})
+				run -= n
+			}
+		}
+	}
+
+	histogram := make([]uint32, len(codeLengthCodeOrder))
+	for _, t := range tokens {
+		histogram[t.symbol]++
+	}
+	// Коду длин нужно хотя бы два символа, иначе он вырождается в простой код, который здесь недопустим
+	used := 0
+	for _, count := range histogram {
+		if count > 0 {
+			used++
+		}
+	}
+	if used == 1 {
+		if histogram[0] == 0 {
+			histogram[0] = 1
+		} else {
+			histogram[1] = 1
+		}
+	}
+	lengthCode := buildPrefixCode(histogram, maxCodeLengthCodeLength)
+
+	count := len(codeLengthCodeOrder)
+	for count > 4 && lengthCode.lengths[codeLengthCodeOrder[count-1]] == 0 {
+		count--
+	}
+	bw.writeBits(uint32(count-4), 4)
+	for _, symbol := range codeLengthCodeOrder[:count] {
+		bw.writeBits(uint32(lengthCode.lengths[symbol]), 3)
+	}
+
+	bw.writeBits(0, 1) // длины переданы для всего алфавита
+	for _, t := range tokens {
+		lengthCode.write(bw, t.symbol)
+		switch t.symbol {
+		case 17:
+			bw.writeBits(t.extra, 3)
+		case 18:
+			bw.writeBits(t.extra, 7)
+		}
+	}
+}
+
+func reverseBits(code uint16, length int) uint16 {
+	var res uint16
+	for i := 0; i < length; i++ {
+		res = res<<1 | code&1
+		code >>= 1
+	}
+	return res
+}
+
+func maxOf(lengths []uint8) int {
+	res := 0
+	for _, l := range lengths {
+		if int(l) > res {
+			res = int(l)
+		}
+	}
+	return res
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// bitWriter пишет биты начиная с младших, как требует формат VP8L
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (w *bitWriter) writeBits(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nacc
+	w.nacc += n
+	for w.nacc >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nacc -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nacc > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nacc = 0, 0
+	}
+	return w.buf
+}
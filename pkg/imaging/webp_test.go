@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// webpRoundTrip кодирует изображение в WebP и декодирует его обратно, сравнивая все пиксели
+func webpRoundTrip(t *testing.T, name string, img *image.NRGBA) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, img); err != nil {
+		t.Fatalf("%s: EncodeWebP: %v", name, err)
+	}
+
+	decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("%s: webp.Decode: %v", name, err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Fatalf("%s: bounds = %v, want %v", name, decoded.Bounds(), img.Bounds())
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+			if want := img.NRGBAAt(x, y); got != want {
+				t.Fatalf("%s: pixel (%d, %d) = %v, want %v", name, x, y, got, want)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestEncodeWebPLossless(t *testing.T) {
+	fill := func(w, h int, f func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.SetNRGBA(x, y, f(x, y))
+			}
+		}
+		return img
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := map[string]*image.NRGBA{
+		"single pixel": fill(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{10, 200, 30, 255} }),
+		// Все пиксели одинаковые: коды из одного символа и серии длиннее maxBackwardLength
+		"solid": fill(120, 90, func(x, y int) color.NRGBA { return color.NRGBA{200, 100, 50, 255} }),
+		// Размеры не кратны блоку predictor
+		"gradient": fill(37, 23, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 7), uint8(y * 11), uint8(x*y + 3), 255}
+		}),
+		"transparent": fill(20, 17, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 13), 80, uint8(y * 15), uint8((x + y) * 7)}
+		}),
+		// Шум использует почти все символы алфавитов и длинные коды
+		"noise": fill(64, 48, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256))}
+		}),
+		"stripes": fill(50, 40, func(x, y int) color.NRGBA {
+			if (x/5+y/3)%2 == 0 {
+				return color.NRGBA{0, 0, 0, 255}
+			}
+			return color.NRGBA{255, 255, 255, 255}
+		}),
+	}
+	for name, img := range tests {
+		webpRoundTrip(t, name, img)
+	}
+}
+
+func TestBuildPrefixCodeLimitsLength(t *testing.T) {
+	// Частоты растут как числа Фибоначчи, и без ограничения коды были бы длиннее 15 бит
+	histogram := make([]uint32, literalAlphabetSize)
+	a, b := uint32(1), uint32(1)
+	for i := 0; i < 25; i++ {
+		histogram[i*10] = a
+		a, b = b, a+b
+	}
+	if lengths := huffmanLengths(histogram); maxOf(lengths) <= maxCodeLength {
+		t.Fatalf("unlimited max length = %d, the test needs a deeper tree", maxOf(lengths))
+	}
+
+	code := buildPrefixCode(histogram, maxCodeLength)
+	if got := maxOf(code.lengths); got > maxCodeLength {
+		t.Fatalf("max length = %d, want at most %d", got, maxCodeLength)
+	}
+	// Код полный: сумма 2^-длина по всем символам равна единице
+	kraft := 0
+	for symbol, l := range code.lengths {
+		if (l == 0) != (histogram[symbol] == 0) {
+			t.Fatalf("symbol %d: length %d for count %d", symbol, l, histogram[symbol])
+		}
+		if l > 0 {
+			kraft += 1 << uint(maxCodeLength-int(l))
+		}
+	}
+	if kraft != 1<<maxCodeLength {
+		t.Fatalf("Kraft sum = %d/%d, want 1", kraft, 1<<maxCodeLength)
+	}
+}
+
+func TestEncodeWebPContainer(t *testing.T) {
+	// Данные VP8L выравниваются до четной длины, размер RIFF учитывает выравнивание
+	data := webpRoundTrip(t, "container", image.NewNRGBA(image.Rect(0, 0, 3, 5)))
+	if string(data[:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		t.Fatalf("header = %q", data[:16])
+	}
+	if len(data)%2 != 0 {
+		t.Fatalf("file length %d is odd", len(data))
+	}
+
+	if _, format, err := Decode(data, 100); err != nil || format != FormatWebP {
+		t.Fatalf("Decode: format = %q, err = %v", format, err)
+	}
+}
+
+func TestEncodeWebPTooLarge(t *testing.T) {
+	img := &image.NRGBA{Rect: image.Rect(0, 0, webpMaxDimension+1, 1)}
+	img.Pix = make([]uint8, 4*img.Rect.Dx())
+	img.Stride = 4 * img.Rect.Dx()
+	if err := EncodeWebP(&bytes.Buffer{}, img); err != ErrTooLargeForWebP {
+		t.Fatalf("err = %v, want ErrTooLargeForWebP", err)
+	}
+}
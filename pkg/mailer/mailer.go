@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message - письмо для отправки пользователю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer определяет способ доставки писем
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format собирает письмо в формате RFC 5322
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
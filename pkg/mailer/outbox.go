@@ -0,0 +1,73 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileOutbox складывает письма в каталог в виде .eml файлов вместо отправки.
+// Используется при локальной разработке.
+type FileOutbox struct {
+	dir  string
+	from string
+}
+
+// NewFileOutbox создает новый экземпляр FileOutbox, каталог создается при необходимости
+func NewFileOutbox(dir, from string) (*FileOutbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %v", err)
+	}
+
+	return &FileOutbox{dir: dir, from: from}, nil
+}
+
+func (o *FileOutbox) Send(msg Message) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), filepath.Base(msg.To))
+	if err := os.WriteFile(filepath.Join(o.dir, name), format(o.from, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write email to outbox: %v", err)
+	}
+	return nil
+}
+
+// MemoryOutbox хранит письма в памяти, чтобы их можно было прочитать в тестах
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryOutbox создает новый экземпляр MemoryOutbox
+func NewMemoryOutbox() *MemoryOutbox {
+	return &MemoryOutbox{}
+}
+
+func (o *MemoryOutbox) Send(msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+// Messages возвращает копию всех отправленных писем
+func (o *MemoryOutbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
+
+// Last возвращает последнее письмо, отправленное на адрес to
+func (o *MemoryOutbox) Last(to string) (Message, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To == to {
+			return o.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// SMTPMailer отправляет письма через SMTP-сервер
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer создает новый экземпляр SMTPMailer. Если user пустой, аутентификация не выполняется.
+func NewSMTPMailer(host string, port int, user, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	return &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", host, port),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, format(m.from, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
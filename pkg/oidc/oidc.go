@@ -0,0 +1,354 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Saveliy12/prod2/pkg/pkce"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// Допустимое расхождение часов с провайдером при проверке exp и iat
+	clockSkew = time.Minute
+	// Ключи провайдера перечитываются не чаще раза в минуту, даже если встретился неизвестный kid
+	minKeysRefresh = time.Minute
+	keysTTL        = time.Hour
+)
+
+// ErrInvalidIDToken возвращается, если ID-токен не прошел проверку
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+// Config - настройки клиента у провайдера OpenID Connect
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // по умолчанию openid email profile
+}
+
+// Metadata - сведения о провайдере из /.well-known/openid-configuration
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token - ответ token endpoint провайдера
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// IDToken - проверенные сведения о пользователе из ID-токена
+type IDToken struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider - клиент одного провайдера OpenID Connect.
+// Discovery выполняется при первом обращении, поэтому недоступность провайдера не мешает запуску сервиса.
+type Provider struct {
+	config     Config
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// NewProvider создает клиент провайдера. httpClient может быть nil.
+func NewProvider(config Config, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{config: config, httpClient: httpClient}
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера для authorization code flow с PKCE
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {pkce.Challenge(codeVerifier)},
+		"code_challenge_method": {pkce.MethodS256},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange обменивает код авторизации на токены провайдера
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (Token, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	var token Token
+	if err := p.do(req, &token); err != nil {
+		return Token{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	if token.IDToken == "" {
+		return Token{}, errors.New("provider did not return an id token")
+	}
+	return token, nil
+}
+
+// VerifyIDToken проверяет подпись ID-токена ключами провайдера, издателя, получателя, срок действия и nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (IDToken, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	var claims idTokenClaims
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, token)
+	})
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	switch {
+	case claims.Issuer != metadata.Issuer:
+		return IDToken{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	case !claims.Audience.contains(p.config.ClientID):
+		return IDToken{}, fmt.Errorf("%w: token is issued for another client", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+		return IDToken{}, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return IDToken{}, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return IDToken{}, fmt.Errorf("%w: token has no subject", ErrInvalidIDToken)
+	}
+
+	return IDToken{
+		Issuer:            claims.Issuer,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     bool(claims.EmailVerified),
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+// discover загружает и кеширует сведения о провайдере
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata Metadata
+	if err := p.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+
+	// Издатель в документе должен совпадать с настроенным (OpenID Connect Discovery, раздел 4.3)
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc provider issuer mismatch: %q", metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("oidc provider metadata is incomplete")
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// verificationKey выбирает ключ провайдера по kid. Провайдеры меняют ключи,
+// поэтому неизвестный kid приводит к повторной загрузке набора ключей.
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+	sinceFetch := time.Since(p.keysFetchedAt)
+	if (!ok && sinceFetch >= minKeysRefresh) || sinceFetch > keysTTL {
+		// Если провайдер недоступен, продолжаем проверять уже известными ключами
+		if err := p.fetchKeys(ctx); err != nil && !ok {
+			return nil, err
+		}
+		key, ok = p.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %v", kid)
+	}
+
+	if !methodMatchesKey(token.Method, key) {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key, nil
+}
+
+// fetchKeys загружает набор ключей провайдера. Вызывается под p.mu.
+func (p *Provider) fetchKeys(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadata.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.do(req, &set); err != nil {
+		return fmt.Errorf("failed to fetch oidc provider keys: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// Ключи неподдерживаемых типов пропускаются, токены ими не проверить
+			continue
+		}
+		keys[k.KeyID] = key
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return nil
+}
+
+// do выполняет запрос и разбирает JSON-ответ
+func (p *Provider) do(req *http.Request, v interface{}) error {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// idTokenClaims - содержимое ID-токена (OpenID Connect Core, раздел 2)
+type idTokenClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          audience     `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	ExpiresAt         int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     flexibleBool `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// Valid проверяет срок действия с учетом расхождения часов
+func (c *idTokenClaims) Valid() error {
+	now := time.Now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("token is expired")
+	}
+	if now.Add(clockSkew).Before(time.Unix(c.IssuedAt, 0)) {
+		return errors.New("token is issued in the future")
+	}
+	return nil
+}
+
+// audience - claim aud, который может быть строкой или массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexibleBool принимает true и "true": некоторые провайдеры передают email_verified строкой
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
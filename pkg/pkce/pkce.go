@@ -0,0 +1,56 @@
+package pkce
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// MethodS256 - единственный поддерживаемый способ преобразования (RFC 7636, OAuth 2.1 запрещает plain)
+const MethodS256 = "S256"
+
+// NewVerifier генерирует code_verifier: 43 символа из алфавита base64url
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge возвращает code_challenge для code_verifier по способу S256
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Verify проверяет, что code_verifier соответствует code_challenge
+func Verify(verifier, challenge string) bool {
+	if !ValidVerifier(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(Challenge(verifier)), []byte(challenge)) == 1
+}
+
+// ValidVerifier проверяет длину (43-128) и алфавит code_verifier
+func ValidVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, r := range verifier {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidChallenge проверяет формат code_challenge для способа S256
+func ValidChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(decoded) == sha256.Size
+}
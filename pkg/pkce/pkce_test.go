@@ -0,0 +1,69 @@
+package pkce
+
+import (
+	"strings"
+	"testing"
+)
+
+// Пример из RFC 7636, приложение B
+const (
+	rfcVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	rfcChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestChallengeRFC7636(t *testing.T) {
+	if got := Challenge(rfcVerifier); got != rfcChallenge {
+		t.Fatalf("Challenge = %s, want %s", got, rfcChallenge)
+	}
+	if !Verify(rfcVerifier, rfcChallenge) {
+		t.Fatal("Verify rejected the RFC example")
+	}
+	if !ValidChallenge(rfcChallenge) {
+		t.Fatal("ValidChallenge rejected the RFC example")
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := map[string]struct{ verifier, challenge string }{
+		"other verifier":     {strings.Replace(rfcVerifier, "d", "e", 1), rfcChallenge},
+		"other challenge":    {rfcVerifier, Challenge(rfcVerifier + "x")},
+		"plain method":       {rfcVerifier, rfcVerifier},
+		"short verifier":     {rfcVerifier[:42], Challenge(rfcVerifier[:42])},
+		"long verifier":      {strings.Repeat("a", 129), Challenge(strings.Repeat("a", 129))},
+		"invalid characters": {rfcVerifier[:42] + "+", Challenge(rfcVerifier[:42] + "+")},
+		"padded challenge":   {rfcVerifier, rfcChallenge + "="},
+		"empty challenge":    {rfcVerifier, ""},
+	}
+	for name, tt := range tests {
+		if Verify(tt.verifier, tt.challenge) {
+			t.Errorf("%s: Verify = true", name)
+		}
+	}
+
+	for _, verifier := range []string{strings.Repeat("a", 43), strings.Repeat("Z", 128), strings.Repeat("-._~", 11)} {
+		if !Verify(verifier, Challenge(verifier)) {
+			t.Errorf("Verify rejected a valid verifier %q", verifier)
+		}
+	}
+}
+
+func TestValidChallenge(t *testing.T) {
+	for _, challenge := range []string{"", rfcChallenge[:42], rfcChallenge + "A", rfcChallenge + "=", "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM"} {
+		if ValidChallenge(challenge) {
+			t.Errorf("ValidChallenge(%q) = true", challenge)
+		}
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	verifier, err := NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verifier) != 43 || !ValidVerifier(verifier) {
+		t.Fatalf("NewVerifier = %q, want a valid 43 character verifier", verifier)
+	}
+	if other, _ := NewVerifier(); other == verifier {
+		t.Fatal("NewVerifier returned the same verifier twice")
+	}
+}
@@ -0,0 +1,63 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPSender отправляет SMS через HTTP API шлюза: POST с JSON {"from", "to", "text"}
+// и токеном в заголовке Authorization. Под API конкретного провайдера обычно
+// достаточно небольшого прокси, принимающего такой запрос.
+type HTTPSender struct {
+	url        string
+	token      string
+	from       string
+	httpClient *http.Client
+}
+
+// NewHTTPSender создает новый экземпляр HTTPSender. Если token пустой, заголовок Authorization не передается.
+func NewHTTPSender(url, token, from string) *HTTPSender {
+	return &HTTPSender{
+		url:        url,
+		token:      token,
+		from:       from,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSender) Send(msg Message) error {
+	body, err := json.Marshal(struct {
+		From string `json:"from,omitempty"`
+		To   string `json:"to"`
+		Text string `json:"text"`
+	}{s.from, msg.To, msg.Text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send sms: gateway returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
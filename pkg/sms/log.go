@@ -0,0 +1,64 @@
+package sms
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Saveliy12/prod2/pkg/logger"
+)
+
+// LogSender пишет SMS в лог вместо отправки.
+// Используется при локальной разработке.
+type LogSender struct {
+	log logger.LoggerInterface
+}
+
+// NewLogSender создает новый экземпляр LogSender
+func NewLogSender(log logger.LoggerInterface) *LogSender {
+	return &LogSender{log: log}
+}
+
+func (s *LogSender) Send(msg Message) error {
+	s.log.Info(fmt.Sprintf("SMS to %s: %s", msg.To, msg.Text))
+	return nil
+}
+
+// MemorySender хранит SMS в памяти, чтобы их можно было прочитать в тестах
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySender создает новый экземпляр MemorySender
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages возвращает копию всех отправленных SMS
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Last возвращает последнее SMS, отправленное на номер to
+func (s *MemorySender) Last(to string) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].To == to {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,12 @@
+package sms
+
+// Message - SMS для отправки пользователю
+type Message struct {
+	To   string // номер в формате E.164
+	Text string
+}
+
+// SMSSender определяет способ доставки SMS
+type SMSSender interface {
+	Send(msg Message) error
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStorage хранит файлы в каталоге на диске. Подходит для одного экземпляра сервиса
+// или для каталога, общего для всех экземпляров. Файлы раздает сам сервис по ссылкам,
+// подписанным signer.
+type LocalStorage struct {
+	root   string
+	signer *URLSigner
+}
+
+// NewLocalStorage создает новый экземпляр LocalStorage, каталог создается при необходимости
+func NewLocalStorage(root string, signer *URLSigner) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	return &LocalStorage{root: root, signer: signer}, nil
+}
+
+// Put сохраняет файл. Запись идет во временный файл, поэтому читатели не увидят файл записанным наполовину.
+func (s *LocalStorage) Put(key string, r io.Reader, contentType string) error {
+	filename, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	return nil
+}
+
+// Get открывает файл для чтения или возвращает ErrNotFound
+func (s *LocalStorage) Get(key string) (io.ReadCloser, Object, error) {
+	filename, err := s.path(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	f, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Object{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Object{}, fmt.Errorf("failed to get object: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, Object{}, fmt.Errorf("failed to get object: %v", err)
+	}
+	return f, fileObject(key, info), nil
+}
+
+// Stat возвращает сведения о файле или ErrNotFound
+func (s *LocalStorage) Stat(key string) (Object, error) {
+	filename, err := s.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	info, err := os.Stat(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, ErrNotFound
+	}
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat object: %v", err)
+	}
+	return fileObject(key, info), nil
+}
+
+// Delete удаляет файл. Удаление несуществующего файла не считается ошибкой.
+func (s *LocalStorage) Delete(key string) error {
+	filename, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	return nil
+}
+
+// PresignGet возвращает подписанную ссылку на обработчик, который раздает файлы хранилища
+func (s *LocalStorage) PresignGet(key string, ttl time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return s.signer.Sign(key, time.Now().Add(ttl)), nil
+}
+
+func fileObject(key string, info fs.FileInfo) Object {
+	return Object{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  contentTypeByKey(key),
+		LastModified: info.ModTime(),
+	}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
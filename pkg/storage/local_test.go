@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) (*LocalStorage, *URLSigner, string) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "media")
+	signer := NewURLSigner([]byte("secret"), "/media")
+	s, err := NewLocalStorage(root, signer)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	return s, signer, root
+}
+
+func readObject(t *testing.T, s Storage, key string) (string, Object) {
+	t.Helper()
+	r, obj, err := s.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %q: %v", key, err)
+	}
+	return string(data), obj
+}
+
+func TestLocalStoragePutGetStatDelete(t *testing.T) {
+	s, _, root := newTestLocalStorage(t)
+	key := "avatars/ab/cdef.jpg"
+
+	if err := s.Put(key, strings.NewReader("first"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s.Put(key, strings.NewReader("second"), "image/jpeg"); err != nil {
+		t.Fatalf("Put again: %v", err)
+	}
+
+	data, obj := readObject(t, s, key)
+	if data != "second" {
+		t.Fatalf("content = %q, want %q", data, "second")
+	}
+	if obj.Key != key || obj.Size != 6 || obj.ContentType != "image/jpeg" || obj.LastModified.IsZero() {
+		t.Fatalf("object = %+v", obj)
+	}
+
+	stat, err := s.Stat(key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat != obj {
+		t.Fatalf("Stat = %+v, want %+v", stat, obj)
+	}
+
+	// Временные файлы не остаются рядом с сохраненным
+	entries, err := os.ReadDir(filepath.Join(root, "avatars", "ab"))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("directory has %d entries, want 1", len(entries))
+	}
+
+	if err := s.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(key); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+	if _, _, err := s.Get(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat(key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Stat after Delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalStorageRejectsInvalidKeys(t *testing.T) {
+	s, _, root := newTestLocalStorage(t)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(root), "secret.txt"), []byte("secret"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	for _, key := range []string{"", "../secret.txt", "/etc/passwd", "a/../../secret.txt", "a//b", "a/./b", "a\\b", "a/"} {
+		if err := s.Put(key, strings.NewReader("x"), "text/plain"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+		if _, _, err := s.Get(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.Stat(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Stat(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+		if err := s.Delete(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := s.PresignGet(key, time.Hour); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("PresignGet(%q): err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalStoragePresignGet(t *testing.T) {
+	s, signer, _ := newTestLocalStorage(t)
+	key := "covers/12/3456.jpg"
+
+	signed, err := s.PresignGet(key, time.Hour)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	gotKey, expires, signature := parseSigned(t, signed, "/media")
+	if gotKey != key {
+		t.Fatalf("key = %q, want %q", gotKey, key)
+	}
+	if err := signer.Verify(gotKey, expires, signature, time.Now()); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := signer.Verify(gotKey, expires, signature, time.Now().Add(2*time.Hour)); !errors.Is(err, ErrURLExpired) {
+		t.Fatalf("Verify after ttl: err = %v, want ErrURLExpired", err)
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	a := ContentKey("avatars", []byte("image"), "jpg")
+	if a != ContentKey("avatars", []byte("image"), "jpg") {
+		t.Fatal("ContentKey is not deterministic")
+	}
+	if a == ContentKey("avatars", []byte("other"), "jpg") {
+		t.Fatal("different content got the same key")
+	}
+	if err := ValidateKey(a); err != nil {
+		t.Fatalf("ValidateKey(%q): %v", a, err)
+	}
+	// sha256("image") = 6105d6cc...
+	if !strings.HasPrefix(a, "avatars/61/05d6cc") || !strings.HasSuffix(a, ".jpg") {
+		t.Fatalf("key = %q", a)
+	}
+}
@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3DateFormat      = "20060102T150405Z"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// Наибольший срок действия подписанной ссылки, который допускает S3
+	s3MaxPresignTTL = 7 * 24 * time.Hour
+)
+
+// S3Config - параметры подключения к S3-совместимому хранилищу
+type S3Config struct {
+	// Endpoint - адрес сервиса, например https://s3.eu-central-1.amazonaws.com или http://localhost:9000
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle включает адреса вида endpoint/bucket/key вместо bucket.endpoint/key. Нужен для MinIO
+	// и других серверов без DNS-имен для бакетов.
+	PathStyle bool
+}
+
+// S3Storage хранит файлы в бакете S3-совместимого хранилища. Запросы подписываются по AWS Signature Version 4,
+// клиенты скачивают файлы напрямую из хранилища по подписанным ссылкам.
+type S3Storage struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Storage создает новый экземпляр S3Storage
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" {
+		return nil, errors.New("s3 bucket and region are required")
+	}
+	return &S3Storage{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Put сохраняет файл. Содержимое читается в память целиком: для подписи нужен его хеш.
+func (s *S3Storage) Put(key string, r io.Reader, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	req, err := s.newRequest(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req, data)
+	if err != nil {
+		return fmt.Errorf("failed to put object: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to put object: %v", s3ResponseError(resp))
+	}
+	return nil
+}
+
+// Get открывает файл для чтения или возвращает ErrNotFound
+func (s *S3Storage) Get(key string) (io.ReadCloser, Object, error) {
+	req, err := s.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return nil, Object{}, fmt.Errorf("failed to get object: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, responseObject(key, resp), nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, Object{}, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, Object{}, fmt.Errorf("failed to get object: %v", s3ResponseError(resp))
+	}
+}
+
+// Stat возвращает сведения о файле или ErrNotFound
+func (s *S3Storage) Stat(key string) (Object, error) {
+	req, err := s.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return Object{}, err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat object: %v", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return responseObject(key, resp), nil
+	case http.StatusNotFound:
+		return Object{}, ErrNotFound
+	default:
+		return Object{}, fmt.Errorf("failed to stat object: %v", s3ResponseError(resp))
+	}
+}
+
+// Delete удаляет файл. Удаление несуществующего файла не считается ошибкой.
+func (s *S3Storage) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete object: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete object: %v", s3ResponseError(resp))
+	}
+	return nil
+}
+
+// PresignGet возвращает ссылку для скачивания файла напрямую из хранилища. Срок действия - не больше 7 дней.
+func (s *S3Storage) PresignGet(key string, ttl time.Duration) (string, error) {
+	if ttl < time.Second || ttl > s3MaxPresignTTL {
+		return "", fmt.Errorf("presigned url ttl must be between 1s and %v", s3MaxPresignTTL)
+	}
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	return s.presign(http.MethodGet, u, ttl, time.Now()).String(), nil
+}
+
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.cfg.PathStyle {
+		objectPath = "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = s3EscapePath(u.Path)
+	return &u, nil
+}
+
+func (s *S3Storage) newRequest(method, key string, body []byte) (*http.Request, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 request: %v", err)
+	}
+	return req, nil
+}
+
+func (s *S3Storage) do(req *http.Request, body []byte) (*http.Response, error) {
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+	return s.client.Do(req)
+}
+
+// sign добавляет к запросу заголовок Authorization. Подписываются все заголовки, заданные в запросе, и Host.
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(s3DateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := s.scope(now)
+	signature := s.signature(now, amzDate, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// presign возвращает адрес с подписью в параметрах запроса. Подписывается только заголовок Host.
+func (s *S3Storage) presign(method string, u *url.URL, ttl time.Duration, now time.Time) *url.URL {
+	amzDate := now.UTC().Format(s3DateFormat)
+	scope := s.scope(now)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalQuery := s3CanonicalQuery(query)
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	signed := *u
+	signed.RawQuery = canonicalQuery + "&X-Amz-Signature=" + s.signature(now, amzDate, scope, canonicalRequest)
+	return &signed
+}
+
+func (s *S3Storage) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := s3Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery кодирует параметры запроса так, как этого требует подпись: по порядку ключей,
+// с экранированием всех символов, кроме A-Z, a-z, 0-9, "-", "_", "." и "~"
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3EscapePath экранирует путь объекта, сохраняя разделители "/"
+func s3EscapePath(p string) string {
+	return s3Escape(p, false)
+}
+
+func s3Escape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !escapeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func responseObject(key string, resp *http.Response) Object {
+	obj := Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if obj.ContentType == "" {
+		obj.ContentType = contentTypeByKey(key)
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.LastModified = modified
+	}
+	return obj
+}
+
+// s3ResponseError описывает неуспешный ответ хранилища по коду ошибки из тела ответа
+func s3ResponseError(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("s3 responded with %s: %s: %s", resp.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3 responded with %s", resp.Status)
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature возвращается для ссылки с неверной подписью
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrURLExpired возвращается для ссылки, срок действия которой истек
+	ErrURLExpired = errors.New("signed url has expired")
+)
+
+// URLSigner подписывает ссылки на файлы, которые раздает сам сервис. Подпись - HMAC-SHA256
+// от ключа и времени истечения, поэтому ссылку нельзя продлить или переделать на другой файл.
+type URLSigner struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewURLSigner создает новый экземпляр URLSigner. baseURL - адрес обработчика, ключ файла
+// добавляется к нему через "/".
+func NewURLSigner(secret []byte, baseURL string) *URLSigner {
+	return &URLSigner{secret: secret, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Sign возвращает ссылку на файл, действующую до expiresAt
+func (s *URLSigner) Sign(key string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{"expires": {expires}, "signature": {s.signature(key, expires)}}
+	return s.baseURL + "/" + key + "?" + query.Encode()
+}
+
+// Verify проверяет подпись и срок действия ссылки
+func (s *URLSigner) Verify(key, expires, signature string, now time.Time) error {
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return ErrInvalidSignature
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expiresAt {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
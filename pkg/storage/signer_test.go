@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseSigned разбирает ссылку URLSigner на ключ, expires и signature
+func parseSigned(t *testing.T, signed, baseURL string) (string, string, string) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", signed, err)
+	}
+	if !strings.HasPrefix(u.Path, baseURL+"/") {
+		t.Fatalf("path %q does not start with %q", u.Path, baseURL+"/")
+	}
+	return strings.TrimPrefix(u.Path, baseURL+"/"), u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestURLSignerSignVerify(t *testing.T) {
+	signer := NewURLSigner([]byte("secret"), "/media/")
+	now := time.Unix(1700000000, 0)
+
+	key, expires, signature := parseSigned(t, signer.Sign("avatars/ab/cd.jpg", now.Add(time.Hour)), "/media")
+	if key != "avatars/ab/cd.jpg" {
+		t.Fatalf("key = %q", key)
+	}
+	if err := signer.Verify(key, expires, signature, now); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := signer.Verify(key, expires, signature, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Verify at expiry: %v", err)
+	}
+	if err := signer.Verify(key, expires, signature, now.Add(time.Hour+time.Second)); !errors.Is(err, ErrURLExpired) {
+		t.Fatalf("Verify after expiry: err = %v, want ErrURLExpired", err)
+	}
+}
+
+func TestURLSignerRejectsTampering(t *testing.T) {
+	signer := NewURLSigner([]byte("secret"), "/media")
+	now := time.Unix(1700000000, 0)
+	key, expires, signature := parseSigned(t, signer.Sign("avatars/ab/cd.jpg", now.Add(time.Hour)), "/media")
+
+	tests := map[string]struct {
+		signer                  *URLSigner
+		key, expires, signature string
+	}{
+		"other key":      {signer, "avatars/ab/ef.jpg", expires, signature},
+		"longer expiry":  {signer, key, expires + "0", signature},
+		"bad signature":  {signer, key, expires, signature[:len(signature)-1] + "A"},
+		"empty":          {signer, key, expires, ""},
+		"other secret":   {NewURLSigner([]byte("other"), "/media"), key, expires, signature},
+		"invalid expiry": {signer, key, "soon", signature},
+	}
+	for name, tt := range tests {
+		if err := tt.signer.Verify(tt.key, tt.expires, tt.signature, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound возвращается, если объекта с таким ключом нет
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey возвращается для ключа, который нельзя безопасно использовать как путь
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Object - сведения о сохраненном файле
+type Object struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Storage хранит файлы пользователей. Ключ - путь вида avatars/ab/cd.jpg, разделитель - "/".
+// PresignGet возвращает ссылку, по которой файл можно скачать без авторизации до истечения ttl.
+type Storage interface {
+	Put(key string, r io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, Object, error)
+	Stat(key string) (Object, error)
+	Delete(key string) error
+	PresignGet(key string, ttl time.Duration) (string, error)
+}
+
+// ContentKey возвращает ключ, определяемый содержимым файла: одинаковые файлы получают один ключ
+// и хранятся один раз. Первые символы хеша выносятся в отдельный каталог, чтобы в одном каталоге
+// не оказалось слишком много файлов.
+func ContentKey(prefix string, data []byte, extension string) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	return prefix + "/" + hash[:2] + "/" + hash[2:] + "." + extension
+}
+
+// ValidateKey проверяет, что ключ относительный и не выходит за пределы хранилища
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// contentTypeByKey определяет тип содержимого по расширению ключа
+func contentTypeByKey(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
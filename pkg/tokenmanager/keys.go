@@ -0,0 +1,447 @@
+package tokenmanager
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Поддерживаемые алгоритмы подписи токенов
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey - ключ подписи токенов, идентифицируемый по kid.
+// ActivatesAt - время, с которого ключ подписывает токены в наборе с ротацией.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	CreatedAt   time.Time
+	ActivatesAt time.Time
+
+	private interface{}
+	public  interface{}
+}
+
+// NewHMACSigningKey создает симметричный ключ HS256 из секрета
+func NewHMACSigningKey(id, secret string) (*SigningKey, error) {
+	if secret == "" {
+		return nil, errors.New("empty signing key")
+	}
+
+	return &SigningKey{
+		ID:        id,
+		Algorithm: AlgorithmHS256,
+		CreatedAt: time.Now(),
+		private:   []byte(secret),
+		public:    []byte(secret),
+	}, nil
+}
+
+// GenerateSigningKey генерирует новый асимметричный ключ для указанного алгоритма
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: id, Algorithm: algorithm, CreatedAt: time.Now()}
+
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		key.private, key.public = private, &private.PublicKey
+	case AlgorithmEdDSA:
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key.private, key.public = private, public
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+
+	return key, nil
+}
+
+// ParseSigningKeyPEM загружает закрытый ключ RSA или Ed25519 в формате PEM (PKCS#1 или PKCS#8)
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("signing key must be PEM encoded")
+	}
+
+	var parsed interface{}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %v", err)
+		}
+	}
+
+	key := &SigningKey{ID: id, CreatedAt: time.Now()}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.private, key.public = AlgorithmRS256, private, &private.PublicKey
+	case ed25519.PrivateKey:
+		key.Algorithm, key.private, key.public = AlgorithmEdDSA, private, private.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// MarshalSigningKeyPEM сохраняет закрытый ключ RSA или Ed25519 в PEM (PKCS#8)
+func MarshalSigningKeyPEM(key *SigningKey) ([]byte, error) {
+	if key.Algorithm == AlgorithmHS256 {
+		return nil, errors.New("HS256 keys can not be stored")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// KeyRing хранит ключи подписи в порядке их вступления в действие. Токены подписывает последний
+// вступивший в действие ключ. Ключ, которому срок еще не настал, уже принимается для проверки и публикуется
+// в JWKS, а ключ, выведенный из оборота, проверяет токены, пока не истекут все подписанные им токены.
+type KeyRing struct {
+	mu         sync.RWMutex
+	schedule   []*SigningKey // по возрастанию ActivatesAt
+	verifyOnly map[string]*SigningKey
+	verifyFor  time.Duration
+
+	store    KeyStore
+	rotation KeyRotation
+}
+
+// NewKeyRing создает набор из одного ключа без ротации. verifyFor - сколько выведенный из оборота ключ
+// еще принимается для проверки, обычно равно времени жизни access-токена.
+func NewKeyRing(current *SigningKey, verifyFor time.Duration) *KeyRing {
+	return &KeyRing{
+		schedule:   []*SigningKey{current},
+		verifyOnly: make(map[string]*SigningKey),
+		verifyFor:  verifyFor,
+	}
+}
+
+// KeyStore хранит ключи подписи в хранилище, общем для всех экземпляров сервиса
+type KeyStore interface {
+	LoadSigningKeys() ([]StoredKey, error)
+	// AddSigningKey сохраняет ключ, если в хранилище нет ключа, вступающего в действие позже after.
+	// Возвращает false, если следующий ключ уже добавил другой экземпляр.
+	AddSigningKey(key StoredKey, after time.Time) (bool, error)
+	DeleteSigningKeys(ids []string) error
+}
+
+// StoredKey - ключ подписи в хранилище. Закрытый ключ хранится в PEM (PKCS#8).
+type StoredKey struct {
+	ID          string
+	PrivateKey  []byte
+	ActivatesAt time.Time
+	CreatedAt   time.Time
+}
+
+// KeyRotation задает плановую ротацию ключей в общем хранилище
+type KeyRotation struct {
+	// Algorithm - алгоритм новых ключей
+	Algorithm string
+	// Period - сколько ключ подписывает токены, 0 - без ротации
+	Period time.Duration
+	// PublishAhead - за сколько до вступления в действие ключ появляется в JWKS. Должно быть не меньше
+	// времени кеширования JWKS другими сервисами плюс интервала синхронизации, иначе они не примут токены нового ключа.
+	PublishAhead time.Duration
+}
+
+// NewStoredKeyRing создает набор ключей, который загружается из общего хранилища и ротируется через него,
+// поэтому все экземпляры сервиса подписывают токены одним ключом и одновременно переходят на следующий.
+// seed - ключ, с которого начинается хранилище, если в нем еще нет ключей; без него ключ генерируется.
+func NewStoredKeyRing(store KeyStore, rotation KeyRotation, verifyFor time.Duration, seed *SigningKey) (*KeyRing, error) {
+	if rotation.Period > 0 && rotation.Period <= rotation.PublishAhead {
+		return nil, fmt.Errorf("key rotation period must be longer than %v", rotation.PublishAhead)
+	}
+
+	r := &KeyRing{
+		verifyOnly: make(map[string]*SigningKey),
+		verifyFor:  verifyFor,
+		store:      store,
+		rotation:   rotation,
+	}
+
+	if seed != nil {
+		stored, err := store.LoadSigningKeys()
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) == 0 {
+			seed.ActivatesAt = time.Now()
+			if err := r.addKey(seed, time.Time{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := r.Sync(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AddVerificationKey добавляет ключ, которым токены только проверяются
+func (r *KeyRing) AddVerificationKey(key *SigningKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.verifyOnly[key.ID] = key
+}
+
+// Sync загружает ключи из хранилища и при необходимости добавляет следующий ключ.
+// Следующий ключ вступает в действие не раньше чем через PublishAhead после добавления.
+// Ключи, которые больше не проверяют токены, удаляются из хранилища.
+func (r *KeyRing) Sync() error {
+	if r.store == nil {
+		return errors.New("key ring has no key store")
+	}
+
+	keys, err := r.loadKeys()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var latest *SigningKey
+	if len(keys) > 0 {
+		latest = keys[len(keys)-1]
+	}
+
+	if latest == nil || (r.rotation.Period > 0 && !latest.ActivatesAt.Add(r.rotation.Period).After(now.Add(r.rotation.PublishAhead))) {
+		next, err := GenerateSigningKey(r.rotation.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		// Первый ключ подписывает сразу: проверять пока нечего
+		next.ActivatesAt = now
+		after := time.Time{}
+		if latest != nil {
+			after = latest.ActivatesAt
+			next.ActivatesAt = latest.ActivatesAt.Add(r.rotation.Period)
+			if earliest := now.Add(r.rotation.PublishAhead); next.ActivatesAt.Before(earliest) {
+				next.ActivatesAt = earliest
+			}
+		}
+
+		if err := r.addKey(next, after); err != nil {
+			return err
+		}
+
+		// Следующий ключ мог добавить другой экземпляр
+		if keys, err = r.loadKeys(); err != nil {
+			return err
+		}
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no signing keys in key store")
+	}
+
+	var expired []string
+	for i, key := range keys[:len(keys)-1] {
+		if r.expired(keys[i+1], now) {
+			expired = append(expired, key.ID)
+		}
+	}
+	if len(expired) > 0 {
+		if err := r.store.DeleteSigningKeys(expired); err != nil {
+			return err
+		}
+		keys = keys[len(expired):]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schedule = keys
+	return nil
+}
+
+// StartRotation периодически синхронизирует ключи с хранилищем. Интервал должен быть меньше PublishAhead,
+// чтобы каждый экземпляр загрузил следующий ключ до его вступления в действие. Возвращает функцию остановки.
+func (r *KeyRing) StartRotation(interval time.Duration, onError func(error)) func() {
+	stop := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Sync(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(stop) }
+}
+
+// addKey сохраняет ключ в хранилище
+func (r *KeyRing) addKey(key *SigningKey, after time.Time) error {
+	data, err := MarshalSigningKeyPEM(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.store.AddSigningKey(StoredKey{
+		ID:          key.ID,
+		PrivateKey:  data,
+		ActivatesAt: key.ActivatesAt,
+		CreatedAt:   key.CreatedAt,
+	}, after)
+	return err
+}
+
+// loadKeys загружает ключи из хранилища в порядке вступления в действие
+func (r *KeyRing) loadKeys() ([]*SigningKey, error) {
+	stored, err := r.store.LoadSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(stored))
+	for _, s := range stored {
+		key, err := ParseSigningKeyPEM(s.ID, s.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %v", s.ID, err)
+		}
+		key.ActivatesAt, key.CreatedAt = s.ActivatesAt, s.CreatedAt
+		keys = append(keys, key)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].ActivatesAt.Before(keys[j].ActivatesAt) })
+	return keys, nil
+}
+
+// expired проверяет, что ключ, выведенный из оборота ключом next, больше не проверяет токены
+func (r *KeyRing) expired(next *SigningKey, now time.Time) bool {
+	return now.After(next.ActivatesAt.Add(r.verifyFor))
+}
+
+func (r *KeyRing) signingKey() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Ключ выбирается по времени, а не в момент загрузки: все экземпляры переходят на следующий ключ одновременно
+	now := time.Now()
+	current := r.schedule[0]
+	for _, key := range r.schedule[1:] {
+		if key.ActivatesAt.After(now) {
+			break
+		}
+		current = key
+	}
+	return current
+}
+
+// activeKeys возвращает ключи, которые сейчас принимаются для проверки
+func (r *KeyRing) activeKeys(now time.Time) []*SigningKey {
+	keys := make([]*SigningKey, 0, len(r.schedule)+len(r.verifyOnly))
+	for i, key := range r.schedule {
+		if i+1 < len(r.schedule) && r.expired(r.schedule[i+1], now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range r.verifyOnly {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (r *KeyRing) verificationKey(id string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.activeKeys(time.Now()) {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// JWK - открытый ключ в формате RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKS - набор открытых ключей для /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые части всех асимметричных ключей, которые принимаются для проверки,
+// включая следующий ключ, который еще не подписывает токены
+func (r *KeyRing) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range r.activeKeys(time.Now()) {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			// Симметричные ключи не публикуются
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+
+	return set
+}
@@ -0,0 +1,302 @@
+package tokenmanager
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// memoryKeyStore - общее хранилище ключей нескольких наборов, как база данных у нескольких экземпляров
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []StoredKey
+}
+
+func (s *memoryKeyStore) LoadSigningKeys() ([]StoredKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]StoredKey(nil), s.keys...), nil
+}
+
+func (s *memoryKeyStore) AddSigningKey(key StoredKey, after time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if k.ActivatesAt.After(after) {
+			return false, nil
+		}
+	}
+	s.keys = append(s.keys, key)
+	return true, nil
+}
+
+func (s *memoryKeyStore) DeleteSigningKeys(ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.keys[:0]
+	for _, k := range s.keys {
+		if !containsString(ids, k.ID) {
+			kept = append(kept, k)
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+// age сдвигает ключи в прошлое, как если бы прошло время d
+func (s *memoryKeyStore) age(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.keys {
+		s.keys[i].ActivatesAt = s.keys[i].ActivatesAt.Add(-d)
+	}
+}
+
+func (s *memoryKeyStore) ids() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := []string{}
+	for _, k := range s.keys {
+		ids = append(ids, k.ID)
+	}
+	return ids
+}
+
+var testRotation = KeyRotation{Algorithm: AlgorithmEdDSA, Period: time.Hour, PublishAhead: 5 * time.Minute}
+
+func newStoredManager(t *testing.T, store KeyStore, seed *SigningKey) (*Manager, *KeyRing) {
+	t.Helper()
+	keys, err := NewStoredKeyRing(store, testRotation, time.Hour, seed)
+	if err != nil {
+		t.Fatalf("NewStoredKeyRing: %v", err)
+	}
+	return NewManagerWithKeyRing(keys), keys
+}
+
+func syncKeys(t *testing.T, keys ...*KeyRing) {
+	t.Helper()
+	for _, k := range keys {
+		if err := k.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+	}
+}
+
+// signedKeyID выпускает токен и возвращает kid, которым он подписан
+func signedKeyID(t *testing.T, m *Manager) (string, string) {
+	t.Helper()
+	token, err := m.NewJWT(Subject{UserID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &jwt.StandardClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return token, kid
+}
+
+func jwksIDs(m *Manager) []string {
+	ids := []string{}
+	for _, key := range m.JWKS().Keys {
+		ids = append(ids, key.KeyID)
+	}
+	return ids
+}
+
+func sameIDs(got, want []string) bool {
+	got, want = append([]string(nil), got...), append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoredKeyRingRotation(t *testing.T) {
+	store := &memoryKeyStore{}
+	first, firstKeys := newStoredManager(t, store, nil)
+	second, secondKeys := newStoredManager(t, store, nil)
+
+	// Второй экземпляр загружает ключ первого, а не создает свой
+	ids := store.ids()
+	if len(ids) != 1 {
+		t.Fatalf("store keys = %v, want one key", ids)
+	}
+	k1 := ids[0]
+	oldToken, kid := signedKeyID(t, first)
+	if kid != k1 {
+		t.Fatalf("kid = %q, want %q", kid, k1)
+	}
+	if _, err := second.ParseClaims(oldToken); err != nil {
+		t.Fatalf("second instance rejects the token of the first: %v", err)
+	}
+
+	// За четыре минуты до конца периода следующий ключ публикуется, но еще не подписывает
+	store.age(56 * time.Minute)
+	syncKeys(t, firstKeys, secondKeys)
+	ids = store.ids()
+	if len(ids) != 2 {
+		t.Fatalf("store keys = %v, want the next key added once", ids)
+	}
+	k2 := ids[1]
+	for _, m := range []*Manager{first, second} {
+		if _, kid := signedKeyID(t, m); kid != k1 {
+			t.Fatalf("kid = %q before the next key activates, want %q", kid, k1)
+		}
+		if got := jwksIDs(m); !sameIDs(got, []string{k1, k2}) {
+			t.Fatalf("JWKS = %v, want the current and the next key", got)
+		}
+	}
+	if next := store.keys[1].ActivatesAt; time.Until(next) < testRotation.PublishAhead-time.Second {
+		t.Fatalf("next key activates in %v, want at least %v", time.Until(next), testRotation.PublishAhead)
+	}
+
+	// Следующий ключ вступает в действие на обоих экземплярах, прежний продолжает проверять
+	store.age(6 * time.Minute)
+	syncKeys(t, firstKeys, secondKeys)
+	newToken, kid := signedKeyID(t, first)
+	if kid != k2 {
+		t.Fatalf("kid = %q after activation, want %q", kid, k2)
+	}
+	if _, kid := signedKeyID(t, second); kid != k2 {
+		t.Fatalf("second instance kid = %q after activation, want %q", kid, k2)
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := second.ParseClaims(token); err != nil {
+			t.Fatalf("ParseClaims: %v", err)
+		}
+	}
+
+	// Через verifyFor после вывода из оборота ключ удаляется и из JWKS, и из хранилища
+	store.age(61 * time.Minute)
+	syncKeys(t, firstKeys, secondKeys)
+	if got := store.ids(); len(got) != 2 || got[0] != k2 {
+		t.Fatalf("store keys = %v, want %s and the next key", got, k2)
+	}
+	if got := jwksIDs(second); len(got) != 2 || containsString(got, k1) || !containsString(got, k2) {
+		t.Fatalf("JWKS = %v, want %s and the next key without %s", got, k2, k1)
+	}
+	if _, err := second.ParseClaims(oldToken); err == nil {
+		t.Fatal("token of the expired key is accepted")
+	}
+	if _, err := second.ParseClaims(newToken); err != nil {
+		t.Fatalf("token of the current key: %v", err)
+	}
+}
+
+func TestStoredKeyRingSeed(t *testing.T) {
+	seed, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed.ID = "primary"
+
+	store := &memoryKeyStore{}
+	m, _ := newStoredManager(t, store, seed)
+	if _, kid := signedKeyID(t, m); kid != "primary" {
+		t.Fatalf("kid = %q, want the seed key", kid)
+	}
+
+	// Ключ из файла не заменяет ключи, которые уже есть в хранилище
+	other, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newStoredManager(t, store, other)
+	if got := store.ids(); !sameIDs(got, []string{"primary"}) {
+		t.Fatalf("store keys = %v, want only the seed key", got)
+	}
+}
+
+func TestNewStoredKeyRingRejectsShortPeriod(t *testing.T) {
+	rotation := testRotation
+	rotation.Period = rotation.PublishAhead
+	if _, err := NewStoredKeyRing(&memoryKeyStore{}, rotation, time.Hour, nil); err == nil {
+		t.Fatal("NewStoredKeyRing accepted a period not longer than PublishAhead")
+	}
+}
+
+func TestJWKSContents(t *testing.T) {
+	rsaKey, err := GenerateSigningKey(AlgorithmRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, err := GenerateSigningKey(AlgorithmEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacKey, err := NewHMACSigningKey("secret", "0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := NewKeyRing(rsaKey, time.Hour)
+	keys.AddVerificationKey(edKey)
+	keys.AddVerificationKey(hmacKey)
+
+	byID := map[string]JWK{}
+	for _, jwk := range keys.JWKS().Keys {
+		byID[jwk.KeyID] = jwk
+	}
+	if len(byID) != 2 {
+		t.Fatalf("JWKS = %+v, want the RSA and Ed25519 keys without the HMAC secret", byID)
+	}
+
+	public := rsaKey.public.(*rsa.PublicKey)
+	rsaJWK := byID[rsaKey.ID]
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != AlgorithmRS256 || rsaJWK.Use != "sig" ||
+		rsaJWK.N != base64.RawURLEncoding.EncodeToString(public.N.Bytes()) ||
+		rsaJWK.E != base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()) {
+		t.Fatalf("RSA JWK = %+v", rsaJWK)
+	}
+
+	edJWK := byID[edKey.ID]
+	x := base64.RawURLEncoding.EncodeToString(edKey.public.(ed25519.PublicKey))
+	if edJWK.KeyType != "OKP" || edJWK.Curve != "Ed25519" || edJWK.Algorithm != AlgorithmEdDSA || edJWK.X != x {
+		t.Fatalf("Ed25519 JWK = %+v", edJWK)
+	}
+}
+
+func TestMarshalSigningKeyPEMRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRS256, AlgorithmEdDSA} {
+		key, err := GenerateSigningKey(algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := MarshalSigningKeyPEM(key)
+		if err != nil {
+			t.Fatalf("%s: MarshalSigningKeyPEM: %v", algorithm, err)
+		}
+		parsed, err := ParseSigningKeyPEM(key.ID, data)
+		if err != nil {
+			t.Fatalf("%s: ParseSigningKeyPEM: %v", algorithm, err)
+		}
+		if parsed.Algorithm != algorithm {
+			t.Fatalf("%s: parsed algorithm = %s", algorithm, parsed.Algorithm)
+		}
+
+		signed := NewManagerWithKeyRing(NewKeyRing(key, time.Hour))
+		token, err := signed.NewJWT(Subject{UserID: 1}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewManagerWithKeyRing(NewKeyRing(parsed, time.Hour)).ParseClaims(token); err != nil {
+			t.Fatalf("%s: token is not verified by the parsed key: %v", algorithm, err)
+		}
+	}
+}
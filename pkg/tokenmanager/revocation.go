@@ -0,0 +1,128 @@
+package tokenmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore хранит отозванные access-токены.
+// Токен считается отозванным, если его jti внесен в список отзыва или
+// он выпущен не позже отметки, установленной для пользователя.
+type RevocationStore interface {
+	RevokeToken(tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(tokenID string) (bool, error)
+	RevokeUserTokens(userID uint, before time.Time) error
+	UserTokensRevokedBefore(userID uint) (time.Time, error)
+}
+
+// IsRevoked проверяет access-токен по хранилищу отзыва
+func IsRevoked(store RevocationStore, claims Claims) (bool, error) {
+	revoked, err := store.IsTokenRevoked(claims.TokenID)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	before, err := store.UserTokensRevokedBefore(claims.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	// Время выпуска округлено вниз до миллисекунды, поэтому токен, выпущенный в ту же миллисекунду,
+	// что и отметка, тоже отзывается: он мог быть выпущен и украден перед самым отзывом
+	return !claims.IssuedAt.After(before), nil
+}
+
+// MemoryRevocationStore - хранилище отзыва в памяти процесса для запуска в одном экземпляре.
+// Записи удаляются фоновой очисткой после истечения срока действия отозванных токенов.
+type MemoryRevocationStore struct {
+	mu         sync.RWMutex
+	tokens     map[string]time.Time
+	watermarks map[uint]time.Time
+	tokenTTL   time.Duration
+	stop       chan struct{}
+}
+
+// NewMemoryRevocationStore создает хранилище в памяти.
+// tokenTTL - максимальное время жизни access-токена, после которого отметка пользователя больше не нужна.
+func NewMemoryRevocationStore(tokenTTL, cleanupInterval time.Duration) *MemoryRevocationStore {
+	s := &MemoryRevocationStore{
+		tokens:     make(map[string]time.Time),
+		watermarks: make(map[uint]time.Time),
+		tokenTTL:   tokenTTL,
+		stop:       make(chan struct{}),
+	}
+
+	go s.cleanup(cleanupInterval)
+
+	return s
+}
+
+func (s *MemoryRevocationStore) RevokeToken(tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsTokenRevoked(tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.tokens[tokenID]
+	return ok, nil
+}
+
+func (s *MemoryRevocationStore) RevokeUserTokens(userID uint, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before.After(s.watermarks[userID]) {
+		s.watermarks[userID] = before
+	}
+	return nil
+}
+
+func (s *MemoryRevocationStore) UserTokensRevokedBefore(userID uint) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.watermarks[userID], nil
+}
+
+// Stop останавливает фоновую очистку
+func (s *MemoryRevocationStore) Stop() {
+	close(s.stop)
+}
+
+func (s *MemoryRevocationStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.evictExpired(now)
+		}
+	}
+}
+
+func (s *MemoryRevocationStore) evictExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for tokenID, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, tokenID)
+		}
+	}
+
+	// Все токены, выпущенные до отметки, к этому моменту уже истекли
+	for userID, before := range s.watermarks {
+		if now.After(before.Add(s.tokenTTL)) {
+			delete(s.watermarks, userID)
+		}
+	}
+}
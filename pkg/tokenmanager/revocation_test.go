@@ -0,0 +1,82 @@
+package tokenmanager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsRevokedWatermarkMillisecond(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour, time.Hour)
+	defer store.Stop()
+
+	watermark := time.Date(2024, 5, 1, 12, 0, 0, 700*int(time.Millisecond)+300, time.UTC)
+	if err := store.RevokeUserTokens(1, watermark); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		{"previous millisecond", watermark.Truncate(time.Millisecond).Add(-time.Millisecond), true},
+		{"same millisecond", watermark.Truncate(time.Millisecond), true},
+		{"next millisecond", watermark.Truncate(time.Millisecond).Add(time.Millisecond), false},
+		{"same second, without iat_ms", watermark.Truncate(time.Second), true},
+		{"next second, without iat_ms", watermark.Truncate(time.Second).Add(time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := IsRevoked(store, Claims{UserID: 1, TokenID: "jti", IssuedAt: tt.issuedAt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.revoked {
+				t.Errorf("IsRevoked() = %v, want %v", revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestTokenIssuedAfterWatermarkIsValid(t *testing.T) {
+	store := NewMemoryRevocationStore(time.Hour, time.Hour)
+	defer store.Stop()
+	manager, err := NewManager("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := manager.NewJWT(Subject{UserID: 1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := store.RevokeUserTokens(1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	// Токен, выпущенный в ту же секунду сразу после отзыва, действителен без ожидания
+	start := time.Now()
+	fresh, err := manager.NewJWT(Subject{UserID: 1}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("NewJWT waited for the watermark")
+	}
+
+	for token, want := range map[string]bool{revoked: true, fresh: false} {
+		claims, err := manager.ParseClaims(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := IsRevoked(store, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("IsRevoked() of a token issued at %v = %v, want %v", claims.IssuedAt, got, want)
+		}
+	}
+}
@@ -1,77 +1,250 @@
-package tokenmanager
-
-import (
-	"errors"
-	"fmt"
-	"math/rand"
-	"time"
-
-	"github.com/Saveliy12/prod2/pkg/logger"
-	"github.com/golang-jwt/jwt"
-)
-
-type Tokens struct {
-	AccessToken  string
-	RefreshToken string
-}
-
-type TokenManagerInterface interface {
-	NewJWT(userId string, ttl time.Duration) (string, error)
-	ParseJWT(accessToken string) (uint, error)
-	NewRefreshToken() (string, error)
-}
-
-type Manager struct {
-	signingKey string
-	log        logger.LoggerInterface
-}
-
-func NewManager(signingKey string) (*Manager, error) {
-	if signingKey == "" {
-		return nil, errors.New("empty signing key")
-	}
-
-	return &Manager{signingKey: signingKey, log: logger.GetLogger()}, nil
-}
-
-func (m *Manager) NewJWT(userID string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(ttl).Unix(),
-		Subject:   userID,
-	})
-
-	return token.SignedString([]byte(m.signingKey))
-}
-
-func (m *Manager) ParseJWT(accessToken string) (uint, error) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return []byte(m.signingKey), nil
-	})
-	if err != nil {
-		return 0, err
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return 0, fmt.Errorf("error get user claims from token")
-	}
-
-	return claims["sub"].(uint), nil
-}
-
-func (m *Manager) NewRefreshToken() (string, error) {
-	b := make([]byte, 32)
-
-	s := rand.NewSource(time.Now().Unix())
-	r := rand.New(s)
-
-	if _, err := r.Read(b); err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%x", b), nil
-}
+package tokenmanager
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Saveliy12/prod2/pkg/logger"
+	"github.com/golang-jwt/jwt"
+)
+
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	DeviceID     string
+}
+
+// Subject описывает владельца access-токена и его права на момент выпуска.
+// Для токенов, выданных стороннему приложению по OAuth, заполняются ClientID и Scopes.
+// MFA - сессия, для которой выпускается токен, подтверждена вторым фактором.
+type Subject struct {
+	UserID      uint
+	Role        string
+	Permissions []string
+	ClientID    string
+	Scopes      []string
+	MFA         bool
+}
+
+// Claims содержит сведения, извлеченные из access-токена
+type Claims struct {
+	UserID      uint
+	Role        string
+	Permissions []string
+	ClientID    string
+	Scopes      []string
+	MFA         bool
+	TokenID     string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// HasPermission проверяет, что токен дает указанное право
+func (c Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope проверяет, что токен стороннего приложения дает указанную область действия
+func (c Claims) HasScope(scope string) bool {
+	return containsString(c.Scopes, scope)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// amrMFA - значение claim amr (RFC 8176) для входа, подтвержденного вторым фактором
+const amrMFA = "mfa"
+
+// accessClaims - содержимое access-токена. client_id и scope заполняются как в RFC 9068.
+// iat_ms - время выпуска с точностью до миллисекунды для сравнения с отметкой отзыва: iat хранит только секунды.
+type accessClaims struct {
+	jwt.StandardClaims
+	IssuedAtMs  int64    `json:"iat_ms,omitempty"`
+	Role        string   `json:"role,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	ClientID    string   `json:"client_id,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	AMR         []string `json:"amr,omitempty"`
+}
+
+type TokenManagerInterface interface {
+	NewJWT(subject Subject, ttl time.Duration) (string, error)
+	ParseJWT(accessToken string) (uint, error)
+	ParseClaims(accessToken string) (Claims, error)
+	NewRefreshToken() (string, error)
+	JWKS() JWKS
+}
+
+type Manager struct {
+	keys *KeyRing
+	log  logger.LoggerInterface
+}
+
+// defaultKeyID - kid симметричного ключа, которым подписываются токены в NewManager
+const defaultKeyID = "default"
+
+// NewManager создает менеджер, подписывающий токены одним симметричным ключом HS256
+func NewManager(signingKey string) (*Manager, error) {
+	key, err := NewHMACSigningKey(defaultKeyID, signingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewManagerWithKeyRing(NewKeyRing(key, 0)), nil
+}
+
+// NewManagerWithKeyRing создает менеджер, подписывающий токены текущим ключом из набора
+func NewManagerWithKeyRing(keys *KeyRing) *Manager {
+	return &Manager{keys: keys, log: logger.GetLogger()}
+}
+
+// NewJWT выпускает access-токен с ролью и правами пользователя. Каждый токен получает уникальный jti,
+// по которому его можно отозвать до истечения срока действия.
+func (m *Manager) NewJWT(subject Subject, ttl time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	key := m.keys.signingKey()
+
+	now := time.Now()
+	var amr []string
+	if subject.MFA {
+		amr = []string{amrMFA}
+	}
+
+	token := jwt.NewWithClaims(key.method(), accessClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Subject:   strconv.FormatUint(uint64(subject.UserID), 10),
+		},
+		IssuedAtMs:  now.UnixMilli(),
+		Role:        subject.Role,
+		Permissions: subject.Permissions,
+		ClientID:    subject.ClientID,
+		Scope:       strings.Join(subject.Scopes, " "),
+		AMR:         amr,
+	})
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.private)
+}
+
+func (m *Manager) ParseJWT(accessToken string) (uint, error) {
+	claims, err := m.ParseClaims(accessToken)
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.UserID, nil
+}
+
+// ParseClaims проверяет подпись и срок действия access-токена и возвращает его claims
+func (m *Manager) ParseClaims(accessToken string) (Claims, error) {
+	var parsed accessClaims
+	_, err := jwt.ParseWithClaims(accessToken, &parsed, m.verificationKey)
+	if err != nil {
+		return Claims{}, err
+	}
+	standard := parsed.StandardClaims
+
+	userID, err := strconv.ParseUint(standard.Subject, 10, 64)
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid subject in token: %v", err)
+	}
+
+	if standard.Id == "" {
+		return Claims{}, fmt.Errorf("token has no jti claim")
+	}
+
+	// У токенов, выпущенных до появления iat_ms, время выпуска известно только до секунды
+	issuedAt := time.Unix(standard.IssuedAt, 0)
+	if parsed.IssuedAtMs != 0 && parsed.IssuedAtMs/1000 == standard.IssuedAt {
+		issuedAt = time.UnixMilli(parsed.IssuedAtMs)
+	}
+
+	return Claims{
+		UserID:      uint(userID),
+		Role:        parsed.Role,
+		Permissions: parsed.Permissions,
+		ClientID:    parsed.ClientID,
+		Scopes:      strings.Fields(parsed.Scope),
+		MFA:         containsString(parsed.AMR, amrMFA),
+		TokenID:     standard.Id,
+		IssuedAt:    issuedAt,
+		ExpiresAt:   time.Unix(standard.ExpiresAt, 0),
+	}, nil
+}
+
+// JWKS возвращает открытые ключи для проверки токенов другими сервисами
+func (m *Manager) JWKS() JWKS {
+	return m.keys.JWKS()
+}
+
+// verificationKey выбирает ключ проверки по kid. Алгоритм токена должен совпадать
+// с алгоритмом ключа, иначе открытый ключ RSA можно было бы подсунуть как секрет HMAC.
+func (m *Manager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Токены, выпущенные до появления kid
+		kid = defaultKeyID
+	}
+
+	key, ok := m.keys.verificationKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %v", kid)
+	}
+
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+// NewRefreshToken генерирует непрозрачный refresh-токен из криптографически стойкого источника
+func (m *Manager) NewRefreshToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HashRefreshToken возвращает хеш refresh-токена, под которым он хранится в базе
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenID генерирует идентификатор токена для claim jti
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,27 @@
+package tokenmanager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewJWTCarriesMFA(t *testing.T) {
+	manager, err := NewManager("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mfa := range []bool{false, true} {
+		token, err := manager.NewJWT(Subject{UserID: 7, Role: "admin", MFA: mfa}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := manager.ParseClaims(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.UserID != 7 || claims.MFA != mfa {
+			t.Errorf("ParseClaims() = user %d, mfa %v; want user 7, mfa %v", claims.UserID, claims.MFA, mfa)
+		}
+	}
+}
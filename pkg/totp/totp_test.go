@@ -0,0 +1,88 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет "12345678901234567890" из RFC 6238 в base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Векторы RFC 6238, приложение B (SHA1). В RFC коды из 8 цифр, здесь - их последние 6 цифр.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		code, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+
+	// Секрет принимается в любом регистре
+	if code, _ := Code(strings.ToLower(rfcSecret), 1); code != "287082" {
+		t.Errorf("Code with a lower case secret = %s", code)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code accepted an invalid secret")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	current := Step(at)
+
+	for _, skew := range []int64{-1, 0, 1} {
+		code, err := Code(rfcSecret, current+skew)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code[:3]+" "+code[3:], at, 1)
+		if !ok || step != current+skew {
+			t.Errorf("Validate of step %+d = %d, %v; want %d, true", skew, step, ok, current+skew)
+		}
+	}
+
+	for _, skew := range []int64{-2, 2} {
+		code, err := Code(rfcSecret, current+skew)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := Validate(rfcSecret, code, at, 1); ok {
+			t.Errorf("Validate accepted a code %+d steps away", skew)
+		}
+	}
+
+	for _, code := range []string{"", "05047", "0504711", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, at, 1); ok {
+			t.Errorf("Validate(%q) = true", code)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("secret length = %d, want 32", len(secret))
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Fatalf("generated secret is not usable: %v", err)
+	}
+}